@@ -0,0 +1,93 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+)
+
+// confirmedServers records the base URLs of servers already known to serve this client's API version, so --strict only
+// checks /version once per server per process.  Servers are confirmed by /version, or by the API versions header on
+// any earlier response.
+var confirmedServers = map[string]bool{}
+var confirmedServersMu sync.Mutex
+
+// requireServerAPIVersion checks, before a request is sent, that the server serves this client's API version.
+// Used with --strict, so an operation is refused up front rather than failing with a confusing 404 (or worse, partway).
+func requireServerAPIVersion(urlStr string) (err error) {
+	baseURL := serverBaseURLFromRequestURL(urlStr)
+
+	if serverConfirmed(baseURL) {
+		return err
+	}
+
+	info, err := fetchServerVersion(baseURL)
+	if err != nil {
+		err = fmt.Errorf("--strict: unable to confirm that %s serves API version %s: %w", baseURL, apiVersion, err)
+		return err
+	}
+
+	if !slices.Contains(info.APIVersions, apiVersion) {
+		err = fmt.Errorf("--strict: this client uses API version %s, but %s only serves %v", apiVersion, baseURL, info.APIVersions)
+		return err
+	}
+
+	confirmServer(baseURL)
+
+	return err
+}
+
+// serverConfirmed reports whether a server is already known to serve this client's API version.
+func serverConfirmed(baseURL string) (confirmed bool) {
+	confirmedServersMu.Lock()
+	defer confirmedServersMu.Unlock()
+
+	confirmed = confirmedServers[baseURL]
+	return confirmed
+}
+
+// confirmServer records that a server serves this client's API version.
+func confirmServer(baseURL string) {
+	confirmedServersMu.Lock()
+	defer confirmedServersMu.Unlock()
+
+	confirmedServers[baseURL] = true
+}
+
+// warnOnAPIVersionMismatch warns if the server's advertised API versions don't include this client's API version.
+// Servers that don't advertise their API versions are not warned about.
+func warnOnAPIVersionMismatch(resp *http.Response) {
+	advertised := resp.Header.Get(k8sctl.APIVersionsHeader)
+	if advertised == "" {
+		return
+	}
+
+	versions := strings.Split(advertised, ",")
+	if slices.Contains(versions, apiVersion) {
+		if resp.Request != nil {
+			confirmServer(serverBaseURLFromRequestURL(resp.Request.URL.String()))
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "WARNING: this client uses API version %s, but the server only serves %s.  Upgrade k8sctl, or use --api-version to pick a version the server serves.\n", apiVersion, advertised)
+}
+
+// serverBaseURLFromRequestURL strips the API version and route from a request URL, leaving the server's base URL.
+func serverBaseURLFromRequestURL(urlStr string) (baseURL string) {
+	baseURL = urlStr
+
+	if idx := strings.Index(urlStr, "/"+apiVersion+"/"); idx >= 0 {
+		baseURL = urlStr[:idx]
+	}
+
+	return baseURL
+}
@@ -4,14 +4,36 @@ Copyright © 2025 Nik Ogura
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
 	"github.com/spf13/cobra"
 )
 
+var authCheckOutput string
+
+var authCheckServerURL string
+
+// authCheckReport is auth-check's JSON output: who the server authenticated the caller as, or why it didn't.
+type authCheckReport struct {
+	Authenticated bool     `json:"authenticated"`
+	Server        string   `json:"server,omitempty"`
+	UserEmail     string   `json:"user_email,omitempty"`
+	Groups        []string `json:"groups,omitempty"`
+	ExpiresAt     string   `json:"expires_at,omitempty"`
+
+	Error  string `json:"error,omitempty"`
+	Status int    `json:"status,omitempty"` // the server's HTTP status, if it answered
+}
+
 // authCheckCmd represents the auth-check command.
 var authCheckCmd = &cobra.Command{
 	Use:   "auth-check",
@@ -22,22 +44,44 @@ your credentials without performing any actual operations. This is useful for
 testing that your SSH keys and server configuration are working correctly.
 
 Returns success (exit code 0) if authentication works, failure (exit code 1) otherwise.
+
+By default, the server checked is the cluster's, but a new user who doesn't know any cluster names yet can check their
+SSH key and Dex setup against any server they know, with --server-url.  The token is requested for that server's
+audience, unless $K8SCTL_AUDIENCE says otherwise.
+
+With --output json, the result is JSON, for use as a gate in CI: the server authenticated against, and the email and
+groups it authenticated you as, with your token's expiry, or on failure, the error and the server's HTTP status.
+
+Example:
+  k8sctl -c cluster1 auth-check
+  k8sctl -c cluster1 auth-check -o json
+  k8sctl auth-check --server-url https://k8sctl-dev.example.com
 `,
 	Run: func(cmd *cobra.Command, args []string) {
+		if authCheckOutput != "text" && authCheckOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use text or json.", authCheckOutput)
+		}
+
+		// Build server URL based on cluster, unless a server was given
+		baseURL := getServerBaseURL(cluster)
+		if authCheckServerURL != "" {
+			baseURL = strings.TrimSuffix(authCheckServerURL, "/")
+		}
+		serverURL := fmt.Sprintf("%s/%s/auth-check", baseURL, apiVersion)
+
+		report := authCheckReport{Server: baseURL}
+
 		// Get OIDC token using kubectl-ssh-oidc pattern
 		token, err := getOIDCToken()
 		if err != nil {
-			log.Fatalf("Failed to get OIDC token: %v", err)
+			report.Error = fmt.Sprintf("failed to get OIDC token: %s", err)
+			failAuthCheck(report)
 		}
 
 		if showToken {
 			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
 		}
 
-		// Build server URL based on cluster
-		baseURL := getServerBaseURL(cluster)
-		serverURL := fmt.Sprintf("%s/%s/auth-check", baseURL, apiVersion)
-
 		if verbose {
 			fmt.Printf("Target URL: %s\n", serverURL)
 			fmt.Printf("Cluster: %s\n", cluster)
@@ -45,23 +89,117 @@ Returns success (exit code 0) if authentication works, failure (exit code 1) oth
 
 		resp, err := makeAuthenticatedRequest("POST", serverURL, "", token)
 		if err != nil {
-			log.Fatalf("failed making authenticated request: %s", err)
+			report.Error = fmt.Sprintf("failed making authenticated request: %s", err)
+			failAuthCheck(report)
 		}
 		defer resp.Body.Close()
 
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			log.Fatalf("failed reading response body: %s", err)
+			report.Error = fmt.Sprintf("failed reading response body: %s", err)
+			failAuthCheck(report)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			report.Status = resp.StatusCode
+			report.Error = responseError(body)
+			failAuthCheck(report)
 		}
 
-		if resp.StatusCode == http.StatusOK {
-			fmt.Printf("✓ Authentication successful: %s\n", body)
-		} else {
-			log.Fatalf("✗ Authentication failed with status %d: %s", resp.StatusCode, body)
+		if authCheckOutput == "text" {
+			fmt.Printf("%s Authentication successful: %s\n", k8sctl.ConsoleMarkers().OK, body)
+			return
 		}
+
+		var result k8sctl.AuthCheckResult
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			report.Error = fmt.Sprintf("failed unmarshalling auth check result: %s", err)
+			failAuthCheck(report)
+		}
+
+		report.Authenticated = true
+		report.UserEmail = result.UserEmail
+		report.Groups = result.Groups
+		fillFromToken(&report, token)
+
+		printAuthCheckReport(report)
 	},
 }
 
+// fillFromToken fills in the token's expiry, and, from servers that don't return them, the email and groups in it.
+// The token isn't verified here: the server has just done that.
+func fillFromToken(report *authCheckReport, token string) {
+	claims := jwt.MapClaims{}
+
+	_, _, err := jwt.NewParser().ParseUnverified(token, claims)
+	if err != nil {
+		return
+	}
+
+	expiry, err := claims.GetExpirationTime()
+	if err == nil && expiry != nil {
+		report.ExpiresAt = expiry.UTC().Format(time.RFC3339)
+	}
+
+	if report.UserEmail == "" {
+		report.UserEmail, _ = claims["email"].(string)
+	}
+
+	if len(report.Groups) == 0 {
+		groups, _ := claims["groups"].([]interface{})
+		for _, group := range groups {
+			if groupStr, ok := group.(string); ok {
+				report.Groups = append(report.Groups, groupStr)
+			}
+		}
+	}
+}
+
+// responseError returns the error message in a server's error response, or the whole response if it has none.
+func responseError(body []byte) (message string) {
+	var response struct {
+		Error string `json:"error"`
+	}
+
+	err := json.Unmarshal(body, &response)
+	if err == nil && response.Error != "" {
+		message = response.Error
+		return message
+	}
+
+	message = string(body)
+	return message
+}
+
+// failAuthCheck reports a failed auth check, and exits 1.
+func failAuthCheck(report authCheckReport) {
+	if authCheckOutput != "json" {
+		if report.Status != 0 {
+			log.Fatalf("%s Authentication failed with status %d: %s", k8sctl.ConsoleMarkers().Fail, report.Status, report.Error)
+		}
+		log.Fatalf("%s Authentication failed: %s", k8sctl.ConsoleMarkers().Fail, report.Error)
+	}
+
+	printAuthCheckReport(report)
+	os.Exit(1)
+}
+
+// printAuthCheckReport writes the auth check report as JSON.
+func printAuthCheckReport(report authCheckReport) {
+	reportBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed marshalling auth check result: %s", err)
+	}
+
+	err = writeResult(reportBytes)
+	if err != nil {
+		log.Fatalf("Failed writing result: %s", err)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(authCheckCmd)
+	addOutputFlag(authCheckCmd, &authCheckOutput, "text", "json")
+	authCheckCmd.Flags().StringVar(&authCheckServerURL, "server-url", "", "Check against this server, e.g. https://k8sctl-dev.example.com, rather than the cluster's (no cluster needed)")
 }
@@ -9,10 +9,12 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/nikogura/k8sctl/pkg/config"
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
 	"github.com/nikogura/kubectl-ssh-oidc/pkg/kubectl"
 )
 
@@ -25,6 +27,13 @@ const debugEnvValue = "true"
 
 // getOIDCToken gets an OIDC token from Dex using kubectl-ssh-oidc library, exactly like tdoctl.
 func getOIDCToken() (token string, err error) {
+	token, err = getClusterOIDCToken(cluster)
+	return token, err
+}
+
+// getClusterOIDCToken gets an OIDC token for the server of the given cluster, rather than the -c cluster's, for
+// commands that call several clusters' servers.
+func getClusterOIDCToken(clusterName string) (token string, err error) {
 	// Create config using kubectl-ssh-oidc's LoadConfig and override with our values
 	config := kubectl.LoadConfig()
 
@@ -61,13 +70,16 @@ func getOIDCToken() (token string, err error) {
 	var targetAudience string
 	if envAudience := os.Getenv("K8SCTL_AUDIENCE"); envAudience != "" {
 		targetAudience = envAudience
-	} else if cluster != "" {
+	} else if authCheckServerURL != "" {
+		// auth-check against an explicit server, whose URL is its audience
+		targetAudience = strings.TrimSuffix(authCheckServerURL, "/")
+	} else if clusterName != "" || clusterEnvironment != "" {
 		// Determine suffix from cluster mapping or environment override
-		suffix := getClusterSuffix(cluster)
-		targetAudience = fmt.Sprintf("https://k8sctl-%s.example.com", suffix)
+		suffix := getClusterSuffix(clusterName)
+		targetAudience = getServerAudience(suffix)
 	} else {
 		// Default to dev environment if no cluster specified
-		targetAudience = "https://k8sctl-dev.example.com"
+		targetAudience = getServerAudience("dev")
 	}
 	config.TargetAudience = targetAudience
 
@@ -75,6 +87,13 @@ func getOIDCToken() (token string, err error) {
 	// to properly detect SSH keys from agent and default locations, just like tdoctl
 	config.SSHKeyPaths = nil
 
+	// Unless a key is pinned, in which case it's the only one tried, as with ssh -i and IdentitiesOnly, so the agent's
+	// other keys aren't offered to Dex
+	if sshKeyValue := getConfigValue(sshKey, "K8SCTL_SSH_KEY"); sshKeyValue != "" {
+		config.SSHKeyPaths = []string{expandHome(sshKeyValue)}
+		config.IdentitiesOnly = true
+	}
+
 	// Debug: log configuration being used
 	if os.Getenv("DEBUG") == debugEnvValue {
 		fmt.Fprintf(os.Stderr, "DEBUG: Using config - DexURL: %s, ClientID: %s, DexInstanceID: %s, TargetAudience: %s, Username: %s\n",
@@ -83,11 +102,23 @@ func getOIDCToken() (token string, err error) {
 		fmt.Fprintf(os.Stderr, "DEBUG: Config ClientSecret set: %t\n", config.ClientSecret != "")
 	}
 
+	// Show the SSH keys that can sign the login, so a failure can be told apart from Dex's
+	diagnose := verbose || os.Getenv("DEBUG") == debugEnvValue
+
+	var diagnostics sshDiagnostics
+	if diagnose {
+		diagnostics = diagnoseSSHKeys(config)
+		diagnostics.Print()
+	}
+
 	// Create SSH-signed JWT using kubectl-ssh-oidc's function
 	var sshJWT string
 	sshJWT, err = kubectl.CreateSSHSignedJWT(config)
 	if err != nil {
 		err = fmt.Errorf("failed to create SSH-signed JWT: %w", err)
+		if diagnose {
+			err = fmt.Errorf("%w\n%s", err, diagnostics.Hint(err))
+		}
 		return token, err
 	}
 
@@ -96,6 +127,9 @@ func getOIDCToken() (token string, err error) {
 	tokenResp, err = exchangeJWTForOIDC(config, sshJWT)
 	if err != nil {
 		err = fmt.Errorf("failed to exchange JWT with Dex: %w", err)
+		if diagnose {
+			err = fmt.Errorf("%w\nThe login was signed with an SSH key Dex authorized, so the problem is with the token exchange: check --dex-url and --client-id.", err)
+		}
 		return token, err
 	}
 
@@ -110,9 +144,9 @@ func getOIDCToken() (token string, err error) {
 		return token, err
 	}
 
-	// Debug: decode token to see what we got
-	if os.Getenv("DEBUG") == debugEnvValue {
-		fmt.Fprintf(os.Stderr, "DEBUG: Received OIDC token: %s\n", token)
+	// Debug: show what we got, without the token itself unless it's asked for with --show-token
+	if os.Getenv("DEBUG") == debugEnvValue && !showToken {
+		fmt.Fprintf(os.Stderr, "DEBUG: Received OIDC token: %s\n", k8sctl.RedactToken(token))
 	}
 
 	return token, err
@@ -128,6 +162,24 @@ func getConfigValue(flagValue, envVar string) (value string) {
 	return value
 }
 
+// expandHome expands a leading ~/ in a path to the user's home directory.
+func expandHome(path string) (expanded string) {
+	expanded = path
+
+	rest, found := strings.CutPrefix(path, "~/")
+	if !found {
+		return expanded
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return expanded
+	}
+
+	expanded = filepath.Join(home, rest)
+	return expanded
+}
+
 // exchangeJWTForOIDC exchanges SSH-signed JWT for OIDC token with proper audience support.
 func exchangeJWTForOIDC(config *kubectl.Config, sshJWT string) (tokenResp *kubectl.DexTokenResponse, err error) {
 	baseURL := strings.TrimSuffix(config.DexURL, "/")
@@ -166,7 +218,12 @@ func exchangeJWTForOIDC(config *kubectl.Config, sshJWT string) (tokenResp *kubec
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	var client *http.Client
+	client, err = newHTTPClient(30 * time.Second)
+	if err != nil {
+		return tokenResp, err
+	}
+
 	var resp *http.Response
 	resp, err = client.Do(req)
 	if err != nil {
@@ -183,7 +240,7 @@ func exchangeJWTForOIDC(config *kubectl.Config, sshJWT string) (tokenResp *kubec
 	}
 
 	if os.Getenv("DEBUG") == debugEnvValue {
-		fmt.Fprintf(os.Stderr, "DEBUG: Dex response: %s\n", string(respBody))
+		fmt.Fprintf(os.Stderr, "DEBUG: Dex response: %s\n", k8sctl.RedactJSON(respBody))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -202,14 +259,19 @@ func exchangeJWTForOIDC(config *kubectl.Config, sshJWT string) (tokenResp *kubec
 	return tokenResp, err
 }
 
-// loadConfig loads the k8sctl configuration if not already loaded.
+// loadConfig loads the k8sctl configuration if not already loaded: the --config file, if given, or else the first found
+// in the default locations.
 func loadConfig() (cfg *config.Config, err error) {
 	if cachedConfig != nil {
 		cfg = cachedConfig
 		return cfg, err
 	}
 
-	cfg, err = config.LoadDefault()
+	if configFile != "" {
+		cfg, err = config.Load(configFile)
+	} else {
+		cfg, err = config.LoadDefault()
+	}
 	if err != nil {
 		return cfg, err
 	}
@@ -221,9 +283,10 @@ func loadConfig() (cfg *config.Config, err error) {
 // getClusterSuffix returns the environment suffix for a cluster.
 // Priority order:
 // 1. K8SCTL_CLUSTER_SUFFIX environment variable (overrides everything)
-// 2. Configuration file cluster mapping
-// 3. Default environment from config
-// 4. Cluster name itself.
+// 2. --env flag
+// 3. Configuration file cluster mapping
+// 4. Default environment from config
+// 5. Cluster name itself.
 func getClusterSuffix(clusterName string) (suffix string) {
 	// Check for environment override first
 	if envSuffix := os.Getenv("K8SCTL_CLUSTER_SUFFIX"); envSuffix != "" {
@@ -231,6 +294,11 @@ func getClusterSuffix(clusterName string) (suffix string) {
 		return suffix
 	}
 
+	if clusterEnvironment != "" {
+		suffix = clusterEnvironment
+		return suffix
+	}
+
 	// Load config and check for cluster mapping
 	cfg, err := loadConfig()
 	if err == nil {
@@ -266,12 +334,127 @@ func getServerBaseURL(clusterName string) (baseURL string) {
 
 	// Construct URL from environment suffix
 	suffix := getClusterSuffix(clusterName)
-	baseURL = fmt.Sprintf("https://k8sctl-%s.example.com", suffix)
+	urlTemplate, _ := getServerTemplates()
+	baseURL = strings.TrimSuffix(config.FillTemplate(urlTemplate, suffix), "/")
 	return baseURL
 }
 
+// getServerAudience returns the audience the server of an environment expects of tokens.
+func getServerAudience(environment string) (audience string) {
+	_, audienceTemplate := getServerTemplates()
+	audience = config.FillTemplate(audienceTemplate, environment)
+	return audience
+}
+
+// getServerTemplates returns the templates for the server URL and token audience of clusters without a server_url.
+// K8SCTL_URL_TEMPLATE and K8SCTL_AUDIENCE_TEMPLATE override the config's url_template and audience_template.  The
+// audience template defaults to the URL template, so the two stay equal unless one is set apart.
+func getServerTemplates() (urlTemplate string, audienceTemplate string) {
+	cfg, err := loadConfig()
+	if err != nil || cfg == nil {
+		cfg = &config.Config{}
+	}
+
+	urlTemplate = cfg.GetURLTemplate()
+	if envTemplate := os.Getenv("K8SCTL_URL_TEMPLATE"); envTemplate != "" {
+		urlTemplate = envTemplate
+	}
+
+	audienceTemplate = cfg.AudienceTemplate
+	if envTemplate := os.Getenv("K8SCTL_AUDIENCE_TEMPLATE"); envTemplate != "" {
+		audienceTemplate = envTemplate
+	}
+	if audienceTemplate == "" {
+		audienceTemplate = urlTemplate
+	}
+
+	return urlTemplate, audienceTemplate
+}
+
+// getClusterRegion returns the AWS region to target for a cluster.
+// Priority order:
+// 1. --region flag
+// 2. Configuration file cluster-specific region
+// 3. Empty string (server uses the cluster's default region).
+func getClusterRegion(clusterName string) (clusterRegion string) {
+	if region != "" {
+		clusterRegion = region
+		return clusterRegion
+	}
+
+	cfg, err := loadConfig()
+	if err == nil {
+		clusterRegion = cfg.GetClusterRegion(clusterName)
+		return clusterRegion
+	}
+
+	clusterRegion = ""
+	return clusterRegion
+}
+
 // makeAuthenticatedRequest makes an HTTP request with Bearer token authentication.
 func makeAuthenticatedRequest(method, urlStr, body, token string) (resp *http.Response, err error) {
+	resp, err = makeAuthenticatedRequestWithHeaders(method, urlStr, body, token, nil)
+	return resp, err
+}
+
+// makeAuthenticatedRequestWithHeaders is makeAuthenticatedRequest, with extra request headers.  If the server rejects
+// the token, e.g. because it expired in flight or the issuer rotated its keys, a fresh one is obtained from Dex and the
+// request retried, once.
+func makeAuthenticatedRequestWithHeaders(method, urlStr, body, token string, headers map[string]string) (resp *http.Response, err error) {
+	resp, err = makeClusterRequest(cluster, method, urlStr, body, token, headers)
+	return resp, err
+}
+
+// makeClusterRequest is makeAuthenticatedRequestWithHeaders to the server of the given cluster, whose token a fresh one
+// replaces if it's rejected.
+func makeClusterRequest(clusterName, method, urlStr, body, token string, headers map[string]string) (resp *http.Response, err error) {
+	if strictAPIVersion {
+		err = requireServerAPIVersion(urlStr)
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	resp, err = doAuthenticatedRequest(method, urlStr, body, token, headers)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Server rejected the token, retrying with a fresh one\n")
+		}
+
+		// A 401 comes from the server's authentication, before any handler runs, so even a request that isn't idempotent
+		// is safe to retry.  getClusterOIDCToken always logs in to Dex anew, so the fresh token can't be a cached one.
+		var freshToken string
+		freshToken, err = getClusterOIDCToken(clusterName)
+		if err != nil {
+			// Leave the server's 401 for the caller to report
+			if verbose {
+				fmt.Fprintf(os.Stderr, "Failed to get a fresh token: %s\n", err)
+			}
+			err = nil
+			warnOnAPIVersionMismatch(resp)
+			return resp, err
+		}
+
+		_ = resp.Body.Close()
+
+		resp, err = doAuthenticatedRequest(method, urlStr, body, freshToken, headers)
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	warnOnAPIVersionMismatch(resp)
+
+	return resp, err
+}
+
+// doAuthenticatedRequest sends one request with the token.
+func doAuthenticatedRequest(method, urlStr, body, token string, headers map[string]string) (resp *http.Response, err error) {
 	var bodyReader io.Reader
 	if body != "" {
 		bodyReader = strings.NewReader(body)
@@ -286,10 +469,30 @@ func makeAuthenticatedRequest(method, urlStr, body, token string) (resp *http.Re
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
 
 	// Use configurable timeout from --timeout-seconds flag (default 300s)
 	timeout := time.Duration(timeoutSeconds) * time.Second
-	httpClient := &http.Client{Timeout: timeout}
+	var httpClient *http.Client
+	httpClient, err = newHTTPClient(timeout)
+	if err != nil {
+		return resp, err
+	}
+
+	if debugHTTP {
+		dumpRequest(req, body)
+	}
+
 	resp, err = httpClient.Do(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if debugHTTP {
+		dumpResponse(resp)
+	}
+
 	return resp, err
 }
@@ -0,0 +1,92 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var capabilitiesOutput string
+
+// capabilitiesCmd represents the capabilities command.
+var capabilitiesCmd = &cobra.Command{
+	Use:   "capabilities",
+	Short: "List the operations the server supports, and the groups they need",
+	Long: `
+List the operations the k8sctl server supports: each one's method and path, the groups allowed to call it, and
+whether it's destructive (it can delete, replace, or restart nodes, or evict their pods).  Every operation needs one
+of the server's allowed groups; those returning cluster credentials further need a credential group.  The registered
+commands 'k8sctl run' can run are listed after, with the group each needs.
+
+Example:
+  k8sctl -c cluster1 capabilities
+  k8sctl -c cluster1 capabilities -o json
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if capabilitiesOutput != "table" && capabilitiesOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table or json.", capabilitiesOutput)
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/capabilities", baseURL, apiVersion)
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+		}
+
+		resp, err := makeAuthenticatedRequest("GET", serverURL, "", token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		if capabilitiesOutput == "json" || outputFile != "" {
+			err = writeResult(body)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+			return
+		}
+
+		var result k8sctl.CapabilitiesResult
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			log.Fatalf("Failed unmarshalling capabilities: %s", err)
+		}
+
+		result.ConsolePrint()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(capabilitiesCmd)
+	addOutputFlag(capabilitiesCmd, &capabilitiesOutput, "table", "json")
+}
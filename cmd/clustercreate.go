@@ -0,0 +1,158 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var createNodeName string
+var createNodeType string
+var createWait int
+var createKubeconfigOut string
+var createOutput string
+
+// clusterCreateCmd represents the cluster create command.
+var clusterCreateCmd = &cobra.Command{
+	Use:   "create [<cluster name>]",
+	Short: "Create a new cluster from its first control plane node",
+	Long: `
+Create a new cluster: launch its first control plane node, bootstrap etcd on it, and wait for the Kubernetes API to come
+up.  The server builds the node from the cluster's controlplane node config, as node create does.
+
+The new cluster's admin kubeconfig is written to --kubeconfig-out (default <cluster>-kubeconfig).  The talosconfig is
+the one generated along with the cluster's machine configs: the server doesn't have it.
+
+A cluster that already has nodes is refused.  Add more nodes to a new cluster with node create.
+
+Example:
+  k8sctl -c cluster1 cluster create
+  k8sctl -c cluster1 cluster create --node-name cluster1-cp-1 --type m5.large --wait 1800
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			// An argument beats $K8SCTL_CLUSTER, but not -c
+			if !cmd.Flags().Changed("cluster") {
+				cluster = args[0]
+			}
+		}
+
+		if cluster == "" {
+			log.Fatalf("Cluster name is required. Use -c flag or provide as argument.")
+		}
+
+		if createOutput != "table" && createOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table or json.", createOutput)
+		}
+
+		if createKubeconfigOut == "" {
+			createKubeconfigOut = fmt.Sprintf("%s-kubeconfig", cluster)
+		}
+
+		// The request lasts as long as the server waits for the cluster, so don't give up on it sooner
+		if timeoutSeconds < createWait+60 {
+			timeoutSeconds = createWait + 60
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/cluster/%s/create", baseURL, apiVersion, cluster)
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+		}
+
+		data := k8sctl.ClusterCreateBody{
+			Name:          createNodeName,
+			Type:          createNodeType,
+			Timeout:       createWait,
+			Verbose:       verbose,
+			CloudProvider: "aws",
+			Region:        getClusterRegion(cluster),
+		}
+
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			log.Fatalf("unable to marshal post data: %s", err)
+		}
+
+		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		var result k8sctl.ClusterCreateResult
+		if resp.StatusCode != http.StatusOK {
+			// A create that launched the node, but didn't get the cluster up, still reports how far it got
+			if json.Unmarshal(body, &result) != nil || result.Error == "" {
+				log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+			}
+		} else {
+			err = json.Unmarshal(body, &result)
+			if err != nil {
+				log.Fatalf("Failed unmarshalling cluster create result: %s", err)
+			}
+		}
+
+		if result.Kubeconfig != "" {
+			err = os.WriteFile(createKubeconfigOut, []byte(result.Kubeconfig), 0600)
+			if err != nil {
+				log.Fatalf("Failed writing kubeconfig to %s: %s", createKubeconfigOut, err)
+			}
+		}
+
+		if createOutput == "json" || outputFile != "" {
+			out, marshalErr := json.MarshalIndent(result, "", "  ")
+			if marshalErr != nil {
+				log.Fatalf("unable to marshal cluster create result: %s", marshalErr)
+			}
+			err = writeResult(out)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+		} else {
+			result.ConsolePrint()
+			if result.Kubeconfig != "" {
+				fmt.Printf("  kubeconfig:   %s\n", createKubeconfigOut)
+			}
+		}
+
+		if result.Error != "" {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	clusterCmd.AddCommand(clusterCreateCmd)
+	clusterCreateCmd.Flags().StringVar(&createNodeName, "node-name", "", "Name of the first control plane node (default <cluster>-cp-1)")
+	clusterCreateCmd.Flags().StringVarP(&createNodeType, "type", "t", "", "Instance type of the first control plane node (default: from the node config)")
+	clusterCreateCmd.Flags().IntVar(&createWait, "wait", 1200, "Seconds to wait for the cluster to bootstrap and its API to come up")
+	clusterCreateCmd.Flags().StringVar(&createKubeconfigOut, "kubeconfig-out", "", "File to write the new cluster's kubeconfig to (default <cluster>-kubeconfig)")
+	addOutputFlag(clusterCreateCmd, &createOutput, "table", "json")
+}
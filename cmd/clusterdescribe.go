@@ -9,22 +9,66 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"time"
 
-	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
 	"github.com/nikogura/k8sctl/pkg/k8sctl"
 	"github.com/spf13/cobra"
 )
 
+var describeRole string
+var describeNamePrefix string
+var describeUnhealthyOnly bool
+var describeNodeLBs bool
+var describeUtilization bool
+var describeSince time.Duration
+var describeOutput string
+
 // clusterDescribeCmd represents the clusterlist command.
 var clusterDescribeCmd = &cobra.Command{
-	Use:   "describe",
-	Short: "Describe a cluster",
+	Use:   "describe [<cluster name>...]",
+	Short: "Describe a cluster, or several",
 	Long: `
 List Information about a cluster.
+
+For large clusters the output can be narrowed down:
+  k8sctl -c cluster1 cluster describe --role worker
+  k8sctl -c cluster1 cluster describe --name-prefix cluster1-worker-1
+  k8sctl -c cluster1 cluster describe --unhealthy-only
+  k8sctl -c cluster1 cluster describe --since 2h
+
+--since shows only the nodes whose EC2 instance launched, or that joined Kubernetes, within that long, along with their
+load balancer targets.
+
+Each node's instance is listed with when it launched and its age, e.g. 3d4h, and when it joined Kubernetes: handy for
+spotting nodes due for rotation, and nodes that have launched but not yet joined.
+
+With --node-lbs, each node is also listed with the load balancer target groups it's registered with and its health in
+each, flagging nodes registered with only some of a load balancer's target groups.
+
+With --utilization, each Kubernetes node's CPU and memory are also listed: what it can allot to pods, what its pods
+request, and what it's using.  The usage needs the metrics server in the cluster; without it, or for nodes it has no
+metrics for, the usage is shown as unavailable.
+
+With --output wide, the nodes are listed in a table with their instance IDs, private IPs, availability zones, launch
+times and ages, instance types, and the state of their load balancer targets, and the load balancers with a target per
+line.  --output json prints the raw result.
+
+With --all, or more than one cluster name, the clusters are described at once, --parallel at a time, and summarized in
+a table with a line per cluster: its nodes, load balancers, unhealthy targets, nodes missing from every load balancer,
+and estimated cost.  A cluster that can't be described is reported as failed, without stopping the others, and the
+command exits non-zero.  --all describes every cluster in the k8sctl config.
+  k8sctl cluster describe --all
+  k8sctl cluster describe cluster1 cluster2 --output json
 `,
 	Run: func(cmd *cobra.Command, args []string) {
+		if describeAll || len(args) > 1 {
+			runDescribeClusters(args)
+			return
+		}
+
 		if len(args) > 0 {
-			if cluster == "" {
+			// An argument beats $K8SCTL_CLUSTER, but not -c
+			if !cmd.Flags().Changed("cluster") {
 				cluster = args[0]
 			}
 		}
@@ -33,6 +77,10 @@ List Information about a cluster.
 			log.Fatalf("Cluster name is required. Use -c flag or provide as argument.")
 		}
 
+		if describeOutput != "table" && describeOutput != "wide" && describeOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table, wide, or json.", describeOutput)
+		}
+
 		// Get OIDC token
 		token, err := getOIDCToken()
 		if err != nil {
@@ -52,7 +100,14 @@ List Information about a cluster.
 		}
 
 		data := k8sctl.DescribeClusterBody{
-			Verbose: verbose,
+			Verbose:       verbose,
+			Region:        getClusterRegion(cluster),
+			Role:          describeRole,
+			NamePrefix:    describeNamePrefix,
+			UnhealthyOnly: describeUnhealthyOnly,
+			NodeLBs:       describeNodeLBs,
+			Utilization:   describeUtilization,
+			Since:         int(describeSince.Seconds()),
 		}
 
 		dataBytes, err := json.Marshal(data)
@@ -75,16 +130,134 @@ List Information about a cluster.
 			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
 		}
 
-		var info manager.ClusterInfo
+		var info k8sctl.DescribeClusterResult
 		err = json.Unmarshal(body, &info)
 		if err != nil {
 			log.Fatalf("Failed unmarshalling cluster info: %s", err)
 		}
 
-		info.ConsolePrint()
+		if describeOutput == "json" || outputFile != "" {
+			err = writeResult(body)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+			return
+		}
+
+		if describeOutput == "wide" {
+			info.ConsolePrintWide()
+		} else {
+			info.ConsolePrint()
+		}
+
+		if len(info.UnhealthyTargets) > 0 {
+			fmt.Printf("Unhealthy Targets: (%d)\n", len(info.UnhealthyTargets))
+			for _, target := range info.UnhealthyTargets {
+				fmt.Printf("  %s\n", target.Summary())
+			}
+		}
+
+		// The wide view shows the instances in the node table
+		if len(info.NodeInstances) > 0 && describeOutput != "wide" {
+			fmt.Printf("Node Instances: (%d)\n", len(info.NodeInstances))
+			for _, instance := range info.NodeInstances {
+				instance.ConsolePrint()
+			}
+		}
+
+		if len(info.NodeAttachments) > 0 {
+			fmt.Printf("Node Load Balancers: (%d)\n", len(info.NodeAttachments))
+			for _, attachment := range info.NodeAttachments {
+				attachment.ConsolePrint()
+			}
+		}
+
+		if len(info.Utilization) > 0 {
+			fmt.Printf("Node Utilization: (%d)\n", len(info.Utilization))
+			for _, utilization := range info.Utilization {
+				utilization.ConsolePrint()
+			}
+		}
+
+		if info.UtilizationError != "" {
+			fmt.Printf("Node Utilization: unavailable: %s\n", info.UtilizationError)
+		}
+
+		if len(info.UncheckedTargetGroups) > 0 {
+			fmt.Printf("Target Groups Whose Health Couldn't Be Fetched: (%d)\n", len(info.UncheckedTargetGroups))
+			for _, name := range info.UncheckedTargetGroups {
+				fmt.Printf("  %s\n", name)
+			}
+		}
 	},
 }
 
+// runDescribeClusters describes several clusters: those named, or with --all, every configured cluster.
+func runDescribeClusters(args []string) {
+	if describeAll && len(args) > 0 {
+		log.Fatalf("Give either --all or cluster names, not both.")
+	}
+
+	if describeNodeLBs || describeUtilization {
+		log.Fatalf("--node-lbs and --utilization only apply to describing a single cluster.")
+	}
+
+	if describeOutput != "table" && describeOutput != "wide" && describeOutput != "json" {
+		log.Fatalf("Invalid output format %q. Use table, wide, or json.", describeOutput)
+	}
+
+	clusters := args
+	if describeAll {
+		var err error
+		clusters, err = configuredClusters()
+		if err != nil {
+			log.Fatalf("Failed loading config: %s", err)
+		}
+
+		if len(clusters) == 0 {
+			log.Fatalf("No clusters are configured.  Add them to the k8sctl config, or name them as arguments.")
+		}
+	}
+
+	printInfo("Describing %d cluster(s), %d at a time\n", len(clusters), max(describeParallel, 1))
+
+	fleet := describeClusters(clusters, k8sctl.DescribeClusterBody{
+		Verbose:       verbose,
+		Role:          describeRole,
+		NamePrefix:    describeNamePrefix,
+		UnhealthyOnly: describeUnhealthyOnly,
+		Since:         int(describeSince.Seconds()),
+	})
+
+	if describeOutput == "json" || outputFile != "" {
+		result, err := json.Marshal(fleet)
+		if err != nil {
+			log.Fatalf("Failed marshalling result: %s", err)
+		}
+
+		err = writeResult(result)
+		if err != nil {
+			log.Fatalf("Failed writing result: %s", err)
+		}
+	} else {
+		fleet.ConsolePrint()
+	}
+
+	if fleet.Failed > 0 {
+		log.Fatalf("Failed describing %d of %d cluster(s)", fleet.Failed, len(clusters))
+	}
+}
+
 func init() {
 	clusterCmd.AddCommand(clusterDescribeCmd)
+	clusterDescribeCmd.Flags().StringVar(&describeRole, "role", "", "Only show nodes with this role (controlplane or worker)")
+	_ = clusterDescribeCmd.RegisterFlagCompletionFunc("role", completeNodeRoles)
+	clusterDescribeCmd.Flags().StringVar(&describeNamePrefix, "name-prefix", "", "Only show nodes whose name starts with this prefix")
+	clusterDescribeCmd.Flags().DurationVar(&describeSince, "since", 0, "Only show nodes launched, or joined to Kubernetes, within this long, e.g. 2h")
+	clusterDescribeCmd.Flags().BoolVar(&describeUnhealthyOnly, "unhealthy-only", false, "Only show load balancer targets that are not healthy")
+	clusterDescribeCmd.Flags().BoolVar(&describeNodeLBs, "node-lbs", false, "Also show the load balancer target groups each node is registered with")
+	addOutputFlag(clusterDescribeCmd, &describeOutput, "table", "wide", "json")
+	clusterDescribeCmd.Flags().BoolVar(&describeAll, "all", false, "Describe every cluster in the k8sctl config, summarized a line per cluster")
+	clusterDescribeCmd.Flags().IntVar(&describeParallel, "parallel", 4, "Clusters to describe at a time, with --all or several cluster names")
+	clusterDescribeCmd.Flags().BoolVar(&describeUtilization, "utilization", false, "Also show each node's CPU and memory allocatable, requested, and in use (usage needs the metrics server)")
 }
@@ -0,0 +1,134 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"golang.org/x/sync/errgroup"
+)
+
+var describeAll bool
+
+var describeParallel int
+
+// configuredClusters returns the names of the clusters in the k8sctl config, sorted.
+func configuredClusters() (names []string, err error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return names, err
+	}
+
+	for name := range cfg.Clusters {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, err
+}
+
+// describeClusters describes the clusters at once, at most --parallel at a time, and summarizes each.  A cluster that
+// can't be described has its error in its overview, rather than failing the others.
+func describeClusters(clusters []string, data k8sctl.DescribeClusterBody) (fleet k8sctl.FleetOverview) {
+	fleet.Clusters = make([]k8sctl.ClusterOverview, len(clusters))
+
+	// Log in once per environment, up front, rather than once per cluster and all at once
+	tokens := make(map[string]string)
+	tokenErrs := make(map[string]error)
+	for _, name := range clusters {
+		suffix := getClusterSuffix(name)
+		if _, ok := tokens[suffix]; ok {
+			continue
+		}
+		if _, ok := tokenErrs[suffix]; ok {
+			continue
+		}
+
+		token, err := getClusterOIDCToken(name)
+		if err != nil {
+			tokenErrs[suffix] = fmt.Errorf("failed to get OIDC token: %w", err)
+			continue
+		}
+		tokens[suffix] = token
+	}
+
+	var group errgroup.Group
+	group.SetLimit(max(describeParallel, 1))
+
+	for i, name := range clusters {
+		fleet.Clusters[i] = k8sctl.ClusterOverview{Cluster: name}
+
+		suffix := getClusterSuffix(name)
+		if tokenErr, ok := tokenErrs[suffix]; ok {
+			fleet.Clusters[i].Error = tokenErr.Error()
+			continue
+		}
+
+		group.Go(func() (err error) {
+			info, describeErr := describeOneCluster(name, data, tokens[suffix])
+			if describeErr != nil {
+				fleet.Clusters[i].Error = describeErr.Error()
+				return err
+			}
+
+			fleet.Clusters[i] = k8sctl.NewClusterOverview(name, info)
+			return err
+		})
+	}
+
+	_ = group.Wait()
+
+	for _, cluster := range fleet.Clusters {
+		if cluster.Error != "" {
+			fleet.Failed++
+		}
+	}
+
+	return fleet
+}
+
+// describeOneCluster describes a cluster for describeClusters.
+func describeOneCluster(name string, data k8sctl.DescribeClusterBody, token string) (info k8sctl.DescribeClusterResult, err error) {
+	serverURL := fmt.Sprintf("%s/%s/cluster/describe/%s", getServerBaseURL(name), apiVersion, name)
+
+	data.Region = getClusterRegion(name)
+
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		err = fmt.Errorf("unable to marshal post data: %w", err)
+		return info, err
+	}
+
+	resp, err := makeClusterRequest(name, "POST", serverURL, string(dataBytes), token, nil)
+	if err != nil {
+		err = fmt.Errorf("failed making authenticated request: %w", err)
+		return info, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		err = fmt.Errorf("failed reading response body: %w", err)
+		return info, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("request failed with status %d: %s", resp.StatusCode, body)
+		return info, err
+	}
+
+	err = json.Unmarshal(body, &info)
+	if err != nil {
+		err = fmt.Errorf("failed unmarshalling cluster info: %w", err)
+		return info, err
+	}
+
+	return info, err
+}
@@ -0,0 +1,177 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+var kubeconfigMerge bool
+var kubeconfigContext string
+var kubeconfigPath string
+
+// clusterKubeconfigCmd represents the cluster kubeconfig command.
+var clusterKubeconfigCmd = &cobra.Command{
+	Use:   "kubeconfig [<cluster name>]",
+	Short: "Get a cluster's admin kubeconfig",
+	Long: `
+Get a cluster's admin kubeconfig, read by the server from one of the cluster's control plane nodes.
+
+By default the kubeconfig is printed.  With --merge, it's merged into your kubeconfig (--kubeconfig, else the first file
+in $KUBECONFIG, else ~/.kube/config) as the context --context (default: the cluster name), replacing any previous merge
+of it.  Your current context is left alone.
+
+Only members of the server's OIDC_CREDENTIAL_GROUPS may fetch kubeconfigs.
+
+Example:
+  k8sctl -c cluster1 cluster kubeconfig > cluster1-kubeconfig
+  k8sctl -c cluster1 cluster kubeconfig --merge
+  k8sctl -c cluster1 cluster kubeconfig --merge --context prod-admin
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			// An argument beats $K8SCTL_CLUSTER, but not -c
+			if !cmd.Flags().Changed("cluster") {
+				cluster = args[0]
+			}
+		}
+
+		if cluster == "" {
+			log.Fatalf("Cluster name is required. Use -c flag or provide as argument.")
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/cluster/%s/kubeconfig", baseURL, apiVersion, cluster)
+
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Target URL: %s\n", serverURL)
+			fmt.Fprintf(os.Stderr, "Cluster: %s\n", cluster)
+		}
+
+		data := k8sctl.KubeconfigBody{
+			Verbose: verbose,
+			Region:  getClusterRegion(cluster),
+		}
+
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			log.Fatalf("unable to marshal post data: %s", err)
+		}
+
+		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		var result k8sctl.KubeconfigResult
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			log.Fatalf("Failed unmarshalling kubeconfig result: %s", err)
+		}
+
+		if !kubeconfigMerge {
+			fmt.Print(result.Kubeconfig)
+			return
+		}
+
+		contextName := kubeconfigContext
+		if contextName == "" {
+			contextName = cluster
+		}
+
+		path := kubeconfigPath
+		if path == "" {
+			path = defaultKubeconfigPath()
+		}
+
+		err = mergeKubeconfigFile(path, []byte(result.Kubeconfig), contextName)
+		if err != nil {
+			log.Fatalf("Failed merging kubeconfig into %s: %s", path, err)
+		}
+
+		fmt.Printf("Merged the kubeconfig for %s into %s as context %s\n", cluster, path, contextName)
+		printInfo("Use it with: kubectl config use-context %s\n", contextName)
+	},
+}
+
+// defaultKubeconfigPath returns the kubeconfig kubectl would write to: the first file in $KUBECONFIG, else
+// ~/.kube/config.
+func defaultKubeconfigPath() (path string) {
+	paths := filepath.SplitList(os.Getenv("KUBECONFIG"))
+	if len(paths) > 0 && paths[0] != "" {
+		path = paths[0]
+		return path
+	}
+
+	path = clientcmd.RecommendedHomeFile
+	return path
+}
+
+// mergeKubeconfigFile merges a kubeconfig into the kubeconfig file at path, as contextName, creating the file if need be.
+func mergeKubeconfigFile(path string, kubeconfig []byte, contextName string) (err error) {
+	existing := clientcmdapi.NewConfig()
+
+	_, statErr := os.Stat(path)
+	if statErr == nil {
+		existing, err = clientcmd.LoadFromFile(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = k8sctl.MergeKubeconfig(existing, kubeconfig, contextName)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	err = clientcmd.WriteToFile(*existing, path)
+	if err != nil {
+		return err
+	}
+
+	return err
+}
+
+func init() {
+	clusterCmd.AddCommand(clusterKubeconfigCmd)
+	clusterKubeconfigCmd.Flags().BoolVar(&kubeconfigMerge, "merge", false, "Merge the kubeconfig into your kubeconfig file rather than printing it")
+	clusterKubeconfigCmd.Flags().StringVar(&kubeconfigContext, "context", "", "Context name to merge the kubeconfig as (default: the cluster name)")
+	clusterKubeconfigCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Kubeconfig file to merge into (default: first file in $KUBECONFIG, else ~/.kube/config)")
+}
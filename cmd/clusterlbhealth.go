@@ -0,0 +1,111 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var lbHealthOutput string
+
+// clusterlbhealthCmd represents the clusterlbhealth command.
+var clusterlbhealthCmd = &cobra.Command{
+	Use:   "lb-health [<cluster name>]",
+	Short: "List unhealthy load balancer targets",
+	Long: `
+List the load balancer targets in a cluster that are not healthy, without running a full describe.
+
+Example:
+  k8sctl -c cluster1 cluster lb-health
+  k8sctl -c cluster1 cluster lb-health -o json
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			// An argument beats $K8SCTL_CLUSTER, but not -c
+			if !cmd.Flags().Changed("cluster") {
+				cluster = args[0]
+			}
+		}
+
+		if cluster == "" {
+			log.Fatalf("Cluster name is required. Use -c flag or provide as argument.")
+		}
+
+		if lbHealthOutput != "table" && lbHealthOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table or json.", lbHealthOutput)
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/cluster/%s/lb/unhealthy", baseURL, apiVersion, cluster)
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+		}
+
+		data := k8sctl.LBHealthBody{
+			Verbose: verbose,
+			Region:  getClusterRegion(cluster),
+		}
+
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			log.Fatalf("unable to marshal post data: %s", err)
+		}
+
+		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		if lbHealthOutput == "json" || outputFile != "" {
+			err = writeResult(body)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+			return
+		}
+
+		var result k8sctl.LBHealthResult
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			log.Fatalf("Failed unmarshalling load balancer health: %s", err)
+		}
+
+		result.ConsolePrint()
+	},
+}
+
+func init() {
+	clusterCmd.AddCommand(clusterlbhealthCmd)
+	addOutputFlag(clusterlbhealthCmd, &lbHealthOutput, "table", "json")
+	addJSONAlias(clusterlbhealthCmd)
+}
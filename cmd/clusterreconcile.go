@@ -5,16 +5,43 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
 	"github.com/spf13/cobra"
 )
 
 var fixTags bool
 
+var fixTagsDryRun bool
+
+var reconcileMinAge int
+
+var reconcileJoinGrace time.Duration
+
+var reconcileMinCPZones int
+
+var reconcileSummary bool
+
+var reconcileIncludeClusterInfo bool
+
+var reconcileStream bool
+
+var reconcileOutput string
+
+var selectRole string
+
+var selectPurpose string
+
+var selectNamePrefix string
+
+var selectSince time.Duration
+
 // clusterreconcileCmd represents the clusterreconcile command.
 var clusterreconcileCmd = &cobra.Command{
 	Use:   "reconcile [<cluster name>]",
@@ -26,12 +53,48 @@ This command will:
 - List all EC2 instances (with and without Cluster tag)
 - List all Kubernetes nodes
 - List all load balancer targets
-- Report any discrepancies
-- Optionally fix missing Cluster tags with --fix-tags
+- Report any discrepancies, including names shared by more than one EC2 instance or Kubernetes node
+- Report each EC2 instance not in Kubernetes with its launch time, age, and estimated monthly cost, and their total
+- Optionally fix missing Cluster tags with --fix-tags, reporting each instance's Cluster tag before and after
+
+With --fix-tags-dry-run, nothing is changed: a summary of the tags --fix-tags would set, and each instance's current
+values, is printed, e.g. "2 instance(s) would have tags fixed".  Use it to preview a fix before making it.  With
+--output json, or --output-file, the result is returned as JSON instead, with the tags as tag_plan.  Reconciles that
+aren't dry runs always return JSON.
+
+If the server config has required_tags, e.g. team or cost-center, each EC2 instance missing any of them (or with one
+empty) is reported in missing_tags, with the tags it's missing.  --fix-tags also sets those with a default in the
+server config; those without one are left to be set by hand.
+
+With --role, --purpose, or --name-prefix, only the matching nodes are compared.  --purpose matches the Kubernetes
+node's purpose label, so EC2 instances without a Kubernetes node are left out.  With --since, e.g. --since 2h, only the
+nodes whose EC2 instance launched, or that joined Kubernetes, in that window are compared.
+
+With --min-age, EC2 instances younger than that many seconds aren't reported as missing from Kubernetes, since they're
+likely still joining.  They're listed as joining_nodes instead.
+
+--join-grace, e.g. --join-grace 10m, does the same, and also separates out the instances that have had their grace and
+still haven't joined: those launched within a day of the grace running out are listed as stuck_joining, rather than
+ec2_not_in_k8s, since they've most likely failed to join.  k8sctl node logs shows why.
+
+The control plane nodes are counted by availability zone, and it's an issue if they span fewer than --min-cp-zones
+zones (default 3, or one per node for a smaller control plane), since losing a zone holding a majority of etcd members
+loses quorum.
+
+With --summary, only the count of each kind of discrepancy, and the total, are returned, not the nodes.  That keeps
+the result small for dashboards and metrics collectors that poll often.
+
+With --include-cluster-info, the cluster info the reconcile compared, its nodes and load balancers, is included as
+cluster_info, so one request gives both the cluster's state and its discrepancies, from the same snapshot.
+
+With --stream, the reconcile's progress and each discrepancy are printed as the server finds them, then the summary,
+as with --summary.  On clusters with hundreds of nodes, that shows results as soon as they're known, rather than all at
+the end.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) > 0 {
-			if cluster == "" {
+			// An argument beats $K8SCTL_CLUSTER, but not -c
+			if !cmd.Flags().Changed("cluster") {
 				cluster = args[0]
 			}
 
@@ -60,9 +123,18 @@ This command will:
 		}
 
 		data := map[string]interface{}{
-			"verbose":  verbose,
-			"fix_tags": fixTags,
+			"verbose":                 verbose,
+			"region":                  getClusterRegion(cluster),
+			"fix_tags":                fixTags,
+			"fix_tags_dry_run":        fixTagsDryRun,
+			"min_age":                 reconcileMinAge,
+			"join_grace":              int(reconcileJoinGrace.Seconds()),
+			"min_control_plane_zones": reconcileMinCPZones,
+			"summary":                 reconcileSummary,
+			"include_cluster_info":    reconcileIncludeClusterInfo,
+			"stream":                  reconcileStream,
 		}
+		addNodeSelector(data)
 
 		dataBytes, err := json.Marshal(data)
 		if err != nil {
@@ -75,6 +147,17 @@ This command will:
 		}
 		defer resp.Body.Close()
 
+		// A dry run is summarised for people, unless its JSON was asked for
+		dryRunText := fixTagsDryRun && reconcileOutput != "json" && outputFile == ""
+
+		if reconcileStream && resp.StatusCode == http.StatusOK {
+			err = printReconcileStream(resp.Body, dryRunText)
+			if err != nil {
+				log.Fatalf("Reconcile failed: %s", err)
+			}
+			return
+		}
+
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
 			log.Fatalf("failed reading response body: %s", err)
@@ -84,11 +167,118 @@ This command will:
 			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
 		}
 
-		fmt.Printf("%s\n", body)
+		if dryRunText {
+			err = printReconcileDryRun(body)
+			if err != nil {
+				log.Fatalf("Failed printing reconcile dry run: %s", err)
+			}
+			return
+		}
+
+		err = writeResult(body)
+		if err != nil {
+			log.Fatalf("Failed writing result: %s", err)
+		}
 	},
 }
 
+// printReconcileStream prints a streamed reconcile's events as they arrive, then writes its summary as the result, or
+// with dryRunText, prints the summary of the dry run.
+func printReconcileStream(body io.Reader, dryRunText bool) (err error) {
+	decoder := json.NewDecoder(body)
+
+	for {
+		var event k8sctl.ReconcileEvent
+		err = decoder.Decode(&event)
+		if errors.Is(err, io.EOF) {
+			err = fmt.Errorf("reconcile stream ended before its summary")
+			return err
+		}
+
+		if err != nil {
+			err = fmt.Errorf("failed reading reconcile stream: %w", err)
+			return err
+		}
+
+		switch event.Type {
+		case k8sctl.ReconcileEventError:
+			err = errors.New(event.Message)
+			return err
+		case k8sctl.ReconcileEventSummary:
+			if dryRunText && event.Summary != nil {
+				printDryRunSummary(event.Summary.DryRunSummary())
+				return err
+			}
+
+			var summary []byte
+			summary, err = json.Marshal(event.Summary)
+			if err != nil {
+				err = fmt.Errorf("failed marshalling reconcile summary: %w", err)
+				return err
+			}
+
+			err = writeResult(summary)
+			return err
+		default:
+			event.ConsolePrint()
+		}
+	}
+}
+
+// printReconcileDryRun prints the summary of a reconcile's dry run, from its result, or with --summary, its counts.
+func printReconcileDryRun(body []byte) (err error) {
+	if reconcileSummary {
+		var summary k8sctl.ReconcileSummary
+		err = json.Unmarshal(body, &summary)
+		if err != nil {
+			err = fmt.Errorf("failed unmarshalling reconcile summary: %w", err)
+			return err
+		}
+
+		printDryRunSummary(summary.DryRunSummary())
+		return err
+	}
+
+	var result k8sctl.ReconcileResult
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		err = fmt.Errorf("failed unmarshalling reconcile result: %w", err)
+		return err
+	}
+
+	printDryRunSummary(result.DryRunSummary())
+
+	return err
+}
+
+// addNodeSelector adds the --role, --purpose, --name-prefix, and --since node selector to a reconcile or monitor
+// request.
+func addNodeSelector(data map[string]interface{}) {
+	data["role"] = selectRole
+	data["purpose"] = selectPurpose
+	data["name_prefix"] = selectNamePrefix
+	data["since"] = int(selectSince.Seconds())
+}
+
+// addNodeSelectorFlags adds the --role, --purpose, --name-prefix, and --since node selector flags to a command.
+func addNodeSelectorFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&selectRole, "role", "", "Only consider nodes with this role (controlplane or worker)")
+	_ = cmd.RegisterFlagCompletionFunc("role", completeNodeRoles)
+	cmd.Flags().StringVar(&selectPurpose, "purpose", "", "Only consider nodes whose Kubernetes purpose label has this value")
+	cmd.Flags().StringVar(&selectNamePrefix, "name-prefix", "", "Only consider nodes whose name starts with this prefix")
+	cmd.Flags().DurationVar(&selectSince, "since", 0, "Only consider nodes launched, or joined to Kubernetes, within this long, e.g. 2h")
+}
+
 func init() {
 	clusterCmd.AddCommand(clusterreconcileCmd)
 	clusterreconcileCmd.Flags().BoolVar(&fixTags, "fix-tags", false, "Automatically fix missing Cluster tags")
+	clusterreconcileCmd.Flags().BoolVar(&fixTagsDryRun, "fix-tags-dry-run", false, "Show the Cluster tags --fix-tags would set, without setting them")
+	clusterreconcileCmd.Flags().IntVar(&reconcileMinAge, "min-age", 0, "Seconds an EC2 instance must have existed before it's reported as missing from Kubernetes")
+	clusterreconcileCmd.Flags().DurationVar(&reconcileJoinGrace, "join-grace", 0, "How long an EC2 instance may take to join Kubernetes, e.g. 10m, before it's reported, as stuck_joining if it launched recently")
+	clusterreconcileCmd.Flags().IntVar(&reconcileMinCPZones, "min-cp-zones", 0, "Availability zones the control plane should span (default 3)")
+	clusterreconcileCmd.Flags().BoolVar(&reconcileSummary, "summary", false, "Only return the count of each kind of discrepancy, not the nodes")
+	clusterreconcileCmd.Flags().BoolVar(&reconcileIncludeClusterInfo, "include-cluster-info", false, "Include the cluster info the reconcile compared in its result")
+	clusterreconcileCmd.Flags().BoolVar(&reconcileStream, "stream", false, "Print progress and discrepancies as they're found, then the summary")
+	addNodeSelectorFlags(clusterreconcileCmd)
+	addOutputFlag(clusterreconcileCmd, &reconcileOutput, "text", "json")
 }
@@ -0,0 +1,106 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var repairHistoryLimit int
+var repairHistoryOutput string
+
+// clusterreconcilehistoryCmd represents the cluster reconcile-history command.
+var clusterreconcilehistoryCmd = &cobra.Command{
+	Use:   "reconcile-history [<cluster name>]",
+	Short: "List the repairs made to a cluster",
+	Long: `
+List the repairs the server has made to a cluster, newest first: when, by whom, and what was changed.  Repairs are the
+tags set by reconcile --fix-tags, and the target groups nodes were reattached to by node lb-reattach.  Changes that
+failed are listed too, with their errors.
+
+The server keeps its latest repairs in memory, so the history starts again when it restarts.  Its logs hold the rest.
+
+Example:
+  k8sctl -c cluster1 cluster reconcile-history
+  k8sctl -c cluster1 cluster reconcile-history --limit 10 -o json
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			// An argument beats $K8SCTL_CLUSTER, but not -c
+			if !cmd.Flags().Changed("cluster") {
+				cluster = args[0]
+			}
+		}
+
+		if cluster == "" {
+			log.Fatalf("Cluster name is required. Use -c flag or provide as argument.")
+		}
+
+		if repairHistoryOutput != "table" && repairHistoryOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table or json.", repairHistoryOutput)
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/cluster/%s/reconcile/history?limit=%d", baseURL, apiVersion, cluster, repairHistoryLimit)
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+		}
+
+		resp, err := makeAuthenticatedRequest("GET", serverURL, "", token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		if repairHistoryOutput == "json" || outputFile != "" {
+			err = writeResult(body)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+			return
+		}
+
+		var result k8sctl.RepairHistoryResult
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			log.Fatalf("Failed unmarshalling repair history: %s", err)
+		}
+
+		result.ConsolePrint()
+	},
+}
+
+func init() {
+	clusterCmd.AddCommand(clusterreconcilehistoryCmd)
+	clusterreconcilehistoryCmd.Flags().IntVar(&repairHistoryLimit, "limit", 0, "Most repairs to list, newest first (default: all the server has)")
+	addOutputFlag(clusterreconcilehistoryCmd, &repairHistoryOutput, "table", "json")
+}
@@ -9,7 +9,9 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
 
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
 	"github.com/spf13/cobra"
 )
 
@@ -22,6 +24,11 @@ var (
 	waitBetweenSeconds int
 	dryRun             bool
 	updateSecrets      bool
+	upgradeOutput      string
+	onFailure          string
+	healthTimeout      int
+	upgradeWait        bool
+	upgradeWaitTimeout int
 )
 
 // clusterupgradeCmd represents the clusterupgrade command.
@@ -33,18 +40,44 @@ Orchestrates a rolling upgrade of all cluster nodes to the specified Talos versi
 
 This command will:
 - Discover the appropriate Talos AMI for the target version
-- Upgrade control plane nodes sequentially (one at a time for etcd quorum safety)
-- Upgrade worker nodes with configurable concurrency
+- Upgrade nodes one at a time, control plane nodes first (unless --control-plane-first=false)
+- After each node, wait for it to be Ready in Kubernetes with healthy load balancer targets before moving on
 - Optionally update Vault secrets after successful upgrade
 
 Example:
   k8sctl cluster upgrade cluster1 --version v1.10.8
-  k8sctl cluster upgrade cluster1 --version v1.10.8 --control-plane-first --max-concurrent 3
+  k8sctl cluster upgrade cluster1 --version v1.10.8 --control-plane-first=false
   k8sctl cluster upgrade cluster1 --version v1.10.8 --dry-run
+  k8sctl cluster upgrade cluster1 --version v1.10.8 --output json
+  k8sctl cluster upgrade cluster1 --version v1.10.8 --on-failure rollback
+  k8sctl cluster upgrade cluster1 --version v1.10.8 --health-timeout 900
+
+The result lists each node with its previous and target version, status (upgraded, skipped, or failed), and duration.
+The command exits non-zero if any node failed to upgrade.
+
+With --dry-run, nothing is upgraded: unless --output is json, the result starts with a summary of what the upgrade
+would do, e.g. "3 node(s) would be upgraded from v1.10.7 to v1.10.8", followed by the per-node table.
+
+With --on-failure rollback, the first node failure stops the upgrade, and every node already upgraded is
+reverted to the version it was running before.  With --on-failure fail-fast, the first node failure stops the upgrade,
+leaving the nodes already upgraded as they are.  The default, --on-failure continue, upgrades the remaining nodes.
+The nodes a stopped upgrade didn't get to are listed as not tried.
+
+A node that isn't healthy within --health-timeout seconds always aborts the rest of the upgrade.
+
+With --wait, once every node has been upgraded (or skipped) without failures, the command waits, up to --wait-timeout
+seconds (default 900), for every node in the cluster to be Ready in Kubernetes with healthy load balancer targets, and
+exits non-zero if the cluster doesn't recover in time, listing what's still unhealthy.  So an exit of 0 means the
+cluster is upgraded and healthy, with no need to poll it afterwards:
+  k8sctl cluster upgrade cluster1 --version v1.10.8 --wait
+
+Upgrades have no client timeout unless --timeout-seconds is given, and the server writes keep-alives so proxies keep the
+connection open.  If k8sctl is interrupted or disconnected anyway, the upgrade carries on on the server.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) > 0 {
-			if cluster == "" {
+			// An argument beats $K8SCTL_CLUSTER, but not -c
+			if !cmd.Flags().Changed("cluster") {
 				cluster = args[0]
 			}
 
@@ -68,7 +101,19 @@ Example:
 			log.Fatalf("Version is required. Use --version flag.")
 		}
 
+		if upgradeOutput != "table" && upgradeOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table or json.", upgradeOutput)
+		}
+
 		baseURL := getServerBaseURL(cluster)
+		checkServerIdentity(baseURL, cluster)
+
+		if !dryRun {
+			confirmDestructive(fmt.Sprintf("upgrade cluster %s", cluster), []string{"Cluster: " + cluster, "Version: " + upgradeVersion}, cluster)
+		}
+
+		startUpgrade(cmd, fmt.Sprintf("cluster %s", cluster))
+
 		serverURL := fmt.Sprintf("%s/%s/cluster/%s/upgrade", baseURL, apiVersion, cluster)
 
 		if verbose {
@@ -86,43 +131,105 @@ Example:
 			"wait_between":        waitBetweenSeconds,
 			"dry_run":             dryRun,
 			"update_secrets":      updateSecrets,
+			"on_failure":          onFailure,
+			"health_timeout":      healthTimeout,
 			"verbose":             verbose,
+			"region":              getClusterRegion(cluster),
+			"keep_alive":          true,
+			"wait":                upgradeWait,
+			"wait_timeout":        upgradeWaitTimeout,
 		}
 
-		dataBytes, err := json.Marshal(data)
-		if err != nil {
-			log.Fatalf("unable to marshal post data: %s", err)
-		}
+		runRollingUpgrade(serverURL, token, data)
+	},
+}
+
+// upgradeContinuesNote is shown when an upgrade's request fails, since the server carries on with the upgrade anyway.
+const upgradeContinuesNote = "The upgrade carries on on the server regardless; check on it with 'k8sctl cluster describe'."
+
+// runRollingUpgrade posts a rolling upgrade, of a cluster or of some of its nodes, and prints its per-node result,
+// exiting non-zero if it didn't succeed.
+func runRollingUpgrade(serverURL string, token string, data map[string]interface{}) {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		log.Fatalf("unable to marshal post data: %s", err)
+	}
 
-		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+	resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+	if err != nil {
+		log.Fatalf("failed making authenticated request: %s\n%s", err, upgradeContinuesNote)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatalf("failed reading response body: %s\n%s", err, upgradeContinuesNote)
+	}
+
+	var result k8sctl.ClusterUpgradeResult
+	if resp.StatusCode != http.StatusOK {
+		// An upgrade that stopped part way still reports the nodes it got to
+		if json.Unmarshal(body, &result) != nil || result.Error == "" {
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+	} else {
+		err = json.Unmarshal(body, &result)
 		if err != nil {
-			log.Fatalf("failed making authenticated request: %s", err)
+			log.Fatalf("Failed unmarshalling upgrade result: %s", err)
 		}
-		defer resp.Body.Close()
+	}
 
-		body, err := io.ReadAll(resp.Body)
+	if upgradeOutput == "json" || outputFile != "" {
+		out, marshalErr := json.MarshalIndent(result, "", "  ")
+		if marshalErr != nil {
+			log.Fatalf("unable to marshal upgrade result: %s", marshalErr)
+		}
+		err = writeResult(out)
 		if err != nil {
-			log.Fatalf("failed reading response body: %s", err)
+			log.Fatalf("Failed writing result: %s", err)
 		}
-
-		if resp.StatusCode != http.StatusOK {
-			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+	} else {
+		if result.DryRun {
+			printDryRunSummary(result.DryRunSummary())
+			fmt.Println()
 		}
+		result.ConsolePrint()
+	}
 
-		fmt.Printf("%s\n", body)
-	},
+	if !result.Succeeded() {
+		os.Exit(1)
+	}
+}
+
+// startUpgrade lifts the client timeout for an upgrade, unless --timeout-seconds was given, since upgrading even one
+// node can take longer than the default.  The server keeps the connection open meanwhile with keep-alives.
+func startUpgrade(cmd *cobra.Command, target string) {
+	if !cmd.Flags().Changed("timeout-seconds") {
+		timeoutSeconds = 0
+	}
+
+	// stderr, so it can't get mixed up with a JSON result
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Upgrading %s to %s.  This can take a while; if k8sctl is interrupted or disconnected, the upgrade carries on on the server.\n", target, upgradeVersion)
+	}
 }
 
 func init() {
 	clusterCmd.AddCommand(clusterupgradeCmd)
 	clusterupgradeCmd.Flags().StringVar(&upgradeVersion, "version", "", "Target Talos version (e.g., v1.10.8)")
 	clusterupgradeCmd.Flags().BoolVar(&controlPlaneFirst, "control-plane-first", true, "Upgrade control plane nodes before workers")
-	clusterupgradeCmd.Flags().IntVar(&maxConcurrent, "max-concurrent", 1, "Maximum concurrent node upgrades (control plane always 1)")
+	clusterupgradeCmd.Flags().IntVar(&maxConcurrent, "max-concurrent", 1, "Maximum concurrent node upgrades. Only 1 is supported: the server rejects higher values")
 	clusterupgradeCmd.Flags().BoolVar(&preserve, "preserve", true, "Preserve ephemeral data during upgrade")
 	clusterupgradeCmd.Flags().BoolVar(&stage, "stage", false, "Stage upgrade and reboot later")
 	clusterupgradeCmd.Flags().IntVar(&waitBetweenSeconds, "wait-between", 30, "Wait duration in seconds between node upgrades")
 	clusterupgradeCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Simulate the upgrade without executing")
 	clusterupgradeCmd.Flags().BoolVar(&updateSecrets, "update-secrets", true, "Update Vault secrets after successful upgrade")
+	clusterupgradeCmd.Flags().StringVar(&onFailure, "on-failure", "continue", "What to do when a node fails: continue, fail-fast, or rollback the nodes already upgraded")
+	clusterupgradeCmd.Flags().IntVar(&healthTimeout, "health-timeout", 600, "Seconds to wait for each upgraded node to be Ready with healthy LB targets before aborting (0 disables)")
+	clusterupgradeCmd.Flags().BoolVar(&upgradeWait, "wait", false, "After upgrading, wait for every node to be Ready with healthy LB targets, failing if the cluster doesn't recover")
+	clusterupgradeCmd.Flags().IntVar(&upgradeWaitTimeout, "wait-timeout", 900, "Seconds --wait waits for the cluster to recover")
+	addOutputFlag(clusterupgradeCmd, &upgradeOutput, "table", "json")
+	addConfirmFlag(clusterupgradeCmd)
 
 	err := clusterupgradeCmd.MarkFlagRequired("version")
 	if err != nil {
@@ -0,0 +1,95 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/spf13/cobra"
+)
+
+// completionCmd represents the completion command.
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate shell completion scripts",
+	Long: `Generate a shell completion script for k8sctl.
+
+Completes subcommands and flags, plus cluster names (from the k8sctl config file) for --cluster, and node roles for --role.
+
+Bash:
+  source <(k8sctl completion bash)
+
+  # To load completions for every session, on Linux:
+  k8sctl completion bash > /etc/bash_completion.d/k8sctl
+  # On macOS:
+  k8sctl completion bash > $(brew --prefix)/etc/bash_completion.d/k8sctl
+
+Zsh:
+  # If shell completion is not already enabled, enable it once with:
+  echo "autoload -U compinit; compinit" >> ~/.zshrc
+
+  k8sctl completion zsh > "${fpath[1]}/_k8sctl"
+
+Fish:
+  k8sctl completion fish | source
+
+  # To load completions for every session:
+  k8sctl completion fish > ~/.config/fish/completions/k8sctl.fish
+
+PowerShell:
+  k8sctl completion powershell | Out-String | Invoke-Expression
+`,
+	DisableFlagsInUseLine: true,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		switch args[0] {
+		case "bash":
+			err = cmd.Root().GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			err = cmd.Root().GenZshCompletion(os.Stdout)
+		case "fish":
+			err = cmd.Root().GenFishCompletion(os.Stdout, true)
+		case "powershell":
+			err = cmd.Root().GenPowerShellCompletionWithDesc(os.Stdout)
+		}
+
+		return err
+	},
+}
+
+// completeClusterNames completes --cluster with the cluster names in the k8sctl config file.
+func completeClusterNames(cmd *cobra.Command, args []string, toComplete string) (names []string, directive cobra.ShellCompDirective) {
+	directive = cobra.ShellCompDirectiveNoFileComp
+
+	cfg, err := loadConfig()
+	if err != nil || cfg == nil {
+		return names, directive
+	}
+
+	for name := range cfg.Clusters {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, directive
+}
+
+// completeNodeRoles completes --role with the node roles.
+func completeNodeRoles(cmd *cobra.Command, args []string, toComplete string) (roles []string, directive cobra.ShellCompDirective) {
+	roles = []string{manager.NodeRoleCp, manager.NodeRoleWorker}
+	directive = cobra.ShellCompDirectiveNoFileComp
+
+	return roles, directive
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
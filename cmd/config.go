@@ -0,0 +1,21 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// configCmd represents the config command.
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "k8sctl Config File Commands",
+	Long: `
+k8sctl Config File Commands
+`,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+}
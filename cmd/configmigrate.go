@@ -0,0 +1,62 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/nikogura/k8sctl/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// configMigrateCmd represents the config migrate command.
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate [<config file>]",
+	Short: "Upgrade a config file to the current schema version",
+	Long: `
+Upgrade a k8sctl config file to the schema version this k8sctl understands, in place.
+
+Without a file, the config k8sctl would load is migrated: --config, $K8SCTL_CONFIG, ./k8sctl.yaml,
+~/.config/k8sctl/config.yaml, or /etc/k8sctl/config.yaml, whichever is found first.
+
+Comments and settings this k8sctl doesn't know are kept.  The original file is saved alongside it, with a .bak suffix.
+A config newer than this k8sctl understands is left alone.
+
+Example:
+  k8sctl config migrate
+  k8sctl config migrate ./k8sctl.yaml
+`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := config.DefaultPath()
+		if configFile != "" {
+			path = configFile
+		}
+
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		if path == "" {
+			log.Fatalf("No config file found.  Give the path of the config file to migrate.")
+		}
+
+		from, migrated, err := config.Migrate(path)
+		if err != nil {
+			log.Fatalf("Failed migrating config: %s", err)
+		}
+
+		if !migrated {
+			fmt.Printf("%s is already version %d\n", path, from)
+			return
+		}
+
+		fmt.Printf("Migrated %s from version %d to %d (original saved as %s.bak)\n", path, from, config.CurrentVersion, path)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configMigrateCmd)
+}
@@ -0,0 +1,52 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var assumeYes bool
+
+// addConfirmFlag adds --yes, to skip the confirmation prompt, to a destructive command.
+func addConfirmFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Don't ask for confirmation (required when stdin isn't a terminal)")
+}
+
+// confirmDestructive asks the operator to confirm a destructive command by typing the name of the node or cluster it
+// acts on, and exits unless they do.  --yes skips the prompt.  Without a terminal to ask on, as in scripts, --yes is
+// required, so a script can't destroy anything by accident.  The prompt goes to stderr, keeping stdout for results.
+func confirmDestructive(action string, details []string, name string) {
+	if assumeYes {
+		return
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		log.Fatalf("Refusing to %s without confirmation: stdin isn't a terminal, so give --yes to confirm.", action)
+	}
+
+	fmt.Fprintf(os.Stderr, "About to %s:\n", action)
+	for _, detail := range details {
+		fmt.Fprintf(os.Stderr, "  %s\n", detail)
+	}
+	fmt.Fprintf(os.Stderr, "Type %q to confirm: ", name)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Fatalf("Failed reading confirmation: %s", err)
+	}
+
+	if strings.TrimSpace(answer) != name {
+		log.Fatalf("Confirmation didn't match %q; nothing was done.", name)
+	}
+}
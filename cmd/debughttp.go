@@ -0,0 +1,54 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strings"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+)
+
+var debugHTTP bool
+
+// dumpRequest prints the request about to be sent to the server to stderr: its method, URL, headers, and body.  The
+// token in the Authorization header is redacted, unless it's being shown anyway with --show-token.
+func dumpRequest(req *http.Request, body string) {
+	dumped := req.Clone(req.Context())
+	if token, found := strings.CutPrefix(dumped.Header.Get("Authorization"), "Bearer "); found && !showToken {
+		dumped.Header.Set("Authorization", "Bearer "+k8sctl.RedactToken(token))
+	}
+
+	dump, err := httputil.DumpRequestOut(dumped, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "DEBUG HTTP: failed dumping request: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "DEBUG HTTP: request to %s\n%s%s\n\n", req.URL, dump, body)
+}
+
+// dumpResponse prints the server's response to stderr: its status and headers at once, and its body as the caller
+// reads it, so streamed responses like node logs still stream.
+func dumpResponse(resp *http.Response) {
+	dump, err := httputil.DumpResponse(resp, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "DEBUG HTTP: failed dumping response: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "DEBUG HTTP: response\n%s", dump)
+
+	resp.Body = dumpedBody{Reader: io.TeeReader(resp.Body, os.Stderr), Closer: resp.Body}
+}
+
+// dumpedBody is a response body copied to stderr as it's read.
+type dumpedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,49 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// serverLogLevels maps the server's log levels to the levels of its two loggers: logrus for the handlers, and zap for
+// the OIDC middleware.  zap has no trace level, so it logs debug for trace.
+var serverLogLevels = map[string]struct {
+	logrus logrus.Level
+	zap    zapcore.Level
+}{
+	"trace": {logrus.TraceLevel, zapcore.DebugLevel},
+	"debug": {logrus.DebugLevel, zapcore.DebugLevel},
+	"info":  {logrus.InfoLevel, zapcore.InfoLevel},
+	"warn":  {logrus.WarnLevel, zapcore.WarnLevel},
+	"error": {logrus.ErrorLevel, zapcore.ErrorLevel},
+}
+
+// applyLogLevel sets the handler logs to a log level, case-insensitively, and returns a zap logger for the OIDC
+// middleware at the same level.
+func applyLogLevel(level string) (logger *zap.Logger, err error) {
+	levels, ok := serverLogLevels[strings.ToLower(level)]
+	if !ok {
+		err = fmt.Errorf("unknown log level %q: use one of Trace, Debug, Info, Warn, or Error", level)
+		return logger, err
+	}
+
+	logrus.SetLevel(levels.logrus)
+
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.Level = zap.NewAtomicLevelAt(levels.zap)
+
+	logger, err = zapConfig.Build()
+	if err != nil {
+		err = fmt.Errorf("failed to create logger: %w", err)
+		return logger, err
+	}
+
+	return logger, err
+}
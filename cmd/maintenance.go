@@ -0,0 +1,112 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var maintenanceReason string
+
+var maintenanceOutput string
+
+// maintenanceCmd represents the maintenance command.
+var maintenanceCmd = &cobra.Command{
+	Use:       "maintenance [on|off]",
+	Short:     "Show, or turn on or off, the server's maintenance mode",
+	ValidArgs: []string{"on", "off"},
+	Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+	Long: `
+Show whether the k8sctl server is in maintenance mode, or turn it on or off.
+
+In maintenance mode, the server refuses the operations that change clusters (create, delete, glass, upgrade, cordon,
+uncordon, retag, secrets sync, run, and reconcile with --fix-tags) with a 503, telling the caller the --reason, since
+when, and who turned it on.  Describes, monitors, and status checks keep working.  Turning it on or off needs one of
+the server's admin groups, and who did is logged.
+
+Example:
+  k8sctl -c cluster1 maintenance
+  k8sctl -c cluster1 maintenance on --reason "AWS account migration"
+  k8sctl -c cluster1 maintenance off
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if maintenanceOutput != "text" && maintenanceOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use text or json.", maintenanceOutput)
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/maintenance", baseURL, apiVersion)
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+		}
+
+		method := "GET"
+		var data string
+		if len(args) > 0 {
+			dataBytes, marshalErr := json.Marshal(k8sctl.MaintenanceBody{Enabled: args[0] == "on", Reason: maintenanceReason})
+			if marshalErr != nil {
+				log.Fatalf("unable to marshal post data: %s", marshalErr)
+			}
+
+			method = "POST"
+			data = string(dataBytes)
+		}
+
+		resp, err := makeAuthenticatedRequest(method, serverURL, data, token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		if maintenanceOutput == "json" || outputFile != "" {
+			err = writeResult(body)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+			return
+		}
+
+		var status k8sctl.MaintenanceStatus
+		err = json.Unmarshal(body, &status)
+		if err != nil {
+			log.Fatalf("Failed unmarshalling maintenance status: %s", err)
+		}
+
+		status.ConsolePrint()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(maintenanceCmd)
+	maintenanceCmd.Flags().StringVar(&maintenanceReason, "reason", "", "Why maintenance mode is being turned on, told to the callers it refuses")
+	addOutputFlag(maintenanceCmd, &maintenanceOutput, "text", "json")
+}
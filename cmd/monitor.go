@@ -4,17 +4,34 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
 
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
 	"github.com/spf13/cobra"
 )
 
 var monitorInterval int
 
+var monitorCache bool
+
+var monitorOnce bool
+
+var monitorWebhookURL string
+
+var monitorWebhookReminder int
+
+var monitorCheckTimeout int
+
 // monitorCmd represents the monitor command.
 var monitorCmd = &cobra.Command{
 	Use:   "monitor [<cluster name>]",
@@ -27,11 +44,33 @@ Continuously monitor cluster health by periodically checking:
 - Discrepancies between systems
 
 The monitor will run indefinitely, checking every interval (default 60 seconds).
-Press Ctrl+C to stop monitoring.
+Press Ctrl+C to stop monitoring: a summary of the session is printed, with how many checks were made, how many found
+issues, and the kind of issue found most often.
+
+Each check is given --check-timeout seconds (default 30).  A check taking longer, e.g. on a hung AWS call, is abandoned
+with a "check timed out" line, counting as a failed check, and the monitor carries on with the next.
+
+With --cache, checks may reuse AWS data from the server's describe cache (if the server has one enabled), rather than
+querying AWS on every interval.
+
+With --once, a single check is run and printed, with its summary, and the exit code reports the result: 0 if the cluster is healthy, 1 if
+issues were found, and 2 if the check itself failed.  Handy for cron jobs.
+
+With --webhook-url (or the server's K8SCTL_MONITOR_WEBHOOK_URL), the server POSTs a JSON alert describing the issues
+when the cluster becomes unhealthy, again every --webhook-reminder seconds while it stays unhealthy, and once more when
+it recovers.  The alert's "text" field summarizes it, so a Slack incoming webhook can be used directly.
+A --webhook-url must be https, and the server only POSTs to it at a public address.
+
+With --role, --purpose, or --name-prefix, only the matching nodes are checked.  --purpose matches the Kubernetes node's
+purpose label, so EC2 instances without a Kubernetes node are left out.
+
+With --since, e.g. --since 30m, only the nodes whose EC2 instance launched, or that joined Kubernetes, within that long
+before each check are checked: handy for watching a rollout's new nodes.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) > 0 {
-			if cluster == "" {
+			// An argument beats $K8SCTL_CLUSTER, but not -c
+			if !cmd.Flags().Changed("cluster") {
 				cluster = args[0]
 			}
 
@@ -60,35 +99,107 @@ Press Ctrl+C to stop monitoring.
 		}
 
 		data := map[string]interface{}{
-			"verbose":  verbose,
-			"interval": monitorInterval,
+			"verbose":          verbose,
+			"region":           getClusterRegion(cluster),
+			"interval":         monitorInterval,
+			"cache":            monitorCache,
+			"once":             monitorOnce,
+			"webhook_url":      monitorWebhookURL,
+			"webhook_reminder": monitorWebhookReminder,
+			"no_color":         usePlainOutput(),
+			"check_timeout":    monitorCheckTimeout,
 		}
+		addNodeSelector(data)
 
 		dataBytes, err := json.Marshal(data)
 		if err != nil {
 			log.Fatalf("unable to marshal post data: %s", err)
 		}
 
+		// A continuous monitor runs until it's interrupted
+		if !monitorOnce && !cmd.Flags().Changed("timeout-seconds") {
+			timeoutSeconds = 0
+		}
+
 		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
 		if err != nil {
 			log.Fatalf("failed making authenticated request: %s", err)
 		}
 		defer resp.Body.Close()
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatalf("failed reading response body: %s", err)
-		}
-
 		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
 			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
 		}
 
-		fmt.Printf("%s\n", body)
+		if monitorOnce {
+			// The server ends a single check with its summary
+			_, err = io.Copy(os.Stdout, resp.Body)
+			if err != nil {
+				log.Fatalf("failed reading response body: %s", err)
+			}
+
+			os.Exit(monitorExitCode(resp.Trailer.Get(k8sctl.MonitorResultTrailer)))
+		}
+
+		streamMonitor(resp.Body)
 	},
 }
 
+// streamMonitor prints a continuous monitor's checks as they arrive.  The session ends when it's interrupted, or the
+// server goes away, which the server can't write a summary for, so the summary is tallied from the stream here.
+func streamMonitor(stream io.Reader) {
+	var summary k8sctl.MonitorSummary
+	var mu sync.Mutex
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-interrupted
+		mu.Lock()
+		fmt.Printf("\n%s\n", summary.String())
+		// As if the interrupt had killed it
+		os.Exit(130)
+	}()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		mu.Lock()
+		fmt.Println(scanner.Text())
+		summary.ScanLine(scanner.Text())
+		mu.Unlock()
+	}
+
+	mu.Lock()
+	fmt.Printf("\n%s\n", summary.String())
+
+	if scanner.Err() != nil {
+		log.Fatalf("failed reading monitor stream: %s", scanner.Err())
+	}
+}
+
+// monitorExitCode maps a one-shot monitor run's result to an exit code: 0 healthy, 1 issues found, 2 the check failed.
+func monitorExitCode(result string) (code int) {
+	issues, err := strconv.Atoi(result)
+	switch {
+	case err != nil:
+		// Check failed, or the server didn't report a result
+		code = 2
+	case issues > 0:
+		code = 1
+	}
+
+	return code
+}
+
 func init() {
 	rootCmd.AddCommand(monitorCmd)
 	monitorCmd.Flags().IntVarP(&monitorInterval, "interval", "i", 60, "Monitoring interval in seconds")
+	monitorCmd.Flags().BoolVar(&monitorOnce, "once", false, "Run a single check and exit, non-zero if issues were found")
+	monitorCmd.Flags().StringVar(&monitorWebhookURL, "webhook-url", "", "https webhook, at a public address, to POST alerts to when the cluster becomes unhealthy or recovers (defaults to the server's)")
+	monitorCmd.Flags().IntVar(&monitorWebhookReminder, "webhook-reminder", 3600, "Seconds between reminder alerts while the cluster stays unhealthy")
+	monitorCmd.Flags().IntVar(&monitorCheckTimeout, "check-timeout", 30, "Seconds each check may take before it's abandoned")
+	monitorCmd.Flags().BoolVar(&monitorCache, "cache", false, "Reuse recent AWS data from the server's describe cache between checks")
+	addNodeSelectorFlags(monitorCmd)
 }
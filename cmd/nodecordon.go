@@ -0,0 +1,117 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var cordonDrain bool
+var cordonDrainTimeout int
+
+// nodecordonCmd represents the nodecordon command.
+var nodecordonCmd = &cobra.Command{
+	Use:   "cordon [<node name>]",
+	Short: "Mark a K8s node unschedulable",
+	Long: `
+Mark a K8s node unschedulable, so no new pods are scheduled on it.  Pods already running on the node are left alone.
+
+With --drain, pods on the node are also evicted, honouring PodDisruptionBudgets.  DaemonSet and mirror pods are left in place.
+If the drain fails part way, the node is left cordoned, and the pods evicted so far are reported along with the error.
+
+Example:
+  k8sctl -c cluster1 node cordon cluster1-worker-2
+  k8sctl -c cluster1 node cordon cluster1-worker-2 --drain
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runNodeSchedulable("cordon", args, k8sctl.NodeCordonBody{
+			Drain:        cordonDrain,
+			DrainTimeout: cordonDrainTimeout,
+			Verbose:      verbose,
+		})
+	},
+}
+
+func init() {
+	nodeCmd.AddCommand(nodecordonCmd)
+	nodecordonCmd.Flags().BoolVar(&cordonDrain, "drain", false, "Also evict pods from the node")
+	nodecordonCmd.Flags().IntVar(&cordonDrainTimeout, "drain-timeout", 300, "Seconds to keep retrying evictions blocked by disruption budgets")
+}
+
+// runNodeSchedulable posts a cordon or uncordon request for a node.  The verb is the last path element of the endpoint.
+func runNodeSchedulable(verb string, args []string, data k8sctl.NodeCordonBody) {
+	if len(args) > 0 {
+		if nodeName == "" {
+			nodeName = args[0]
+		}
+	}
+
+	if cluster == "" {
+		log.Fatalf("Cluster name is required. Use -c flag.")
+	}
+
+	// Get OIDC token
+	token, err := getOIDCToken()
+	if err != nil {
+		log.Fatalf("Failed to get OIDC token: %v", err)
+	}
+
+	if showToken {
+		fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+	}
+
+	if nodeName == "" {
+		log.Fatalf("Node name is required. Use -n flag or provide as argument.")
+	}
+
+	baseURL := getServerBaseURL(cluster)
+	serverURL := fmt.Sprintf("%s/%s/cluster/%s/node/%s/%s", baseURL, apiVersion, cluster, verb, nodeName)
+
+	// Draining evicts the node's pods
+	if data.Drain {
+		checkServerIdentity(baseURL, cluster)
+	}
+
+	if verbose {
+		fmt.Printf("Target URL: %s\n", serverURL)
+		fmt.Printf("Cluster: %s\n", cluster)
+		fmt.Printf("Node: %s\n", nodeName)
+	}
+
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		log.Fatalf("unable to marshal post data: %s", err)
+	}
+
+	resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+	if err != nil {
+		log.Fatalf("failed making authenticated request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatalf("failed reading response body: %s", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		// A failed drain still returns the partial result, so show it before exiting.
+		var result k8sctl.NodeCordonResult
+		if json.Unmarshal(body, &result) == nil && result.Error != "" {
+			fmt.Printf("%s\n", body)
+			log.Fatalf("%s %s failed: node left unschedulable=%t: %s", verb, result.Node, result.Unschedulable, result.Error)
+		}
+
+		log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	fmt.Printf("%s\n", body)
+}
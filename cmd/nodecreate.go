@@ -4,11 +4,15 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/nikogura/k8sctl/pkg/k8sctl"
 
@@ -17,12 +21,77 @@ import (
 
 var roleName string
 
+var idempotencyKey string
+
+var spot bool
+
+var spotMaxPrice string
+
+var availabilityZone string
+
+var subnetID string
+
+var nodeTags []string
+
+var nodeLabels []string
+
+var nodeTargetGroups []string
+
+// parseKeyValues parses repeated key=value flags into a map.  A value may contain =, but a key may not.
+func parseKeyValues(flag string, pairs []string) (values map[string]string, err error) {
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			err = fmt.Errorf("invalid --%s %q: expected key=value", flag, pair)
+			return values, err
+		}
+
+		if values == nil {
+			values = make(map[string]string)
+		}
+
+		if _, dup := values[key]; dup {
+			err = fmt.Errorf("--%s %s given more than once", flag, key)
+			return values, err
+		}
+
+		values[key] = value
+	}
+
+	return values, err
+}
+
 // nodecreateCmd represents the nodecreate command.
 var nodecreateCmd = &cobra.Command{
 	Use:   "create [<node name>]",
 	Short: "Create a new K8s node",
 	Long: `
 Create a new K8s node
+
+Each create is sent with an idempotency key, by default derived from the request, so re-running a create that timed out
+within an hour returns the first attempt's result, rather than launching a second instance.  A create that failed
+before launching an instance, e.g. when AWS throttled it, isn't remembered, so re-running it tries again.  One that
+failed after launching is, and re-running it replays the failure.  To create a node again after deleting it within
+that hour, or after such a failure, give a new key with --idempotency-key.
+
+Workers can be launched as spot instances with --spot, optionally capped at --spot-max-price USD per hour (default the
+on-demand price).  An interrupted spot node is terminated, not stopped.  Control plane nodes can't be spot.
+
+By default the node is launched in the node config's subnet.  Spread nodes across availability zones with
+--availability-zone, which picks the zone's subnet in the same VPC, public or private like the node config's, or give
+the subnet itself with --subnet.
+
+Add EC2 tags to the instance with --tag, e.g. for cost allocation, and Kubernetes labels to the node with --label,
+e.g. for scheduling, each repeated as needed.  The node registers with its labels.  The Name, Cluster, and purpose tags
+and the purpose label are k8sctl's own, and can't be given.
+
+Register the node with load balancer target groups beyond those its role gets with --target-group, by ARN or name,
+repeated as needed, e.g. for a canary.  Each must exist and be attached to a load balancer, or nothing is launched.
+The instance is tagged with them, so describe shows the node in their load balancers.
+
+Example:
+  k8sctl -c cluster1 node create cluster1-worker-4 --tag team=platform --tag cost-center=1234 --label team=platform
+  k8sctl -c cluster1 node create cluster1-worker-5 --target-group canary-ingress-443
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) > 0 {
@@ -49,6 +118,16 @@ Create a new K8s node
 			log.Fatalf("Node name is required. Use -n flag or provide as argument.")
 		}
 
+		tags, err := parseKeyValues("tag", nodeTags)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		labels, err := parseKeyValues("label", nodeLabels)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
 		baseURL := getServerBaseURL(cluster)
 		serverURL := fmt.Sprintf("%s/%s/cluster/%s/node/create", baseURL, apiVersion, cluster)
 
@@ -57,15 +136,24 @@ Create a new K8s node
 			fmt.Printf("Cluster: %s\n", cluster)
 			fmt.Printf("Node: %s\n", nodeName)
 			fmt.Printf("Role: %s\n", roleName)
+			fmt.Printf("Spot: %t\n", spot)
 		}
 
 		data := k8sctl.NodeCreateBody{
-			Name:          nodeName,
-			Role:          roleName,
-			Verbose:       verbose,
-			CloudProvider: "aws",
-			Type:          nodeType,
-			Purpose:       purpose,
+			Name:             nodeName,
+			Role:             roleName,
+			Verbose:          verbose,
+			Region:           getClusterRegion(cluster),
+			CloudProvider:    "aws",
+			Type:             nodeType,
+			Purpose:          purpose,
+			Spot:             spot,
+			SpotMaxPrice:     spotMaxPrice,
+			AvailabilityZone: availabilityZone,
+			SubnetID:         subnetID,
+			Tags:             tags,
+			Labels:           labels,
+			TargetGroups:     nodeTargetGroups,
 		}
 
 		dataBytes, err := json.Marshal(data)
@@ -73,18 +161,35 @@ Create a new K8s node
 			log.Fatalf("unable to marshal post data: %s", err)
 		}
 
-		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+		// The same create gets the same key, so an operator's retry is recognized as one
+		key := idempotencyKey
+		if key == "" {
+			sum := sha256.Sum256([]byte(serverURL + "\n" + string(dataBytes)))
+			key = "k8sctl-" + hex.EncodeToString(sum[:16])
+		}
+
+		headers := map[string]string{k8sctl.IdempotencyKeyHeader: key}
+
+		resp, err := makeAuthenticatedRequestWithHeaders("POST", serverURL, string(dataBytes), token, headers)
 		if err != nil {
-			log.Fatalf("failed making authenticated request: %s", err)
+			log.Fatalf("failed making authenticated request: %s\nRetrying the same create is safe: it won't launch a second instance.", err)
 		}
 		defer resp.Body.Close()
 
+		if resp.Header.Get(k8sctl.IdempotentReplayHeader) != "" && !quiet {
+			fmt.Fprintf(os.Stderr, "Node %s was already requested with idempotency key %s; this is that request's result.\n", nodeName, key)
+		}
+
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
 			log.Fatalf("failed reading response body: %s", err)
 		}
 
 		if resp.StatusCode != http.StatusOK {
+			// Only a create that launched an instance before failing is replayed
+			if resp.Header.Get(k8sctl.IdempotentReplayHeader) != "" && resp.StatusCode >= http.StatusInternalServerError {
+				log.Fatalf("request failed with status %d: %s\nThat create launched an instance before it failed, so retrying it only replays this.  Check the node with 'k8sctl node describe', and to create it again anyway, give a new --idempotency-key.", resp.StatusCode, body)
+			}
 			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
 		}
 
@@ -95,5 +200,15 @@ Create a new K8s node
 func init() {
 	nodeCmd.AddCommand(nodecreateCmd)
 	nodecreateCmd.Flags().StringVarP(&roleName, "role", "r", "worker", "Node role")
+	_ = nodecreateCmd.RegisterFlagCompletionFunc("role", completeNodeRoles)
+	nodecreateCmd.Flags().StringVar(&idempotencyKey, "idempotency-key", "", "Idempotency key for the create (default: derived from the request, so retries of the same create share it)")
+	nodecreateCmd.Flags().BoolVar(&spot, "spot", false, "Launch the node as a spot instance (workers only)")
+	nodecreateCmd.Flags().StringVar(&spotMaxPrice, "spot-max-price", "", "Most to pay for a spot instance, in USD per hour (default: the on-demand price)")
+	nodecreateCmd.Flags().StringVar(&availabilityZone, "availability-zone", "", "Availability zone to launch the node in (default: the node config subnet's)")
+	nodecreateCmd.Flags().StringVar(&subnetID, "subnet", "", "Subnet to launch the node in, instead of the node config's")
+	nodecreateCmd.MarkFlagsMutuallyExclusive("availability-zone", "subnet")
+	nodecreateCmd.Flags().StringArrayVar(&nodeTags, "tag", nil, "EC2 tag to add to the instance, as key=value (repeatable)")
+	nodecreateCmd.Flags().StringArrayVar(&nodeLabels, "label", nil, "Kubernetes label to add to the node, as key=value (repeatable)")
+	nodecreateCmd.Flags().StringArrayVar(&nodeTargetGroups, "target-group", nil, "Extra target group to register the node with, by ARN or name (repeatable)")
 
 }
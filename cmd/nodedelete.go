@@ -15,12 +15,18 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var nodeDryRun bool
+
 // nodedeleteCmd represents the nodedelete command.
 var nodedeleteCmd = &cobra.Command{
 	Use:   "delete [<node name>]",
 	Short: "Delete a K8s node",
 	Long: `
 Delete a K8s node
+
+With --dry-run, nothing is deleted: the instance that would be terminated, the load balancer target groups it would be
+deregistered from, the DNS records that would be removed, and the Kubernetes node that would be deleted are listed
+instead, with a warning if the node is in the control plane.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) > 0 {
@@ -48,6 +54,12 @@ Delete a K8s node
 		}
 
 		baseURL := getServerBaseURL(cluster)
+		checkServerIdentity(baseURL, cluster)
+
+		if !nodeDryRun {
+			confirmDestructive(fmt.Sprintf("delete node %s", nodeName), []string{"Cluster: " + cluster, "Node: " + nodeName}, nodeName)
+		}
+
 		serverURL := fmt.Sprintf("%s/%s/cluster/%s/node/delete/%s", baseURL, apiVersion, cluster, nodeName)
 
 		if verbose {
@@ -59,7 +71,9 @@ Delete a K8s node
 		data := k8sctl.NodeDeleteBody{
 			Name:          nodeName,
 			Verbose:       verbose,
+			Region:        getClusterRegion(cluster),
 			CloudProvider: "aws",
+			DryRun:        nodeDryRun,
 		}
 
 		dataBytes, err := json.Marshal(data)
@@ -82,12 +96,38 @@ Delete a K8s node
 			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
 		}
 
+		if nodeDryRun {
+			printDeletePlan(body)
+			return
+		}
+
 		fmt.Printf("%s\n", body)
 	},
 }
 
+// printDeletePlan prints a delete or glass dry run's plan, or writes it to --output-file.
+func printDeletePlan(body []byte) {
+	if outputFile != "" {
+		err := writeResult(body)
+		if err != nil {
+			log.Fatalf("Failed writing result: %s", err)
+		}
+		return
+	}
+
+	var plan k8sctl.NodeDeletePlan
+	err := json.Unmarshal(body, &plan)
+	if err != nil {
+		log.Fatalf("Failed unmarshalling plan: %s", err)
+	}
+
+	plan.ConsolePrint()
+}
+
 func init() {
 	nodeCmd.AddCommand(nodedeleteCmd)
+	nodedeleteCmd.Flags().BoolVar(&nodeDryRun, "dry-run", false, "Show what the delete would do, without deleting anything")
+	addConfirmFlag(nodedeleteCmd)
 
 	// Here you will define your flags and configuration settings.
 
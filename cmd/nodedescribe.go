@@ -75,7 +75,10 @@ List information about a K8s node.
 			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
 		}
 
-		fmt.Printf("%s\n", body)
+		err = writeResult(body)
+		if err != nil {
+			log.Fatalf("Failed writing result: %s", err)
+		}
 	},
 }
 
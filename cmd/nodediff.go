@@ -0,0 +1,106 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var diffRole string
+
+// nodediffCmd represents the nodediff command.
+var nodediffCmd = &cobra.Command{
+	Use:   "diff [<node name>]",
+	Short: "Compare a node's intended config with what it is actually running",
+	Long: `
+Compare the intended Talos machine config for a node (config.yaml, node-aws.yaml and patch.yaml
+for the node's role) against the machine config actually running on the node.
+
+Reports keys that were added on the node, removed from it, or changed.  Values of keys holding
+key material, tokens, or secrets are redacted.
+
+Example:
+  k8sctl -c cluster1 node diff cluster1-worker-2
+  k8sctl -c cluster1 node diff cluster1-cp-0 --role controlplane
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			if nodeName == "" {
+				nodeName = args[0]
+			}
+		}
+
+		if cluster == "" {
+			log.Fatalf("Cluster name is required. Use -c flag.")
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		if nodeName == "" {
+			log.Fatalf("Node name is required. Use -n flag or provide as argument.")
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/cluster/%s/node/diff/%s", baseURL, apiVersion, cluster, nodeName)
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+			fmt.Printf("Node: %s\n", nodeName)
+		}
+
+		data := k8sctl.NodeDiffBody{
+			Role:          diffRole,
+			Verbose:       verbose,
+			Region:        getClusterRegion(cluster),
+			CloudProvider: "aws",
+		}
+
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			log.Fatalf("unable to marshal post data: %s", err)
+		}
+
+		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		err = writeResult(body)
+		if err != nil {
+			log.Fatalf("Failed writing result: %s", err)
+		}
+	},
+}
+
+func init() {
+	nodeCmd.AddCommand(nodediffCmd)
+	nodediffCmd.Flags().StringVarP(&diffRole, "role", "r", "", "Node role (default: the instance's k8sctl:role tag, or if it has none, inferred from the node name)")
+	_ = nodediffCmd.RegisterFlagCompletionFunc("role", completeNodeRoles)
+}
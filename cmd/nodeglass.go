@@ -10,6 +10,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
 	"github.com/spf13/cobra"
 )
 
@@ -19,6 +20,9 @@ var nodeglassCmd = &cobra.Command{
 	Short: "Glass a K8s node (Destroy it and recreate it)",
 	Long: `
 Glass a K8s node (Destroy it and recreate it).
+
+With --dry-run, nothing is changed: what deleting the node would do is listed, as for node delete --dry-run, followed
+by the node that would be created in its place.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) > 0 {
@@ -46,6 +50,12 @@ Glass a K8s node (Destroy it and recreate it).
 		}
 
 		baseURL := getServerBaseURL(cluster)
+		checkServerIdentity(baseURL, cluster)
+
+		if !nodeDryRun {
+			confirmDestructive(fmt.Sprintf("glass (destroy and recreate) node %s", nodeName), []string{"Cluster: " + cluster, "Node: " + nodeName}, nodeName)
+		}
+
 		serverURL := fmt.Sprintf("%s/%s/cluster/%s/node/glass/%s", baseURL, apiVersion, cluster, nodeName)
 
 		if verbose {
@@ -54,7 +64,12 @@ Glass a K8s node (Destroy it and recreate it).
 			fmt.Printf("Node: %s\n", nodeName)
 		}
 
-		data := struct{}{}
+		data := k8sctl.NodeGlassBody{
+			Verbose: verbose,
+			Region:  getClusterRegion(cluster),
+			DryRun:  nodeDryRun,
+		}
+
 		dataBytes, err := json.Marshal(data)
 		if err != nil {
 			log.Fatalf("unable to marshal post data: %s", err)
@@ -75,12 +90,19 @@ Glass a K8s node (Destroy it and recreate it).
 			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
 		}
 
+		if nodeDryRun {
+			printDeletePlan(body)
+			return
+		}
+
 		fmt.Printf("%s\n", body)
 	},
 }
 
 func init() {
 	nodeCmd.AddCommand(nodeglassCmd)
+	nodeglassCmd.Flags().BoolVar(&nodeDryRun, "dry-run", false, "Show what the glass would do, without changing anything")
+	addConfirmFlag(nodeglassCmd)
 
 	// Here you will define your flags and configuration settings.
 
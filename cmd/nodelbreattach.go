@@ -0,0 +1,131 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var reattachUnhealthyOnly bool
+var reattachWait int
+var reattachOutput string
+
+// nodelbreattachCmd represents the node lb-reattach command.
+var nodelbreattachCmd = &cobra.Command{
+	Use:   "lb-reattach [<node name>]",
+	Short: "Deregister a node from its load balancer target groups and register it again",
+	Long: `
+Deregister a node from the cluster's load balancer target groups it's registered with, and register it again at once,
+with the same target and port.  For a target stuck unhealthy although the node itself is fine, this is a quicker,
+narrower fix than a reconcile or glassing the node.
+
+The node's state in each target group is shown before and after.  After registering, the targets are given --wait
+seconds (default 120) to pass or fail their health checks; those still being checked are shown as initial.
+
+With --unhealthy-only, only the target groups the node isn't healthy in are touched.
+
+Example:
+  k8sctl -c cluster1 node lb-reattach cluster1-worker-2
+  k8sctl -c cluster1 node lb-reattach cluster1-worker-2 --unhealthy-only --wait 300
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			if nodeName == "" {
+				nodeName = args[0]
+			}
+		}
+
+		if cluster == "" {
+			log.Fatalf("Cluster name is required. Use -c flag.")
+		}
+
+		if nodeName == "" {
+			log.Fatalf("Node name is required. Use -n flag or provide as argument.")
+		}
+
+		if reattachOutput != "table" && reattachOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table or json.", reattachOutput)
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/cluster/%s/node/lb-reattach/%s", baseURL, apiVersion, cluster, nodeName)
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+			fmt.Printf("Node: %s\n", nodeName)
+		}
+
+		data := k8sctl.NodeLBReattachBody{
+			Verbose:       verbose,
+			Region:        getClusterRegion(cluster),
+			UnhealthyOnly: reattachUnhealthyOnly,
+			Wait:          reattachWait,
+		}
+
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			log.Fatalf("unable to marshal post data: %s", err)
+		}
+
+		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		var result k8sctl.NodeLBReattachResult
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			log.Fatalf("Failed unmarshalling reattach result: %s", err)
+		}
+
+		if reattachOutput == "json" || outputFile != "" {
+			err = writeResult(body)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+		} else {
+			result.ConsolePrint()
+		}
+
+		if result.Failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	nodeCmd.AddCommand(nodelbreattachCmd)
+	nodelbreattachCmd.Flags().BoolVar(&reattachUnhealthyOnly, "unhealthy-only", false, "Only reattach the node to the target groups it isn't healthy in")
+	nodelbreattachCmd.Flags().IntVar(&reattachWait, "wait", 120, "Seconds to wait for the reattached targets to pass or fail their health checks")
+	addOutputFlag(nodelbreattachCmd, &reattachOutput, "table", "json")
+}
@@ -0,0 +1,139 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var logsSource string
+
+var logsService string
+
+var logsFollow bool
+
+var logsTail int
+
+// nodelogsCmd represents the nodelogs command.
+var nodelogsCmd = &cobra.Command{
+	Use:   "logs [<node name>]",
+	Short: "Show a node's serial console, kernel, or service logs",
+	Long: `
+Show a node's logs, to find out why it isn't joining or becoming Ready.
+
+By default this is the node's EC2 serial console output, which is there even if Talos never came up.  Once Talos is
+answering, read its kernel log with --source dmesg, or a service's log with --source service --service <name>.  Kernel
+and service logs can be followed with --follow, which streams new lines until interrupted.
+
+Example:
+  k8sctl -c cluster1 node logs cluster1-worker-7
+  k8sctl -c cluster1 node logs cluster1-worker-7 --source dmesg --follow
+  k8sctl -c cluster1 node logs cluster1-worker-7 --source service --service kubelet --tail 200
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			if nodeName == "" {
+				nodeName = args[0]
+			}
+		}
+
+		if cluster == "" {
+			log.Fatalf("Cluster name is required. Use -c flag.")
+		}
+
+		if nodeName == "" {
+			log.Fatalf("Node name is required. Use -n flag or provide as argument.")
+		}
+
+		if logsFollow {
+			if outputFile != "" {
+				log.Fatalf("--output-file can't be used with --follow; redirect the output instead")
+			}
+
+			// Followed logs stream until interrupted
+			if !cmd.Flags().Changed("timeout-seconds") {
+				timeoutSeconds = 0
+			}
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/cluster/%s/node/logs/%s", baseURL, apiVersion, cluster, nodeName)
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+			fmt.Printf("Node: %s\n", nodeName)
+		}
+
+		data := k8sctl.NodeLogsBody{
+			Source:    logsSource,
+			Service:   logsService,
+			Follow:    logsFollow,
+			TailLines: logsTail,
+			Verbose:   verbose,
+			Region:    getClusterRegion(cluster),
+		}
+
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			log.Fatalf("unable to marshal post data: %s", err)
+		}
+
+		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		if outputFile != "" {
+			body, readErr := io.ReadAll(resp.Body)
+			if readErr != nil {
+				log.Fatalf("failed reading response body: %s", readErr)
+			}
+
+			err = writeResult(body)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+			return
+		}
+
+		// Copied as it arrives, so followed logs show up as they're written
+		_, err = io.Copy(os.Stdout, resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading logs: %s", err)
+		}
+	},
+}
+
+func init() {
+	nodeCmd.AddCommand(nodelogsCmd)
+	nodelogsCmd.Flags().StringVar(&logsSource, "source", k8sctl.LogSourceConsole, "Logs to show: console, dmesg, or service")
+	nodelogsCmd.Flags().StringVar(&logsService, "service", "", "Talos service whose logs to show, with --source service (e.g. kubelet, etcd, machined)")
+	nodelogsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep streaming new lines (dmesg and service logs only)")
+	nodelogsCmd.Flags().IntVar(&logsTail, "tail", 0, "Only show the last lines of a service's log (default: all)")
+}
@@ -0,0 +1,118 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var podsOutput string
+
+// nodepodsCmd represents the nodepods command.
+var nodepodsCmd = &cobra.Command{
+	Use:   "pods [<node name>]",
+	Short: "List the pods on a node, to check what deleting it would disrupt",
+	Long: `
+List the pods running on a K8s node, with each one's owner, the PodDisruptionBudgets covering it, and its local
+storage (emptyDir and hostPath volumes).  Run it before deleting or glassing a node.
+
+Pods nothing would recreate (those without a controller, and static pods) and pods with local storage are marked with
+a ⚠ (or [WARN]): their data, or the pods themselves, don't survive the node.  EVICTABLE says whether
+'node cordon --drain' would evict the pod; DaemonSet and static pods stay until the node goes.
+
+Example:
+  k8sctl -c cluster1 node pods cluster1-worker-2
+  k8sctl -c cluster1 node pods cluster1-worker-2 -o json
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			if nodeName == "" {
+				nodeName = args[0]
+			}
+		}
+
+		if cluster == "" {
+			log.Fatalf("Cluster name is required. Use -c flag.")
+		}
+
+		if nodeName == "" {
+			log.Fatalf("Node name is required. Use -n flag or provide as argument.")
+		}
+
+		if podsOutput != "table" && podsOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table or json.", podsOutput)
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/cluster/%s/node/pods/%s", baseURL, apiVersion, cluster, nodeName)
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+			fmt.Printf("Node: %s\n", nodeName)
+		}
+
+		data := k8sctl.NodePodsBody{
+			Verbose: verbose,
+		}
+
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			log.Fatalf("unable to marshal post data: %s", err)
+		}
+
+		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		if podsOutput == "json" || outputFile != "" {
+			err = writeResult(body)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+			return
+		}
+
+		var result k8sctl.NodePodsResult
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			log.Fatalf("Failed unmarshalling node pods: %s", err)
+		}
+
+		result.ConsolePrint()
+	},
+}
+
+func init() {
+	nodeCmd.AddCommand(nodepodsCmd)
+	addOutputFlag(nodepodsCmd, &podsOutput, "table", "json")
+}
@@ -0,0 +1,120 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var nodeReconcileOutput string
+
+// nodereconcileCmd represents the nodereconcile command.
+var nodereconcileCmd = &cobra.Command{
+	Use:   "reconcile [<node name>]",
+	Short: "Check one node's state in EC2, Kubernetes, and the load balancers",
+	Long: `
+Check one node for the discrepancies 'cluster reconcile' looks for, without scanning the whole cluster:
+
+- Whether a running EC2 instance has its name, and the instance has the cluster's Cluster tag
+- Whether it's a Kubernetes node, and Ready
+- Whether it's a target of the load balancers its role belongs in, and those targets are healthy
+
+Control plane nodes belong in the API server load balancers, and workers in the rest.
+
+Example:
+  k8sctl -c cluster1 node reconcile cluster1-worker-2
+  k8sctl -c cluster1 node reconcile cluster1-worker-2 -o json
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			if nodeName == "" {
+				nodeName = args[0]
+			}
+		}
+
+		if cluster == "" {
+			log.Fatalf("Cluster name is required. Use -c flag.")
+		}
+
+		if nodeName == "" {
+			log.Fatalf("Node name is required. Use -n flag or provide as argument.")
+		}
+
+		if nodeReconcileOutput != "table" && nodeReconcileOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table or json.", nodeReconcileOutput)
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/cluster/%s/node/reconcile/%s", baseURL, apiVersion, cluster, nodeName)
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+			fmt.Printf("Node: %s\n", nodeName)
+		}
+
+		data := k8sctl.NodeReconcileBody{
+			Verbose: verbose,
+			Region:  getClusterRegion(cluster),
+		}
+
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			log.Fatalf("unable to marshal post data: %s", err)
+		}
+
+		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		if nodeReconcileOutput == "json" || outputFile != "" {
+			err = writeResult(body)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+			return
+		}
+
+		var result k8sctl.NodeReconcileResult
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			log.Fatalf("Failed unmarshalling node reconcile result: %s", err)
+		}
+
+		result.ConsolePrint()
+	},
+}
+
+func init() {
+	nodeCmd.AddCommand(nodereconcileCmd)
+	addOutputFlag(nodereconcileCmd, &nodeReconcileOutput, "table", "json")
+}
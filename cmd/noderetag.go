@@ -0,0 +1,141 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var retagName string
+var retagClusterTag string
+var retagOutput string
+
+// noderetagCmd represents the noderetag command.
+var noderetagCmd = &cobra.Command{
+	Use:   "retag [<node name>]",
+	Short: "Correct a node's Name, purpose, or Cluster tags without recreating it",
+	Long: `
+Correct the Name, purpose, or Cluster tag on a node's EC2 instance, without destroying and recreating it.
+
+--purpose also updates the Kubernetes node's purpose label and taint.  The Kubernetes node is named for the node's
+hostname, so --new-name only changes the EC2 Name tag: use it to make a mislabeled Name tag match the hostname.
+The new name is refused if another instance, or another Kubernetes node, already has it.
+
+Example:
+  k8sctl -c cluster1 node retag cluster1-worker-7 --new-name cluster1-worker-2
+  k8sctl -c cluster1 node retag cluster1-worker-2 --purpose ingress
+  k8sctl -c cluster1 node retag cluster1-worker-2 --cluster-tag cluster1
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			if nodeName == "" {
+				nodeName = args[0]
+			}
+		}
+
+		if cluster == "" {
+			log.Fatalf("Cluster name is required. Use -c flag.")
+		}
+
+		if nodeName == "" {
+			log.Fatalf("Node name is required. Use -n flag or provide as argument.")
+		}
+
+		if retagName == "" && purpose == "" && retagClusterTag == "" {
+			log.Fatalf("Nothing to retag. Use --new-name, --purpose, or --cluster-tag.")
+		}
+
+		if retagOutput != "table" && retagOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table or json.", retagOutput)
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/cluster/%s/node/retag/%s", baseURL, apiVersion, cluster, nodeName)
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+			fmt.Printf("Node: %s\n", nodeName)
+		}
+
+		data := k8sctl.NodeRetagBody{
+			Name:    retagName,
+			Purpose: purpose,
+			Cluster: retagClusterTag,
+			Verbose: verbose,
+			Region:  getClusterRegion(cluster),
+		}
+
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			log.Fatalf("unable to marshal post data: %s", err)
+		}
+
+		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		var result k8sctl.NodeRetagResult
+		if resp.StatusCode != http.StatusOK {
+			// A retag that changed the tags, but not the labels, still reports what it changed
+			if json.Unmarshal(body, &result) != nil || result.Error == "" {
+				log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+			}
+		} else {
+			err = json.Unmarshal(body, &result)
+			if err != nil {
+				log.Fatalf("Failed unmarshalling retag result: %s", err)
+			}
+		}
+
+		if retagOutput == "json" || outputFile != "" {
+			out, marshalErr := json.MarshalIndent(result, "", "  ")
+			if marshalErr != nil {
+				log.Fatalf("unable to marshal retag result: %s", marshalErr)
+			}
+			err = writeResult(out)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+		} else {
+			result.ConsolePrint()
+		}
+
+		if result.Error != "" {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	nodeCmd.AddCommand(noderetagCmd)
+	noderetagCmd.Flags().StringVar(&retagName, "new-name", "", "New EC2 Name tag for the node")
+	noderetagCmd.Flags().StringVar(&retagClusterTag, "cluster-tag", "", "New EC2 Cluster tag for the node")
+	addOutputFlag(noderetagCmd, &retagOutput, "table", "json")
+}
@@ -0,0 +1,30 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+// nodeuncordonCmd represents the nodeuncordon command.
+var nodeuncordonCmd = &cobra.Command{
+	Use:   "uncordon [<node name>]",
+	Short: "Mark a K8s node schedulable",
+	Long: `
+Mark a previously cordoned K8s node schedulable again.
+
+Example:
+  k8sctl -c cluster1 node uncordon cluster1-worker-2
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runNodeSchedulable("uncordon", args, k8sctl.NodeCordonBody{
+			Verbose: verbose,
+		})
+	},
+}
+
+func init() {
+	nodeCmd.AddCommand(nodeuncordonCmd)
+}
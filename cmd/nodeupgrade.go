@@ -4,21 +4,24 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
 // nodeupgradeCmd represents the nodeupgrade command.
 var nodeupgradeCmd = &cobra.Command{
-	Use:   "upgrade [<node name>]",
-	Short: "Upgrade a specific node to specified Talos version",
+	Use:   "upgrade [<node name>...]",
+	Short: "Upgrade specific nodes to specified Talos version",
 	Long: `
-Upgrade a specific K8s node to the specified Talos version.
+Upgrade a specific K8s node, or several, to the specified Talos version.
 
 This command will:
 - Discover the appropriate Talos AMI for the target version
@@ -28,13 +31,19 @@ This command will:
 Example:
   k8sctl node upgrade cluster1-cp-0 --version v1.10.8
   k8sctl node upgrade cluster1-worker-2 --version v1.10.8 --dry-run
+  k8sctl node upgrade cluster1-worker-2 cluster1-worker-5 cluster1-cp-1 --version v1.10.8
+
+Several nodes are upgraded one at a time, with the same options, like a cluster upgrade of just those nodes: control
+plane nodes first, each waited on, up to --health-timeout seconds, to be Ready with healthy load balancer targets
+before the next is upgraded.  --on-failure says what to do when one fails, as for cluster upgrade.  The result lists
+each node with its previous and target version, status, and duration, as a table, or with --output json, as JSON.  The
+command exits non-zero if any node failed.  One node's result is always JSON.
+
+Like cluster upgrades, node upgrades have no client timeout unless --timeout-seconds is given, and carry on on the
+server if k8sctl is interrupted or disconnected.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) > 0 {
-			if nodeName == "" {
-				nodeName = args[0]
-			}
-		}
+		nodes := upgradeNodeNames(args)
 
 		if cluster == "" {
 			log.Fatalf("Cluster name is required. Use -c flag.")
@@ -50,7 +59,7 @@ Example:
 			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
 		}
 
-		if nodeName == "" {
+		if len(nodes) == 0 {
 			log.Fatalf("Node name is required. Use -n flag or provide as argument.")
 		}
 
@@ -58,7 +67,22 @@ Example:
 			log.Fatalf("Version is required. Use --version flag.")
 		}
 
+		if upgradeOutput != "table" && upgradeOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table or json.", upgradeOutput)
+		}
+
 		baseURL := getServerBaseURL(cluster)
+		checkServerIdentity(baseURL, cluster)
+
+		if len(nodes) > 1 {
+			upgradeNodes(cmd, baseURL, token, nodes)
+			return
+		}
+
+		nodeName = nodes[0]
+
+		startUpgrade(cmd, fmt.Sprintf("node %s", nodeName))
+
 		serverURL := fmt.Sprintf("%s/%s/cluster/%s/node/upgrade/%s", baseURL, apiVersion, cluster, nodeName)
 
 		if verbose {
@@ -75,6 +99,8 @@ Example:
 			"dry_run":        dryRun,
 			"update_secrets": updateSecrets,
 			"verbose":        verbose,
+			"region":         getClusterRegion(cluster),
+			"keep_alive":     true,
 		}
 
 		dataBytes, err := json.Marshal(data)
@@ -84,13 +110,23 @@ Example:
 
 		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
 		if err != nil {
-			log.Fatalf("failed making authenticated request: %s", err)
+			log.Fatalf("failed making authenticated request: %s\n%s", err, upgradeContinuesNote)
 		}
 		defer resp.Body.Close()
 
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			log.Fatalf("failed reading response body: %s", err)
+			log.Fatalf("failed reading response body: %s\n%s", err, upgradeContinuesNote)
+		}
+
+		// Keep-alives leave whitespace ahead of the result, and mean a failure can come with a 200
+		body = bytes.TrimSpace(body)
+
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Error != "" {
+			log.Fatalf("upgrade of node %s failed: %s", nodeName, failure.Error)
 		}
 
 		if resp.StatusCode != http.StatusOK {
@@ -101,6 +137,50 @@ Example:
 	},
 }
 
+// upgradeNodeNames returns the nodes to upgrade: the -n node, if any, then those given as arguments, each once.
+func upgradeNodeNames(args []string) (names []string) {
+	for _, name := range append([]string{nodeName}, args...) {
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// upgradeNodes upgrades several nodes one at a time, with a rolling upgrade of just those nodes, and prints each one's
+// result.
+func upgradeNodes(cmd *cobra.Command, baseURL string, token string, nodes []string) {
+	startUpgrade(cmd, fmt.Sprintf("nodes %s", strings.Join(nodes, ", ")))
+
+	serverURL := fmt.Sprintf("%s/%s/cluster/%s/upgrade", baseURL, apiVersion, cluster)
+
+	if verbose {
+		fmt.Printf("Target URL: %s\n", serverURL)
+		fmt.Printf("Cluster: %s\n", cluster)
+		fmt.Printf("Nodes: %s\n", strings.Join(nodes, ", "))
+		fmt.Printf("Target Version: %s\n", upgradeVersion)
+	}
+
+	data := map[string]interface{}{
+		"version":             upgradeVersion,
+		"nodes":               nodes,
+		"control_plane_first": true,
+		"max_concurrent":      1,
+		"preserve":            preserve,
+		"stage":               stage,
+		"dry_run":             dryRun,
+		"update_secrets":      updateSecrets,
+		"on_failure":          onFailure,
+		"health_timeout":      healthTimeout,
+		"verbose":             verbose,
+		"region":              getClusterRegion(cluster),
+		"keep_alive":          true,
+	}
+
+	runRollingUpgrade(serverURL, token, data)
+}
+
 func init() {
 	nodeCmd.AddCommand(nodeupgradeCmd)
 	nodeupgradeCmd.Flags().StringVar(&upgradeVersion, "version", "", "Target Talos version (e.g., v1.10.8)")
@@ -108,6 +188,9 @@ func init() {
 	nodeupgradeCmd.Flags().BoolVar(&stage, "stage", false, "Stage upgrade and reboot later")
 	nodeupgradeCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Simulate the upgrade without executing")
 	nodeupgradeCmd.Flags().BoolVar(&updateSecrets, "update-secrets", false, "Update Vault secrets after successful upgrade")
+	nodeupgradeCmd.Flags().StringVar(&onFailure, "on-failure", "continue", "With several nodes, what to do when one fails: continue, fail-fast, or rollback the nodes already upgraded")
+	nodeupgradeCmd.Flags().IntVar(&healthTimeout, "health-timeout", 600, "With several nodes, seconds to wait for each upgraded node to be Ready with healthy LB targets before aborting (0 disables)")
+	addOutputFlag(nodeupgradeCmd, &upgradeOutput, "table", "json")
 
 	err := nodeupgradeCmd.MarkFlagRequired("version")
 	if err != nil {
@@ -0,0 +1,184 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var outputFile string
+
+var outputForce bool
+
+var quiet bool
+
+var noColor bool
+
+// outputFormatsAnnotation annotates a command's --output flag with the formats it takes, so a default output format from
+// the config is only applied to the commands that have it.
+const outputFormatsAnnotation = "k8sctl_output_formats"
+
+// addOutputFlag adds the -o/--output flag to a command, taking the given formats, the first of them the default.
+func addOutputFlag(cmd *cobra.Command, output *string, formats ...string) {
+	usage := strings.Join(formats, " or ")
+	if len(formats) > 2 {
+		usage = strings.Join(formats[:len(formats)-1], ", ") + ", or " + formats[len(formats)-1]
+	}
+
+	cmd.Flags().StringVarP(output, "output", "o", formats[0], fmt.Sprintf("Output format (%s)", usage))
+	_ = cmd.Flags().SetAnnotation("output", outputFormatsAnnotation, formats)
+	_ = cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(formats, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// jsonAlias is the value of a command's deprecated --json flag, which sets its --output to json, as given on the command
+// line, so the config's default output doesn't override it.
+type jsonAlias struct {
+	cmd *cobra.Command
+}
+
+func (a jsonAlias) String() (value string) {
+	value = "false"
+	return value
+}
+
+func (a jsonAlias) Set(value string) (err error) {
+	asJSON, err := strconv.ParseBool(value)
+	if err != nil || !asJSON {
+		return err
+	}
+
+	err = a.cmd.Flags().Set("output", "json")
+	return err
+}
+
+func (a jsonAlias) Type() (name string) {
+	name = "bool"
+	return name
+}
+
+// addJSONAlias keeps --json working, hidden and deprecated, on a command that has since taken -o/--output, as -o json.
+func addJSONAlias(cmd *cobra.Command) {
+	flag := cmd.Flags().VarPF(jsonAlias{cmd: cmd}, "json", "", "Output raw JSON (deprecated: use -o json)")
+	flag.NoOptDefVal = "true"
+	_ = cmd.Flags().MarkDeprecated("json", "use -o json")
+}
+
+// applyConfigDefaults sets the flags the config has defaults for, that weren't given: --verbose, unless --quiet was,
+// and --output, on commands that have the config's format.  Flags always override the config.
+func applyConfigDefaults(cmd *cobra.Command) {
+	cfg, err := loadConfig()
+	if err != nil || cfg == nil {
+		return
+	}
+
+	if cfg.Defaults.Verbose && !cmd.Flags().Changed("verbose") && !cmd.Flags().Changed("quiet") {
+		verbose = true
+	}
+
+	output := cmd.Flags().Lookup("output")
+	if cfg.Defaults.Output == "" || output == nil || output.Changed {
+		return
+	}
+
+	if slices.Contains(output.Annotations[outputFormatsAnnotation], cfg.Defaults.Output) {
+		_ = output.Value.Set(cfg.Defaults.Output)
+	}
+}
+
+// usePlainOutput says whether to flag lines with plain text markers, like [WARN] and [OK], rather than emoji: with
+// --no-color, with NO_COLOR set (see https://no-color.org), or when stdout isn't a terminal, as in CI logs and pipes.
+func usePlainOutput() (plain bool) {
+	plain = noColor || os.Getenv("NO_COLOR") != "" || !term.IsTerminal(int(os.Stdout.Fd()))
+	return plain
+}
+
+// printInfo prints an informational message, one that's neither a result nor an error, unless --quiet was given.
+func printInfo(format string, args ...interface{}) {
+	if quiet {
+		return
+	}
+
+	fmt.Printf(format, args...)
+}
+
+// printDryRunSummary prints what a dry run found would be done, under a header saying nothing was, so every dry run
+// previews its changes the same way, whatever the command.
+func printDryRunSummary(lines []string) {
+	fmt.Printf("DRY RUN: nothing was changed\n\n")
+
+	for _, line := range lines {
+		fmt.Printf("%s\n", line)
+	}
+}
+
+// checkOutputFile fails early if --output-file would overwrite a file without --force, rather than after the request
+// has been made.
+func checkOutputFile() (err error) {
+	if outputFile == "" || outputForce {
+		return err
+	}
+
+	_, statErr := os.Stat(outputFile)
+	if statErr == nil {
+		err = fmt.Errorf("output file %s already exists (use --force to overwrite it)", outputFile)
+		return err
+	}
+
+	return err
+}
+
+// writeResult writes a command's machine-readable result: to --output-file if it's set, creating its parent
+// directories as needed, else to stdout.
+func writeResult(result []byte) (err error) {
+	if outputFile == "" {
+		fmt.Printf("%s\n", result)
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(outputFile), 0755)
+	if err != nil {
+		err = fmt.Errorf("failed creating directory for output file %s: %w", outputFile, err)
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if outputForce {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(outputFile, flags, 0644)
+	if errors.Is(err, os.ErrExist) {
+		err = fmt.Errorf("output file %s already exists (use --force to overwrite it)", outputFile)
+		return err
+	}
+
+	if err != nil {
+		err = fmt.Errorf("failed opening output file %s: %w", outputFile, err)
+		return err
+	}
+
+	_, err = fmt.Fprintf(file, "%s\n", result)
+	if err != nil {
+		_ = file.Close()
+		err = fmt.Errorf("failed writing output file %s: %w", outputFile, err)
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		err = fmt.Errorf("failed writing output file %s: %w", outputFile, err)
+		return err
+	}
+
+	return err
+}
@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"os"
 
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
 	"github.com/spf13/cobra"
 )
 
@@ -19,16 +21,50 @@ var showToken bool
 
 var cluster string
 
+var clusterEnvironment string
+
 var dexURL string
 
 var clientID string
 
 var clientSecret string
 
+var sshKey string
+
+var region string
+
+var strictAPIVersion bool
+
+var configFile string
+
 // rootCmd represents the base command when called without any subcommands.
 var rootCmd = &cobra.Command{
 	Use:   "k8sctl",
 	Short: "Manage Talos Kubernetes Clusters",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// A config file named with --config must load, rather than commands quietly falling back to the defaults
+		if configFile != "" {
+			_, err := loadConfig()
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+		}
+
+		applyConfigDefaults(cmd)
+
+		if quiet && verbose {
+			log.Fatalf("--quiet and --verbose can't be used together")
+		}
+
+		cluster = getConfigValue(cluster, "K8SCTL_CLUSTER")
+
+		k8sctl.SetPlainOutput(usePlainOutput())
+
+		err := checkOutputFile()
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+	},
 	Long: `k8sctl is a command-line tool for managing Talos Kubernetes Clusters behind Cloud Load Balancers.
 
 Authentication is performed via Dex OIDC provider using SSH key-based JWT tokens.
@@ -47,8 +83,11 @@ Example usage:
   # With full Dex configuration
   k8sctl -d https://dex.example.com --client-id client-id --client-secret secret -c cluster1 cluster describe
 
+Results can be written to a file rather than stdout, e.g. for CI artifacts:
+  k8sctl -c cluster1 cluster describe --output-file reports/cluster1.json
+
 Environment variables:
-  DEX_URL, K8SCTL_CLIENT_ID, K8SCTL_CLIENT_SECRET, KUBECTL_SSH_USER can be used instead of flags
+  K8SCTL_CLUSTER, NO_COLOR, DEX_URL, K8SCTL_CLIENT_ID, K8SCTL_CLIENT_SECRET, KUBECTL_SSH_USER, K8SCTL_CA_CERT, K8SCTL_INSECURE_SKIP_VERIFY can be used instead of flags
   (CLIENT_ID and CLIENT_SECRET have built-in defaults for internal use)`,
 }
 
@@ -64,12 +103,25 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "", false, "verbose output")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print results and errors, not informational output")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Flag output with plain text like [WARN] rather than emoji (default when NO_COLOR is set or stdout isn't a terminal)")
 	rootCmd.PersistentFlags().StringVarP(&username, "username", "u", "", "Username for authentication")
 	rootCmd.PersistentFlags().IntVarP(&timeoutSeconds, "timeout-seconds", "", 300, "Timeout")
-	rootCmd.PersistentFlags().StringVarP(&apiVersion, "version", "v", "v1", "API version")
-	rootCmd.PersistentFlags().BoolVarP(&showToken, "show-token", "", false, "Dump OIDC token to stdout")
-	rootCmd.PersistentFlags().StringVarP(&cluster, "cluster", "c", "", "Cluster name (required)")
+	rootCmd.PersistentFlags().StringVarP(&apiVersion, "api-version", "v", "v1", "API version of the k8sctl server endpoints to call (not the k8sctl build; see the version command)")
+	rootCmd.PersistentFlags().BoolVarP(&showToken, "show-token", "", false, "Dump OIDC token to stdout, and leave it unredacted in debug output")
+	rootCmd.PersistentFlags().StringVarP(&cluster, "cluster", "c", "", "Cluster name (required; default $K8SCTL_CLUSTER)")
+	_ = rootCmd.RegisterFlagCompletionFunc("cluster", completeClusterNames)
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Config file to load, rather than $K8SCTL_CONFIG or the default locations")
+	rootCmd.PersistentFlags().StringVar(&clusterEnvironment, "env", "", "Environment suffix of the server to call, e.g. staging, overriding the cluster's configured environment ($K8SCTL_CLUSTER_SUFFIX overrides this)")
 	rootCmd.PersistentFlags().StringVarP(&dexURL, "dex-url", "d", "", "Dex issuer URL for OIDC authentication")
 	rootCmd.PersistentFlags().StringVar(&clientID, "client-id", "", "OAuth2 client ID for Dex (default: built-in)")
 	rootCmd.PersistentFlags().StringVar(&clientSecret, "client-secret", "", "OAuth2 client secret for Dex (default: built-in)")
+	rootCmd.PersistentFlags().StringVar(&sshKey, "ssh-key", "", "Private SSH key to sign the Dex login with, and the only one tried (default: every key in the agent, or the default key files; $K8SCTL_SSH_KEY)")
+	rootCmd.PersistentFlags().StringVar(&caCertFile, "ca-cert", "", "PEM bundle of extra CAs to trust for Dex and the k8sctl server, e.g. an internal CA")
+	rootCmd.PersistentFlags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "Don't verify TLS certificates of Dex and the k8sctl server. INSECURE: for local development with self-signed certs only")
+	rootCmd.PersistentFlags().BoolVar(&debugHTTP, "debug-http", false, "Dump each request to the k8sctl server, and its response, to stderr (the token is redacted unless --show-token)")
+	rootCmd.PersistentFlags().BoolVar(&strictAPIVersion, "strict", false, "Refuse to send requests unless the server advertises support for the client's API version")
+	rootCmd.PersistentFlags().StringVar(&region, "region", "", "AWS region for the cluster (default: cluster's configured region)")
+	rootCmd.PersistentFlags().StringVar(&outputFile, "output-file", "", "Write the command's JSON result to this file instead of stdout")
+	rootCmd.PersistentFlags().BoolVar(&outputForce, "force", false, "Overwrite --output-file if it already exists")
 }
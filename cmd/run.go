@@ -0,0 +1,118 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var runOutput string
+
+// runCmd represents the run command.
+var runCmd = &cobra.Command{
+	Use:   "run <command name> [args...]",
+	Short: "Run one of the server's registered commands",
+	Long: `
+Run one of the commands registered on the server (see K8SCTL_COMMANDS_FILE in 'k8sctl server --help'), and print its
+output.  Any args are passed to the command after its own.  Put args starting with - after --, so k8sctl doesn't take
+them as its own flags.  The command's name for the cluster is in its $K8SCTL_CLUSTER.
+
+Each command can be restricted to a group: if you're not in it, the server refuses to run it.  k8sctl exits with the
+command's exit code.  With --output json, the result, with the command's output and exit code, is printed as JSON.
+
+Example:
+  k8sctl -c cluster1 run etcd-snapshot
+  k8sctl -c cluster1 run etcd-snapshot -- --bucket backups
+  k8sctl -c cluster1 run etcd-snapshot -o json
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		commandName := args[0]
+
+		if cluster == "" {
+			log.Fatalf("Cluster name is required. Use -c flag.")
+		}
+
+		if runOutput != "text" && runOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use text or json.", runOutput)
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/cluster/%s/run/%s", baseURL, apiVersion, cluster, commandName)
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+			fmt.Printf("Command: %s\n", commandName)
+		}
+
+		data := k8sctl.RunCommandBody{
+			Args:    args[1:],
+			Verbose: verbose,
+		}
+
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			log.Fatalf("unable to marshal post data: %s", err)
+		}
+
+		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		var result k8sctl.K8sCtlCommandResult
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			log.Fatalf("Failed unmarshalling command result: %s", err)
+		}
+
+		if runOutput == "json" || outputFile != "" {
+			err = writeResult(body)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+		} else {
+			fmt.Print(result.Output)
+		}
+
+		if result.ExitCode != 0 {
+			os.Exit(result.ExitCode)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	addOutputFlag(runCmd, &runOutput, "text", "json")
+	addJSONAlias(runCmd)
+}
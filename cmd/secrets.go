@@ -0,0 +1,21 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// secretsCmd represents the secrets command.
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage cluster secrets",
+	Long: `
+Manage the Vault secrets, machine config and AMI, that new nodes are built from.
+`,
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+}
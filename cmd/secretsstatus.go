@@ -0,0 +1,125 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+var secretsStatusOutput string
+
+// secretsstatusCmd represents the secretsstatus command.
+var secretsstatusCmd = &cobra.Command{
+	Use:   "status [<cluster name>]",
+	Short: "Compare Vault secrets with the versions clusters are running",
+	Long: `
+Compare each role's Vault secret, its installer version and AMI, with the Talos version the role's nodes are running,
+without changing anything.  Given no cluster, every cluster in the server's config is checked, for a fleet-wide view
+of stale secrets.  Stale secrets can be updated with 'k8sctl secrets sync'.
+
+Exits 1 if any secret is stale, or couldn't be checked.
+
+Example:
+  k8sctl secrets status cluster1
+  k8sctl secrets status
+  k8sctl secrets status --role worker -o json
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			// An argument beats $K8SCTL_CLUSTER, but not -c
+			if !cmd.Flags().Changed("cluster") {
+				cluster = args[0]
+			}
+		}
+
+		if secretsStatusOutput != "table" && secretsStatusOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table or json.", secretsStatusOutput)
+		}
+
+		// Get OIDC token
+		token, err := getOIDCToken()
+		if err != nil {
+			log.Fatalf("Failed to get OIDC token: %v", err)
+		}
+
+		if showToken {
+			fmt.Printf("OIDC Token:\n\n%s\n\n", token)
+		}
+
+		baseURL := getServerBaseURL(cluster)
+		serverURL := fmt.Sprintf("%s/%s/secrets/status", baseURL, apiVersion)
+		if cluster != "" {
+			serverURL = fmt.Sprintf("%s/%s/cluster/%s/secrets/status", baseURL, apiVersion, cluster)
+		}
+
+		if verbose {
+			fmt.Printf("Target URL: %s\n", serverURL)
+			fmt.Printf("Cluster: %s\n", cluster)
+		}
+
+		data := k8sctl.SecretsStatusBody{
+			Role:    syncRole,
+			Verbose: verbose,
+		}
+
+		if cluster != "" {
+			data.Region = getClusterRegion(cluster)
+		}
+
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			log.Fatalf("unable to marshal post data: %s", err)
+		}
+
+		resp, err := makeAuthenticatedRequest("POST", serverURL, string(dataBytes), token)
+		if err != nil {
+			log.Fatalf("failed making authenticated request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Fatalf("failed reading response body: %s", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
+		}
+
+		var result k8sctl.SecretsStatusResult
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			log.Fatalf("Failed unmarshalling secrets status: %s", err)
+		}
+
+		if secretsStatusOutput == "json" || outputFile != "" {
+			err = writeResult(body)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+		} else {
+			result.ConsolePrint()
+		}
+
+		if result.Stale > 0 || result.Errors > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	secretsCmd.AddCommand(secretsstatusCmd)
+	secretsstatusCmd.Flags().StringVar(&syncRole, "role", "", "Specific role to check (controlplane or worker). If not specified, checks all roles.")
+	_ = secretsstatusCmd.RegisterFlagCompletionFunc("role", completeNodeRoles)
+	addOutputFlag(secretsstatusCmd, &secretsStatusOutput, "table", "json")
+	addJSONAlias(secretsstatusCmd)
+}
@@ -9,12 +9,17 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
 
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
 	"github.com/spf13/cobra"
 )
 
 var (
-	syncRole string
+	syncRole     string
+	syncOutput   string
+	syncFailFast bool
+	syncContinue bool
 )
 
 // secretssyncCmd represents the secretssync command.
@@ -32,14 +37,24 @@ This command will:
 This is useful when clusters have been upgraded but Vault secrets were not updated,
 preventing the secrets from becoming stale.
 
+Each role is listed with the version and AMI stored in Vault, the version its node is running and that version's AMI,
+and whether its secret was updated.  With --dry-run nothing is updated: the roles whose secret would be are listed as
+"would update", after a summary of what each would be updated from and to.  --output json prints the raw result instead.
+
+By default (--continue), every role is synced, even if one fails, and the failures are reported at the end.  With
+--fail-fast, the first role to fail stops the sync, and the roles after it are listed as not tried.  A role the
+cluster has no nodes of fails, rather than being skipped.  The command exits non-zero if any role failed.
+
 Example:
   k8sctl secrets sync cluster1
   k8sctl secrets sync cluster1 --role controlplane
   k8sctl secrets sync cluster1 --role worker --dry-run
+  k8sctl secrets sync cluster1 --fail-fast
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) > 0 {
-			if cluster == "" {
+			// An argument beats $K8SCTL_CLUSTER, but not -c
+			if !cmd.Flags().Changed("cluster") {
 				cluster = args[0]
 			}
 
@@ -49,6 +64,10 @@ Example:
 			log.Fatalf("Cluster name is required. Use -c flag or provide as argument.")
 		}
 
+		if syncOutput != "table" && syncOutput != "json" {
+			log.Fatalf("Invalid output format %q. Use table or json.", syncOutput)
+		}
+
 		// Get OIDC token
 		token, err := getOIDCToken()
 		if err != nil {
@@ -72,6 +91,7 @@ Example:
 
 		data := map[string]interface{}{
 			"verbose": verbose,
+			"region":  getClusterRegion(cluster),
 			"dry_run": dryRun,
 		}
 
@@ -79,6 +99,10 @@ Example:
 			data["role"] = syncRole
 		}
 
+		if syncFailFast {
+			data["on_failure"] = k8sctl.OnFailureFailFast
+		}
+
 		dataBytes, err := json.Marshal(data)
 		if err != nil {
 			log.Fatalf("unable to marshal post data: %s", err)
@@ -99,15 +123,39 @@ Example:
 			log.Fatalf("request failed with status %d: %s", resp.StatusCode, body)
 		}
 
-		fmt.Printf("%s\n", body)
+		var result k8sctl.SecretsSyncResult
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			log.Fatalf("Failed unmarshalling secrets sync result: %s", err)
+		}
+
+		if syncOutput == "json" || outputFile != "" {
+			err = writeResult(body)
+			if err != nil {
+				log.Fatalf("Failed writing result: %s", err)
+			}
+		} else {
+			if dryRun {
+				printDryRunSummary(result.DryRunSummary())
+				fmt.Println()
+			}
+			result.ConsolePrint()
+		}
+
+		if len(result.Failed) > 0 {
+			os.Exit(1)
+		}
 	},
 }
 
 func init() {
-	rootCmd.AddCommand(secretssyncCmd)
-	secretssyncCmd.Use = "secrets"
-	secretssyncCmd.Short = "Manage cluster secrets"
-
+	secretsCmd.AddCommand(secretssyncCmd)
 	secretssyncCmd.Flags().StringVar(&syncRole, "role", "", "Specific role to sync (controlplane or worker). If not specified, syncs all roles.")
+	_ = secretssyncCmd.RegisterFlagCompletionFunc("role", completeNodeRoles)
 	secretssyncCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be updated without making changes")
+	addOutputFlag(secretssyncCmd, &syncOutput, "table", "json")
+	addJSONAlias(secretssyncCmd)
+	secretssyncCmd.Flags().BoolVar(&syncFailFast, "fail-fast", false, "Stop at the first role that fails, rather than syncing the rest")
+	secretssyncCmd.Flags().BoolVar(&syncContinue, "continue", false, "Sync every role, even if one fails, reporting all the failures at the end (the default)")
+	secretssyncCmd.MarkFlagsMutuallyExclusive("fail-fast", "continue")
 }
@@ -4,22 +4,28 @@ Copyright © 2025 Nik Ogura
 package cmd
 
 import (
-	"fmt"
+	"context"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/vault"
+	"github.com/nikogura/k8sctl/pkg/config"
 	"github.com/nikogura/k8sctl/pkg/k8sctl"
 	"github.com/nikogura/k8sctl/pkg/oidc"
+	"github.com/nikogura/k8sctl/pkg/vaultclient"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"go.uber.org/zap"
 )
 
 var address string
 
 var logLevel string
 
+var ginLogger bool
+
 // serverCmd represents the server command.
 var serverCmd = &cobra.Command{
 	Use:   "server",
@@ -31,8 +37,28 @@ Uses environment variables for configuration:
 - OIDC_ISSUER_URL: Dex issuer URL (required, e.g., https://dex.example.com)
 - OIDC_AUDIENCE: The URL of this k8sctl server (required, e.g., https://k8sctl-dev.example.com)
 - OIDC_ALLOWED_GROUPS: Comma-separated list of allowed groups (optional, defaults to engineering)
+- OIDC_CREDENTIAL_GROUPS: Comma-separated list of groups allowed to fetch cluster credentials, e.g. kubeconfigs (optional, defaults to none)
+- OIDC_ADMIN_GROUPS: Comma-separated list of groups allowed to turn maintenance mode on and off (optional, defaults to none)
+- OIDC_ALLOWED_ALGORITHMS: Comma-separated list of accepted token signing algorithms (optional, defaults to RS256)
+- K8SCTL_CA_CERT: PEM bundle of extra CAs to trust when fetching the OIDC issuer's JWKS (optional)
 - CLOUDFLARE_API_TOKEN: Cloudflare API token for DNS management (required)
 - CLOUDFLARE_ZONE_ID: Cloudflare zone ID (required)
+- K8SCTL_SERVER_CONFIG: Path to a cluster config file (optional).  Per cluster, it can set the AWS region, and an
+  IAM role to assume for clusters in other AWS accounts (aws_role_arn, aws_external_id, aws_session_name).
+- K8SCTL_MONITOR_WEBHOOK_URL: Webhook monitors POST alerts to, unless the monitor names its own (optional)
+- K8SCTL_DESCRIBE_CACHE_TTL: Seconds to cache cluster describe results for reconciles and monitors that opt in
+  (optional, default 0: no caching).
+- K8SCTL_MAINTENANCE: Start in maintenance mode, giving this as the reason (optional).  See below.
+- K8SCTL_LOG_LEVEL: Log level, as for --log-level, which overrides it (optional, default Info)
+- VAULT_ADDR: Vault holding each cluster role's machine config and AMI, for 'k8sctl secrets' (optional: without it,
+  secrets can't be synced).  The server logs in and checks its token at startup.
+- VAULT_NAMESPACE: Vault Enterprise namespace (optional)
+- K8SCTL_VAULT_AUTH_METHOD: token (default, with VAULT_TOKEN), approle (with K8SCTL_VAULT_ROLE_ID and
+  K8SCTL_VAULT_SECRET_ID), or kubernetes (with K8SCTL_VAULT_K8S_ROLE, and the service account token at
+  K8SCTL_VAULT_K8S_TOKEN_PATH, default the pod's).  K8SCTL_VAULT_AUTH_MOUNT is where it's mounted, if not at its name.
+- K8SCTL_VAULT_MOUNT: KV v2 mount of the cluster secrets (optional, default secret)
+- K8SCTL_COMMANDS_FILE: Path to a JSON file of commands users can run with 'k8sctl run' (optional).  Each has a name,
+  an executable and args, a description, and a role: the group allowed to run it (empty for anyone allowed here).
 
 Example:
   export OIDC_ISSUER_URL="https://dex.example.com"
@@ -40,6 +66,28 @@ Example:
   export CLOUDFLARE_API_TOKEN="your-token"
   export CLOUDFLARE_ZONE_ID="your-zone-id"
   k8sctl server
+
+The server's build info and supported API versions are served (unauthenticated) at /version, along with the
+environment it serves ($K8SCTL_ENVIRONMENT) and the clusters it manages ($K8SCTL_CLUSTERS), if set.  Clients check
+those before destructive operations.  The supported API
+versions are also sent on every response in the X-K8sctl-API-Versions header.
+The OpenAPI spec for the API is served (unauthenticated) at /openapi.json, and printed by 'k8sctl server openapi'.
+The operations the API supports, the groups they need, and whether they're destructive are served at
+/v1/capabilities, and printed by 'k8sctl capabilities'.
+
+In maintenance mode, the operations that change clusters (create, delete, glass, upgrade, cordon, uncordon, retag,
+secrets sync, run, and reconcile with fix_tags) are refused with a 503 and the reason, while describes, monitors, and
+status checks keep working.  It's turned on and off at /v1/maintenance by the admin groups, with 'k8sctl maintenance',
+and who did is logged.
+
+With --quiet, the configuration isn't printed at startup.
+
+Only /status, /version, and /openapi.json are served without a token: every other request, to any path, must be
+authenticated.
+
+Each authenticated request is logged as JSON, with its method, path, status, latency, client IP, request ID, and user.  The
+request ID is the client's X-Request-ID header, or a new one, and is returned in the X-Request-ID response header.
+Turn off gin's own request log with --gin-logger=false, to avoid logging those requests twice.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		viper.AutomaticEnv()
@@ -59,9 +107,27 @@ Example:
 			oidcConfig.AllowedGroups = []string{"engineering"}
 		}
 
-		fmt.Printf("OIDC Issuer: %s\n", oidcConfig.IssuerURL)
-		fmt.Printf("OIDC Audience: %s\n", oidcConfig.Audience)
-		fmt.Printf("OIDC Allowed Groups: %v\n", oidcConfig.AllowedGroups)
+		k8sctl.SetAccessGroups(oidcConfig.AllowedGroups, oidcConfig.CredentialGroups)
+		k8sctl.SetAdminGroups(oidcConfig.AdminGroups)
+
+		printInfo("k8sctl %s\n", buildInfo)
+		printInfo("OIDC Issuer: %s\n", oidcConfig.IssuerURL)
+		printInfo("OIDC Audience: %s\n", oidcConfig.Audience)
+		printInfo("OIDC Allowed Groups: %v\n", oidcConfig.AllowedGroups)
+		if len(oidcConfig.AdminGroups) > 0 {
+			printInfo("OIDC Admin Groups: %v\n", oidcConfig.AdminGroups)
+		}
+		printInfo("OIDC Groups Claim: %s\n", oidcConfig.GroupsClaim)
+		if len(oidcConfig.AllowedEmailDomains) > 0 {
+			printInfo("OIDC Allowed Email Domains: %v\n", oidcConfig.AllowedEmailDomains)
+		}
+		printInfo("OIDC Allowed Algorithms: %v\n", oidcConfig.AllowedAlgorithms)
+		if oidcConfig.Introspection != oidc.IntrospectionOff {
+			printInfo("OIDC Introspection: %s\n", oidcConfig.Introspection)
+		}
+		if oidcConfig.CACertFile != "" {
+			printInfo("CA Bundle: %s\n", oidcConfig.CACertFile)
+		}
 
 		// Load Cloudflare configuration
 		cfAPIToken := viper.GetString("CLOUDFLARE_API_TOKEN")
@@ -74,52 +140,144 @@ Example:
 			log.Fatalf("CLOUDFLARE_ZONE_ID environment variable is required")
 		}
 
-		fmt.Printf("Cloudflare Zone ID: %s\n", cfZoneID)
+		printInfo("Cloudflare Zone ID: %s\n", cfZoneID)
+
+		// Load per-cluster configuration, if any
+		if serverConfigPath := viper.GetString("K8SCTL_SERVER_CONFIG"); serverConfigPath != "" {
+			clusterCfg, cfgErr := config.Load(serverConfigPath)
+			if cfgErr != nil {
+				log.Fatalf("failed loading server config: %s", cfgErr)
+			}
+
+			k8sctl.SetClusterConfig(clusterCfg)
+			printInfo("Cluster Config: %s (%d clusters)\n", serverConfigPath, len(clusterCfg.Clusters))
+		}
+
+		// Enable the describe cache, if configured
+		if cacheTTL := viper.GetInt("K8SCTL_DESCRIBE_CACHE_TTL"); cacheTTL > 0 {
+			k8sctl.SetDescribeCacheTTL(time.Duration(cacheTTL) * time.Second)
+			printInfo("Describe Cache TTL: %ds\n", cacheTTL)
+		}
+
+		// The server's identity, if configured, for clients to check before destructive operations
+		serverEnvironment := viper.GetString("K8SCTL_ENVIRONMENT")
+		var serverClusters []string
+		for _, clusterName := range strings.Split(viper.GetString("K8SCTL_CLUSTERS"), ",") {
+			if trimmed := strings.TrimSpace(clusterName); trimmed != "" {
+				serverClusters = append(serverClusters, trimmed)
+			}
+		}
+
+		if serverEnvironment != "" {
+			printInfo("Environment: %s\n", serverEnvironment)
+		}
+		if len(serverClusters) > 0 {
+			printInfo("Clusters: %v\n", serverClusters)
+		}
 
-		// Create logger for OIDC middleware
-		logger, err := zap.NewProduction()
+		// Default webhook for monitor alerts, if any.  The URL itself isn't printed, as webhook URLs often embed a secret.
+		if webhookURL := viper.GetString("K8SCTL_MONITOR_WEBHOOK_URL"); webhookURL != "" {
+			k8sctl.SetMonitorWebhookURL(webhookURL)
+			printInfo("Monitor Webhook: configured\n")
+		}
+
+		// Start in maintenance mode, if asked to, e.g. to keep a new server from changing clusters until it's checked
+		if reason := viper.GetString("K8SCTL_MAINTENANCE"); reason != "" {
+			k8sctl.SetMaintenance(true, reason, "K8SCTL_MAINTENANCE")
+			printInfo("Maintenance Mode: on (%s)\n", reason)
+		}
+
+		// Apply the log level to the handler logs, and the OIDC middleware's logger.  The flag wins over the environment.
+		level := logLevel
+		if envLevel := viper.GetString("K8SCTL_LOG_LEVEL"); envLevel != "" && !cmd.Flags().Changed("log-level") {
+			level = envLevel
+		}
+
+		logger, err := applyLogLevel(level)
 		if err != nil {
-			log.Fatalf("failed to create logger: %s", err)
+			log.Fatalf("%s", err)
+		}
+
+		// Vault, for secrets syncs, if configured.  It's logged in to and checked now, so a misconfiguration is found at
+		// startup.
+		vaultConfig := vaultclient.LoadConfigFromEnv()
+		if vaultConfig.Address != "" {
+			vaultClient, vaultErr := vaultclient.NewClient(context.Background(), vaultConfig, logger)
+			if vaultErr != nil {
+				log.Fatalf("failed setting up Vault: %s", vaultErr)
+			}
+
+			k8sctl.SetSecretManager(&vault.VaultSecretManager{Client: vaultClient, MountPath: vaultConfig.KVMount})
+			printInfo("Vault: %s (namespace %q, %s auth, mount %s)\n", vaultConfig.Address, vaultConfig.Namespace, vaultConfig.AuthMethod, vaultConfig.KVMount)
 		}
 
 		// Create OIDC validator
-		oidcValidator := oidc.NewValidator(oidcConfig, logger)
+		oidcValidator, err := oidc.NewValidator(oidcConfig, logger)
+		if err != nil {
+			log.Fatalf("failed to create OIDC validator: %s", err)
+		}
 
-		// Initialize k8sctl commands
+		// Initialize k8sctl commands, and the registered commands users can run, if any
 		commands := &k8sctl.K8sCtlCommands{}
+		if commandsPath := viper.GetString("K8SCTL_COMMANDS_FILE"); commandsPath != "" {
+			commands, err = k8sctl.LoadK8sCtlCommandsFromFile(commandsPath)
+			if err != nil {
+				log.Fatalf("failed loading commands: %s", err)
+			}
+
+			printInfo("Commands: %s (%d commands)\n", commandsPath, len(commands.Commands))
+		}
 
 		// Inject CF credentials into package
 		k8sctl.SetCloudflareCredentials(cfAPIToken, cfZoneID)
 
-		// Create Gin router
-		router := gin.Default()
+		// Router-wide middleware.  Requests are logged by the access log, so gin's own request log can be turned off.
+		middleware := []gin.HandlerFunc{gin.Recovery()}
+		if ginLogger {
+			middleware = append(middleware, gin.Logger())
+		}
+
+		// Advertise the supported API versions on every response, so clients can detect a mismatch
+		apiVersions := strings.Join(k8sctl.SupportedAPIVersions(), ",")
+		middleware = append(middleware, func(ctx *gin.Context) {
+			ctx.Header(k8sctl.APIVersionsHeader, apiVersions)
+			ctx.Next()
+		})
+
+		// The only routes served without a token.  Every other request is authenticated.
+		unauthenticated := []k8sctl.UnauthenticatedRoute{
+			{Method: http.MethodGet, Path: "/status", Handler: func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{
+					"status": "ok",
+				})
+			}},
+			{Method: http.MethodGet, Path: "/version", Handler: func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, k8sctl.ServerVersionInfo{
+					BuildInfo:   buildInfo,
+					APIVersions: k8sctl.SupportedAPIVersions(),
+					Environment: serverEnvironment,
+					Clusters:    serverClusters,
+				})
+			}},
+			{Method: http.MethodGet, Path: "/openapi.json", Handler: func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, k8sctl.GenerateOpenAPISpec(commands.APIRoutes()))
+			}},
+		}
 
-		// Add status endpoint (unauthenticated)
-		router.GET("/status", func(ctx *gin.Context) {
-			ctx.JSON(http.StatusOK, gin.H{
-				"status": "ok",
-			})
+		router, err := commands.NewRouter(k8sctl.RouterConfig{
+			Authenticate:     oidc.Middleware(oidcValidator),
+			AccessLog:        k8sctl.AccessLog(logger),
+			Middleware:       middleware,
+			CredentialGroups: oidcConfig.CredentialGroups,
+			AdminGroups:      oidcConfig.AdminGroups,
+			Unauthenticated:  unauthenticated,
 		})
+		if err != nil {
+			log.Fatalf("failed setting up routes: %s", err)
+		}
 
-		// Create API group with OIDC authentication
-		apiGroup := router.Group("/v1")
-		apiGroup.Use(oidc.Middleware(oidcValidator))
-
-		// Add API handlers
-		apiGroup.POST("/cluster/describe/:cluster", commands.DescribeClusterHandler)
-		apiGroup.POST("/cluster/:cluster/node/create", commands.CreateNodeHandler)
-		apiGroup.POST("/cluster/:cluster/node/delete/:name", commands.DeleteNodeHandler)
-		apiGroup.POST("/cluster/:cluster/node/glass/:name", commands.GlassNodeHandler)
-		apiGroup.POST("/cluster/:cluster/node/describe/:name", commands.DescribeNodeHandler)
-		apiGroup.POST("/cluster/:cluster/node/upgrade/:node", commands.UpgradeNodeHandler)
-		apiGroup.POST("/cluster/:cluster/reconcile", commands.ReconcileClusterHandler)
-		apiGroup.POST("/cluster/:cluster/upgrade", commands.UpgradeClusterHandler)
-		apiGroup.POST("/cluster/:cluster/secrets/sync", commands.SecretsSyncHandler)
-		apiGroup.POST("/monitor/:cluster", commands.MonitorClusterHandler)
-		apiGroup.POST("/auth-check", commands.AuthCheckHandler)
-
-		fmt.Printf("Starting k8sctl server with OIDC authentication via %s\n", oidcConfig.IssuerURL)
-		fmt.Printf("Server starting on address: %s\n", address)
+		printInfo("Starting k8sctl server with OIDC authentication via %s\n", oidcConfig.IssuerURL)
+		printInfo("Server starting on address: %s\n", address)
 
 		err = router.Run(address)
 		if err != nil {
@@ -131,5 +289,6 @@ Example:
 func init() {
 	rootCmd.AddCommand(serverCmd)
 	serverCmd.Flags().StringVarP(&address, "bind-address", "b", "0.0.0.0:9999", "Address (host and port) on which to listen")
-	serverCmd.Flags().StringVarP(&logLevel, "log-level", "l", "Info", "Log Level.  One of (Trace, Debug, Info, Warn, Error).")
+	serverCmd.Flags().BoolVar(&ginLogger, "gin-logger", true, "Also log every request with gin's plain-text request log")
+	serverCmd.Flags().StringVarP(&logLevel, "log-level", "l", "Info", "Log Level.  One of (Trace, Debug, Info, Warn, Error).  Also set by K8SCTL_LOG_LEVEL.")
 }
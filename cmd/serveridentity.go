@@ -0,0 +1,78 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+)
+
+// checkServerIdentity makes sure the server a destructive command is about to call is the one for the cluster, and
+// exits if it isn't.  A misconfigured environment suffix or server URL can otherwise point at the wrong environment,
+// e.g. prod rather than dev.  The server's environment and clusters are read from its /version endpoint.  A server
+// advertising neither can't be checked, which is only warned about.
+func checkServerIdentity(baseURL string, clusterName string) {
+	info, err := fetchServerVersion(baseURL)
+	if err != nil {
+		log.Fatalf("Unable to confirm %s is the server for cluster %s: %s", baseURL, clusterName, err)
+	}
+
+	err = serverIdentityMismatch(info, clusterName, expectedServerEnvironment(clusterName))
+	if err != nil {
+		log.Fatalf("%s Refusing to continue: %s.  Check the cluster's environment and server_url in your config, and $K8SCTL_CLUSTER_SUFFIX and $K8SCTL_SERVER_URL.", k8sctl.ConsoleMarkers().Fail, err)
+	}
+
+	if info.Environment == "" && len(info.Clusters) == 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: %s doesn't advertise its environment or clusters, so it can't be confirmed as the server for cluster %s\n", baseURL, clusterName)
+	}
+}
+
+// serverIdentityMismatch returns an error if the server's advertised identity doesn't match the cluster: the cluster
+// must be one of the server's clusters, if it lists any, and the server's environment must be the one expected, if
+// both are known.
+func serverIdentityMismatch(info k8sctl.ServerVersionInfo, clusterName string, environment string) (err error) {
+	if len(info.Clusters) > 0 && !slices.Contains(info.Clusters, clusterName) {
+		err = fmt.Errorf("the server manages clusters %v, not %s", info.Clusters, clusterName)
+		return err
+	}
+
+	if info.Environment != "" && environment != "" && info.Environment != environment {
+		err = fmt.Errorf("the server is in environment %s, but cluster %s is in %s", info.Environment, clusterName, environment)
+		return err
+	}
+
+	return err
+}
+
+// expectedServerEnvironment returns the environment the cluster's server should be in: $K8SCTL_CLUSTER_SUFFIX, --env,
+// or the cluster's environment, or the default environment, from the config file.  Unlike getClusterSuffix, it doesn't fall
+// back to the cluster name, which is a URL suffix, not an environment: with none configured, it's empty.
+func expectedServerEnvironment(clusterName string) (environment string) {
+	if envSuffix := os.Getenv("K8SCTL_CLUSTER_SUFFIX"); envSuffix != "" {
+		environment = envSuffix
+		return environment
+	}
+
+	if clusterEnvironment != "" {
+		environment = clusterEnvironment
+		return environment
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return environment
+	}
+
+	if clusterCfg, ok := cfg.Clusters[clusterName]; ok && clusterCfg.Environment != "" {
+		environment = clusterCfg.Environment
+		return environment
+	}
+
+	environment = cfg.DefaultEnvironment
+	return environment
+}
@@ -0,0 +1,44 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+// serverOpenAPICmd represents the server openapi command.
+var serverOpenAPICmd = &cobra.Command{
+	Use:   "openapi",
+	Short: "Print the OpenAPI spec for the server's API",
+	Long: `
+Print the OpenAPI 3 spec for the server's /v1 API as JSON.
+
+The spec is generated from the same route table and request/response structs the server uses, so it always matches
+the running code.  A running server also serves it at /openapi.json.
+
+Example:
+  k8sctl server openapi > openapi.json
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		commands := &k8sctl.K8sCtlCommands{}
+
+		spec := k8sctl.GenerateOpenAPISpec(commands.APIRoutes())
+
+		specJSON, err := json.MarshalIndent(spec, "", "  ")
+		if err != nil {
+			log.Fatalf("failed marshalling OpenAPI spec: %s", err)
+		}
+
+		fmt.Println(string(specJSON))
+	},
+}
+
+func init() {
+	serverCmd.AddCommand(serverOpenAPICmd)
+}
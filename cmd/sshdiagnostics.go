@@ -0,0 +1,139 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/nikogura/kubectl-ssh-oidc/pkg/kubectl"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// sshDiagnostics is what was found of the SSH keys a Dex login can be signed with.
+type sshDiagnostics struct {
+	agentSocket string   // $SSH_AUTH_SOCK
+	agentError  error    // why the agent couldn't be used, if it couldn't
+	agentKeys   []string // the agent's keys, as fingerprint and comment
+	keyFiles    []string // the key files that exist, of those that would be tried
+}
+
+// diagnoseSSHKeys looks for the SSH keys kubectl-ssh-oidc would try, without using them: the agent's, then the key
+// files.  Key files aren't read, so encrypted ones don't prompt for their passphrase.
+func diagnoseSSHKeys(config *kubectl.Config) (diagnostics sshDiagnostics) {
+	diagnostics.agentSocket = os.Getenv("SSH_AUTH_SOCK")
+
+	switch {
+	case !config.UseAgent:
+		diagnostics.agentError = errors.New("disabled by SSH_USE_AGENT")
+	case config.IdentitiesOnly:
+		diagnostics.agentError = errors.New("not used, as a key was pinned")
+	case diagnostics.agentSocket == "":
+		diagnostics.agentError = errors.New("$SSH_AUTH_SOCK isn't set")
+	default:
+		diagnostics.agentKeys, diagnostics.agentError = listAgentKeys(diagnostics.agentSocket)
+	}
+
+	keyPaths := config.SSHKeyPaths
+	if len(keyPaths) == 0 {
+		keyPaths = defaultSSHKeyPaths()
+	}
+
+	for _, keyPath := range keyPaths {
+		if _, err := os.Stat(keyPath); err == nil {
+			diagnostics.keyFiles = append(diagnostics.keyFiles, keyPath)
+		}
+	}
+
+	return diagnostics
+}
+
+// listAgentKeys lists the keys in the SSH agent at the socket.
+func listAgentKeys(socket string) (keys []string, err error) {
+	dialer := &net.Dialer{}
+
+	conn, err := dialer.DialContext(context.Background(), "unix", socket)
+	if err != nil {
+		err = fmt.Errorf("unreachable: %w", err)
+		return keys, err
+	}
+	defer conn.Close()
+
+	agentKeys, err := agent.NewClient(conn).List()
+	if err != nil {
+		err = fmt.Errorf("failed listing keys: %w", err)
+		return keys, err
+	}
+
+	for _, agentKey := range agentKeys {
+		fingerprint := "unparseable key"
+		if pubKey, parseErr := ssh.ParsePublicKey(agentKey.Blob); parseErr == nil {
+			fingerprint = ssh.FingerprintSHA256(pubKey)
+		}
+
+		keys = append(keys, fmt.Sprintf("%s %s", fingerprint, agentKey.Comment))
+	}
+
+	return keys, err
+}
+
+// defaultSSHKeyPaths are the key files tried when no key is pinned, as ssh tries them.
+func defaultSSHKeyPaths() (paths []string) {
+	for _, name := range []string{"id_rsa", "id_ecdsa", "id_ecdsa_sk", "id_ed25519", "id_ed25519_sk", "id_dsa"} {
+		paths = append(paths, expandHome("~/.ssh/"+name))
+	}
+
+	return paths
+}
+
+// Print writes the diagnostics to stderr.
+func (d sshDiagnostics) Print() {
+	socket := d.agentSocket
+	if socket == "" {
+		socket = "(none)"
+	}
+
+	fmt.Fprintf(os.Stderr, "SSH agent: %s\n", socket)
+	if d.agentError != nil {
+		fmt.Fprintf(os.Stderr, "  not used: %s\n", d.agentError)
+	} else {
+		fmt.Fprintf(os.Stderr, "  %d key(s)\n", len(d.agentKeys))
+	}
+
+	for _, key := range d.agentKeys {
+		fmt.Fprintf(os.Stderr, "  %s\n", key)
+	}
+
+	fmt.Fprintf(os.Stderr, "SSH key files: %d found\n", len(d.keyFiles))
+	for _, keyFile := range d.keyFiles {
+		fmt.Fprintf(os.Stderr, "  %s\n", keyFile)
+	}
+}
+
+// Hint suggests what to do about failing to sign a Dex login: whether it looks like an SSH key problem, or a Dex one.
+func (d sshDiagnostics) Hint(err error) (hint string) {
+	var keyErrors *kubectl.MultiKeyAuthError
+
+	switch {
+	case len(d.agentKeys) == 0 && len(d.keyFiles) == 0:
+		hint = "No SSH keys were found: load one into your agent with ssh-add, or name one with --ssh-key."
+	case errors.As(err, &keyErrors) && len(keyErrors.KeyErrors) > 0:
+		var tried []string
+		for _, keyErr := range keyErrors.KeyErrors {
+			tried = append(tried, fmt.Sprintf("%s (%s)", keyErr.Fingerprint, keyErr.Source))
+		}
+		hint = fmt.Sprintf("Tried SSH key(s) %s, but Dex authorized none: check your public key is registered with Dex, or pin the one that is with --ssh-key.", strings.Join(tried, ", "))
+	case len(d.agentKeys) == 0:
+		hint = "The SSH agent has no keys, and the key files couldn't be used: an encrypted key needs a terminal for its passphrase, or load it into your agent with ssh-add."
+	default:
+		hint = "The SSH keys were found, but signing failed: check your agent with ssh-add -l."
+	}
+
+	return hint
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nikogura/k8sctl/pkg/tlsconfig"
+)
+
+var insecureSkipVerify bool
+
+var caCertFile string
+
+// transportOnce guards building the transport shared by every client, so the CA bundle is only read once.
+var transportOnce sync.Once
+
+var sharedTransport *http.Transport
+
+var sharedTransportErr error
+
+// clientMaxIdleConnsPerHost is how many idle connections to each of Dex and the server are kept for reuse.  Go's
+// default of 2 is too few for commands making requests concurrently, like batch creates.
+const clientMaxIdleConnsPerHost = 16
+
+// clientIdleConnTimeout is how long an idle connection is kept for reuse, long enough to span a poll loop's interval.
+const clientIdleConnTimeout = 2 * time.Minute
+
+// newHTTPClient returns a client for talking to Dex and the k8sctl server, with the TLS settings from the flags.
+// Every client shares one transport, which pools the connections, so a command making several requests reuses them.
+// The client itself only carries the timeout, so each call can have its own.
+func newHTTPClient(timeout time.Duration) (client *http.Client, err error) {
+	transportOnce.Do(func() {
+		sharedTransport, sharedTransportErr = clientTransport()
+	})
+
+	err = sharedTransportErr
+	if err != nil {
+		return client, err
+	}
+
+	client = &http.Client{
+		Timeout:   timeout,
+		Transport: sharedTransport,
+	}
+
+	return client, err
+}
+
+// clientTransport returns the transport for Dex and server requests, keeping connections alive for reuse.
+// With --ca-cert (or K8SCTL_CA_CERT), the CAs in that PEM bundle are trusted as well as the system's, for Dex and
+// servers with certificates from an internal CA.
+// With --insecure-skip-verify (or K8SCTL_INSECURE_SKIP_VERIFY=true), certificates aren't verified, for dev
+// environments with self-signed certs.  That's warned about on stderr.
+func clientTransport() (transport *http.Transport, err error) {
+	var pool *x509.CertPool
+
+	if caFile := getConfigValue(caCertFile, "K8SCTL_CA_CERT"); caFile != "" {
+		pool, err = tlsconfig.LoadCertPool(caFile)
+		if err != nil {
+			return transport, err
+		}
+	}
+
+	skipVerify := insecureSkipVerify
+	if envValue, parseErr := strconv.ParseBool(os.Getenv("K8SCTL_INSECURE_SKIP_VERIFY")); parseErr == nil && envValue {
+		skipVerify = true
+	}
+
+	if skipVerify {
+		fmt.Fprintf(os.Stderr, "WARNING: TLS certificate verification is disabled (--insecure-skip-verify). Connections to Dex and the k8sctl server can be intercepted. Only use this in local development.\n")
+	}
+
+	transport = tlsconfig.NewTransport(pool, skipVerify)
+	transport.MaxIdleConnsPerHost = clientMaxIdleConnsPerHost
+	transport.IdleConnTimeout = clientIdleConnTimeout
+
+	return transport, err
+}
@@ -0,0 +1,128 @@
+/*
+Copyright © 2025 Nik Ogura
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/spf13/cobra"
+)
+
+// buildInfo is this binary's build info, set from main via SetBuildInfo.
+var buildInfo = k8sctl.NewBuildInfo("dev", "unknown", "unknown")
+
+var versionServer bool
+
+// versionCheckTimeout bounds the unauthenticated /version request to the server.
+const versionCheckTimeout = 10 * time.Second
+
+// SetBuildInfo records the version, git commit, and build date injected into main via ldflags.
+func SetBuildInfo(version string, commit string, date string) {
+	buildInfo = k8sctl.NewBuildInfo(version, commit, date)
+}
+
+// versionCmd represents the version command.
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the k8sctl version, and optionally the server's",
+	Long: `
+Print the version, git commit, and build date of this k8sctl binary.
+
+With --server, also query the /version endpoint of the cluster's k8sctl server, so you can check that client and
+server are compatible before running operations: it shows the API versions the server serves, and warns if this
+client's API version (--version, default v1) isn't one of them.  No authentication is needed.
+
+Example:
+  k8sctl version
+  k8sctl -c cluster1 version --server
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Printf("Client: %s\n", buildInfo)
+
+		if !versionServer {
+			return
+		}
+
+		baseURL := getServerBaseURL(cluster)
+
+		if verbose {
+			fmt.Printf("Target URL: %s/version\n", baseURL)
+		}
+
+		serverInfo, err := fetchServerVersion(baseURL)
+		if err != nil {
+			log.Fatalf("failed getting server version: %s", err)
+		}
+
+		fmt.Printf("Server: %s\n", serverInfo.BuildInfo)
+
+		if len(serverInfo.APIVersions) == 0 {
+			fmt.Printf("Server API versions: unknown (server predates API version advertisement)\n")
+			return
+		}
+
+		fmt.Printf("Server API versions: %s\n", strings.Join(serverInfo.APIVersions, ", "))
+
+		if !slices.Contains(serverInfo.APIVersions, apiVersion) {
+			fmt.Printf("WARNING: this client uses API version %s, which the server does not serve\n", apiVersion)
+		}
+	},
+}
+
+// fetchServerVersion gets the build info and supported API versions from a k8sctl server's /version endpoint.
+func fetchServerVersion(baseURL string) (info k8sctl.ServerVersionInfo, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), versionCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/version", nil)
+	if err != nil {
+		err = fmt.Errorf("failed to create request: %w", err)
+		return info, err
+	}
+
+	client, err := newHTTPClient(versionCheckTimeout)
+	if err != nil {
+		return info, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("failed requesting %s: %w", req.URL, err)
+		return info, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		err = fmt.Errorf("failed reading response body: %w", err)
+		return info, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("server returned status %d: %s", resp.StatusCode, body)
+		return info, err
+	}
+
+	err = json.Unmarshal(body, &info)
+	if err != nil {
+		err = fmt.Errorf("failed parsing version response: %w", err)
+		return info, err
+	}
+
+	return info, err
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+	versionCmd.Flags().BoolVar(&versionServer, "server", false, "Also show the version of the cluster's k8sctl server")
+}
@@ -17,6 +17,14 @@ import (
 	"github.com/nikogura/k8sctl/cmd"
 )
 
+// Set at build time, e.g. go build -ldflags "-X main.version=1.2.3 -X main.commit=$(git rev-parse --short HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)".
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
 func main() {
+	cmd.SetBuildInfo(version, commit, date)
 	cmd.Execute()
 }
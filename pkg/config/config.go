@@ -4,17 +4,60 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+// CurrentVersion is the config schema version this k8sctl understands.  Configs written before the version field
+// existed have no version, and are read as version 0.
+const CurrentVersion = 1
+
+// EnvironmentPlaceholder is replaced with a cluster's environment suffix in the URL and audience templates.
+const EnvironmentPlaceholder = "{environment}"
+
+// DefaultURLTemplate is the server URL of clusters without a server_url, when the config has no url_template.
+const DefaultURLTemplate = "https://k8sctl-" + EnvironmentPlaceholder + ".example.com"
+
 // Config represents the k8sctl configuration.
 type Config struct {
+	// Version is the config schema version.  See CurrentVersion.
+	Version int `yaml:"version,omitempty"`
+
 	// Clusters maps cluster names to their configuration
 	Clusters map[string]ClusterConfig `yaml:"clusters"`
 
 	// DefaultEnvironment is used when cluster is not found in mappings
 	DefaultEnvironment string `yaml:"default_environment,omitempty"`
+
+	// DefaultInstanceTypes maps node roles to the instance type new nodes get when neither the request nor the role's
+	// node config names one (server side)
+	DefaultInstanceTypes map[string]string `yaml:"default_instance_types,omitempty"`
+
+	// RequiredTags are the EC2 tags every cluster's instances must have, e.g. team or cost-center, which reconciles
+	// report the instances missing.  Each maps to the value a reconcile's fix_tags sets when it's missing, or "" for
+	// none, leaving it to be set by hand (server side).
+	RequiredTags map[string]string `yaml:"required_tags,omitempty"`
+
+	// URLTemplate is the server URL of clusters without a server_url, with EnvironmentPlaceholder replaced by the
+	// cluster's environment suffix.  Defaults to DefaultURLTemplate.
+	URLTemplate string `yaml:"url_template,omitempty"`
+
+	// AudienceTemplate is the audience the server expects of tokens, likewise.  Defaults to the URL template, for
+	// servers whose audience is their URL.
+	AudienceTemplate string `yaml:"audience_template,omitempty"`
+
+	// Defaults are the values of CLI flags that aren't given.  Flags always override them.
+	Defaults Defaults `yaml:"defaults,omitempty"`
+}
+
+// Defaults are the CLI's flag defaults.
+type Defaults struct {
+	// Verbose turns on --verbose, unless --quiet is given.
+	Verbose bool `yaml:"verbose,omitempty"`
+
+	// Output is the --output format, e.g. json, of the commands that have it.  Commands without it keep their own default.
+	Output string `yaml:"output,omitempty"`
 }
 
 // ClusterConfig represents configuration for a single cluster.
@@ -24,6 +67,22 @@ type ClusterConfig struct {
 
 	// ServerURL overrides the default server URL for this cluster
 	ServerURL string `yaml:"server_url,omitempty"`
+
+	// Region is the AWS region the cluster runs in.  If unset, the server's default region is used.
+	Region string `yaml:"region,omitempty"`
+
+	// AWSRoleARN is an IAM role the server assumes to manage this cluster, e.g. for clusters in other AWS accounts (server side)
+	AWSRoleARN string `yaml:"aws_role_arn,omitempty"`
+
+	// AWSExternalID is the external ID to pass when assuming AWSRoleARN, if the role's trust policy requires one (server side)
+	AWSExternalID string `yaml:"aws_external_id,omitempty"`
+
+	// AWSSessionName is the session name used when assuming AWSRoleARN.  Defaults to k8sctl-<cluster> (server side)
+	AWSSessionName string `yaml:"aws_session_name,omitempty"`
+
+	// RequiredTags are more required tags for this cluster's instances, as the top level required_tags, or other
+	// defaults for them, e.g. environment (server side)
+	RequiredTags map[string]string `yaml:"required_tags,omitempty"`
 }
 
 // Load loads configuration from a file.
@@ -42,9 +101,79 @@ func Load(path string) (cfg *Config, err error) {
 		return cfg, err
 	}
 
+	err = checkVersion(path, cfg.Version)
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.expandEnv()
+
 	return cfg, err
 }
 
+// expandEnv replaces ${VAR} and $VAR in the config's string values with the environment variable's value, so one
+// config can be shared by environments that differ only in their environment variables.  Unset variables expand to "".
+func (c *Config) expandEnv() {
+	c.DefaultEnvironment = expandEnv(c.DefaultEnvironment)
+	c.URLTemplate = expandEnv(c.URLTemplate)
+	c.AudienceTemplate = expandEnv(c.AudienceTemplate)
+	c.Defaults.Output = expandEnv(c.Defaults.Output)
+
+	for role, instanceType := range c.DefaultInstanceTypes {
+		c.DefaultInstanceTypes[role] = expandEnv(instanceType)
+	}
+
+	for key, value := range c.RequiredTags {
+		c.RequiredTags[key] = expandEnv(value)
+	}
+
+	for name, clusterCfg := range c.Clusters {
+		clusterCfg.Environment = expandEnv(clusterCfg.Environment)
+		clusterCfg.ServerURL = expandEnv(clusterCfg.ServerURL)
+		clusterCfg.Region = expandEnv(clusterCfg.Region)
+		clusterCfg.AWSRoleARN = expandEnv(clusterCfg.AWSRoleARN)
+		clusterCfg.AWSExternalID = expandEnv(clusterCfg.AWSExternalID)
+		clusterCfg.AWSSessionName = expandEnv(clusterCfg.AWSSessionName)
+		for key, value := range clusterCfg.RequiredTags {
+			clusterCfg.RequiredTags[key] = expandEnv(value)
+		}
+		c.Clusters[name] = clusterCfg
+	}
+}
+
+// expandEnv expands environment variables in a config value.  $$ is a literal $.
+func expandEnv(value string) (expanded string) {
+	if !strings.Contains(value, "$") {
+		expanded = value
+		return expanded
+	}
+
+	expanded = os.Expand(value, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+
+		return os.Getenv(name)
+	})
+
+	return expanded
+}
+
+// checkVersion returns an error for an invalid config version, and warns about a version newer than this k8sctl
+// understands, since settings added in that version would be silently ignored.
+func checkVersion(path string, version int) (err error) {
+	if version < 0 {
+		err = fmt.Errorf("invalid version %d in config file %s", version, path)
+		return err
+	}
+
+	if version > CurrentVersion {
+		fmt.Fprintf(os.Stderr, "WARNING: config file %s is version %d, but this k8sctl only understands up to version %d.  Settings it doesn't know are ignored.  Upgrade k8sctl.\n", path, version, CurrentVersion)
+	}
+
+	return err
+}
+
 // LoadDefault attempts to load configuration from default locations.
 // It searches in order:
 // 1. K8SCTL_CONFIG environment variable
@@ -53,15 +182,32 @@ func Load(path string) (cfg *Config, err error) {
 // 4. /etc/k8sctl/config.yaml
 // Returns nil config if no file found (not an error).
 func LoadDefault() (cfg *Config, err error) {
-	// Check environment variable first
-	if configPath := os.Getenv("K8SCTL_CONFIG"); configPath != "" {
-		cfg, err = Load(configPath)
+	if path := DefaultPath(); path != "" {
+		cfg, err = Load(path)
 		if err != nil {
 			return cfg, err
 		}
 		return cfg, err
 	}
 
+	// No config found - return empty config (not an error)
+	cfg = &Config{
+		Clusters:           make(map[string]ClusterConfig),
+		DefaultEnvironment: "dev",
+	}
+
+	return cfg, err
+}
+
+// DefaultPath returns the config file LoadDefault loads, searching the same locations in the same order.
+// Returns "" if there is no config file.
+func DefaultPath() (path string) {
+	// Check environment variable first
+	if configPath := os.Getenv("K8SCTL_CONFIG"); configPath != "" {
+		path = configPath
+		return path
+	}
+
 	// Try default locations
 	locations := []string{
 		"./k8sctl.yaml",
@@ -70,24 +216,14 @@ func LoadDefault() (cfg *Config, err error) {
 	}
 
 	for _, loc := range locations {
-		var statErr error
-		_, statErr = os.Stat(loc)
+		_, statErr := os.Stat(loc)
 		if statErr == nil {
-			cfg, err = Load(loc)
-			if err != nil {
-				return cfg, err
-			}
-			return cfg, err
+			path = loc
+			return path
 		}
 	}
 
-	// No config found - return empty config (not an error)
-	cfg = &Config{
-		Clusters:           make(map[string]ClusterConfig),
-		DefaultEnvironment: "dev",
-	}
-
-	return cfg, err
+	return path
 }
 
 // GetClusterEnvironment returns the environment suffix for a cluster.
@@ -118,3 +254,68 @@ func (c *Config) GetClusterServerURL(clusterName string) (serverURL string) {
 	serverURL = ""
 	return serverURL
 }
+
+// GetClusterRegion returns the AWS region for a cluster.
+// If not configured, returns empty string (server uses its default region).
+func (c *Config) GetClusterRegion(clusterName string) (region string) {
+	if clusterCfg, ok := c.Clusters[clusterName]; ok {
+		region = clusterCfg.Region
+		return region
+	}
+
+	region = ""
+	return region
+}
+
+// GetDefaultInstanceType returns the default instance type for a node role.
+// If not configured, returns empty string.
+func (c *Config) GetDefaultInstanceType(role string) (instanceType string) {
+	instanceType = c.DefaultInstanceTypes[role]
+	return instanceType
+}
+
+// GetRequiredTags returns the EC2 tags a cluster's instances must have, with their defaults: the top level
+// required_tags, plus the cluster's own, whose defaults win.
+func (c *Config) GetRequiredTags(clusterName string) (tags map[string]string) {
+	clusterTags := c.Clusters[clusterName].RequiredTags
+	if len(c.RequiredTags) == 0 && len(clusterTags) == 0 {
+		return tags
+	}
+
+	tags = make(map[string]string, len(c.RequiredTags)+len(clusterTags))
+	for key, value := range c.RequiredTags {
+		tags[key] = value
+	}
+	for key, value := range clusterTags {
+		tags[key] = value
+	}
+
+	return tags
+}
+
+// GetURLTemplate returns the template for the server URL of clusters without a server_url.
+func (c *Config) GetURLTemplate() (template string) {
+	template = c.URLTemplate
+	if template == "" {
+		template = DefaultURLTemplate
+	}
+
+	return template
+}
+
+// GetAudienceTemplate returns the template for the audience the server expects of tokens, the URL template unless
+// the config sets its own.
+func (c *Config) GetAudienceTemplate() (template string) {
+	template = c.AudienceTemplate
+	if template == "" {
+		template = c.GetURLTemplate()
+	}
+
+	return template
+}
+
+// FillTemplate returns a URL or audience template with EnvironmentPlaceholder replaced by the environment suffix.
+func FillTemplate(template string, environment string) (filled string) {
+	filled = strings.ReplaceAll(template, EnvironmentPlaceholder, environment)
+	return filled
+}
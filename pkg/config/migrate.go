@@ -0,0 +1,104 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// versionKey is the config file key the schema version is stored under.
+const versionKey = "version"
+
+// Migrate upgrades a config file to CurrentVersion in place, keeping its comments and any settings this k8sctl doesn't
+// know.  The original file is kept alongside it, at <path>.bak.  It returns the version the file was at, and
+// whether it was changed: a file already at CurrentVersion is left alone.
+func Migrate(path string) (from int, migrated bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		err = fmt.Errorf("failed to read config file %s: %w", path, err)
+		return from, migrated, err
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		return from, migrated, err
+	}
+
+	from = cfg.Version
+
+	if from > CurrentVersion {
+		err = fmt.Errorf("config file %s is version %d, newer than this k8sctl's version %d", path, from, CurrentVersion)
+		return from, migrated, err
+	}
+
+	if from == CurrentVersion {
+		return from, migrated, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		err = fmt.Errorf("failed to read config file %s: %w", path, err)
+		return from, migrated, err
+	}
+
+	migratedData := migrateData(data)
+
+	backupPath := path + ".bak"
+	err = os.WriteFile(backupPath, data, info.Mode().Perm())
+	if err != nil {
+		err = fmt.Errorf("failed to back up config file %s to %s: %w", path, backupPath, err)
+		return from, migrated, err
+	}
+
+	err = os.WriteFile(path, migratedData, info.Mode().Perm())
+	if err != nil {
+		err = fmt.Errorf("failed to write config file %s: %w", path, err)
+		return from, migrated, err
+	}
+
+	migrated = true
+	return from, migrated, err
+}
+
+// migrateData upgrades a config file's contents to CurrentVersion.  Version 1 only added the version field, so that's
+// all there is to set, and it's set in the text itself so the file's layout is kept as it is.  Later schema changes add
+// their steps here, in order of the version they upgrade from.
+func migrateData(data []byte) (migrated []byte) {
+	versionLine := fmt.Sprintf("%s: %d", versionKey, CurrentVersion)
+
+	// An explicit older version is replaced where it is
+	existing := regexp.MustCompile(`(?m)^` + versionKey + `:.*$`)
+	if existing.Match(data) {
+		migrated = existing.ReplaceAll(data, []byte(versionLine))
+		return migrated
+	}
+
+	// Otherwise it goes first, after any leading comments
+	lines := strings.SplitAfter(string(data), "\n")
+	header := 0
+	for header < len(lines) {
+		line := strings.TrimSpace(lines[header])
+		if line != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+		header++
+	}
+
+	// Keep comments about the first setting with it, rather than above the version
+	for header > 0 && strings.TrimSpace(lines[header-1]) != "" && header < len(lines) {
+		header--
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(strings.Join(lines[:header], ""))
+	if header > 0 && !strings.HasSuffix(lines[header-1], "\n") {
+		buf.WriteString("\n")
+	}
+	buf.WriteString(versionLine + "\n\n")
+	buf.WriteString(strings.Join(lines[header:], ""))
+
+	migrated = buf.Bytes()
+	return migrated
+}
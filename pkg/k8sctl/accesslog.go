@@ -0,0 +1,52 @@
+package k8sctl
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader is the header a request's ID is read from, if the client sent one, and returned in.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limits the length of a client's request ID, so it can't bloat the logs.
+const maxRequestIDLength = 128
+
+// AccessLog returns middleware that logs each request, once it's been handled, with its status, latency, request ID,
+// and the authenticated user.  The request ID is the client's X-Request-ID, or a new one, and is returned in the
+// response's X-Request-ID header and stored in the context as "request_id".
+func AccessLog(logger *zap.Logger) (handler gin.HandlerFunc) {
+	handler = func(ctx *gin.Context) {
+		start := time.Now()
+
+		requestID := ctx.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+
+		ctx.Set("request_id", requestID)
+		ctx.Header(RequestIDHeader, requestID)
+
+		ctx.Next()
+
+		fields := []zap.Field{
+			zap.String("method", ctx.Request.Method),
+			zap.String("path", ctx.Request.URL.Path),
+			zap.Int("status", ctx.Writer.Status()),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", ctx.ClientIP()),
+			zap.String("request_id", requestID),
+			zap.String("user_email", ctx.GetString("user_email")),
+		}
+
+		if len(ctx.Errors) > 0 {
+			fields = append(fields, zap.String("errors", ctx.Errors.String()))
+		}
+
+		logger.Info("request", fields...)
+	}
+
+	return handler
+}
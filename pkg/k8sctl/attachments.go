@@ -0,0 +1,124 @@
+package k8sctl
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+)
+
+// NodeLBAttachment is the load balancer target groups a node is registered with, and its health in each.
+// MissingTargetGroups are the other target groups of those same load balancers, which the node isn't registered with:
+// a node in some but not all of a load balancer's target groups is usually a partly failed attach.
+type NodeLBAttachment struct {
+	Node                string            `json:"node"`
+	ID                  string            `json:"id"`
+	TargetGroups        []NodeTargetGroup `json:"target_groups"`
+	MissingTargetGroups []string          `json:"missing_target_groups,omitempty"`
+}
+
+// NodeTargetGroup is a node's registration in one load balancer target group.
+type NodeTargetGroup struct {
+	LoadBalancer string `json:"load_balancer"`
+	TargetGroup  string `json:"target_group"`
+	Port         int32  `json:"port"`
+	State        string `json:"state"`
+}
+
+// ConsolePrint prints the node and its target groups, one per line.
+func (a NodeLBAttachment) ConsolePrint() {
+	fmt.Printf("  %s (%s)\n", a.Node, a.ID)
+
+	if len(a.TargetGroups) == 0 {
+		fmt.Printf("    not in any load balancer\n")
+	}
+
+	for _, tg := range a.TargetGroups {
+		fmt.Printf("    %s/%s:%d (%s)\n", tg.LoadBalancer, tg.TargetGroup, tg.Port, tg.State)
+	}
+
+	for _, name := range a.MissingTargetGroups {
+		fmt.Printf("    %s missing from %s\n", consoleMarkers.Warn, name)
+	}
+}
+
+// nodeLBAttachments maps each node to the target groups it's registered with, from the target groups' health.
+// Targets are matched to nodes by instance ID, or for target groups of type ip, by any of the node's private IPv4 and
+// IPv6 addresses.
+func nodeLBAttachments(nodes []manager.NodeInfo, nodeIPs map[string][]string, health []targetGroupHealth) (attachments []NodeLBAttachment) {
+	attachments = make([]NodeLBAttachment, 0, len(nodes))
+
+	for _, node := range nodes {
+		attachment := NodeLBAttachment{
+			Node:         node.Name,
+			ID:           node.ID,
+			TargetGroups: make([]NodeTargetGroup, 0),
+		}
+
+		addresses := nodeIPs[node.ID]
+
+		attachedLBs := make(map[string]bool)
+		registered := make(map[string]bool)
+
+		for _, tgHealth := range health {
+			for _, desc := range tgHealth.output.TargetHealthDescriptions {
+				if desc.Target == nil || desc.Target.Id == nil {
+					continue
+				}
+
+				if *desc.Target.Id != node.ID && !isNodeAddress(*desc.Target.Id, addresses) {
+					continue
+				}
+
+				tg := NodeTargetGroup{
+					LoadBalancer: tgHealth.lb,
+					TargetGroup:  tgHealth.tg.Name,
+					Port:         tgHealth.tg.Port,
+				}
+				if desc.Target.Port != nil {
+					tg.Port = *desc.Target.Port
+				}
+				if desc.TargetHealth != nil {
+					tg.State = string(desc.TargetHealth.State)
+				}
+
+				attachment.TargetGroups = append(attachment.TargetGroups, tg)
+				attachedLBs[tgHealth.lb] = true
+				registered[tgHealth.lb+"/"+tgHealth.tg.Name] = true
+			}
+		}
+
+		for _, tgHealth := range health {
+			name := tgHealth.lb + "/" + tgHealth.tg.Name
+			if attachedLBs[tgHealth.lb] && !registered[name] {
+				attachment.MissingTargetGroups = append(attachment.MissingTargetGroups, name)
+			}
+		}
+
+		sort.Strings(attachment.MissingTargetGroups)
+
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments
+}
+
+// isNodeAddress says whether an ip target's ID is one of a node's addresses.  They're compared as addresses, so the
+// same IPv6 address written two ways still matches.
+func isNodeAddress(targetID string, addresses []string) (ok bool) {
+	target, err := netip.ParseAddr(targetID)
+	if err != nil {
+		return ok
+	}
+
+	for _, address := range addresses {
+		addr, parseErr := netip.ParseAddr(address)
+		if parseErr == nil && addr == target {
+			ok = true
+			return ok
+		}
+	}
+
+	return ok
+}
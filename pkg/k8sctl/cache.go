@@ -0,0 +1,196 @@
+package k8sctl
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/kubernetes"
+	"github.com/sirupsen/logrus"
+)
+
+// describeCache holds recent describe results, so rapid successive reconciles and monitor ticks don't re-query AWS.
+// It's nil, and caching is off, unless SetDescribeCacheTTL is called with a positive TTL.
+type describeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]describeCacheEntry
+}
+
+type describeCacheEntry struct {
+	value   interface{}
+	fetched time.Time
+}
+
+// SetDescribeCacheTTL enables caching of cluster describe results, per cluster and region, for the given TTL.
+// A TTL of 0 turns caching off.
+func SetDescribeCacheTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		clusterCache = nil
+		return
+	}
+
+	clusterCache = &describeCache{
+		ttl:     ttl,
+		entries: make(map[string]describeCacheEntry),
+	}
+}
+
+// get returns the cached value for key, if there is one younger than the TTL.
+func (c *describeCache) get(key string) (value interface{}, ok bool) {
+	if c == nil {
+		return value, ok
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[key]
+	if !found {
+		logrus.Debugf("describe cache miss for %s", key)
+		return value, ok
+	}
+
+	age := time.Since(entry.fetched)
+	if age > c.ttl {
+		delete(c.entries, key)
+		logrus.Debugf("describe cache miss for %s (expired %s ago)", key, (age - c.ttl).Round(time.Second))
+		return value, ok
+	}
+
+	logrus.Infof("describe cache hit for %s (age %s)", key, age.Round(time.Second))
+
+	value = entry.value
+	ok = true
+
+	return value, ok
+}
+
+func (c *describeCache) put(key string, value interface{}) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = describeCacheEntry{
+		value:   value,
+		fetched: time.Now(),
+	}
+}
+
+// fetch returns the value for key from the cache if useCache is set and it holds a fresh copy.  Otherwise it calls
+// fetchFunc, caching the value on success.
+func (c *describeCache) fetch(key string, useCache bool, fetchFunc func() (value interface{}, err error)) (value interface{}, err error) {
+	if !useCache {
+		value, err = fetchFunc()
+		return value, err
+	}
+
+	if cached, ok := c.get(key); ok {
+		value = cached
+		return value, err
+	}
+
+	value, err = fetchFunc()
+	if err != nil {
+		return value, err
+	}
+
+	c.put(key, value)
+
+	return value, err
+}
+
+// describeCacheKey identifies cached data by kind, cluster, region and the AWS identity used to fetch it, so that data
+// fetched under one assumed role or profile is never served for another.
+func describeCacheKey(kind string, cm *aws.AWSClusterManager, clusterName string) (key string) {
+	var roleARN string
+	if clusterConfig != nil {
+		roleARN = clusterConfig.Clusters[clusterName].AWSRoleARN
+	}
+
+	key = strings.Join([]string{kind, clusterName, cm.Config.Region, roleARN, os.Getenv("AWS_PROFILE")}, "/")
+
+	return key
+}
+
+// cachedDescribeCluster returns the cluster info, from the describe cache if useCache is set and it holds a fresh copy.
+// Fresh copies are fetched with the concurrent describe.  The info is copied on the way in and out of the cache, so
+// callers may modify what they're given.
+func cachedDescribeCluster(ctx context.Context, cm *aws.AWSClusterManager, clusterName string, useCache bool) (info manager.ClusterInfo, err error) {
+	value, err := clusterCache.fetch(describeCacheKey("describe", cm, clusterName), useCache, func() (value interface{}, err error) {
+		fetched, err := describeClusterInfo(ctx, cm, clusterName)
+		value = cloneClusterInfo(fetched)
+		return value, err
+	})
+	if err != nil {
+		return info, err
+	}
+
+	info = cloneClusterInfo(value.(manager.ClusterInfo))
+
+	return info, err
+}
+
+// cachedNodesInSecurityGroup returns the nodes in the cluster's security group, from the describe cache if useCache is
+// set and it holds a fresh copy.
+func cachedNodesInSecurityGroup(cm *aws.AWSClusterManager, clusterName string, useCache bool) (nodes []manager.NodeInfo, err error) {
+	value, err := clusterCache.fetch(describeCacheKey("security-group", cm, clusterName), useCache, func() (value interface{}, err error) {
+		fetched, err := cm.GetNodesInSecurityGroup()
+		value = slices.Clone(fetched)
+		return value, err
+	})
+	if err != nil {
+		return nodes, err
+	}
+
+	nodes = slices.Clone(value.([]manager.NodeInfo))
+
+	return nodes, err
+}
+
+// cachedK8sNodes returns the names of the cluster's Kubernetes nodes, from the describe cache if useCache is set and it
+// holds a fresh copy.
+func cachedK8sNodes(ctx context.Context, cm *aws.AWSClusterManager, clusterName string, verbose bool, useCache bool) (nodes []string, err error) {
+	value, err := clusterCache.fetch(describeCacheKey("k8s-nodes", cm, clusterName), useCache, func() (value interface{}, err error) {
+		fetched, err := kubernetes.ListNodes(ctx, verbose)
+		value = slices.Clone(fetched)
+		return value, err
+	})
+	if err != nil {
+		return nodes, err
+	}
+
+	nodes = slices.Clone(value.([]string))
+
+	return nodes, err
+}
+
+// cloneClusterInfo copies the cluster info, including the slices it holds.
+func cloneClusterInfo(info manager.ClusterInfo) (clone manager.ClusterInfo) {
+	clone = info
+	clone.Nodes = slices.Clone(info.Nodes)
+
+	if info.LoadBalancers != nil {
+		clone.LoadBalancers = make([]manager.LBInfo, len(info.LoadBalancers))
+		for i, lb := range info.LoadBalancers {
+			clone.LoadBalancers[i] = lb
+			clone.LoadBalancers[i].Targets = slices.Clone(lb.Targets)
+			clone.LoadBalancers[i].TargetGroups = slices.Clone(lb.TargetGroups)
+		}
+	}
+
+	if info.EstimatedDailyCost != nil {
+		cost := *info.EstimatedDailyCost
+		clone.EstimatedDailyCost = &cost
+	}
+
+	return clone
+}
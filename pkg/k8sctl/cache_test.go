@@ -0,0 +1,134 @@
+package k8sctl
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/nikogura/k8sctl/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeCacheFetch(t *testing.T) {
+	SetDescribeCacheTTL(50 * time.Millisecond)
+	t.Cleanup(func() { SetDescribeCacheTTL(0) })
+
+	calls := 0
+	fetchFunc := func() (value interface{}, err error) {
+		calls++
+		value = calls
+		return value, err
+	}
+
+	value, err := clusterCache.fetch("key", true, fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value, "first fetch is a miss")
+
+	value, err = clusterCache.fetch("key", true, fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value, "second fetch within the TTL is a hit")
+
+	value, err = clusterCache.fetch("key", false, fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value, "useCache=false always fetches")
+
+	value, err = clusterCache.fetch("other", true, fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, 3, value, "keys are cached separately")
+
+	time.Sleep(75 * time.Millisecond)
+
+	value, err = clusterCache.fetch("key", true, fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, 4, value, "fetch after the TTL is a miss")
+
+	value, err = clusterCache.fetch("key", true, fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, 4, value, "and refreshes the cache")
+}
+
+func TestDescribeCacheFetchErrorNotCached(t *testing.T) {
+	SetDescribeCacheTTL(time.Minute)
+	t.Cleanup(func() { SetDescribeCacheTTL(0) })
+
+	_, err := clusterCache.fetch("key", true, func() (value interface{}, err error) {
+		err = errors.New("throttled")
+		return value, err
+	})
+	require.Error(t, err)
+
+	value, err := clusterCache.fetch("key", true, func() (value interface{}, err error) {
+		value = "fresh"
+		return value, err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", value)
+}
+
+func TestDescribeCacheDisabled(t *testing.T) {
+	SetDescribeCacheTTL(0)
+
+	calls := 0
+	for range 2 {
+		_, err := clusterCache.fetch("key", true, func() (value interface{}, err error) {
+			calls++
+			return value, err
+		})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, calls, "a nil cache never hits")
+}
+
+func TestDescribeCacheKey(t *testing.T) {
+	origConfig := clusterConfig
+	t.Cleanup(func() { clusterConfig = origConfig })
+
+	cm := &aws.AWSClusterManager{Config: awssdk.Config{Region: "us-east-1"}}
+
+	clusterConfig = nil
+	unassumed := describeCacheKey("describe", cm, "cluster1")
+
+	clusterConfig = &config.Config{Clusters: map[string]config.ClusterConfig{
+		"cluster1": {AWSRoleARN: "arn:aws:iam::123456789012:role/k8sctl"},
+	}}
+	assumed := describeCacheKey("describe", cm, "cluster1")
+
+	assert.NotEqual(t, unassumed, assumed, "data fetched under an assumed role is keyed separately")
+	assert.Contains(t, assumed, "arn:aws:iam::123456789012:role/k8sctl")
+
+	cm.Config.Region = "us-west-2"
+	assert.NotEqual(t, assumed, describeCacheKey("describe", cm, "cluster1"), "regions are keyed separately")
+	assert.NotEqual(t, describeCacheKey("describe", cm, "cluster1"), describeCacheKey("k8s-nodes", cm, "cluster1"))
+}
+
+func TestCloneClusterInfo(t *testing.T) {
+	cost := 12.5
+	info := manager.ClusterInfo{
+		Name:  "cluster1",
+		Nodes: []manager.NodeInfo{{Name: "node-1", ID: "i-1"}},
+		LoadBalancers: []manager.LBInfo{{
+			Name:         "lb-1",
+			Targets:      []manager.LBTargetInfo{{ID: "i-1", Port: 443, State: "healthy"}},
+			TargetGroups: []manager.LBTargetGroupInfo{{Name: "tg-1", Port: 443}},
+		}},
+		EstimatedDailyCost: &cost,
+	}
+
+	clone := cloneClusterInfo(info)
+	assert.Equal(t, info, clone)
+
+	clone.Nodes[0].Name = "changed"
+	clone.LoadBalancers[0].Targets[0].State = "unhealthy"
+	clone.LoadBalancers[0].TargetGroups[0].Name = "changed"
+	*clone.EstimatedDailyCost = 0
+
+	assert.Equal(t, "node-1", info.Nodes[0].Name)
+	assert.Equal(t, "healthy", info.LoadBalancers[0].Targets[0].State)
+	assert.Equal(t, "tg-1", info.LoadBalancers[0].TargetGroups[0].Name)
+	assert.InDelta(t, 12.5, *info.EstimatedDailyCost, 0)
+}
@@ -0,0 +1,177 @@
+package k8sctl
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// capabilityProviders are the cloud providers the server manages clusters on.
+var capabilityProviders = []string{"aws"}
+
+var allowedGroups []string
+var credentialGroups []string
+var adminGroups []string
+
+// SetAccessGroups sets the groups allowed to use the server, and the groups allowed to get cluster credentials, for
+// the capabilities endpoint to report.  It doesn't enforce them: the server's OIDC middleware does.
+func SetAccessGroups(allowed []string, credential []string) {
+	allowedGroups = allowed
+	credentialGroups = credential
+}
+
+// SetAdminGroups sets the groups allowed to call the admin operations, e.g. to turn maintenance mode on, for the
+// capabilities endpoint to report.
+func SetAdminGroups(admin []string) {
+	adminGroups = admin
+}
+
+// CapabilitiesResult is what the server supports: its providers, its operations, and its registered commands, with
+// the groups each needs.
+type CapabilitiesResult struct {
+	Providers     []string            `json:"providers"`
+	AllowedGroups []string            `json:"allowed_groups"` // every operation needs one of these
+	Operations    []Capability        `json:"operations"`
+	Commands      []CommandCapability `json:"commands,omitempty"`
+}
+
+// Capability is one operation the server supports.
+type Capability struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"` // OpenAPI style, e.g. /v1/cluster/{cluster}/upgrade
+	Summary     string `json:"summary"`
+	Destructive bool   `json:"destructive"`
+	Idempotent  bool   `json:"idempotent,omitempty"`
+
+	// Restricted operations are further limited to the members of Groups.  One with no Groups can't be called at all.
+	Restricted bool     `json:"restricted,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+
+	// RoleGated operations check the caller's groups per request, e.g. run, against each command's Role.
+	RoleGated bool `json:"role_gated,omitempty"`
+
+	// Mutating operations change clusters, so they're refused while the server is in maintenance mode.
+	Mutating bool `json:"mutating,omitempty"`
+}
+
+// CommandCapability is one registered command, and the group allowed to run it.  With no Group, anyone allowed to use
+// the server may.
+type CommandCapability struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Group       string `json:"group,omitempty"`
+}
+
+// CapabilitiesHandler returns the operations the server supports, and the groups they need.
+func (c *K8sCtlCommands) CapabilitiesHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, capabilities(c.APIRoutes(), c.Commands))
+}
+
+// capabilities lists the routes and registered commands, with the groups each needs.
+func capabilities(routes []APIRoute, commands []*K8sCtlCommand) (result CapabilitiesResult) {
+	result = CapabilitiesResult{
+		Providers:     capabilityProviders,
+		AllowedGroups: allowedGroups,
+		Operations:    make([]Capability, 0, len(routes)),
+	}
+
+	for _, route := range routes {
+		path, _ := openAPIPath(APIPathPrefix + route.Path)
+
+		capability := Capability{
+			Method:      route.Method,
+			Path:        path,
+			Summary:     route.Summary,
+			Destructive: route.Destructive,
+			Idempotent:  route.Idempotent,
+			RoleGated:   route.RoleGated,
+			Mutating:    route.Mutating,
+		}
+
+		if route.Credentials {
+			capability.Restricted = true
+			capability.Groups = credentialGroups
+		}
+
+		if route.Admin {
+			capability.Restricted = true
+			capability.Groups = adminGroups
+		}
+
+		result.Operations = append(result.Operations, capability)
+	}
+
+	for _, command := range commands {
+		result.Commands = append(result.Commands, CommandCapability{
+			Name:        command.Name,
+			Description: command.Description,
+			Group:       command.Role,
+		})
+	}
+
+	return result
+}
+
+// ConsolePrint prints the operations, then the registered commands, as tables.
+func (r CapabilitiesResult) ConsolePrint() {
+	fmt.Printf("Providers: %s\n", strings.Join(r.Providers, ", "))
+	fmt.Printf("Allowed groups: %s\n\n", groupsString(r.AllowedGroups, false))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "METHOD\tPATH\tDESTRUCTIVE\tGROUPS\tSUMMARY")
+	for _, op := range r.Operations {
+		destructive := ""
+		if op.Destructive {
+			destructive = consoleMarkers.Warn + " yes"
+		}
+
+		groups := groupsString(op.Groups, op.Restricted)
+		if op.RoleGated {
+			groups = "per request"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", op.Method, op.Path, destructive, groups, op.Summary)
+	}
+
+	_ = w.Flush()
+
+	if len(r.Commands) == 0 {
+		return
+	}
+
+	fmt.Printf("\nCommands:\n")
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "NAME\tGROUP\tDESCRIPTION")
+	for _, command := range r.Commands {
+		group := command.Group
+		if group == "" {
+			group = "any"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n", command.Name, group, command.Description)
+	}
+
+	_ = w.Flush()
+}
+
+// groupsString describes the groups an operation needs: "any" allowed group if it isn't restricted, and "none" if it's
+// restricted to no groups at all.
+func groupsString(groups []string, restricted bool) (s string) {
+	switch {
+	case len(groups) > 0:
+		s = strings.Join(groups, ", ")
+	case restricted:
+		s = "none"
+	default:
+		s = "any"
+	}
+
+	return s
+}
@@ -0,0 +1,316 @@
+package k8sctl
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+	machineapi "github.com/siderolabs/talos/pkg/machinery/api/machine"
+	"github.com/siderolabs/talos/pkg/machinery/client"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// defaultClusterCreateTimeout is how long a cluster create waits for the new control plane to bootstrap and serve the
+// Kubernetes API, when the request doesn't say.
+const defaultClusterCreateTimeout = 20 * time.Minute
+
+// clusterCreatePollInterval is how often a cluster create retries bootstrapping, and checks for the Kubernetes API.
+const clusterCreatePollInterval = 10 * time.Second
+
+// ClusterCreateBody describes the first control plane node of a new cluster.
+type ClusterCreateBody struct {
+	Name          string `json:"name,omitempty"` // the control plane node's name, default <cluster>-cp-1
+	Type          string `json:"type,omitempty"` // instance type, overriding the node config's
+	Timeout       int    `json:"timeout,omitempty"`
+	Verbose       bool   `json:"verbose"`
+	CloudProvider string `json:"cloud_provider"`
+	Region        string `json:"region,omitempty"`
+}
+
+// ClusterCreateResult reports how far a cluster create got, and the new cluster's kubeconfig once its API is up.
+type ClusterCreateResult struct {
+	Cluster      string `json:"cluster"`
+	Node         string `json:"node"`
+	ID           string `json:"id,omitempty"`
+	IP           string `json:"ip,omitempty"`
+	Bootstrapped bool   `json:"bootstrapped"`
+	APIReady     bool   `json:"api_ready"`
+	Kubeconfig   string `json:"kubeconfig,omitempty"`
+	Error        string `json:"error,omitempty"` // set when the node was created, but the cluster didn't come up
+}
+
+// ConsolePrint prints how far the cluster create got.  The kubeconfig itself isn't printed.
+func (r ClusterCreateResult) ConsolePrint() {
+	fmt.Printf("Cluster %s: control plane node %s (%s, %s)\n", r.Cluster, r.Node, r.ID, r.IP)
+	fmt.Printf("  bootstrapped: %t\n", r.Bootstrapped)
+	fmt.Printf("  API ready:    %t\n", r.APIReady)
+
+	if r.Error != "" {
+		fmt.Printf("  failed: %s\n", r.Error)
+	}
+}
+
+// CreateClusterHandler creates a new cluster's first control plane node, bootstraps etcd on it, and waits for the
+// Kubernetes API to come up.  It refuses to run against a cluster that already has nodes.
+func (c *K8sCtlCommands) CreateClusterHandler(ctx *gin.Context) {
+	clusterName := ctx.Param("cluster")
+
+	var body ClusterCreateBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	cloudProvider := strings.ToLower(body.CloudProvider)
+
+	// Error out if we're doing anything other than AWS
+	if cloudProvider != "aws" {
+		providerErr := errors.New(fmt.Sprintf("Unsupported cloud provider: %s", cloudProvider))
+		logrus.Errorf("Unsupported cloud provider %s: %s", cloudProvider, providerErr)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, providerErr)
+		return
+	}
+
+	nodeName := body.Name
+	if nodeName == "" {
+		nodeName = fmt.Sprintf("%s-cp-1", clusterName)
+	}
+
+	timeout := defaultClusterCreateTimeout
+	if body.Timeout > 0 {
+		timeout = time.Duration(body.Timeout) * time.Second
+	}
+
+	logrus.Infof("creating cluster %s with control plane node %s", clusterName, nodeName)
+
+	cm, err := newClusterManager(ctx, clusterName, body.Region, body.Verbose)
+	if err != nil {
+		logrus.Errorf("Failed creating cluster manager: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	existing, err := cm.GetNodes(clusterName)
+	if err != nil {
+		logrus.Errorf("failed listing nodes in cluster %s: %s", clusterName, err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(existing) > 0 {
+		err = errors.New(fmt.Sprintf("cluster %s already has %d node(s); add nodes with node create", clusterName, len(existing)))
+		_ = ctx.AbortWithError(http.StatusConflict, err)
+		return
+	}
+
+	files, err := loadNodeConfigFiles(clusterName, manager.NodeRoleCp, cloudProvider)
+	if err != nil {
+		logrus.Errorf("failed loading node config files: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	nodeConfig := files.NodeConfig
+
+	var instanceTypeSource string
+	nodeConfig.InstanceType, instanceTypeSource = chooseInstanceType(body.Type, nodeConfig.InstanceType, defaultInstanceType(manager.NodeRoleCp))
+	logrus.Infof("setting instance type to %q, from the %s", nodeConfig.InstanceType, instanceTypeSource)
+
+	err = cm.CreateNode(nodeName, manager.NodeRoleCp, nodeConfig, files.MachineConfig, files.Patches, "")
+	if err != nil {
+		logrus.Errorf("error creating node: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	result := ClusterCreateResult{Cluster: clusterName, Node: nodeName}
+
+	err = bootstrapCluster(ctx, cm, nodeName, timeout, &result)
+	if err != nil {
+		// The node exists now, so say how far we got, rather than just failing
+		logrus.Errorf("created node %s, but cluster %s didn't come up: %s", nodeName, clusterName, err)
+		result.Error = err.Error()
+		_ = ctx.Error(err)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, result)
+		return
+	}
+
+	logrus.Infof("cluster %s is up, with control plane node %s", clusterName, nodeName)
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// bootstrapCluster bootstraps etcd on a new control plane node, then waits for it to serve the Kubernetes API,
+// recording its progress in the result.
+func bootstrapCluster(ctx context.Context, cm *aws.AWSClusterManager, nodeName string, timeout time.Duration, result *ClusterCreateResult) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result.ID, result.IP, err = runningNodeAddress(cm, nodeName)
+	if err != nil {
+		return err
+	}
+
+	nodeIP := result.IP
+
+	// The node installs Talos and reboots after its config is applied, so its API may not answer at first
+	err = pollUntil(ctx, clusterCreatePollInterval, func() (done bool, pollErr error) {
+		pollErr = bootstrapNode(ctx, nodeIP)
+		done = pollErr == nil
+		if !done {
+			logrus.Debugf("bootstrapping %s not done yet: %s", nodeName, pollErr)
+		}
+
+		return done, pollErr
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed bootstrapping node %s", nodeName)
+		return err
+	}
+
+	result.Bootstrapped = true
+	logrus.Infof("bootstrapped node %s, waiting for the Kubernetes API", nodeName)
+
+	var kubeconfig []byte
+	err = pollUntil(ctx, clusterCreatePollInterval, func() (done bool, pollErr error) {
+		kubeconfig, pollErr = readyKubeconfig(ctx, nodeIP)
+		done = pollErr == nil
+		if !done {
+			logrus.Debugf("Kubernetes API on %s not ready yet: %s", nodeName, pollErr)
+		}
+
+		return done, pollErr
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "Kubernetes API on node %s didn't come up", nodeName)
+		return err
+	}
+
+	result.APIReady = true
+	result.Kubeconfig = string(kubeconfig)
+
+	return err
+}
+
+// pollUntil calls check every interval until it's done, or the context is done.  On timeout, the last check's error
+// is returned.
+func pollUntil(ctx context.Context, interval time.Duration, check func() (done bool, err error)) (err error) {
+	for {
+		done, checkErr := check()
+		if done {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			if checkErr != nil {
+				err = errors.Wrapf(ctx.Err(), "last attempt: %s", checkErr)
+			}
+			return err
+		case <-time.After(interval):
+		}
+	}
+}
+
+// newTalosClient creates a Talos API client for a node.  Like fetchRunningMachineConfig's, it's built the way the
+// cluster manager builds its clients, since the server has no talosconfig.
+func newTalosClient(ctx context.Context, nodeIP string) (tClient *client.Client, err error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	tClient, err = client.New(ctx, client.WithTLSConfig(tlsConfig), client.WithEndpoints(nodeIP))
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating talos client for %s", nodeIP)
+		return tClient, err
+	}
+
+	return tClient, err
+}
+
+// bootstrapNode bootstraps etcd on a control plane node.  A node that's already bootstrapped isn't an error, so a
+// bootstrap that's retried after the first attempt succeeded still succeeds.
+func bootstrapNode(ctx context.Context, nodeIP string) (err error) {
+	tClient, err := newTalosClient(ctx, nodeIP)
+	if err != nil {
+		return err
+	}
+
+	defer tClient.Close()
+
+	err = tClient.Bootstrap(ctx, &machineapi.BootstrapRequest{})
+	if status.Code(err) == codes.AlreadyExists {
+		err = nil
+		return err
+	}
+
+	if err != nil {
+		err = errors.Wrapf(err, "failed bootstrapping %s", nodeIP)
+		return err
+	}
+
+	return err
+}
+
+// fetchKubeconfig reads the cluster's admin kubeconfig from a control plane node via the Talos API.
+func fetchKubeconfig(ctx context.Context, nodeIP string) (kubeconfig []byte, err error) {
+	tClient, err := newTalosClient(ctx, nodeIP)
+	if err != nil {
+		return kubeconfig, err
+	}
+
+	defer tClient.Close()
+
+	kubeconfig, err = tClient.Kubeconfig(ctx)
+	if err != nil {
+		err = errors.Wrapf(err, "failed fetching kubeconfig from %s", nodeIP)
+		return kubeconfig, err
+	}
+
+	return kubeconfig, err
+}
+
+// readyKubeconfig fetches the cluster's kubeconfig from a control plane node, and returns it once the Kubernetes API
+// it points at answers.
+func readyKubeconfig(ctx context.Context, nodeIP string) (kubeconfig []byte, err error) {
+	kubeconfig, err = fetchKubeconfig(ctx, nodeIP)
+	if err != nil {
+		return kubeconfig, err
+	}
+
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		err = errors.Wrapf(err, "failed parsing kubeconfig from %s", nodeIP)
+		return kubeconfig, err
+	}
+
+	clientSet, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating Kubernetes client for %s", restConfig.Host)
+		return kubeconfig, err
+	}
+
+	_, err = clientSet.Discovery().ServerVersion()
+	if err != nil {
+		err = errors.Wrapf(err, "Kubernetes API at %s not answering", restConfig.Host)
+		return kubeconfig, err
+	}
+
+	return kubeconfig, err
+}
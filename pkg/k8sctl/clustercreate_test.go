@@ -0,0 +1,42 @@
+package k8sctl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollUntil(t *testing.T) {
+	t.Run("done after retries", func(t *testing.T) {
+		calls := 0
+		err := pollUntil(context.Background(), time.Millisecond, func() (done bool, err error) {
+			calls++
+			if calls < 3 {
+				err = errors.New("not yet")
+				return done, err
+			}
+
+			done = true
+			return done, err
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("timeout reports the last error", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		err := pollUntil(ctx, time.Millisecond, func() (done bool, err error) {
+			err = errors.New("connection refused")
+			return done, err
+		})
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Contains(t, err.Error(), "connection refused")
+	})
+}
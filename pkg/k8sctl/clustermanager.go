@@ -0,0 +1,129 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/cloudflare"
+	"github.com/nikogura/k8sctl/pkg/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// newClusterManager creates the cluster manager a handler acts on a cluster with.  Tests swap it for one with fake AWS
+// clients.
+var newClusterManager = awsClusterManager
+
+// awsClusterManager creates the AWS cluster manager for a cluster.
+// The region is, in order of preference: the region in the request, the cluster's region in the server config, or the
+// region from the server's AWS config.  If the server config has an aws_role_arn for the cluster, that role is assumed
+// (with the configured external ID and session name) and used for all AWS calls made for the cluster.
+func awsClusterManager(ctx context.Context, clusterName string, region string, verbose bool) (cm *aws.AWSClusterManager, err error) {
+	// Create the cloudflare manager
+	dnsManager := cloudflare.NewCloudFlareManager(cfZoneID, cfAPIToken)
+
+	cm, err = aws.NewAWSClusterManager(ctx, clusterName, "", "", dnsManager, verbose)
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating cluster manager for %s", clusterName)
+		return cm, err
+	}
+
+	var clusterCfg config.ClusterConfig
+	if clusterConfig != nil {
+		clusterCfg = clusterConfig.Clusters[clusterName]
+	}
+
+	if region == "" {
+		region = clusterCfg.Region
+	}
+
+	rebuildClients := false
+
+	if region != "" && region != cm.Config.Region {
+		logrus.Infof("using region %s for cluster %s (default %s)", region, clusterName, cm.Config.Region)
+		cm.Config.Region = region
+		rebuildClients = true
+	}
+
+	if clusterCfg.AWSRoleARN != "" {
+		sessionName := clusterCfg.AWSSessionName
+		if sessionName == "" {
+			sessionName = fmt.Sprintf("k8sctl-%s", clusterName)
+		}
+
+		logrus.Infof("assuming role %s (session %s) for cluster %s", clusterCfg.AWSRoleARN, sessionName, clusterName)
+
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cm.Config), clusterCfg.AWSRoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = sessionName
+			if clusterCfg.AWSExternalID != "" {
+				o.ExternalID = awssdk.String(clusterCfg.AWSExternalID)
+			}
+		})
+
+		cm.Config.Credentials = awssdk.NewCredentialsCache(provider)
+
+		// Fail fast if the role can't be assumed, rather than on the first EC2 call
+		_, err = cm.Config.Credentials.Retrieve(ctx)
+		if err != nil {
+			err = errors.Wrapf(err, "failed assuming role %s for cluster %s", clusterCfg.AWSRoleARN, clusterName)
+			return cm, err
+		}
+
+		rebuildClients = true
+	}
+
+	// The EC2 and ELB clients are built from the config at construction, so rebuild them to pick up the changes.
+	if rebuildClients {
+		cm.Ec2Client = ec2.NewFromConfig(cm.Config)
+		cm.ELBClient = elasticloadbalancingv2.NewFromConfig(cm.Config)
+	}
+
+	return cm, err
+}
+
+// nodePrivateIP returns the private IP address of the EC2 instance backing a node.
+func nodePrivateIP(cm *aws.AWSClusterManager, nodeName string, nodeID string) (ip string, err error) {
+	instances, err := cm.GetEC2InstancesByNodeID(nodeID)
+	if err != nil {
+		err = errors.Wrapf(err, "failed fetching EC2 instance for node %s (%s)", nodeName, nodeID)
+		return ip, err
+	}
+
+	if len(instances) == 0 || instances[0].PrivateIpAddress == nil {
+		err = errors.New(fmt.Sprintf("unable to determine IP address for node %s (%s)", nodeName, nodeID))
+		return ip, err
+	}
+
+	ip = *instances[0].PrivateIpAddress
+
+	return ip, err
+}
+
+// runningNodeAddress returns the instance ID and private IP address of a node's running instance.
+func runningNodeAddress(cm *aws.AWSClusterManager, nodeName string) (id string, ip string, err error) {
+	nodeInfo, err := cm.GetNode(nodeName)
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting node %s", nodeName)
+		return id, ip, err
+	}
+
+	if nodeInfo.ID == "" {
+		err = errors.New(fmt.Sprintf("no running instance found for node %s", nodeName))
+		return id, ip, err
+	}
+
+	id = nodeInfo.ID
+
+	ip, err = nodePrivateIP(cm, nodeName, id)
+	if err != nil {
+		return id, ip, err
+	}
+
+	return id, ip, err
+}
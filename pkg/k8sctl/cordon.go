@@ -0,0 +1,206 @@
+package k8sctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	k8s_utility_client "github.com/nikogura/k8s-utility-client/pkg/k8s-utility-client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// defaultDrainTimeout is how long a drain keeps retrying evictions blocked by PodDisruptionBudgets.
+const defaultDrainTimeout = 5 * time.Minute
+
+// drainRetryInterval is how long to wait before retrying a blocked eviction.
+const drainRetryInterval = 5 * time.Second
+
+// mirrorPodAnnotation marks static pods managed by the kubelet, which can't be evicted.
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+type NodeCordonBody struct {
+	Drain        bool `json:"drain"`
+	DrainTimeout int  `json:"drain_timeout,omitempty"` // seconds
+	Verbose      bool `json:"verbose"`
+}
+
+// NodeCordonResult reports the schedulable state of a node after a cordon or uncordon, and any pods evicted by a drain.
+type NodeCordonResult struct {
+	Node          string   `json:"node"`
+	Unschedulable bool     `json:"unschedulable"`
+	EvictedPods   []string `json:"evicted_pods,omitempty"`
+	SkippedPods   []string `json:"skipped_pods,omitempty"` // DaemonSet and mirror pods, which a drain leaves in place
+	Error         string   `json:"error,omitempty"`        // set when a drain fails part way, leaving the node cordoned
+}
+
+// CordonNodeHandler marks a node unschedulable, optionally draining its pods.
+func (c *K8sCtlCommands) CordonNodeHandler(ctx *gin.Context) {
+	c.setNodeSchedulable(ctx, true)
+}
+
+// UncordonNodeHandler marks a node schedulable again.
+func (c *K8sCtlCommands) UncordonNodeHandler(ctx *gin.Context) {
+	c.setNodeSchedulable(ctx, false)
+}
+
+func (c *K8sCtlCommands) setNodeSchedulable(ctx *gin.Context, unschedulable bool) {
+	clusterName := ctx.Param("cluster")
+	nodeName := stripDomainSuffix(ctx.Param("node"))
+
+	var body NodeCordonBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if !unschedulable && body.Drain {
+		err = errors.New("drain is only valid when cordoning a node")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	logrus.Infof("setting node %s in cluster %s unschedulable=%t (drain: %t)", nodeName, clusterName, unschedulable, body.Drain)
+
+	clients, err := k8s_utility_client.NewK8sClients()
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating k8s clients")
+		logrus.Errorf("%s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable)
+
+	_, err = clients.ClientSet.CoreV1().Nodes().Patch(ctx, nodeName, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if apierrors.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		err = errors.Wrapf(err, "failed patching node %s", nodeName)
+		logrus.Errorf("%s", err)
+		_ = ctx.AbortWithError(status, err)
+		return
+	}
+
+	result := NodeCordonResult{
+		Node:          nodeName,
+		Unschedulable: unschedulable,
+	}
+
+	if body.Drain {
+		timeout := defaultDrainTimeout
+		if body.DrainTimeout > 0 {
+			timeout = time.Duration(body.DrainTimeout) * time.Second
+		}
+
+		result.EvictedPods, result.SkippedPods, err = drainNode(ctx, clients, nodeName, timeout)
+		if err != nil {
+			// The node stays cordoned, so report what the drain got through before it failed.
+			logrus.Errorf("failed draining node %s: %s", nodeName, err)
+			result.Error = err.Error()
+			_ = ctx.Error(err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, result)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// drainNode evicts every pod on a node, other than DaemonSet and mirror pods.
+// Evictions are used (rather than deletes) so that PodDisruptionBudgets are honoured.
+// Evictions blocked by a budget are retried until the timeout expires.
+func drainNode(ctx context.Context, clients *k8s_utility_client.K8sClients, nodeName string, timeout time.Duration) (evicted []string, skipped []string, err error) {
+	pods, err := clients.ClientSet.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", nodeName),
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed listing pods on node %s", nodeName)
+		return evicted, skipped, err
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for _, pod := range pods.Items {
+		podName := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		if !evictable(pod) {
+			skipped = append(skipped, podName)
+			continue
+		}
+
+		err = evictPod(ctx, clients, pod, deadline)
+		if err != nil {
+			return evicted, skipped, err
+		}
+
+		logrus.Infof("evicted pod %s from node %s", podName, nodeName)
+		evicted = append(evicted, podName)
+	}
+
+	return evicted, skipped, err
+}
+
+// evictable returns false for pods a drain should leave alone: DaemonSet pods (which would be recreated on the node) and mirror pods.
+func evictable(pod corev1.Pod) (ok bool) {
+	if _, mirror := pod.Annotations[mirrorPodAnnotation]; mirror {
+		return ok
+	}
+
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return ok
+		}
+	}
+
+	ok = true
+	return ok
+}
+
+// evictPod evicts a single pod, retrying while a PodDisruptionBudget blocks it.
+func evictPod(ctx context.Context, clients *k8s_utility_client.K8sClients, pod corev1.Pod, deadline time.Time) (err error) {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+	}
+
+	for {
+		evictErr := clients.ClientSet.CoreV1().Pods(pod.Namespace).EvictV1(ctx, eviction)
+		if evictErr == nil || apierrors.IsNotFound(evictErr) {
+			return err
+		}
+
+		if !apierrors.IsTooManyRequests(evictErr) || time.Now().After(deadline) {
+			err = errors.Wrapf(evictErr, "failed evicting pod %s/%s", pod.Namespace, pod.Name)
+			return err
+		}
+
+		logrus.Infof("eviction of pod %s/%s blocked by disruption budget, retrying", pod.Namespace, pod.Name)
+
+		select {
+		case <-ctx.Done():
+			err = errors.Wrapf(ctx.Err(), "gave up evicting pod %s/%s", pod.Namespace, pod.Name)
+			return err
+		case <-time.After(drainRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudflare/cloudflare-go/v4"
+	"github.com/cloudflare/cloudflare-go/v4/dns"
+	"github.com/cloudflare/cloudflare-go/v4/option"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+)
+
+// NodeDeletePlan is what deleting a node would do, as reported by a dry run of a delete or glass.
+type NodeDeletePlan struct {
+	Node         string `json:"node"`
+	ID           string `json:"id"` // the instance that's terminated
+	Role         string `json:"role"`
+	InstanceType string `json:"instance_type"`
+	// TargetGroups are the "<load balancer>/<target group>:<port>" the node is deregistered from.
+	TargetGroups []string `json:"target_groups"`
+	// DNSRecords are the names of the DNS records that are removed: every record whose name contains the node's name.
+	DNSRecords []string `json:"dns_records"`
+	K8sNode    string   `json:"k8s_node"`           // the Kubernetes node that's deleted
+	Recreate   bool     `json:"recreate,omitempty"` // a glass creates a node with the same name and role afterwards
+	// ControlPlaneNodes is how many control plane nodes the cluster has now, if the node is one of them.
+	ControlPlaneNodes int      `json:"control_plane_nodes,omitempty"`
+	Warnings          []string `json:"warnings,omitempty"`
+}
+
+// ConsolePrint prints the plan, with its warnings first so they can't be missed.
+func (p NodeDeletePlan) ConsolePrint() {
+	for _, warning := range p.Warnings {
+		fmt.Printf("%s WARNING: %s\n", consoleMarkers.Warn, warning)
+	}
+
+	if len(p.Warnings) > 0 {
+		fmt.Println()
+	}
+
+	verb := "Deleting"
+	if p.Recreate {
+		verb = "Glassing"
+	}
+
+	fmt.Printf("Dry run: %s node %s (%s) would:\n", verb, p.Node, p.Role)
+	fmt.Printf("  terminate instance %s (%s)\n", p.ID, p.InstanceType)
+
+	if len(p.TargetGroups) == 0 {
+		fmt.Printf("  deregister it from no target groups: it isn't in any\n")
+	}
+
+	for _, tg := range p.TargetGroups {
+		fmt.Printf("  deregister it from %s\n", tg)
+	}
+
+	if len(p.DNSRecords) == 0 {
+		fmt.Printf("  remove no DNS records: none match\n")
+	}
+
+	for _, record := range p.DNSRecords {
+		fmt.Printf("  remove DNS record %s\n", record)
+	}
+
+	fmt.Printf("  delete Kubernetes node %s\n", p.K8sNode)
+
+	if p.Recreate {
+		fmt.Printf("  then create a new %s node named %s\n", p.Role, p.Node)
+	}
+}
+
+// planNodeDelete works out what deleting a node would do, without doing any of it.  The node's load balancer target
+// groups come from a describe of the cluster, which also gives the control plane's size.  A failure to list the DNS
+// records is a warning in the plan, rather than an error, since the rest of the plan still stands.
+func planNodeDelete(ctx context.Context, cm *aws.AWSClusterManager, clusterName string, nodeInfo manager.NodeInfo) (plan NodeDeletePlan, err error) {
+	plan = NodeDeletePlan{
+		Node:         nodeInfo.Name,
+		ID:           nodeInfo.ID,
+		InstanceType: nodeInfo.InstanceType,
+		TargetGroups: make([]string, 0),
+		DNSRecords:   make([]string, 0),
+		K8sNode:      stripDomainSuffix(nodeInfo.Name),
+	}
+
+	described, err := DescribeCluster(ctx, cm, clusterName, DescribeClusterOptions{NodeLBs: true})
+	if err != nil {
+		return plan, err
+	}
+
+	roles := describedRoles(described.NodeInstances)
+	plan.Role = roleOf(roles, nodeInfo.Name)
+
+	for _, attachment := range described.NodeAttachments {
+		if attachment.ID != nodeInfo.ID {
+			continue
+		}
+
+		for _, tg := range attachment.TargetGroups {
+			plan.TargetGroups = append(plan.TargetGroups, fmt.Sprintf("%s/%s:%d", tg.LoadBalancer, tg.TargetGroup, tg.Port))
+		}
+	}
+
+	if plan.Role == manager.NodeRoleCp {
+		for _, node := range described.Nodes {
+			if roleOf(roles, node.Name) == manager.NodeRoleCp {
+				plan.ControlPlaneNodes++
+			}
+		}
+
+		plan.Warnings = append(plan.Warnings, controlPlaneDeleteWarning(nodeInfo.Name, plan.ControlPlaneNodes))
+	}
+
+	for _, name := range described.UncheckedTargetGroups {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("couldn't check target group %s: the node may be deregistered from it too", name))
+	}
+
+	records, dnsErr := dnsRecordsFor(ctx, nodeInfo.Name)
+	if dnsErr != nil {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("couldn't list DNS records: %s", dnsErr))
+	}
+
+	for _, record := range records {
+		plan.DNSRecords = append(plan.DNSRecords, record)
+		if stripDomainSuffix(record) != plan.K8sNode {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("DNS record %s contains the node's name, so it would be removed too", record))
+		}
+	}
+
+	return plan, err
+}
+
+// controlPlaneDeleteWarning describes what losing a control plane node does to etcd.  The member isn't removed from
+// etcd by a delete, so until it's replaced the cluster runs one member down.
+func controlPlaneDeleteWarning(nodeName string, controlPlaneNodes int) (warning string) {
+	quorum := controlPlaneNodes/2 + 1
+	remaining := controlPlaneNodes - 1
+
+	switch {
+	case remaining < quorum:
+		warning = fmt.Sprintf("%s is a control plane node: deleting it leaves %d of %d etcd members, which loses quorum and takes down the Kubernetes API", nodeName, remaining, controlPlaneNodes)
+	case remaining == quorum:
+		warning = fmt.Sprintf("%s is a control plane node: deleting it leaves %d of %d etcd members, so the cluster can't lose another until it's replaced", nodeName, remaining, controlPlaneNodes)
+	default:
+		warning = fmt.Sprintf("%s is a control plane node: deleting it leaves %d of %d etcd members", nodeName, remaining, controlPlaneNodes)
+	}
+
+	return warning
+}
+
+// dnsRecordsFor lists the names of the DNS records a node delete removes.  It makes the same query the cluster
+// manager's DNS deregistration does, so it finds the same records.  With no DNS zone configured, there are none.
+func dnsRecordsFor(ctx context.Context, nodeName string) (names []string, err error) {
+	if cfZoneID == "" {
+		return names, err
+	}
+
+	client := cloudflare.NewClient(option.WithAPIToken(cfAPIToken))
+
+	resp, err := client.DNS.Records.List(ctx, dns.RecordListParams{
+		ZoneID: cloudflare.F(cfZoneID),
+		Name: cloudflare.F(dns.RecordListParamsName{
+			Contains: cloudflare.F(nodeName),
+		}),
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed listing DNS records for %s", nodeName)
+		return names, err
+	}
+
+	for _, record := range resp.Result {
+		names = append(names, record.Name)
+	}
+
+	return names, err
+}
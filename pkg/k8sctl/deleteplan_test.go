@@ -0,0 +1,15 @@
+package k8sctl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControlPlaneDeleteWarning(t *testing.T) {
+	assert.Contains(t, controlPlaneDeleteWarning("cluster1-cp-1", 1), "leaves 0 of 1 etcd members, which loses quorum")
+	assert.Contains(t, controlPlaneDeleteWarning("cluster1-cp-1", 2), "leaves 1 of 2 etcd members, which loses quorum")
+	assert.Contains(t, controlPlaneDeleteWarning("cluster1-cp-1", 3), "leaves 2 of 3 etcd members, so the cluster can't lose another")
+	assert.NotContains(t, controlPlaneDeleteWarning("cluster1-cp-1", 5), "quorum")
+	assert.NotContains(t, controlPlaneDeleteWarning("cluster1-cp-1", 5), "can't lose another")
+}
@@ -0,0 +1,376 @@
+package k8sctl
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// describeTimeout bounds a whole describe, so one slow AWS call can't hang the request.
+const describeTimeout = 2 * time.Minute
+
+// describeConcurrency is the most AWS calls a describe makes at once.  Kept low to stay clear of API throttling.
+const describeConcurrency = 8
+
+// DescribeClusterOptions filter the nodes and load balancer targets a describe reports.
+type DescribeClusterOptions struct {
+	Role          string
+	NamePrefix    string
+	UnhealthyOnly bool
+	NodeLBs       bool // also report the load balancers and target groups each node is registered with
+
+	Utilization bool // also report each Kubernetes node's CPU and memory, allocatable, requested, and in use
+
+	Since time.Duration // only report the nodes launched, or joined to Kubernetes, in this window
+}
+
+// DescribeCluster gathers the cluster info, and the reason for each unhealthy load balancer target.
+// It returns the same info as the cluster manager's DescribeCluster, but fetches the nodes and the load balancer list
+// concurrently, then gathers each load balancer's target groups and targets, and the target health reasons, with a
+// bounded pool of workers.
+func DescribeCluster(ctx context.Context, cm *aws.AWSClusterManager, clusterName string, options DescribeClusterOptions) (result DescribeClusterResult, err error) {
+	ctx, cancel := context.WithTimeout(ctx, describeTimeout)
+	defer cancel()
+
+	info, err := describeClusterInfo(ctx, cm, clusterName)
+	if err != nil {
+		return result, err
+	}
+
+	instances := nodeInstances(ctx, cm, info.Nodes)
+
+	// Load balancers the nodes were added to at create, beyond the cluster's own, are reported with them
+	extras, extrasErr := extraLoadBalancers(ctx, cm, info.Nodes, instances, info.LoadBalancers)
+	if extrasErr != nil {
+		logrus.Warnf("failed describing the nodes' extra load balancers: %s", extrasErr)
+	}
+	info.LoadBalancers = append(info.LoadBalancers, extras...)
+
+	// Without Kubernetes, nodes are reported, and picked by Since, without their join times
+	joined, joinErr := k8sNodeJoinTimes(ctx)
+	if joinErr != nil {
+		logrus.Warnf("failed getting Kubernetes node join times: %s", joinErr)
+	}
+
+	if options.Since > 0 {
+		recent := recentNodes(info.Nodes, instances, joined, time.Now().Add(-options.Since))
+		info = filterClusterInfoBy(info, func(nodeName string) bool { return recent[stripDomainSuffix(nodeName)] }, false)
+	}
+
+	info = filterClusterInfo(info, nodeRoles(info.Nodes, instances), options.Role, options.NamePrefix, options.UnhealthyOnly)
+
+	result = DescribeClusterResult{
+		ClusterInfo:      info,
+		UnhealthyTargets: findUnhealthyTargets(info.LoadBalancers),
+	}
+
+	// Target health is needed for the unhealthy target reasons, and for the node attachments if asked for
+	var health []targetGroupHealth
+	if len(result.UnhealthyTargets) > 0 || options.NodeLBs {
+		health, result.UncheckedTargetGroups = describeTargetGroupsHealth(ctx, cm, info.LoadBalancers)
+	}
+
+	applyTargetHealthReasons(health, result.UnhealthyTargets)
+
+	result.NodeInstances = describeNodeInstances(info.Nodes, instances, joined, time.Now())
+
+	if options.NodeLBs {
+		result.NodeAttachments = nodeLBAttachments(info.Nodes, nodeAddresses(instances), health)
+	}
+
+	// Without Kubernetes, the rest of the describe still stands
+	if options.Utilization {
+		var utilizationErr error
+		result.Utilization, utilizationErr = nodeUtilization(ctx, info.Nodes)
+		if utilizationErr != nil {
+			logrus.Warnf("failed getting node utilization: %s", utilizationErr)
+			result.UtilizationError = utilizationErr.Error()
+		}
+	}
+
+	return result, err
+}
+
+// describeClusterInfo is the concurrent equivalent of the cluster manager's DescribeCluster: the cluster's nodes, with
+// their specs and costs, and its load balancers.
+func describeClusterInfo(ctx context.Context, cm *aws.AWSClusterManager, clusterName string) (info manager.ClusterInfo, err error) {
+	ctx, cancel := context.WithTimeout(ctx, describeTimeout)
+	defer cancel()
+
+	// The cluster manager makes its AWS calls with its own context, so give it a copy bound to ours, rather than
+	// swapping the context on one its caller may share.
+	scoped := *cm
+	scoped.Context = ctx
+	cm = &scoped
+
+	info = manager.ClusterInfo{
+		Provider: "aws",
+		Name:     clusterName,
+	}
+
+	var lbOutput *elasticloadbalancingv2.DescribeLoadBalancersOutput
+
+	var group errgroup.Group
+
+	group.Go(func() (nodesErr error) {
+		info.Nodes, nodesErr = cm.GetNodes(clusterName)
+		if nodesErr != nil {
+			nodesErr = errors.Wrapf(nodesErr, "failed getting cluster nodes")
+		}
+		return nodesErr
+	})
+
+	group.Go(func() (lbsErr error) {
+		lbOutput, lbsErr = cm.ELBClient.DescribeLoadBalancers(ctx, &elasticloadbalancingv2.DescribeLoadBalancersInput{})
+		if lbsErr != nil {
+			lbsErr = errors.Wrapf(lbsErr, "failed getting lbs for cluster %s", clusterName)
+		}
+		return lbsErr
+	})
+
+	err = group.Wait()
+	if err == nil {
+		info.LoadBalancers, err = describeClusterLBs(ctx, cm, lbOutput.LoadBalancers, info.Nodes)
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			err = errors.Wrapf(err, "describe of cluster %s timed out after %s", clusterName, describeTimeout)
+		}
+		return info, err
+	}
+
+	addNodeSpecsAndCosts(cm, &info)
+
+	return info, err
+}
+
+// describeClusterLBs builds the info for each of the cluster's load balancers, several load balancers at a time.
+// The cluster manager caches the nodes it looks up by target ID in maps it doesn't lock, so each worker gets its own
+// copy of the cluster manager, with its cache seeded from the nodes already fetched.
+func describeClusterLBs(ctx context.Context, cm *aws.AWSClusterManager, lbs []elbtypes.LoadBalancer, nodes []manager.NodeInfo) (infos []manager.LBInfo, err error) {
+	results := make([]*manager.LBInfo, len(lbs))
+
+	var group errgroup.Group
+	group.SetLimit(describeConcurrency)
+
+	for i, lb := range lbs {
+		group.Go(func() (lbErr error) {
+			worker := *cm
+			worker.FetchedNodesById = make(map[string]manager.NodeInfo, len(nodes))
+			worker.FetchedNodesByName = make(map[string]manager.NodeInfo, len(nodes))
+			for _, node := range nodes {
+				worker.FetchedNodesById[node.ID] = node
+				worker.FetchedNodesByName[node.Name] = node
+			}
+
+			results[i], lbErr = describeClusterLB(ctx, &worker, lb)
+			return lbErr
+		})
+	}
+
+	err = group.Wait()
+	if err != nil {
+		return infos, err
+	}
+
+	// Keep the order AWS listed the load balancers in, as the cluster manager does
+	for _, lbInfo := range results {
+		if lbInfo != nil {
+			infos = append(infos, *lbInfo)
+		}
+	}
+
+	return infos, err
+}
+
+// describeClusterLB returns the info for a load balancer, or nil if the load balancer doesn't belong to the cluster.
+// Like the cluster manager, the load balancer's Targets are those of its last target group.
+func describeClusterLB(ctx context.Context, cm *aws.AWSClusterManager, lb elbtypes.LoadBalancer) (lbInfo *manager.LBInfo, err error) {
+	tagOutput, err := cm.ELBClient.DescribeTags(ctx, &elasticloadbalancingv2.DescribeTagsInput{
+		ResourceArns: []string{*lb.LoadBalancerArn},
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed fetching tags")
+		return lbInfo, err
+	}
+
+	if !taggedForCluster(tagOutput, cm.ClusterName()) {
+		return lbInfo, err
+	}
+
+	lbInfo, err = describeLB(ctx, cm, lb)
+	return lbInfo, err
+}
+
+// describeLB returns the info for a load balancer, its target groups and targets, whoever it belongs to.
+func describeLB(ctx context.Context, cm *aws.AWSClusterManager, lb elbtypes.LoadBalancer) (lbInfo *manager.LBInfo, err error) {
+	lbInfo = &manager.LBInfo{
+		Name:         *lb.LoadBalancerName,
+		Targets:      make([]manager.LBTargetInfo, 0),
+		TargetGroups: make([]manager.LBTargetGroupInfo, 0),
+		IsAPIServer:  strings.Contains(*lb.LoadBalancerName, "apiserver"),
+	}
+
+	tgOutput, err := cm.GetTargetGroupsForLB(*lb.LoadBalancerArn)
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting target groups")
+		return lbInfo, err
+	}
+
+	for _, tg := range tgOutput.TargetGroups {
+		lbInfo.TargetGroups = append(lbInfo.TargetGroups, manager.LBTargetGroupInfo{
+			Name: *tg.TargetGroupName,
+			Arn:  *tg.TargetGroupArn,
+			Port: *tg.Port,
+		})
+
+		targets, targetsErr := cm.GetTargets(*tg.TargetGroupName)
+		if targetsErr != nil {
+			err = errors.Wrapf(targetsErr, "failed getting target %s", *tg.TargetGroupName)
+			return lbInfo, err
+		}
+
+		lbInfo.Targets = targets
+	}
+
+	return lbInfo, err
+}
+
+// taggedForCluster returns true if the tags include the cluster's Cluster tag.
+func taggedForCluster(tagOutput *elasticloadbalancingv2.DescribeTagsOutput, clusterName string) (tagged bool) {
+	for _, td := range tagOutput.TagDescriptions {
+		for _, tag := range td.Tags {
+			if tag.Key != nil && tag.Value != nil && *tag.Key == aws.ELBClusterTag && *tag.Value == clusterName {
+				tagged = true
+				return tagged
+			}
+		}
+	}
+
+	return tagged
+}
+
+// addNodeSpecsAndCosts fills in each node's vCPUs, memory, and cost, and the cluster totals, as the cluster manager does.
+func addNodeSpecsAndCosts(cm *aws.AWSClusterManager, info *manager.ClusterInfo) {
+	for i := range info.Nodes {
+		if info.Nodes[i].InstanceType == "" {
+			continue
+		}
+
+		vcpus, memoryGiB, specsErr := aws.GetInstanceSpecs(info.Nodes[i].InstanceType)
+		if specsErr == nil {
+			info.Nodes[i].VCPUs = vcpus
+			info.Nodes[i].MemoryGiB = memoryGiB
+			info.TotalVCPUs += vcpus
+			info.TotalMemoryGiB += memoryGiB
+		}
+
+		if cm.CostEstimator != nil {
+			dailyCost, costErr := cm.CostEstimator.EstimateDailyCost(info.Nodes[i].InstanceType)
+			if costErr == nil {
+				info.Nodes[i].DailyCost = dailyCost
+			}
+		}
+	}
+
+	if cm.CostEstimator != nil {
+		totalCost, costErr := aws.CalculateClusterDailyCost(info.Nodes, cm.CostEstimator)
+		if costErr != nil {
+			// Log warning but don't fail the entire describe operation
+			manager.VerboseOutput(cm.Verbose, "Warning: failed to calculate cluster cost: %v", costErr)
+		} else {
+			info.EstimatedDailyCost = &totalCost
+		}
+	}
+}
+
+// targetGroupHealth is the target health of one of a load balancer's target groups.
+type targetGroupHealth struct {
+	lb     string
+	tg     manager.LBTargetGroupInfo
+	output *elasticloadbalancingv2.DescribeTargetHealthOutput
+}
+
+// describeTargetGroupsHealth fetches the target health of every target group of the given load balancers, at most
+// describeConcurrency at a time.  Target groups whose health can't be fetched are logged, left out of health, and
+// returned in failed, as "<load balancer>/<target group>".
+func describeTargetGroupsHealth(ctx context.Context, cm *aws.AWSClusterManager, lbs []manager.LBInfo) (health []targetGroupHealth, failed []string) {
+	var tgs []targetGroupHealth
+	for _, lb := range lbs {
+		for _, tg := range lb.TargetGroups {
+			tgs = append(tgs, targetGroupHealth{lb: lb.Name, tg: tg})
+		}
+	}
+
+	results := make([]*elasticloadbalancingv2.DescribeTargetHealthOutput, len(tgs))
+
+	var group errgroup.Group
+	group.SetLimit(describeConcurrency)
+
+	for i, tgHealth := range tgs {
+		tg := tgHealth.tg
+		group.Go(func() (err error) {
+			tgArn := tg.Arn
+			output, descErr := cm.ELBClient.DescribeTargetHealth(ctx, &elasticloadbalancingv2.DescribeTargetHealthInput{
+				TargetGroupArn: &tgArn,
+			})
+			if descErr != nil {
+				logrus.Warnf("failed getting target health for target group %s: %s", tg.Name, descErr)
+				return err
+			}
+
+			results[i] = output
+			return err
+		})
+	}
+
+	_ = group.Wait()
+
+	for i, output := range results {
+		if output == nil {
+			failed = append(failed, tgs[i].lb+"/"+tgs[i].tg.Name)
+			continue
+		}
+
+		tgs[i].output = output
+		health = append(health, tgs[i])
+	}
+
+	return health, failed
+}
+
+// nodePrivateIPs returns the private IP of each node's instance, by instance ID, for matching IP targets to nodes,
+// from the nodes' instances.  Instances that weren't found just have no IP, so IP targets to them go unmatched.
+func nodePrivateIPs(instances map[string]ec2types.Instance) (ips map[string]string) {
+	ips = make(map[string]string, len(instances))
+
+	for id, instance := range instances {
+		if instance.PrivateIpAddress != nil {
+			ips[id] = *instance.PrivateIpAddress
+		}
+	}
+
+	return ips
+}
+
+// nodeAddresses returns the IPv4 and IPv6 addresses of each node's instance, by instance ID, for matching IP targets
+// to nodes on dual-stack subnets, where a target may be registered by either.
+func nodeAddresses(instances map[string]ec2types.Instance) (addresses map[string][]string) {
+	addresses = make(map[string][]string, len(instances))
+
+	for id, instance := range instances {
+		addresses[id] = instanceAddresses(instance)
+	}
+
+	return addresses
+}
@@ -0,0 +1,123 @@
+package k8sctl
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+)
+
+// ConsolePrintWide prints the cluster with a node per line, showing each node's instance, IPv4 and IPv6 addresses,
+// zone, launch time and age, specs, cost, and the state of its load balancer targets, then a load balancer target per line.  ConsolePrint is the compact view.
+func (r DescribeClusterResult) ConsolePrintWide() {
+	fmt.Printf("Cluster Info for Cluster %q\nProvider: %s\n", r.Name, r.Provider)
+
+	instances := make(map[string]NodeInstance, len(r.NodeInstances))
+	for _, instance := range r.NodeInstances {
+		instances[instance.ID] = instance
+	}
+
+	fmt.Printf("Nodes: (%d)\n", len(r.Nodes))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "  NAME\tINSTANCE ID\tTYPE\tPRIVATE IP\tIPV6\tZONE\tLIFECYCLE\tLAUNCHED\tAGE\tVCPUS\tMEMORY\tCOST/DAY\tLB TARGETS\n")
+	for _, node := range r.Nodes {
+		instance := instances[node.ID]
+		_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			node.Name,
+			orDash(node.ID),
+			orDash(node.InstanceType),
+			orDash(instance.PrivateIP),
+			orDash(strings.Join(instance.IPv6Addresses, ",")),
+			orDash(instance.AvailabilityZone),
+			orDash(instance.Lifecycle),
+			formatNodeTime(instance.LaunchTime),
+			orDash(instance.Age),
+			orDash(wideCount(node.VCPUs)),
+			orDash(wideGiB(node.MemoryGiB)),
+			orDash(wideCost(node.DailyCost)),
+			listOrNone(nodeTargetStates(node, instance.Addresses(), r.LoadBalancers)),
+		)
+	}
+	_ = w.Flush()
+
+	if r.TotalVCPUs > 0 || r.TotalMemoryGiB > 0 || r.EstimatedDailyCost != nil {
+		fmt.Printf("Cluster Totals:\n")
+		if r.TotalVCPUs > 0 {
+			fmt.Printf("  Total vCPUs: %d\n", r.TotalVCPUs)
+		}
+		if r.TotalMemoryGiB > 0 {
+			fmt.Printf("  Total Memory: %.1f GiB\n", r.TotalMemoryGiB)
+		}
+		if r.EstimatedDailyCost != nil {
+			fmt.Printf("  Estimated Daily Cost: $%.2f\n", *r.EstimatedDailyCost)
+		}
+	}
+
+	fmt.Printf("Load Balancers: (%d)\n", len(r.LoadBalancers))
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "  LOAD BALANCER\tAPI SERVER\tTARGET\tTARGET ID\tPORT\tSTATE\n")
+	for _, lb := range r.LoadBalancers {
+		if len(lb.Targets) == 0 {
+			_, _ = fmt.Fprintf(w, "  %s\t%t\t-\t-\t-\t-\n", lb.Name, lb.IsAPIServer)
+		}
+
+		for _, target := range lb.Targets {
+			_, _ = fmt.Fprintf(w, "  %s\t%t\t%s\t%s\t%d\t%s\n", lb.Name, lb.IsAPIServer, orDash(target.Name), target.ID, target.Port, target.State)
+		}
+	}
+	_ = w.Flush()
+}
+
+// nodeTargetStates returns a node's load balancer targets, as "<load balancer>:<port>=<state>".  Targets are matched
+// to the node by instance ID, by one of its addresses for target groups of type ip, or failing those, by name.
+func nodeTargetStates(node manager.NodeInfo, addresses []string, lbs []manager.LBInfo) (states []string) {
+	shortName := stripDomainSuffix(node.Name)
+
+	for _, lb := range lbs {
+		for _, target := range lb.Targets {
+			matches := target.ID == node.ID || isNodeAddress(target.ID, addresses) || (target.Name != "" && stripDomainSuffix(target.Name) == shortName)
+			if matches {
+				states = append(states, fmt.Sprintf("%s:%d=%s", lb.Name, target.Port, target.State))
+			}
+		}
+	}
+
+	sort.Strings(states)
+
+	return states
+}
+
+// orDash returns a table cell's value, or "-" if there isn't one.
+func orDash(value string) (cell string) {
+	cell = strings.TrimSpace(value)
+	if cell == "" {
+		cell = "-"
+	}
+	return cell
+}
+
+func wideCount(count int) (cell string) {
+	if count > 0 {
+		cell = fmt.Sprintf("%d", count)
+	}
+	return cell
+}
+
+func wideGiB(gib float64) (cell string) {
+	if gib > 0 {
+		cell = fmt.Sprintf("%.1f GiB", gib)
+	}
+	return cell
+}
+
+func wideCost(cost float64) (cell string) {
+	if cost > 0 {
+		cell = fmt.Sprintf("$%.2f", cost)
+	}
+	return cell
+}
@@ -0,0 +1,36 @@
+package k8sctl
+
+import (
+	"testing"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeTargetStates(t *testing.T) {
+	lbs := []manager.LBInfo{
+		{Name: "cluster1-api", Targets: []manager.LBTargetInfo{
+			{ID: "i-1", Name: "cluster1-cp-1.example.com", Port: 6443, State: "healthy"},
+			{ID: "i-2", Name: "cluster1-cp-2.example.com", Port: 6443, State: "unhealthy"},
+		}},
+		{Name: "cluster1-ingress", Targets: []manager.LBTargetInfo{
+			{ID: "10.0.1.11", Port: 443, State: "healthy"},                  // ip target group
+			{ID: "i-9", Name: "cluster1-cp-1", Port: 80, State: "draining"}, // matched by name only
+		}},
+		{Name: "cluster1-ingress-v6", Targets: []manager.LBTargetInfo{
+			{ID: "2600:1f18:aaaa::11", Port: 443, State: "healthy"}, // ip target group, on a dual-stack subnet
+			{ID: "2600:1f18:aaaa::12", Port: 443, State: "healthy"},
+		}},
+	}
+
+	node := manager.NodeInfo{Name: "cluster1-cp-1.example.com", ID: "i-1"}
+
+	assert.Equal(t, []string{
+		"cluster1-api:6443=healthy",
+		"cluster1-ingress-v6:443=healthy",
+		"cluster1-ingress:443=healthy",
+		"cluster1-ingress:80=draining",
+	}, nodeTargetStates(node, []string{"10.0.1.11", "2600:1f18:aaaa::11"}, lbs))
+
+	assert.Empty(t, nodeTargetStates(manager.NodeInfo{Name: "cluster1-worker-1", ID: "i-5"}, nil, lbs))
+}
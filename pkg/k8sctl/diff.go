@@ -0,0 +1,343 @@
+package k8sctl
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/pkg/errors"
+	"github.com/siderolabs/talos/pkg/machinery/client"
+	"github.com/siderolabs/talos/pkg/machinery/config/configpatcher"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// talosMachineConfigPath is where Talos keeps the active machine config on a node.
+const talosMachineConfigPath = "/system/state/config.yaml"
+
+// redactedValue replaces sensitive values (keys, tokens, secrets) in diff output.
+const redactedValue = "<redacted>"
+
+type NodeDiffBody struct {
+	Role          string `json:"role,omitempty"`
+	Verbose       bool   `json:"verbose"`
+	CloudProvider string `json:"cloud_provider"`
+	Region        string `json:"region,omitempty"`
+}
+
+// ConfigChange describes a key whose value differs between the intended and running config.
+type ConfigChange struct {
+	Intended interface{} `json:"intended"`
+	Actual   interface{} `json:"actual"`
+}
+
+// NodeDiffResult is a structured diff between a node's intended and running machine config.
+// Keys are dotted paths, e.g. "machine.network.hostname" or "machine.certSANs[0]".
+type NodeDiffResult struct {
+	Node    string                  `json:"node"`
+	Role    string                  `json:"role"`
+	Added   map[string]interface{}  `json:"added"`   // present on the node, but not in the intended config
+	Removed map[string]interface{}  `json:"removed"` // present in the intended config, but missing on the node
+	Changed map[string]ConfigChange `json:"changed"`
+	InSync  bool                    `json:"in_sync"`
+}
+
+// DiffNodeHandler compares the intended machine config for a node against the config actually running on it.
+func (c *K8sCtlCommands) DiffNodeHandler(ctx *gin.Context) {
+	clusterName := ctx.Param("cluster")
+	nodeName := ctx.Param("node")
+
+	logrus.Infof("diffing node %s in cluster %s\n", nodeName, clusterName)
+
+	var body NodeDiffBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	verbose := body.Verbose
+	cloudProvider := strings.ToLower(body.CloudProvider)
+
+	// Error out if we're doing anything other than AWS
+	if cloudProvider != "aws" {
+		providerErr := errors.New(fmt.Sprintf("Unsupported cloud provider: %s", cloudProvider))
+		logrus.Errorf("Unsupported cloud provider %s: %s", cloudProvider, providerErr)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, providerErr)
+		return
+	}
+
+	cm, err := newClusterManager(ctx, clusterName, body.Region, verbose)
+	if err != nil {
+		logrus.Errorf("Failed creating cluster manager: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	// Find the node, for its role and address
+	nodeInfo, err := cm.GetNode(nodeName)
+	if err != nil {
+		logrus.Errorf("failed getting node %s: %s", nodeName, err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if nodeInfo.ID == "" {
+		err = errors.New(fmt.Sprintf("no running instance found for node %s", nodeName))
+		_ = ctx.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+
+	role := body.Role
+	if role == "" {
+		role = nodeRole(nodeInfo, nodeInstances(ctx, cm, []manager.NodeInfo{nodeInfo}))
+	}
+
+	// Build the config the node should be running
+	files, err := loadNodeConfigFiles(clusterName, role, cloudProvider)
+	if err != nil {
+		logrus.Errorf("failed loading node config files: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	intended, err := renderMachineConfig(files, nodeName)
+	if err != nil {
+		logrus.Errorf("failed rendering intended machine config for %s: %s", nodeName, err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	nodeIP, err := nodePrivateIP(cm, nodeName, nodeInfo.ID)
+	if err != nil {
+		logrus.Errorf("%s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	// Fetch the config the node is actually running
+	actual, err := fetchRunningMachineConfig(ctx, nodeIP)
+	if err != nil {
+		logrus.Errorf("failed fetching running machine config from %s: %s", nodeName, err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	result, err := diffMachineConfigs(intended, actual)
+	if err != nil {
+		logrus.Errorf("failed diffing machine configs for %s: %s", nodeName, err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	result.Node = nodeName
+	result.Role = role
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// renderMachineConfig applies the role's patches, plus the per-node hostname patch, to the machine config.
+// This mirrors what the cluster manager does when it applies config to a new node.
+func renderMachineConfig(files nodeConfigFiles, nodeName string) (rendered []byte, err error) {
+	// Note the spaces (not tabs) cos it's yaml.
+	nodeNamePatch := fmt.Sprintf(`machine:
+  network:
+    hostname: %s.%s
+`, nodeName, files.NodeConfig.Domain)
+
+	patchStrings := make([]string, 0, len(files.Patches)+1)
+	patchStrings = append(patchStrings, files.Patches...)
+	patchStrings = append(patchStrings, nodeNamePatch)
+
+	patches, err := configpatcher.LoadPatches(patchStrings)
+	if err != nil {
+		err = errors.Wrapf(err, "failed loading config patches")
+		return rendered, err
+	}
+
+	cfg, err := configpatcher.Apply(configpatcher.WithBytes(files.MachineConfig), patches)
+	if err != nil {
+		err = errors.Wrapf(err, "failed applying config patches to machine config")
+		return rendered, err
+	}
+
+	rendered, err = cfg.Bytes()
+	if err != nil {
+		err = errors.Wrapf(err, "failed extracting config bytes")
+		return rendered, err
+	}
+
+	return rendered, err
+}
+
+// fetchRunningMachineConfig reads the active machine config from a Talos node via the Talos API.
+// The client is built the way the cluster manager builds its clients for node operations (upgrades, config applies),
+// rather than from a talosconfig, which the server doesn't have.
+func fetchRunningMachineConfig(ctx context.Context, nodeIP string) (configBytes []byte, err error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	tClient, err := client.New(ctx, client.WithTLSConfig(tlsConfig), client.WithEndpoints(nodeIP))
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating talos client for %s", nodeIP)
+		return configBytes, err
+	}
+
+	defer tClient.Close()
+
+	reader, err := tClient.Read(ctx, talosMachineConfigPath)
+	if err != nil {
+		err = errors.Wrapf(err, "failed reading %s from %s", talosMachineConfigPath, nodeIP)
+		return configBytes, err
+	}
+
+	defer reader.Close()
+
+	configBytes, err = io.ReadAll(reader)
+	if err != nil {
+		err = errors.Wrapf(err, "failed reading machine config stream from %s", nodeIP)
+		return configBytes, err
+	}
+
+	return configBytes, err
+}
+
+// diffMachineConfigs compares two (possibly multi-document) machine configs key by key.
+func diffMachineConfigs(intended []byte, actual []byte) (result NodeDiffResult, err error) {
+	intendedKeys, err := flattenYAMLDocuments(intended)
+	if err != nil {
+		err = errors.Wrapf(err, "failed parsing intended machine config")
+		return result, err
+	}
+
+	actualKeys, err := flattenYAMLDocuments(actual)
+	if err != nil {
+		err = errors.Wrapf(err, "failed parsing running machine config")
+		return result, err
+	}
+
+	result.Added = make(map[string]interface{})
+	result.Removed = make(map[string]interface{})
+	result.Changed = make(map[string]ConfigChange)
+
+	for key, intendedValue := range intendedKeys {
+		actualValue, ok := actualKeys[key]
+		if !ok {
+			result.Removed[key] = redactConfigValue(key, intendedValue)
+			continue
+		}
+
+		if !reflect.DeepEqual(intendedValue, actualValue) {
+			result.Changed[key] = ConfigChange{
+				Intended: redactConfigValue(key, intendedValue),
+				Actual:   redactConfigValue(key, actualValue),
+			}
+		}
+	}
+
+	for key, actualValue := range actualKeys {
+		if _, ok := intendedKeys[key]; !ok {
+			result.Added[key] = redactConfigValue(key, actualValue)
+		}
+	}
+
+	result.InSync = len(result.Added) == 0 && len(result.Removed) == 0 && len(result.Changed) == 0
+
+	return result, err
+}
+
+// flattenYAMLDocuments decodes every document in a YAML stream and flattens them into dotted keys.
+// The main (v1alpha1) document is unprefixed.  Other documents are prefixed by their kind (and name, if any).
+func flattenYAMLDocuments(data []byte) (flat map[string]interface{}, err error) {
+	flat = make(map[string]interface{})
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	for i := 0; ; i++ {
+		var doc map[string]interface{}
+		decodeErr := decoder.Decode(&doc)
+		if errors.Is(decodeErr, io.EOF) {
+			break
+		}
+		if decodeErr != nil {
+			err = errors.Wrapf(decodeErr, "failed decoding yaml document %d", i)
+			return flat, err
+		}
+
+		if doc == nil {
+			continue
+		}
+
+		prefix := ""
+		if kind, ok := doc["kind"].(string); ok {
+			prefix = kind
+			if name, nameOK := doc["name"].(string); nameOK {
+				prefix = kind + "/" + name
+			}
+		} else if i > 0 {
+			prefix = fmt.Sprintf("doc%d", i)
+		}
+
+		flattenValue(prefix, doc, flat)
+	}
+
+	return flat, err
+}
+
+// flattenValue recursively writes leaf values of maps and lists into flat, keyed by their dotted path.
+func flattenValue(prefix string, value interface{}, flat map[string]interface{}) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if len(typed) == 0 && prefix != "" {
+			flat[prefix] = typed
+			return
+		}
+		for key, child := range typed {
+			childKey := key
+			if prefix != "" {
+				childKey = prefix + "." + key
+			}
+			flattenValue(childKey, child, flat)
+		}
+	case []interface{}:
+		if len(typed) == 0 {
+			flat[prefix] = typed
+			return
+		}
+		for i, child := range typed {
+			flattenValue(fmt.Sprintf("%s[%d]", prefix, i), child, flat)
+		}
+	default:
+		flat[prefix] = typed
+	}
+}
+
+// redactConfigValue hides values of keys that hold key material, tokens, or secrets.
+func redactConfigValue(key string, value interface{}) (redacted interface{}) {
+	segments := strings.Split(key, ".")
+	last := strings.ToLower(segments[len(segments)-1])
+
+	// strip any list index, e.g. "tokens[0]" -> "tokens"
+	if idx := strings.Index(last, "["); idx >= 0 {
+		last = last[:idx]
+	}
+
+	if strings.HasSuffix(last, "key") || strings.Contains(last, "token") || strings.Contains(last, "secret") {
+		redacted = redactedValue
+		return redacted
+	}
+
+	redacted = value
+	return redacted
+}
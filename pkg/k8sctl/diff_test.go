@@ -0,0 +1,146 @@
+package k8sctl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlattenYAMLDocuments(t *testing.T) {
+	testCases := []struct {
+		name     string
+		yaml     string
+		expected map[string]interface{}
+	}{
+		{
+			name: "nested maps and lists",
+			yaml: `
+machine:
+  network:
+    hostname: node-1
+  certSANs:
+    - a.example.com
+    - b.example.com
+`,
+			expected: map[string]interface{}{
+				"machine.network.hostname": "node-1",
+				"machine.certSANs[0]":      "a.example.com",
+				"machine.certSANs[1]":      "b.example.com",
+			},
+		},
+		{
+			name: "empty map and list are kept as leaves",
+			yaml: `
+machine:
+  env: {}
+  files: []
+`,
+			expected: map[string]interface{}{
+				"machine.env":   map[string]interface{}{},
+				"machine.files": []interface{}{},
+			},
+		},
+		{
+			name: "later documents are prefixed by kind and name",
+			yaml: `
+version: v1alpha1
+---
+kind: ExtensionServiceConfig
+name: tailscale
+port: 8080
+---
+kind: HostnameConfig
+auto: stable
+---
+other: value
+`,
+			expected: map[string]interface{}{
+				"version":                               "v1alpha1",
+				"ExtensionServiceConfig/tailscale.kind": "ExtensionServiceConfig",
+				"ExtensionServiceConfig/tailscale.name": "tailscale",
+				"ExtensionServiceConfig/tailscale.port": 8080,
+				"HostnameConfig.kind":                   "HostnameConfig",
+				"HostnameConfig.auto":                   "stable",
+				"doc3.other":                            "value",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			flat, err := flattenYAMLDocuments([]byte(tc.yaml))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, flat)
+		})
+	}
+
+	_, err := flattenYAMLDocuments([]byte("machine: [unclosed"))
+	require.Error(t, err)
+}
+
+func TestRedactConfigValue(t *testing.T) {
+	testCases := []struct {
+		key      string
+		redacted bool
+	}{
+		{key: "machine.ca.key", redacted: true},
+		{key: "cluster.aescbcEncryptionSecret", redacted: true},
+		{key: "machine.token", redacted: true},
+		{key: "cluster.bootstrapTokens[0]", redacted: true},
+		{key: "cluster.secretboxEncryptionSecret", redacted: true},
+		{key: "machine.ca.crt", redacted: false},
+		{key: "machine.network.hostname", redacted: false},
+		{key: "machine.certSANs[0]", redacted: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			value := redactConfigValue(tc.key, "value")
+			if tc.redacted {
+				assert.Equal(t, redactedValue, value)
+				return
+			}
+			assert.Equal(t, "value", value)
+		})
+	}
+}
+
+func TestDiffMachineConfigs(t *testing.T) {
+	intended := `
+machine:
+  token: intended-token
+  network:
+    hostname: node-1
+  certSANs:
+    - a.example.com
+cluster:
+  secret: same-secret
+`
+	actual := `
+machine:
+  token: running-token
+  network:
+    hostname: node-2
+  kubelet:
+    image: kubelet:v1.31
+cluster:
+  secret: same-secret
+`
+
+	result, err := diffMachineConfigs([]byte(intended), []byte(actual))
+	require.NoError(t, err)
+
+	assert.False(t, result.InSync)
+	assert.Equal(t, map[string]interface{}{"machine.kubelet.image": "kubelet:v1.31"}, result.Added)
+	assert.Equal(t, map[string]interface{}{"machine.certSANs[0]": "a.example.com"}, result.Removed)
+	assert.Equal(t, map[string]ConfigChange{
+		"machine.network.hostname": {Intended: "node-1", Actual: "node-2"},
+		// Changed secrets are reported, but never their values
+		"machine.token": {Intended: redactedValue, Actual: redactedValue},
+	}, result.Changed)
+
+	result, err = diffMachineConfigs([]byte(intended), []byte(intended))
+	require.NoError(t, err)
+	assert.True(t, result.InSync)
+}
@@ -0,0 +1,125 @@
+package k8sctl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DryRunSummary says what the upgrade would do, a line each, e.g. "3 node(s) would be upgraded from v1.10.7 to
+// v1.10.8: cluster1-cp-1, cluster1-cp-2, cluster1-worker-1".  Nodes are grouped by the version they're running.
+func (r ClusterUpgradeResult) DryRunSummary() (lines []string) {
+	byVersion := make(map[string][]string)
+	var skipped, failed []string
+
+	for _, node := range r.Nodes {
+		switch node.Status {
+		case NodeUpgradeStatusUpgraded:
+			byVersion[node.PreviousVersion] = append(byVersion[node.PreviousVersion], node.Node)
+		case NodeUpgradeStatusSkipped:
+			skipped = append(skipped, node.Node)
+		case NodeUpgradeStatusFailed:
+			failed = append(failed, node.Node)
+		}
+	}
+
+	versions := make([]string, 0, len(byVersion))
+	for version := range byVersion {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
+	for _, version := range versions {
+		nodes := byVersion[version]
+		lines = append(lines, fmt.Sprintf("%d node(s) would be upgraded from %s to %s: %s", len(nodes), version, r.Version, strings.Join(nodes, ", ")))
+	}
+
+	if len(skipped) > 0 {
+		lines = append(lines, fmt.Sprintf("%d node(s) already running %s would be skipped: %s", len(skipped), r.Version, strings.Join(skipped, ", ")))
+	}
+
+	if len(failed) > 0 {
+		lines = append(lines, fmt.Sprintf("%d node(s) failed the dry run: %s", len(failed), strings.Join(failed, ", ")))
+	}
+
+	if len(r.NotTried) > 0 {
+		lines = append(lines, fmt.Sprintf("%d node(s) weren't checked, as the dry run stopped: %s", len(r.NotTried), strings.Join(r.NotTried, ", ")))
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "No nodes would be upgraded")
+	}
+
+	return lines
+}
+
+// DryRunSummary says which tags a reconcile's fix_tags would set, e.g. "2 instance(s) would have tags fixed", then an
+// instance a line.
+func (r ReconcileResult) DryRunSummary() (lines []string) {
+	if len(r.TagPlan) == 0 {
+		lines = append(lines, "No tags would be fixed")
+		return lines
+	}
+
+	lines = append(lines, fmt.Sprintf("%d instance(s) would have tags fixed", len(r.TagPlan)))
+	for _, fix := range r.TagPlan {
+		lines = append(lines, "  "+fix.Summary())
+	}
+
+	return lines
+}
+
+// DryRunSummary says how many instances a reconcile's fix_tags would set tags on.  The summary doesn't have the
+// instances: the full result does.
+func (s ReconcileSummary) DryRunSummary() (lines []string) {
+	if s.TagPlan == 0 {
+		lines = append(lines, "No tags would be fixed")
+		return lines
+	}
+
+	lines = append(lines, fmt.Sprintf("%d instance(s) would have tags fixed", s.TagPlan))
+
+	return lines
+}
+
+// DryRunSummary says which roles' secrets a sync would update, from what to what, and which it couldn't check.
+func (r SecretsSyncResult) DryRunSummary() (lines []string) {
+	if r.Error != "" {
+		lines = append(lines, fmt.Sprintf("Secrets of cluster %q couldn't be checked: %s", r.Cluster, r.Error))
+		return lines
+	}
+
+	var updates, upToDate, failed []string
+
+	for _, result := range r.Results {
+		switch {
+		case result.Error != "":
+			failed = append(failed, result.Role)
+		case result.WouldUpdate:
+			updates = append(updates, fmt.Sprintf("  %s: version %s -> %s, AMI %s -> %s", result.Role, result.StoredVersion, result.Version, result.CurrentAMI, result.DetectedAMI))
+		default:
+			upToDate = append(upToDate, result.Role)
+		}
+	}
+
+	if len(updates) > 0 {
+		lines = append(lines, fmt.Sprintf("%d role(s)' secrets would be updated", len(updates)))
+		lines = append(lines, updates...)
+	} else {
+		lines = append(lines, "No secrets would be updated")
+	}
+
+	if len(upToDate) > 0 {
+		lines = append(lines, fmt.Sprintf("%d role(s) already up to date: %s", len(upToDate), strings.Join(upToDate, ", ")))
+	}
+
+	if len(failed) > 0 {
+		lines = append(lines, fmt.Sprintf("%d role(s) couldn't be checked: %s", len(failed), strings.Join(failed, ", ")))
+	}
+
+	if len(r.NotTried) > 0 {
+		lines = append(lines, fmt.Sprintf("%d role(s) weren't checked, as the dry run stopped: %s", len(r.NotTried), strings.Join(r.NotTried, ", ")))
+	}
+
+	return lines
+}
@@ -0,0 +1,70 @@
+package k8sctl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterUpgradeDryRunSummary(t *testing.T) {
+	result := ClusterUpgradeResult{
+		Version: "v1.10.8",
+		DryRun:  true,
+		Nodes: []NodeUpgradeDetail{
+			{Node: "cluster1-cp-1", PreviousVersion: "v1.10.7", Status: NodeUpgradeStatusUpgraded},
+			{Node: "cluster1-cp-2", PreviousVersion: "v1.10.8", Status: NodeUpgradeStatusSkipped},
+			{Node: "cluster1-worker-1", PreviousVersion: "v1.10.7", Status: NodeUpgradeStatusUpgraded},
+			{Node: "cluster1-worker-2", PreviousVersion: "v1.10.6", Status: NodeUpgradeStatusUpgraded},
+			{Node: "cluster1-worker-3", PreviousVersion: unknownVersion, Status: NodeUpgradeStatusFailed},
+		},
+		NotTried: []string{"cluster1-worker-4"},
+	}
+
+	assert.Equal(t, []string{
+		"1 node(s) would be upgraded from v1.10.6 to v1.10.8: cluster1-worker-2",
+		"2 node(s) would be upgraded from v1.10.7 to v1.10.8: cluster1-cp-1, cluster1-worker-1",
+		"1 node(s) already running v1.10.8 would be skipped: cluster1-cp-2",
+		"1 node(s) failed the dry run: cluster1-worker-3",
+		"1 node(s) weren't checked, as the dry run stopped: cluster1-worker-4",
+	}, result.DryRunSummary())
+
+	assert.Equal(t, []string{"No nodes would be upgraded"}, ClusterUpgradeResult{Version: "v1.10.8"}.DryRunSummary())
+}
+
+func TestReconcileDryRunSummary(t *testing.T) {
+	result := ReconcileResult{TagPlan: []TagFix{
+		{ID: "i-1", Name: "cluster1-worker-1", Added: map[string]string{"Cluster": "cluster1"}, Previous: map[string]string{"Cluster": ""}},
+	}}
+
+	assert.Equal(t, []string{
+		"1 instance(s) would have tags fixed",
+		`  cluster1-worker-1 (i-1): Cluster "" -> "cluster1"`,
+	}, result.DryRunSummary())
+	assert.Equal(t, []string{"1 instance(s) would have tags fixed"}, result.Summary().DryRunSummary())
+
+	assert.Equal(t, []string{"No tags would be fixed"}, ReconcileResult{}.DryRunSummary())
+	assert.Equal(t, []string{"No tags would be fixed"}, ReconcileSummary{}.DryRunSummary())
+}
+
+func TestSecretsSyncDryRunSummary(t *testing.T) {
+	result := SecretsSyncResult{
+		Cluster: "cluster1",
+		Results: []SyncResult{
+			{Role: "controlplane", StoredVersion: "v1.10.8", Version: "v1.10.8", CurrentAMI: "ami-2", DetectedAMI: "ami-2"},
+			{Role: "worker", StoredVersion: "v1.10.7", Version: "v1.10.8", CurrentAMI: "ami-1", DetectedAMI: "ami-2", WouldUpdate: true, DryRun: true},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"1 role(s)' secrets would be updated",
+		"  worker: version v1.10.7 -> v1.10.8, AMI ami-1 -> ami-2",
+		"1 role(s) already up to date: controlplane",
+	}, result.DryRunSummary())
+
+	result = SecretsSyncResult{Cluster: "cluster1", Results: []SyncResult{{Role: "worker", Error: "no nodes"}}, NotTried: []string{"controlplane"}}
+	assert.Equal(t, []string{
+		"No secrets would be updated",
+		"1 role(s) couldn't be checked: worker",
+		"1 role(s) weren't checked, as the dry run stopped: controlplane",
+	}, result.DryRunSummary())
+}
@@ -0,0 +1,100 @@
+package k8sctl
+
+import (
+	"strings"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+)
+
+// targetStateHealthy is the state reported by AWS for a healthy load balancer target.
+const targetStateHealthy = "healthy"
+
+// inferNodeRole guesses a node's role from its name.  Control plane nodes are named <cluster>-cp-<n>.  It's the
+// fallback for nodes whose instances have no role tag: see nodeRole.
+func inferNodeRole(nodeName string) (role string) {
+	if strings.Contains(strings.ToLower(nodeName), "cp") {
+		role = manager.NodeRoleCp
+		return role
+	}
+
+	role = manager.NodeRoleWorker
+	return role
+}
+
+// nodeMatches reports whether a node name satisfies the role and name prefix filters, with the node's role from roles,
+// as roleOf finds it.  Empty filters match everything.
+func nodeMatches(nodeName string, roles map[string]string, role string, namePrefix string) (matches bool) {
+	if role != "" && roleOf(roles, nodeName) != role {
+		return matches
+	}
+
+	if namePrefix != "" && !strings.HasPrefix(nodeName, namePrefix) {
+		return matches
+	}
+
+	matches = true
+	return matches
+}
+
+// filterClusterInfo trims a ClusterInfo down to the nodes and load balancer targets matching the given filters, with
+// the nodes' roles from roles.  Cluster totals are recomputed from the remaining nodes so they stay consistent with
+// what is returned.
+func filterClusterInfo(info manager.ClusterInfo, roles map[string]string, role string, namePrefix string, unhealthyOnly bool) (filtered manager.ClusterInfo) {
+	if role == "" && namePrefix == "" && !unhealthyOnly {
+		filtered = info
+		return filtered
+	}
+
+	var match func(nodeName string) bool
+	if role != "" || namePrefix != "" {
+		match = func(nodeName string) bool { return nodeMatches(nodeName, roles, role, namePrefix) }
+	}
+
+	filtered = filterClusterInfoBy(info, match, unhealthyOnly)
+	return filtered
+}
+
+// filterClusterInfoBy trims a ClusterInfo down to the nodes and load balancer targets whose names match.  A nil
+// match keeps every node.
+func filterClusterInfoBy(info manager.ClusterInfo, match func(nodeName string) bool, unhealthyOnly bool) (filtered manager.ClusterInfo) {
+	filtered = info
+
+	if match != nil {
+		filtered.Nodes = make([]manager.NodeInfo, 0)
+		filtered.TotalVCPUs = 0
+		filtered.TotalMemoryGiB = 0
+
+		var dailyCost float64
+		for _, node := range info.Nodes {
+			if !match(node.Name) {
+				continue
+			}
+			filtered.Nodes = append(filtered.Nodes, node)
+			filtered.TotalVCPUs += node.VCPUs
+			filtered.TotalMemoryGiB += node.MemoryGiB
+			dailyCost += node.DailyCost
+		}
+
+		if info.EstimatedDailyCost != nil {
+			filtered.EstimatedDailyCost = &dailyCost
+		}
+	}
+
+	filtered.LoadBalancers = make([]manager.LBInfo, 0, len(info.LoadBalancers))
+	for _, lb := range info.LoadBalancers {
+		targets := make([]manager.LBTargetInfo, 0)
+		for _, target := range lb.Targets {
+			if unhealthyOnly && target.State == targetStateHealthy {
+				continue
+			}
+			if match != nil && !match(stripDomainSuffix(target.Name)) {
+				continue
+			}
+			targets = append(targets, target)
+		}
+		lb.Targets = targets
+		filtered.LoadBalancers = append(filtered.LoadBalancers, lb)
+	}
+
+	return filtered
+}
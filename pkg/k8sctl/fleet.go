@@ -0,0 +1,119 @@
+package k8sctl
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+)
+
+// ClusterOverview is a cluster's line in a describe of several clusters: its nodes, and what's wrong with it.
+type ClusterOverview struct {
+	Cluster          string `json:"cluster"`
+	Nodes            int    `json:"nodes"`
+	ControlPlane     int    `json:"control_plane"`
+	Workers          int    `json:"workers"`
+	LoadBalancers    int    `json:"load_balancers"`
+	UnhealthyTargets int    `json:"unhealthy_targets"`
+
+	// NotInLB are the nodes that aren't a target of any load balancer, as a reconcile's ec2_not_in_lb.
+	NotInLB []string `json:"not_in_lb,omitempty"`
+	// UncheckedTargetGroups counts the target groups whose health couldn't be fetched, so UnhealthyTargets may be short.
+	UncheckedTargetGroups int `json:"unchecked_target_groups,omitempty"`
+
+	EstimatedDailyCost *float64 `json:"estimated_daily_cost,omitempty"`
+
+	// Error is why the cluster couldn't be described, in which case the rest is empty.
+	Error string `json:"error,omitempty"`
+}
+
+// Issues counts the cluster's unhealthy targets and nodes missing from the load balancers.
+func (o ClusterOverview) Issues() (issues int) {
+	issues = o.UnhealthyTargets + len(o.NotInLB)
+	return issues
+}
+
+// NewClusterOverview summarizes a cluster's describe.
+func NewClusterOverview(cluster string, info DescribeClusterResult) (overview ClusterOverview) {
+	overview = ClusterOverview{
+		Cluster:               cluster,
+		Nodes:                 len(info.Nodes),
+		LoadBalancers:         len(info.LoadBalancers),
+		UnhealthyTargets:      len(info.UnhealthyTargets),
+		UncheckedTargetGroups: len(info.UncheckedTargetGroups),
+		EstimatedDailyCost:    info.EstimatedDailyCost,
+	}
+
+	lbTargets := make(map[string]bool)
+	for _, lb := range info.LoadBalancers {
+		for _, target := range lb.Targets {
+			lbTargets[stripDomainSuffix(target.Name)] = true
+		}
+	}
+
+	roles := describedRoles(info.NodeInstances)
+
+	for _, node := range info.Nodes {
+		if roleOf(roles, node.Name) == manager.NodeRoleCp {
+			overview.ControlPlane++
+		} else {
+			overview.Workers++
+		}
+
+		if !lbTargets[stripDomainSuffix(node.Name)] {
+			overview.NotInLB = append(overview.NotInLB, node.Name)
+		}
+	}
+
+	return overview
+}
+
+// FleetOverview is the overview of several clusters, described at once.
+type FleetOverview struct {
+	Clusters []ClusterOverview `json:"clusters"`
+	Failed   int               `json:"failed"` // the clusters that couldn't be described
+}
+
+// ConsolePrint prints a table of the clusters, then the nodes missing from their load balancers, and the errors of
+// those that couldn't be described.
+func (f FleetOverview) ConsolePrint() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "CLUSTER\tNODES\tCONTROL PLANE\tWORKERS\tLOAD BALANCERS\tUNHEALTHY TARGETS\tNOT IN LB\tCOST/DAY\tSTATUS\n")
+	for _, cluster := range f.Clusters {
+		if cluster.Error != "" {
+			_, _ = fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\t-\t-\t%s failed\n", cluster.Cluster, consoleMarkers.Fail)
+			continue
+		}
+
+		cost := "-"
+		if cluster.EstimatedDailyCost != nil {
+			cost = fmt.Sprintf("$%.2f", *cluster.EstimatedDailyCost)
+		}
+
+		status := consoleMarkers.OK + " ok"
+		if cluster.Issues() > 0 {
+			status = fmt.Sprintf("%s %d issue(s)", consoleMarkers.Warn, cluster.Issues())
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n", cluster.Cluster, cluster.Nodes, cluster.ControlPlane, cluster.Workers,
+			cluster.LoadBalancers, cluster.UnhealthyTargets, len(cluster.NotInLB), cost, status)
+	}
+	_ = w.Flush()
+
+	for _, cluster := range f.Clusters {
+		if len(cluster.NotInLB) > 0 {
+			fmt.Printf("\n%s: not in any load balancer: %s\n", cluster.Cluster, strings.Join(cluster.NotInLB, ", "))
+		}
+		if cluster.UncheckedTargetGroups > 0 {
+			fmt.Printf("\n%s: the health of %d target group(s) couldn't be fetched\n", cluster.Cluster, cluster.UncheckedTargetGroups)
+		}
+	}
+
+	for _, cluster := range f.Clusters {
+		if cluster.Error != "" {
+			fmt.Printf("\n%s %s: %s\n", consoleMarkers.Error, cluster.Cluster, cluster.Error)
+		}
+	}
+}
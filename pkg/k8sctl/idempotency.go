@@ -0,0 +1,205 @@
+package k8sctl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// IdempotencyKeyHeader is the request header naming a logical request, so a retry of it isn't carried out twice.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader is set on a response replayed from an earlier request with the same idempotency key.
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+// idempotencyTTL is how long a request's result is kept for retries with its idempotency key.
+const idempotencyTTL = time.Hour
+
+// idempotentKeepKey is set on a request's context once it's changed something, so its result is kept even if it fails.
+const idempotentKeepKey = "idempotent_keep"
+
+// maxIdempotencyKeyLength limits the length of an idempotency key, so a client can't use the store to hold much.
+const maxIdempotencyKeyLength = 128
+
+// idempotencyStore records the results of recent requests that had idempotency keys, in memory.  Results are lost on a
+// restart, which only reopens the window a retry could create a duplicate in.
+type idempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]idempotencyEntry
+}
+
+// idempotencyEntry is a request's result, or a request that's still in progress if it isn't done.
+type idempotencyEntry struct {
+	fingerprint [sha256.Size]byte // of the request body, so a key reused for a different request is caught
+	done        bool
+	status      int
+	contentType string
+	body        []byte
+	started     time.Time
+}
+
+var idempotencyKeys = newIdempotencyStore(idempotencyTTL)
+
+func newIdempotencyStore(ttl time.Duration) (store *idempotencyStore) {
+	store = &idempotencyStore{
+		ttl:     ttl,
+		entries: make(map[string]idempotencyEntry),
+	}
+
+	return store
+}
+
+// begin records that a request with key has started, unless one has already, in which case that request's entry is
+// returned instead.
+func (s *idempotencyStore) begin(key string, fingerprint [sha256.Size]byte, now time.Time) (existing idempotencyEntry, found bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for entryKey, entry := range s.entries {
+		if now.Sub(entry.started) > s.ttl {
+			delete(s.entries, entryKey)
+		}
+	}
+
+	existing, found = s.entries[key]
+	if found {
+		return existing, found
+	}
+
+	s.entries[key] = idempotencyEntry{fingerprint: fingerprint, started: now}
+
+	return existing, found
+}
+
+// finish records the result of a request begun with key.
+func (s *idempotencyStore) finish(key string, status int, contentType string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return
+	}
+
+	entry.done = true
+	entry.status = status
+	entry.contentType = contentType
+	entry.body = body
+	s.entries[key] = entry
+}
+
+// forget drops a request begun with key, so it can be retried.
+func (s *idempotencyStore) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+}
+
+// recordingWriter passes a response through, keeping a copy of its body.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (n int, err error) {
+	w.body.Write(data)
+	n, err = w.ResponseWriter.Write(data)
+	return n, err
+}
+
+func (w *recordingWriter) WriteString(data string) (n int, err error) {
+	w.body.WriteString(data)
+	n, err = w.ResponseWriter.WriteString(data)
+	return n, err
+}
+
+// KeepIdempotentResult records that the request has changed something, e.g. launched an instance, so its result is
+// kept for retries with its idempotency key even if it goes on to fail with a 5xx.
+func KeepIdempotentResult(ctx *gin.Context) {
+	ctx.Set(idempotentKeepKey, true)
+}
+
+// Idempotent returns middleware that carries out a request with an Idempotency-Key header only once.  A retry with the
+// same key, from the same user, within an hour gets the first request's result, with an Idempotent-Replayed header,
+// or a 409 if the first request is still running.  Reusing a key for a different request is a 422.  A request turned
+// away with a 4xx didn't do anything, so it isn't recorded, and can be retried as is.  Nor is one that failed with a
+// 5xx, unless its handler called KeepIdempotentResult first, since a transient failure should be retried, not replayed
+// for an hour.  Requests without the header are passed through.
+func Idempotent() (handler gin.HandlerFunc) {
+	handler = func(ctx *gin.Context) {
+		key := ctx.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("%s is longer than %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength),
+			})
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			_ = ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys are per user and route, so one user's key can never replay another's result
+		storeKey := strings.Join([]string{ctx.GetString("user_email"), ctx.Request.Method, ctx.Request.URL.Path, key}, " ")
+		fingerprint := sha256.Sum256(body)
+
+		existing, found := idempotencyKeys.begin(storeKey, fingerprint, time.Now())
+		if found {
+			switch {
+			case existing.fingerprint != fingerprint:
+				ctx.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+					"error": fmt.Sprintf("%s %q was already used for a different request", IdempotencyKeyHeader, key),
+				})
+			case !existing.done:
+				ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{
+					"error": fmt.Sprintf("a request with %s %q is still in progress; retry later for its result", IdempotencyKeyHeader, key),
+				})
+			default:
+				logrus.Infof("replaying the result of %s %s for %s %q", ctx.Request.Method, ctx.Request.URL.Path, IdempotencyKeyHeader, key)
+				ctx.Header(IdempotentReplayHeader, "true")
+				if existing.contentType != "" {
+					ctx.Header("Content-Type", existing.contentType)
+				}
+				ctx.Status(existing.status)
+				_, _ = ctx.Writer.Write(existing.body)
+				ctx.Abort()
+			}
+
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+
+		ctx.Next()
+
+		status := writer.Status()
+		if status >= http.StatusBadRequest && (status < http.StatusInternalServerError || !ctx.GetBool(idempotentKeepKey)) {
+			idempotencyKeys.forget(storeKey)
+			return
+		}
+
+		idempotencyKeys.finish(storeKey, status, writer.Header().Get("Content-Type"), writer.body.Bytes())
+	}
+
+	return handler
+}
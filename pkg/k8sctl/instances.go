@@ -0,0 +1,177 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/sirupsen/logrus"
+)
+
+// lifecycleOnDemand is the lifecycle of an instance EC2 reports none for.
+const lifecycleOnDemand = "on-demand"
+
+// NodeInstance is what a describe reports about each node's EC2 instance, beyond the cluster manager's node info.
+type NodeInstance struct {
+	Name             string `json:"name"`
+	ID               string `json:"id"`
+	Role             string `json:"role"`      // from the instance's role tag, or if it has none, the node name
+	Lifecycle        string `json:"lifecycle"` // spot or on-demand
+	AvailabilityZone string `json:"availability_zone"`
+
+	PrivateIP     string   `json:"private_ip,omitempty"`     // IPv4
+	IPv6Addresses []string `json:"ipv6_addresses,omitempty"` // on dual-stack and IPv6-only subnets
+
+	LaunchTime *time.Time `json:"launch_time,omitempty"` // when the instance launched
+	JoinTime   *time.Time `json:"join_time,omitempty"`   // when its Kubernetes node joined, if it has one
+	Age        string     `json:"age,omitempty"`         // how long since the instance launched, as of the describe, e.g. 3d4h
+}
+
+// ConsolePrint prints the node and its instance's details on one line.
+func (n NodeInstance) ConsolePrint() {
+	details := []string{n.Lifecycle, n.AvailabilityZone}
+	details = append(details, n.Addresses()...)
+
+	if n.LaunchTime != nil {
+		details = append(details, fmt.Sprintf("launched %s (%s ago)", formatNodeTime(n.LaunchTime), n.Age))
+	}
+	if n.JoinTime != nil {
+		details = append(details, fmt.Sprintf("joined %s", formatNodeTime(n.JoinTime)))
+	}
+
+	fmt.Printf("  %s (%s): %s\n", n.Name, n.ID, strings.Join(details, ", "))
+}
+
+// Addresses returns the instance's private IPv4 address, if it has one, then its IPv6 addresses.
+func (n NodeInstance) Addresses() (addresses []string) {
+	if n.PrivateIP != "" {
+		addresses = append(addresses, n.PrivateIP)
+	}
+	addresses = append(addresses, n.IPv6Addresses...)
+
+	return addresses
+}
+
+// nodeInstances fetches each node's EC2 instance, by instance ID.  It's a single EC2 call for all the nodes.  If it
+// fails, the failure is logged and no instances are returned, so what's derived from them is just left out.
+func nodeInstances(ctx context.Context, cm *aws.AWSClusterManager, nodes []manager.NodeInfo) (instances map[string]ec2types.Instance) {
+	instances = make(map[string]ec2types.Instance, len(nodes))
+
+	ids := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if node.ID != "" {
+			ids = append(ids, node.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return instances
+	}
+
+	output, err := cm.Ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: ids})
+	if err != nil {
+		logrus.Warnf("failed describing node instances: %s", err)
+		return instances
+	}
+
+	for _, reservation := range output.Reservations {
+		for _, instance := range reservation.Instances {
+			if instance.InstanceId != nil {
+				instances[*instance.InstanceId] = instance
+			}
+		}
+	}
+
+	return instances
+}
+
+// describeNodeInstances reports each node's instance details, in node order, with when its Kubernetes node joined, from
+// joined, and its age as of now.  Nodes whose instance wasn't found are left out.
+func describeNodeInstances(nodes []manager.NodeInfo, instances map[string]ec2types.Instance, joined map[string]time.Time, now time.Time) (described []NodeInstance) {
+	described = make([]NodeInstance, 0, len(nodes))
+
+	for _, node := range nodes {
+		instance, ok := instances[node.ID]
+		if !ok {
+			continue
+		}
+
+		described = append(described, NodeInstance{
+			Name:             node.Name,
+			ID:               node.ID,
+			Role:             nodeRole(node, instances),
+			Lifecycle:        instanceLifecycle(instance),
+			AvailabilityZone: instanceZone(instance),
+			PrivateIP:        awssdk.ToString(instance.PrivateIpAddress),
+			IPv6Addresses:    instanceIPv6Addresses(instance),
+			LaunchTime:       instance.LaunchTime,
+		})
+
+		if instance.LaunchTime != nil {
+			described[len(described)-1].Age = humanAge(now.Sub(*instance.LaunchTime))
+		}
+
+		if joinTime, ok := joined[stripDomainSuffix(node.Name)]; ok {
+			described[len(described)-1].JoinTime = &joinTime
+		}
+	}
+
+	return described
+}
+
+// instanceLifecycle returns whether an instance is spot or on-demand.  EC2 only sets the lifecycle of instances that
+// aren't on-demand.
+func instanceLifecycle(instance ec2types.Instance) (lifecycle string) {
+	lifecycle = string(instance.InstanceLifecycle)
+	if lifecycle == "" {
+		lifecycle = lifecycleOnDemand
+	}
+
+	return lifecycle
+}
+
+// instanceZone returns the availability zone an instance is in.
+func instanceZone(instance ec2types.Instance) (zone string) {
+	if instance.Placement != nil {
+		zone = awssdk.ToString(instance.Placement.AvailabilityZone)
+	}
+
+	return zone
+}
+
+// instanceIPv6Addresses returns an instance's IPv6 addresses, on any of its network interfaces, with its primary IPv6
+// address first.
+func instanceIPv6Addresses(instance ec2types.Instance) (addresses []string) {
+	if instance.Ipv6Address != nil {
+		addresses = append(addresses, *instance.Ipv6Address)
+	}
+
+	for _, eni := range instance.NetworkInterfaces {
+		for _, ipv6 := range eni.Ipv6Addresses {
+			address := awssdk.ToString(ipv6.Ipv6Address)
+			if address != "" && !slices.Contains(addresses, address) {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+
+	return addresses
+}
+
+// instanceAddresses returns an instance's private IPv4 address, if it has one, then its IPv6 addresses: any of them
+// can be the ID of a target in a target group of type ip.
+func instanceAddresses(instance ec2types.Instance) (addresses []string) {
+	if instance.PrivateIpAddress != nil {
+		addresses = append(addresses, *instance.PrivateIpAddress)
+	}
+	addresses = append(addresses, instanceIPv6Addresses(instance)...)
+
+	return addresses
+}
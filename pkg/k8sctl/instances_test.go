@@ -0,0 +1,92 @@
+package k8sctl
+
+import (
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeNodeInstances(t *testing.T) {
+	nodes := []manager.NodeInfo{
+		{Name: "cluster1-worker-1", ID: "i-1"},
+		{Name: "cluster1-worker-2", ID: "i-2"},
+		{Name: "cluster1-worker-3", ID: "i-3"},
+		{Name: "cluster1-worker-4", ID: "i-4"},
+	}
+
+	instances := map[string]ec2types.Instance{
+		"i-1": {InstanceId: awssdk.String("i-1")},
+		"i-2": {
+			InstanceId:        awssdk.String("i-2"),
+			InstanceLifecycle: ec2types.InstanceLifecycleTypeSpot,
+			Placement:         &ec2types.Placement{AvailabilityZone: awssdk.String("us-east-1b")},
+			PrivateIpAddress:  awssdk.String("10.0.1.12"),
+		},
+		// Dual-stack, with a second IPv6 address on the primary interface and one on another
+		"i-4": {
+			InstanceId:       awssdk.String("i-4"),
+			PrivateIpAddress: awssdk.String("10.0.1.14"),
+			Ipv6Address:      awssdk.String("2600:1f18:aaaa::14"),
+			NetworkInterfaces: []ec2types.InstanceNetworkInterface{
+				{Ipv6Addresses: []ec2types.InstanceIpv6Address{
+					{Ipv6Address: awssdk.String("2600:1f18:aaaa::14"), IsPrimaryIpv6: awssdk.Bool(true)},
+					{Ipv6Address: awssdk.String("2600:1f18:aaaa::15")},
+				}},
+				{Ipv6Addresses: []ec2types.InstanceIpv6Address{{Ipv6Address: awssdk.String("2600:1f18:bbbb::14")}}},
+			},
+		},
+	}
+
+	assert.Equal(t, []NodeInstance{
+		{Name: "cluster1-worker-1", ID: "i-1", Role: manager.NodeRoleWorker, Lifecycle: "on-demand"},
+		{Name: "cluster1-worker-2", ID: "i-2", Role: manager.NodeRoleWorker, Lifecycle: "spot", AvailabilityZone: "us-east-1b", PrivateIP: "10.0.1.12"},
+		{
+			Name: "cluster1-worker-4", ID: "i-4", Role: manager.NodeRoleWorker, Lifecycle: "on-demand", PrivateIP: "10.0.1.14",
+			IPv6Addresses: []string{"2600:1f18:aaaa::14", "2600:1f18:aaaa::15", "2600:1f18:bbbb::14"},
+		},
+	}, describeNodeInstances(nodes, instances, nil, time.Now()))
+
+	assert.Equal(t, []string{"10.0.1.14", "2600:1f18:aaaa::14", "2600:1f18:aaaa::15", "2600:1f18:bbbb::14"}, instanceAddresses(instances["i-4"]))
+	assert.Empty(t, instanceAddresses(instances["i-1"]))
+}
+func TestDescribeNodeInstancesTimes(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	launched := now.Add(-(3*24*time.Hour + 4*time.Hour + 20*time.Minute))
+	joined := launched.Add(2 * time.Minute)
+
+	nodes := []manager.NodeInfo{
+		{Name: "cluster1-worker-1.example.com", ID: "i-1"},
+		{Name: "cluster1-worker-2.example.com", ID: "i-2"},
+	}
+	instances := map[string]ec2types.Instance{
+		"i-1": {InstanceId: awssdk.String("i-1"), LaunchTime: &launched},
+		"i-2": {InstanceId: awssdk.String("i-2")},
+	}
+
+	described := describeNodeInstances(nodes, instances, map[string]time.Time{"cluster1-worker-1": joined}, now)
+
+	assert.Equal(t, &launched, described[0].LaunchTime)
+	assert.Equal(t, &joined, described[0].JoinTime)
+	assert.Equal(t, "3d4h", described[0].Age)
+
+	// Without a launch time, or a Kubernetes node, there's no age or join time
+	assert.Nil(t, described[1].LaunchTime)
+	assert.Nil(t, described[1].JoinTime)
+	assert.Empty(t, described[1].Age)
+}
+
+func TestIsNodeAddress(t *testing.T) {
+	addresses := []string{"10.0.1.14", "2600:1f18:aaaa::14"}
+
+	assert.True(t, isNodeAddress("10.0.1.14", addresses))
+	assert.True(t, isNodeAddress("2600:1f18:aaaa::14", addresses))
+	assert.True(t, isNodeAddress("2600:1f18:aaaa:0000:0000:0000:0000:0014", addresses))
+	assert.True(t, isNodeAddress("2600:1F18:AAAA::14", addresses))
+	assert.False(t, isNodeAddress("2600:1f18:aaaa::15", addresses))
+	assert.False(t, isNodeAddress("i-4", addresses))
+	assert.False(t, isNodeAddress("10.0.1.14", nil))
+}
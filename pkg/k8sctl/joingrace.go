@@ -0,0 +1,32 @@
+package k8sctl
+
+import (
+	"time"
+)
+
+// joinStuckWindow is how long after its join grace runs out an instance missing from Kubernetes is reported as stuck
+// joining.  Older than that, it's more likely left over than still trying, and is reported as ec2_not_in_k8s.
+const joinStuckWindow = 24 * time.Hour
+
+// joinGrace returns how long an instance may take to join Kubernetes before it's reported: join_grace, or min_age,
+// whichever is longer.
+func joinGrace(body ReconcileClusterBody) (grace time.Duration) {
+	grace = time.Duration(max(body.JoinGrace, body.MinAge)) * time.Second
+	return grace
+}
+
+// stuckJoining splits the instances missing from Kubernetes, all older than the join grace, into those launched within
+// joinStuckWindow of the grace running out, which are stuck joining, and the rest.  Instances whose launch time isn't
+// known are left with the rest.
+func stuckJoining(orphans []OrphanNode, grace time.Duration, now time.Time) (rest []OrphanNode, stuck []OrphanNode) {
+	for _, orphan := range orphans {
+		if orphan.LaunchTime != nil && now.Sub(*orphan.LaunchTime) < grace+joinStuckWindow {
+			stuck = append(stuck, orphan)
+			continue
+		}
+
+		rest = append(rest, orphan)
+	}
+
+	return rest, stuck
+}
@@ -0,0 +1,36 @@
+package k8sctl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinGrace(t *testing.T) {
+	assert.Equal(t, time.Duration(0), joinGrace(ReconcileClusterBody{}))
+	assert.Equal(t, 15*time.Minute, joinGrace(ReconcileClusterBody{MinAge: 900}))
+	assert.Equal(t, 15*time.Minute, joinGrace(ReconcileClusterBody{MinAge: 900, JoinGrace: 600}))
+	assert.Equal(t, 10*time.Minute, joinGrace(ReconcileClusterBody{MinAge: 60, JoinGrace: 600}))
+}
+
+func TestStuckJoining(t *testing.T) {
+	now := time.Now()
+	grace := 10 * time.Minute
+	launched := func(ago time.Duration) *time.Time {
+		launchTime := now.Add(-ago)
+		return &launchTime
+	}
+
+	orphans := []OrphanNode{
+		{Name: "cluster1-worker-4", ID: "i-4", LaunchTime: launched(20 * time.Minute)},
+		{Name: "cluster1-worker-5", ID: "i-5", LaunchTime: launched(3 * 24 * time.Hour)},
+		{Name: "cluster1-worker-6", ID: "i-6"}, // launch time unknown
+		{Name: "cluster1-worker-7", ID: "i-7", LaunchTime: launched(24 * time.Hour)},
+	}
+
+	rest, stuck := stuckJoining(orphans, grace, now)
+
+	assert.Equal(t, []OrphanNode{orphans[0], orphans[3]}, stuck)
+	assert.Equal(t, []OrphanNode{orphans[1], orphans[2]}, rest)
+}
@@ -1,23 +1,29 @@
 package k8sctl
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
 	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
-	"github.com/nikogura/k8s-cluster-manager/pkg/manager/cloudflare"
-	"github.com/nikogura/k8s-cluster-manager/pkg/manager/kubernetes"
+	"github.com/nikogura/k8sctl/pkg/config"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"maps"
 	"net/http"
-	"os"
+	"net/netip"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
 var cfAPIToken string
 var cfZoneID string
+var clusterConfig *config.Config
+var clusterCache *describeCache
+var monitorWebhookURL string
 
 // SetCloudflareCredentials sets the Cloudflare API credentials for the package.
 func SetCloudflareCredentials(apiToken, zoneID string) {
@@ -25,8 +31,39 @@ func SetCloudflareCredentials(apiToken, zoneID string) {
 	cfZoneID = zoneID
 }
 
+// SetClusterConfig sets the per-cluster server configuration (region, IAM role to assume) for the package.
+func SetClusterConfig(cfg *config.Config) {
+	clusterConfig = cfg
+}
+
 type DescribeClusterBody struct {
-	Verbose bool `json:"verbose"`
+	Verbose       bool   `json:"verbose"`
+	Role          string `json:"role,omitempty"`
+	NamePrefix    string `json:"name_prefix,omitempty"`
+	UnhealthyOnly bool   `json:"unhealthy_only,omitempty"`
+	NodeLBs       bool   `json:"node_lbs,omitempty"`
+	Region        string `json:"region,omitempty"`
+
+	Utilization bool `json:"utilization,omitempty"`
+
+	// Since, in seconds, reports only the nodes launched, or joined to Kubernetes, in this window
+	Since int `json:"since,omitempty"`
+}
+
+// DescribeClusterResult is the cluster info, plus the reason each unhealthy load balancer target is unhealthy.
+// NodeAttachments is only filled in when asked for.
+type DescribeClusterResult struct {
+	manager.ClusterInfo
+	UnhealthyTargets []UnhealthyTarget  `json:"unhealthy_targets,omitempty"`
+	NodeAttachments  []NodeLBAttachment `json:"node_attachments,omitempty"`
+	NodeInstances    []NodeInstance     `json:"node_instances,omitempty"`
+	// UncheckedTargetGroups are the target groups, as "<load balancer>/<target group>", whose target health couldn't be
+	// fetched.  Their reasons and node attachments are missing from the result.
+	UncheckedTargetGroups []string `json:"unchecked_target_groups,omitempty"`
+
+	Utilization []NodeUtilization `json:"utilization,omitempty"`
+	// UtilizationError is why the utilization couldn't be reported, if it was asked for.
+	UtilizationError string `json:"utilization_error,omitempty"`
 }
 
 type NodeCreateBody struct {
@@ -36,12 +73,216 @@ type NodeCreateBody struct {
 	CloudProvider string `json:"cloud_provider"`
 	Type          string `json:"type"`
 	Purpose       string `json:"purpose"`
+	Region        string `json:"region,omitempty"`
+	Spot          bool   `json:"spot,omitempty"`           // launch as a spot instance.  Workers only.
+	SpotMaxPrice  string `json:"spot_max_price,omitempty"` // USD per hour, default the on-demand price
+	// SubnetID launches the node in a subnet other than the node config's.  AvailabilityZone launches it in the zone's
+	// subnet like the node config's.  At most one may be given.
+	SubnetID         string `json:"subnet_id,omitempty"`
+	AvailabilityZone string `json:"availability_zone,omitempty"`
+
+	// Tags are extra EC2 tags for the instance, e.g. team or cost-center, and Labels extra Kubernetes labels for the
+	// node, which it registers with.  The Name, Cluster, and purpose tags and the purpose label are set as usual.
+	Tags   map[string]string `json:"tags,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// TargetGroups are extra load balancer target groups, by ARN or name, to register the node with, beyond those of
+	// the cluster's load balancers that its role gets.
+	TargetGroups []string `json:"target_groups,omitempty"`
+}
+
+// NodeCreateResult describes a created node.  It's returned in verbose mode.
+type NodeCreateResult struct {
+	Node               string `json:"node"`
+	Role               string `json:"role"`
+	InstanceType       string `json:"instance_type"`
+	InstanceTypeSource string `json:"instance_type_source"` // request, node config, or config default
+	Spot               bool   `json:"spot,omitempty"`
+	SubnetID           string `json:"subnet_id"`
+
+	Tags   map[string]string `json:"tags,omitempty"`   // the extra EC2 tags the instance was launched with
+	Labels map[string]string `json:"labels,omitempty"` // the extra Kubernetes labels the node registered with
+
+	TargetGroups []string `json:"target_groups,omitempty"` // the extra target groups the node was registered with, by name
 }
 
 type NodeDeleteBody struct {
 	Name          string `json:"name"`
 	Verbose       bool   `json:"verbose"`
 	CloudProvider string `json:"cloud_provider"`
+	Region        string `json:"region,omitempty"`
+	DryRun        bool   `json:"dry_run,omitempty"` // return the NodeDeletePlan, rather than deleting the node
+}
+
+type NodeGlassBody struct {
+	Verbose bool   `json:"verbose"`
+	Region  string `json:"region,omitempty"`
+	DryRun  bool   `json:"dry_run,omitempty"` // return the NodeDeletePlan, rather than glassing the node
+}
+
+type ReconcileClusterBody struct {
+	Verbose bool   `json:"verbose"`
+	FixTags bool   `json:"fix_tags"`
+	Region  string `json:"region,omitempty"`
+	// FixTagsDryRun returns the tags fix_tags would set, as the result's tag_plan, without setting them.  It overrides
+	// fix_tags.
+	FixTagsDryRun bool `json:"fix_tags_dry_run,omitempty"`
+	// MinAge, in seconds, is how old an EC2 instance must be before it's reported as missing from Kubernetes, so
+	// instances that are still joining aren't flagged.
+	MinAge int `json:"min_age,omitempty"`
+	// JoinGrace, in seconds, is min_age, plus instances older than it, but launched within a day of it running out, are
+	// reported as stuck_joining rather than ec2_not_in_k8s: they've most likely failed to join.
+	JoinGrace int `json:"join_grace,omitempty"`
+	// MinControlPlaneZones is how many availability zones the control plane nodes should span, default 3.
+	MinControlPlaneZones int `json:"min_control_plane_zones,omitempty"`
+	// Summary returns just the counts, a ReconcileSummary, rather than the full result with its lists of nodes.
+	Summary bool `json:"summary,omitempty"`
+	// IncludeClusterInfo adds the cluster info the reconcile compared to its result, so one request gives both the
+	// cluster's state and the discrepancies in it, from the same snapshot.
+	IncludeClusterInfo bool `json:"include_cluster_info,omitempty"`
+
+	// Stream writes the reconcile as JSON Lines of ReconcileEvents: its progress and each discrepancy as they're found,
+	// then the summary.  On a large cluster, results arrive as soon as they're known, rather than all at the end.
+	Stream bool `json:"stream,omitempty"`
+
+	// NodeSelector limits the reconcile to matching nodes.  Unset, every node is compared.
+	NodeSelector
+}
+
+// ReconcileResult lists the discrepancies found between EC2, Kubernetes, and the load balancers.
+type ReconcileResult struct {
+	UntaggedNodes     []string        `json:"untagged_nodes,omitempty"`
+	EC2NotInK8s       []OrphanNode    `json:"ec2_not_in_k8s,omitempty"`
+	JoiningNodes      []string        `json:"joining_nodes,omitempty"`                 // instances not in Kubernetes, but younger than min_age or join_grace
+	StuckJoining      []OrphanNode    `json:"stuck_joining,omitempty"`                 // with join_grace, instances past it, but launched recently, not in Kubernetes
+	OrphanCost        *float64        `json:"orphan_estimated_monthly_cost,omitempty"` // USD, of the instances not in Kubernetes
+	K8sNotInEC2       []string        `json:"k8s_not_in_ec2,omitempty"`
+	EC2NotInLB        []string        `json:"ec2_not_in_lb,omitempty"`
+	DuplicateEC2Names []DuplicateName `json:"duplicate_ec2_names,omitempty"` // Name tags shared by several instances
+	DuplicateK8sNames []DuplicateName `json:"duplicate_k8s_names,omitempty"` // Kubernetes node names that collide without their domain
+	MissingTags       []MissingTags   `json:"missing_tags,omitempty"`        // instances missing the server config's required_tags
+	FixedTags         bool            `json:"fixed_tags"`
+	TagFixes          []TagFix        `json:"tag_fixes,omitempty"`        // the tags set on each instance by fix_tags, before and after
+	TagFixesFailed    int             `json:"tag_fixes_failed,omitempty"` // the tag fixes that couldn't be made, each with its error
+	TagPlan           []TagFix        `json:"tag_plan,omitempty"`         // the tags fix_tags_dry_run found fix_tags would set
+	Message           string          `json:"message"`
+	TotalIssuesFound  int             `json:"total_issues_found"`
+
+	// ControlPlaneSpread is the control plane nodes in each availability zone.  It's an issue if it isn't balanced.
+	ControlPlaneSpread *ZoneSpread `json:"control_plane_spread,omitempty"`
+
+	// ClusterInfo is what was reconciled, of the selected nodes, with include_cluster_info.
+	ClusterInfo *manager.ClusterInfo `json:"cluster_info,omitempty"`
+}
+
+type MonitorClusterBody struct {
+	Verbose  bool   `json:"verbose"`
+	Interval int    `json:"interval"`
+	Cache    bool   `json:"cache,omitempty"` // reuse AWS data from the server's describe cache, if it's enabled
+	Once     bool   `json:"once,omitempty"`  // run a single check and return, rather than streaming
+	Region   string `json:"region,omitempty"`
+
+	// WebhookURL is POSTed a MonitorAlert when the cluster becomes unhealthy or recovers.  Defaults to the server's.
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// WebhookReminder is how often, in seconds, to re-send the alert while the cluster stays unhealthy.
+	WebhookReminder int `json:"webhook_reminder,omitempty"`
+
+	// NoColor flags the stream's lines with plain text markers, like [WARN] and [OK], rather than emoji.
+	NoColor bool `json:"no_color,omitempty"`
+
+	// CheckTimeout, in seconds, is how long each check may take before it's abandoned, default 30.
+	CheckTimeout int `json:"check_timeout,omitempty"`
+
+	// NodeSelector limits the checks to matching nodes.  Unset, every node is checked.
+	NodeSelector
+}
+
+// AuthCheckResult is returned to a client whose token passed authentication.
+type AuthCheckResult struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+
+	// Who the server authenticated the caller as
+	UserEmail string   `json:"user_email,omitempty"`
+	Groups    []string `json:"groups,omitempty"`
+}
+
+type UpgradeClusterBody struct {
+	Version           string `json:"version"`
+	ControlPlaneFirst bool   `json:"control_plane_first"`
+	MaxConcurrent     int    `json:"max_concurrent"`
+	Preserve          bool   `json:"preserve"`
+	Stage             bool   `json:"stage"`
+	WaitBetween       int    `json:"wait_between"`
+	DryRun            bool   `json:"dry_run"`
+	UpdateSecrets     bool   `json:"update_secrets"`
+	OnFailure         string `json:"on_failure,omitempty"`
+	HealthTimeout     *int   `json:"health_timeout,omitempty"`
+	Verbose           bool   `json:"verbose"`
+	Region            string `json:"region,omitempty"`
+	KeepAlive         bool   `json:"keep_alive,omitempty"` // write whitespace while upgrading, so proxies keep the connection open
+
+	// Wait, once every node's been upgraded without failures, waits for every node in the cluster to be Ready with
+	// healthy load balancer targets, for up to WaitTimeout seconds, default 900.  The result's health says whether it was.
+	Wait        bool `json:"wait,omitempty"`
+	WaitTimeout int  `json:"wait_timeout,omitempty"`
+
+	// Nodes upgrades only these nodes, by name, with the same rolling upgrade, rather than every node in the cluster.
+	Nodes []string `json:"nodes,omitempty"`
+}
+
+type UpgradeNodeBody struct {
+	Version       string `json:"version"`
+	Preserve      bool   `json:"preserve"`
+	Stage         bool   `json:"stage"`
+	DryRun        bool   `json:"dry_run"`
+	UpdateSecrets bool   `json:"update_secrets"`
+	Verbose       bool   `json:"verbose"`
+	Region        string `json:"region,omitempty"`
+	KeepAlive     bool   `json:"keep_alive,omitempty"` // write whitespace while upgrading, so proxies keep the connection open
+}
+
+type SecretsSyncBody struct {
+	Role    string `json:"role"`
+	DryRun  bool   `json:"dry_run"`
+	Verbose bool   `json:"verbose"`
+	Region  string `json:"region,omitempty"`
+
+	// OnFailure is what to do with the rest of the roles when one fails: continue, the default, or fail-fast.
+	OnFailure string `json:"on_failure,omitempty"`
+}
+
+// SyncResult reports the AMI and machine config state for one role after a secrets sync.  CurrentAMI is the AMI
+// stored in Vault, and Version the Talos version the role's node is running.
+type SyncResult struct {
+	Role          string `json:"role"`
+	CurrentAMI    string `json:"current_ami"`
+	Version       string `json:"version"`
+	UpdatedAMI    string `json:"updated_ami,omitempty"`
+	UpdatedConfig bool   `json:"updated_config"`
+	DryRun        bool   `json:"dry_run"`
+
+	// DetectedAMI is the AMI for the running version.  WouldUpdate is true if it, or the running version, differs from
+	// what's stored, so a sync updates the stored AMI and installer version to match.
+	Node          string `json:"node"` // the node whose version was detected
+	StoredVersion string `json:"stored_version"`
+	DetectedAMI   string `json:"detected_ami"`
+	WouldUpdate   bool   `json:"would_update"`
+	Error         string `json:"error,omitempty"` // why the role couldn't be checked or synced
+}
+
+// SecretsSyncResult lists the sync results for each role in a cluster.
+type SecretsSyncResult struct {
+	Cluster string       `json:"cluster"`
+	Results []SyncResult `json:"results"`
+
+	Error string `json:"error,omitempty"` // why the cluster's roles couldn't be checked, in a secrets status
+
+	// Succeeded and Failed are the roles whose sync succeeded and failed.  NotTried are those a fail-fast sync stopped
+	// before.  A secrets status leaves them out.
+	Succeeded []string `json:"succeeded,omitempty"`
+	Failed    []string `json:"failed,omitempty"`
+	NotTried  []string `json:"not_tried,omitempty"`
 }
 
 func (c *K8sCtlCommands) DescribeClusterHandler(ctx *gin.Context) {
@@ -59,8 +300,30 @@ func (c *K8sCtlCommands) DescribeClusterHandler(ctx *gin.Context) {
 
 	verbose := body.Verbose
 
-	dnsManager := cloudflare.NewCloudFlareManager(cfZoneID, cfAPIToken)
-	cm, err := aws.NewAWSClusterManager(ctx, clusterName, "", "", dnsManager, verbose)
+	// Query parameters are accepted as an alternative to body fields for the filters
+	if role := ctx.Query("role"); role != "" {
+		body.Role = role
+	}
+	if namePrefix := ctx.Query("name_prefix"); namePrefix != "" {
+		body.NamePrefix = namePrefix
+	}
+	if ctx.Query("unhealthy_only") == "true" {
+		body.UnhealthyOnly = true
+	}
+	if ctx.Query("node_lbs") == "true" {
+		body.NodeLBs = true
+	}
+	if ctx.Query("utilization") == "true" {
+		body.Utilization = true
+	}
+
+	if body.Role != "" && body.Role != manager.NodeRoleCp && body.Role != manager.NodeRoleWorker {
+		err = errors.New(fmt.Sprintf("invalid role %q: must be %s or %s", body.Role, manager.NodeRoleCp, manager.NodeRoleWorker))
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	cm, err := newClusterManager(ctx, clusterName, body.Region, verbose)
 	if err != nil {
 		logrus.Errorf("Failed creating cluster manager: %s", err)
 		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
@@ -73,14 +336,21 @@ func (c *K8sCtlCommands) DescribeClusterHandler(ctx *gin.Context) {
 	costEstimator := aws.NewAWSPricingEstimator(region, customPricing)
 	cm.SetCostEstimator(costEstimator)
 
-	info, err := cm.DescribeCluster(clusterName)
+	result, err := DescribeCluster(ctx, cm, clusterName, DescribeClusterOptions{
+		Role:          body.Role,
+		NamePrefix:    body.NamePrefix,
+		UnhealthyOnly: body.UnhealthyOnly,
+		NodeLBs:       body.NodeLBs,
+		Utilization:   body.Utilization,
+		Since:         time.Duration(body.Since) * time.Second,
+	})
 	if err != nil {
 		logrus.Errorf("Failed describing cluster: %s", err)
 		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, info)
+	ctx.JSON(http.StatusOK, result)
 
 }
 
@@ -111,76 +381,165 @@ func (c *K8sCtlCommands) CreateNodeHandler(ctx *gin.Context) {
 		return
 	}
 
-	// Create the cloudflare manager
-	dnsManager := cloudflare.NewCloudFlareManager(cfZoneID, cfAPIToken)
-	cm, err := aws.NewAWSClusterManager(ctx, clusterName, "", "", dnsManager, verbose)
+	err = validateSpot(nodeRole, body.Spot, body.SpotMaxPrice)
 	if err != nil {
-		logrus.Errorf("Failed creating cluster manager: %s", err)
-		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
 
-	// NB: the following could be more efficiently done at pod start up, but that would require loading ALL the configs for all clusters and roles.  Not bothering with that now.  This isn't a high speed app.  Loading at run time is acceptable for now.
-	// develop the expected paths for this cluster and node role
-	machineConfigPath := fmt.Sprintf("/etc/clusters/%s/%s/config.yaml", clusterName, nodeRole)
-	nodeConfigPath := fmt.Sprintf("/etc/clusters/%s/%s/node-%s.yaml", clusterName, nodeRole, cloudProvider)
-	patchConfigPath := fmt.Sprintf("/etc/clusters/%s/%s/patch.yaml", clusterName, nodeRole)
+	err = validatePlacement(body.SubnetID, body.AvailabilityZone)
+	if err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
 
-	logrus.Infof("Machine Config Path: %s", machineConfigPath)
-	logrus.Infof("Node Config Path: %s", nodeConfigPath)
-	logrus.Infof("Patch Path: %s", patchConfigPath)
+	err = validateNodeTags(body.Tags)
+	if err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
 
-	// Load the machine config
-	configBytes, err := os.ReadFile(machineConfigPath)
+	err = validateNodeLabels(body.Labels)
 	if err != nil {
-		err = errors.Wrapf(err, "Failed loading machine config file %s", machineConfigPath)
-		logrus.Errorf("failed reading machine config file %s: %s", machineConfigPath, err)
-		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
 
-	// Load the node config
-	nodeConfigBytes, err := os.ReadFile(nodeConfigPath)
+	cm, err := newClusterManager(ctx, clusterName, body.Region, verbose)
 	if err != nil {
-		err = errors.Wrapf(err, "Failed loading node config file %s", nodeConfigPath)
-		logrus.Errorf("failed reading node config file %s: %s", nodeConfigPath, err)
+		logrus.Errorf("Failed creating cluster manager: %s", err)
 		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
-	// Create the AWS Node Config struct
-	nodeConfig, err := aws.LoadAWSNodeConfig(nodeConfigBytes)
+	// Extra target groups are looked up before anything's launched, so a wrong one fails the create cleanly
+	targetGroups, err := resolveTargetGroups(ctx, cm.ELBClient, body.TargetGroups)
 	if err != nil {
-		err = errors.Wrapf(err, "failed making node struct from config %s", nodeConfigPath)
-		logrus.Errorf("failed making node struct from config %s: %s", nodeConfigPath, err)
-		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		logrus.Errorf("failed resolving target groups: %s", err)
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
 
-	// Override the default instance type if a type was provided in the request.
-	if body.Type != "" {
-		nodeConfig.InstanceType = body.Type
-		logrus.Infof("setting instance type to %q", body.Type)
+	// The instance records its role, so it needn't be guessed from the node's name, and its extra target groups, for
+	// describe to find their load balancers
+	launchTags := maps.Clone(body.Tags)
+	if launchTags == nil {
+		launchTags = make(map[string]string)
+	}
+	launchTags[nodeRoleTag] = nodeRole
+
+	if len(targetGroups) > 0 {
+		tagValue, tagErr := targetGroupsTagValue(targetGroups)
+		if tagErr != nil {
+			_ = ctx.AbortWithError(http.StatusBadRequest, tagErr)
+			return
+		}
+
+		launchTags[nodeTargetGroupsTag] = tagValue
 	}
 
-	// Load the machine config patch
-	patchBytes, err := os.ReadFile(patchConfigPath)
+	// Load the machine config, node config, and patch for this cluster and node role
+	files, err := loadNodeConfigFiles(clusterName, nodeRole, cloudProvider)
 	if err != nil {
-		err = errors.Wrapf(err, "Failed loading patch file %s", patchConfigPath)
-		logrus.Errorf("failed reading patch file %s: %s", patchConfigPath, err)
+		logrus.Errorf("failed loading node config files: %s", err)
 		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
-	logrus.Infof("node config: %s", nodeConfig)
+	nodeConfig := files.NodeConfig
+
+	// A type in the request overrides the node config's, which overrides the server config's default for the role.
+	var instanceTypeSource string
+	nodeConfig.InstanceType, instanceTypeSource = chooseInstanceType(body.Type, nodeConfig.InstanceType, defaultInstanceType(nodeRole))
+	logrus.Infof("setting instance type to %q, from the %s", nodeConfig.InstanceType, instanceTypeSource)
+
+	// A subnet in the request overrides the node config's.  An availability zone picks that zone's subnet.
+	switch {
+	case body.SubnetID != "":
+		nodeConfig.SubnetID = body.SubnetID
+	case body.AvailabilityZone != "":
+		defaultSubnet, candidates, subnetErr := describeZoneSubnets(ctx, cm, nodeConfig.SubnetID, body.AvailabilityZone)
+		if subnetErr != nil {
+			logrus.Errorf("failed finding a subnet in availability zone %s: %s", body.AvailabilityZone, subnetErr)
+			_ = ctx.AbortWithError(http.StatusInternalServerError, subnetErr)
+			return
+		}
+
+		nodeConfig.SubnetID, err = pickZoneSubnet(defaultSubnet, candidates, body.AvailabilityZone)
+		if err != nil {
+			_ = ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		logrus.Infof("launching node %s in subnet %s, in availability zone %s", nodeName, nodeConfig.SubnetID, body.AvailabilityZone)
+	}
+
+	logrus.Infof("node config: %s", RedactConfig(nodeConfig))
+
+	// The cluster manager is this request's own, so its EC2 client can be swapped for one that asks for spot
+	if body.Spot {
+		logrus.Infof("launching node %s as a spot instance", nodeName)
+		cm.Ec2Client = &spotEC2Client{Ec2Client: cm.Ec2Client, maxPrice: body.SpotMaxPrice}
+	}
+
+	// Likewise for extra tags, and the labels are a last machine config patch
+	logrus.Infof("launching node %s with tags %v", nodeName, launchTags)
+	tagging := &taggingEC2Client{Ec2Client: cm.Ec2Client, tags: launchTags}
+	cm.Ec2Client = tagging
+
+	if len(body.Labels) > 0 {
+		labelsPatch, patchErr := nodeLabelsPatch(body.Labels)
+		if patchErr != nil {
+			_ = ctx.AbortWithError(http.StatusInternalServerError, patchErr)
+			return
+		}
+
+		logrus.Infof("registering node %s with labels %v", nodeName, body.Labels)
+		files.Patches = append(files.Patches, labelsPatch)
+	}
 
 	// Actually create the node and attach it to the load balancers
-	err = cm.CreateNode(nodeName, nodeRole, nodeConfig, configBytes, []string{string(patchBytes)}, body.Purpose)
+	err = cm.CreateNode(nodeName, nodeRole, nodeConfig, files.MachineConfig, files.Patches, body.Purpose)
 	if err != nil {
 		logrus.Errorf("error creating node: %s", err)
+
+		// A retry of a create that failed before launching can run again.  One that launched an instance mustn't.
+		if tagging.launched {
+			KeepIdempotentResult(ctx)
+		}
+
 		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+
+	KeepIdempotentResult(ctx)
+
+	var registered []string
+	if len(targetGroups) > 0 {
+		registered, err = registerNewNode(ctx, cm, nodeName, targetGroups)
+		if err != nil {
+			err = errors.Wrapf(err, "node %s was created, but not registered with all its extra target groups", nodeName)
+			logrus.Errorf("%s", err)
+			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		logrus.Infof("registered node %s with extra target groups %v", nodeName, registered)
+	}
+
+	if verbose {
+		ctx.JSON(http.StatusOK, NodeCreateResult{
+			Node:               nodeName,
+			Role:               nodeRole,
+			InstanceType:       nodeConfig.InstanceType,
+			InstanceTypeSource: instanceTypeSource,
+			Spot:               body.Spot,
+			SubnetID:           nodeConfig.SubnetID,
+			Tags:               body.Tags,
+			Labels:             body.Labels,
+			TargetGroups:       registered,
+		})
+	}
 }
 
 func (c *K8sCtlCommands) DeleteNodeHandler(ctx *gin.Context) {
@@ -193,6 +552,11 @@ func (c *K8sCtlCommands) DeleteNodeHandler(ctx *gin.Context) {
 		return
 	}
 
+	// Deleting a node is refused in maintenance mode.  Showing what it would do isn't.
+	if !body.DryRun && refuseInMaintenance(ctx) {
+		return
+	}
+
 	// Extract variables
 	clusterName := ctx.Param("cluster")
 	verbose := body.Verbose
@@ -209,15 +573,18 @@ func (c *K8sCtlCommands) DeleteNodeHandler(ctx *gin.Context) {
 		return
 	}
 
-	// Create the cloudflare manager
-	dnsManager := cloudflare.NewCloudFlareManager(cfZoneID, cfAPIToken)
-	cm, err := aws.NewAWSClusterManager(ctx, clusterName, "", "", dnsManager, verbose)
+	cm, err := newClusterManager(ctx, clusterName, body.Region, verbose)
 	if err != nil {
 		logrus.Errorf("Failed creating cluster manager: %s", err)
 		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
+	if body.DryRun {
+		planNodeDeleteHandler(ctx, cm, clusterName, nodeName, false)
+		return
+	}
+
 	// Delete Node
 	err = cm.DeleteNode(nodeName)
 	if err != nil {
@@ -229,11 +596,67 @@ func (c *K8sCtlCommands) DeleteNodeHandler(ctx *gin.Context) {
 }
 
 func (c *K8sCtlCommands) GlassNodeHandler(ctx *gin.Context) {
-	nodeName := ctx.Param("node")
+	nodeName := ctx.Param("name")
 	clusterName := ctx.Param("cluster")
 
 	logrus.Infof("glassing node %s in cluster %s\n", nodeName, clusterName)
 
+	var body NodeGlassBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	// Glassing a node is refused in maintenance mode.  Showing what it would do isn't.
+	if !body.DryRun && refuseInMaintenance(ctx) {
+		return
+	}
+
+	if !body.DryRun {
+		err = errors.New("glass isn't implemented yet: delete the node and create it again")
+		_ = ctx.AbortWithError(http.StatusNotImplemented, err)
+		return
+	}
+
+	cm, err := newClusterManager(ctx, clusterName, body.Region, body.Verbose)
+	if err != nil {
+		logrus.Errorf("Failed creating cluster manager: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	planNodeDeleteHandler(ctx, cm, clusterName, nodeName, true)
+}
+
+// planNodeDeleteHandler responds with what deleting, or glassing, a node would do.
+func planNodeDeleteHandler(ctx *gin.Context, cm *aws.AWSClusterManager, clusterName string, nodeName string, recreate bool) {
+	nodeInfo, err := cm.GetNode(nodeName)
+	if err != nil {
+		logrus.Errorf("failed getting node %s: %s", nodeName, err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	// The cluster manager finds no running instance without an error
+	if nodeInfo.ID == "" {
+		err = errors.New(fmt.Sprintf("no running node %s in cluster %s", nodeName, clusterName))
+		_ = ctx.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+
+	plan, err := planNodeDelete(ctx, cm, clusterName, nodeInfo)
+	if err != nil {
+		logrus.Errorf("failed planning delete of node %s: %s", nodeName, err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	plan.Recreate = recreate
+
+	ctx.JSON(http.StatusOK, plan)
 }
 
 func (c *K8sCtlCommands) DescribeNodeHandler(ctx *gin.Context) {
@@ -249,10 +672,7 @@ func (c *K8sCtlCommands) ReconcileClusterHandler(ctx *gin.Context) {
 
 	logrus.Infof("reconciling cluster %s\n", clusterName)
 
-	var body struct {
-		Verbose bool `json:"verbose"`
-		FixTags bool `json:"fix_tags"`
-	}
+	var body ReconcileClusterBody
 
 	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
 	if err != nil {
@@ -262,41 +682,60 @@ func (c *K8sCtlCommands) ReconcileClusterHandler(ctx *gin.Context) {
 	}
 
 	verbose := body.Verbose
-	fixTags := body.FixTags
+	fixTags := body.FixTags && !body.FixTagsDryRun
 
-	// Create the cloudflare manager
-	dnsManager := cloudflare.NewCloudFlareManager(cfZoneID, cfAPIToken)
-	cm, err := aws.NewAWSClusterManager(ctx, clusterName, "", "", dnsManager, verbose)
+	// Fixing tags changes the cluster, so it's refused in maintenance mode.  Finding discrepancies isn't.
+	if fixTags && refuseInMaintenance(ctx) {
+		return
+	}
+
+	cm, err := newClusterManager(ctx, clusterName, body.Region, verbose)
 	if err != nil {
 		logrus.Errorf("Failed creating cluster manager: %s", err)
 		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
+	// With stream, each discrepancy's written as it's found, rather than in one result at the end
+	stream := newReconcileStream(ctx, body.Stream)
+
+	// Reuse a recent describe, if caching is on.  Not when fixing tags, or planning to, since that needs the current state.
+	useCache := !fixTags && !body.FixTagsDryRun
+
 	// Get cluster info
-	clusterInfo, err := cm.DescribeCluster(clusterName)
+	stream.Progress("describing cluster %s", clusterName)
+	clusterInfo, err := cachedDescribeCluster(ctx, cm, clusterName, useCache)
 	if err != nil {
 		logrus.Errorf("Failed getting cluster info: %s", err)
-		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		stream.Abort(ctx, err)
 		return
 	}
 
 	// Get K8s nodes
-	k8sNodes, err := kubernetes.ListNodes(ctx, verbose)
+	k8sNodes, err := cachedK8sNodes(ctx, cm, clusterName, verbose, useCache)
 	if err != nil {
 		logrus.Errorf("Failed listing Kubernetes nodes: %s", err)
-		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		stream.Abort(ctx, err)
 		return
 	}
 
 	// Get nodes potentially missing Cluster tag
-	untaggedNodes, err := cm.GetNodesInSecurityGroup()
+	untaggedNodes, err := cachedNodesInSecurityGroup(cm, clusterName, useCache)
 	if err != nil {
 		logrus.Errorf("Failed checking for untagged nodes: %s", err)
-		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		stream.Abort(ctx, err)
+		return
+	}
+
+	clusterInfo, k8sNodes, untaggedNodes, err = applyNodeSelector(ctx, cm, body.NodeSelector, clusterInfo, k8sNodes, untaggedNodes)
+	if err != nil {
+		logrus.Errorf("Failed selecting nodes: %s", err)
+		stream.Abort(ctx, err)
 		return
 	}
 
+	stream.Progress("comparing %d EC2 instance(s), %d Kubernetes node(s), and %d load balancer(s)", len(clusterInfo.Nodes), len(k8sNodes), len(clusterInfo.LoadBalancers))
+
 	// Build maps for comparison
 	// Normalize EC2 names by stripping domain suffix for comparison
 	ec2Map := make(map[string]bool)
@@ -321,16 +760,6 @@ func (c *K8sCtlCommands) ReconcileClusterHandler(ctx *gin.Context) {
 	}
 
 	// Collect all issues
-	type ReconcileResult struct {
-		UntaggedNodes    []string `json:"untagged_nodes,omitempty"`
-		EC2NotInK8s      []string `json:"ec2_not_in_k8s,omitempty"`
-		K8sNotInEC2      []string `json:"k8s_not_in_ec2,omitempty"`
-		EC2NotInLB       []string `json:"ec2_not_in_lb,omitempty"`
-		FixedTags        bool     `json:"fixed_tags"`
-		Message          string   `json:"message"`
-		TotalIssuesFound int      `json:"total_issues_found"`
-	}
-
 	result := ReconcileResult{}
 
 	// Check for missing Cluster tags
@@ -338,27 +767,94 @@ func (c *K8sCtlCommands) ReconcileClusterHandler(ctx *gin.Context) {
 		for _, node := range untaggedNodes {
 			result.UntaggedNodes = append(result.UntaggedNodes, fmt.Sprintf("%s (%s)", node.Name, node.ID))
 		}
+		stream.Discrepancies("untagged_nodes", result.UntaggedNodes)
 
 		if fixTags {
-			instanceIDs := make([]string, len(untaggedNodes))
-			for i, node := range untaggedNodes {
-				instanceIDs[i] = node.ID
-			}
-			err = cm.FixMissingClusterTags(instanceIDs)
+			result.TagFixes, err = fixClusterTags(ctx, cm, untaggedNodes)
 			if err != nil {
 				logrus.Errorf("Failed fixing tags: %s", err)
-				_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+				stream.Abort(ctx, err)
+				return
+			}
+		}
+
+		if body.FixTagsDryRun {
+			result.TagPlan, err = planClusterTags(ctx, cm, untaggedNodes)
+			if err != nil {
+				logrus.Errorf("Failed planning tag fixes: %s", err)
+				stream.Abort(ctx, err)
 				return
 			}
-			result.FixedTags = true
 		}
 	}
 
+	// Check for the tags the server config requires, besides Cluster.  fix_tags sets those with defaults.
+	if required := requiredTags(clusterName); len(required) > 0 {
+		ec2Nodes := append(slices.Clone(clusterInfo.Nodes), untaggedNodes...)
+		result.MissingTags = missingRequiredTags(ec2Nodes, nodeInstances(ctx, cm, ec2Nodes), required)
+
+		for _, instance := range result.MissingTags {
+			stream.Discrepancy("missing_tags", instance.Name, instance)
+		}
+
+		if fixTags {
+			result.TagFixes = append(result.TagFixes, fixRequiredTags(ctx, cm, result.MissingTags, required)...)
+		}
+
+		if body.FixTagsDryRun {
+			result.TagPlan = append(result.TagPlan, planRequiredTags(result.MissingTags, required)...)
+		}
+	}
+
+	if fixTags {
+		result.TagFixesFailed = failedTagFixes(result.TagFixes)
+		result.FixedTags = result.TagFixesFailed < len(result.TagFixes)
+		recordTagFixes(ctx, clusterName, result.TagFixes)
+
+		for _, fix := range result.TagFixes {
+			if fix.Error != "" {
+				logrus.Errorf("reconcile of cluster %s couldn't fix tags on %s", clusterName, fix.Summary())
+				stream.Progress("couldn't fix tags on %s", fix.Summary())
+				continue
+			}
+			logrus.Infof("reconcile of cluster %s fixed tags on %s", clusterName, fix.Summary())
+			stream.Progress("fixed tags on %s", fix.Summary())
+		}
+	}
+
+	for _, fix := range result.TagPlan {
+		stream.Progress("fix_tags would set tags on %s", fix.Summary())
+	}
+
 	// Check for EC2 not in K8s
+	notInK8s := make([]manager.NodeInfo, 0)
 	for _, node := range clusterInfo.Nodes {
 		shortName := stripDomainSuffix(node.Name)
 		if !k8sMap[shortName] {
-			result.EC2NotInK8s = append(result.EC2NotInK8s, node.Name)
+			notInK8s = append(notInK8s, node)
+		}
+	}
+
+	if len(notInK8s) > 0 {
+		grace := joinGrace(body)
+		result.EC2NotInK8s, result.JoiningNodes = orphanNodes(ctx, cm, notInK8s, grace)
+		result.OrphanCost = orphanMonthlyCost(result.EC2NotInK8s)
+
+		if body.JoinGrace > 0 {
+			result.EC2NotInK8s, result.StuckJoining = stuckJoining(result.EC2NotInK8s, grace, time.Now())
+		}
+
+		for _, orphan := range result.EC2NotInK8s {
+			stream.Discrepancy("ec2_not_in_k8s", orphan.Name, orphan)
+		}
+
+		for _, stuck := range result.StuckJoining {
+			logrus.Warnf("reconcile of cluster %s found instance %s (%s) launched %s ago, but not in Kubernetes", clusterName, stuck.Name, stuck.ID, stuck.Age)
+			stream.Discrepancy("stuck_joining", stuck.Name, stuck)
+		}
+
+		if len(result.JoiningNodes) > 0 {
+			stream.Progress("not reporting instance(s) younger than the join grace, likely still joining: %s", strings.Join(result.JoiningNodes, ", "))
 		}
 	}
 
@@ -368,6 +864,7 @@ func (c *K8sCtlCommands) ReconcileClusterHandler(ctx *gin.Context) {
 			result.K8sNotInEC2 = append(result.K8sNotInEC2, node)
 		}
 	}
+	stream.Discrepancies("k8s_not_in_ec2", result.K8sNotInEC2)
 
 	// Check for EC2 not in any LB
 	for _, node := range clusterInfo.Nodes {
@@ -376,9 +873,44 @@ func (c *K8sCtlCommands) ReconcileClusterHandler(ctx *gin.Context) {
 			result.EC2NotInLB = append(result.EC2NotInLB, node.Name)
 		}
 	}
+	stream.Discrepancies("ec2_not_in_lb", result.EC2NotInLB)
+
+	// Check for names shared by several nodes, which the maps above collapse
+	result.DuplicateEC2Names = duplicateEC2Names(append(slices.Clone(clusterInfo.Nodes), untaggedNodes...))
+	result.DuplicateK8sNames = duplicateK8sNames(k8sNodes)
+
+	for _, duplicate := range result.DuplicateEC2Names {
+		logrus.Warnf("reconcile of cluster %s found EC2 Name %s on instances %s", clusterName, duplicate.Name, strings.Join(duplicate.IDs, ", "))
+		stream.Discrepancy("duplicate_ec2_names", duplicate.Name, duplicate)
+	}
+
+	for _, duplicate := range result.DuplicateK8sNames {
+		stream.Discrepancy("duplicate_k8s_names", duplicate.Name, duplicate)
+	}
+
+	// Check the control plane is spread across enough availability zones to keep quorum if one is lost
+	minZones := body.MinControlPlaneZones
+	if minZones <= 0 {
+		minZones = defaultMinControlPlaneZones
+	}
+
+	// The instances give the nodes' zones, and their role tags which nodes are the control plane.  Without them,
+	// every node's zone would be unknown, which says nothing about the spread
+	instances := nodeInstances(ctx, cm, clusterInfo.Nodes)
+	if len(instances) > 0 {
+		result.ControlPlaneSpread = controlPlaneSpread(clusterInfo.Nodes, instances, minZones)
+	}
+
+	unbalancedZones := 0
+	if result.ControlPlaneSpread != nil && !result.ControlPlaneSpread.Balanced {
+		unbalancedZones = 1
+		logrus.Warnf("reconcile of cluster %s found the control plane in too few availability zones: %s", clusterName, result.ControlPlaneSpread.Summary())
+		stream.Discrepancy("control_plane_spread", "", result.ControlPlaneSpread)
+	}
 
 	// Calculate total issues
-	result.TotalIssuesFound = len(result.UntaggedNodes) + len(result.EC2NotInK8s) + len(result.K8sNotInEC2) + len(result.EC2NotInLB)
+	result.TotalIssuesFound = len(result.UntaggedNodes) + len(result.EC2NotInK8s) + len(result.StuckJoining) + len(result.K8sNotInEC2) + len(result.EC2NotInLB) +
+		len(result.DuplicateEC2Names) + len(result.DuplicateK8sNames) + len(result.MissingTags) + unbalancedZones
 
 	if result.TotalIssuesFound == 0 {
 		result.Message = "No discrepancies found - cluster state is consistent"
@@ -386,6 +918,32 @@ func (c *K8sCtlCommands) ReconcileClusterHandler(ctx *gin.Context) {
 		result.Message = fmt.Sprintf("Found %d issue(s) in cluster state", result.TotalIssuesFound)
 	}
 
+	if result.OrphanCost != nil {
+		result.Message += fmt.Sprintf("; instances not in Kubernetes cost an estimated $%.2f/month", *result.OrphanCost)
+	}
+
+	if result.TagFixesFailed > 0 {
+		result.Message += fmt.Sprintf("; failed fixing tags on %d of %d instance(s)", result.TagFixesFailed, len(result.TagFixes))
+	}
+
+	if len(result.TagPlan) > 0 {
+		result.Message += fmt.Sprintf("; fix_tags would set tags on %d instance(s)", len(result.TagPlan))
+	}
+
+	if body.IncludeClusterInfo {
+		result.ClusterInfo = &clusterInfo
+	}
+
+	if stream != nil {
+		stream.Summary(result.Summary())
+		return
+	}
+
+	if body.Summary {
+		ctx.JSON(http.StatusOK, result.Summary())
+		return
+	}
+
 	ctx.JSON(http.StatusOK, result)
 }
 
@@ -394,10 +952,7 @@ func (c *K8sCtlCommands) MonitorClusterHandler(ctx *gin.Context) {
 
 	logrus.Infof("monitoring cluster %s\n", clusterName)
 
-	var body struct {
-		Verbose  bool `json:"verbose"`
-		Interval int  `json:"interval"`
-	}
+	var body MonitorClusterBody
 
 	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
 	if err != nil {
@@ -412,60 +967,130 @@ func (c *K8sCtlCommands) MonitorClusterHandler(ctx *gin.Context) {
 		interval = 60 // Default to 60 seconds
 	}
 
-	// Create the cloudflare manager
-	dnsManager := cloudflare.NewCloudFlareManager(cfZoneID, cfAPIToken)
-	cm, err := aws.NewAWSClusterManager(ctx, clusterName, "", "", dnsManager, verbose)
+	alerter, err := newMonitorAlerter(clusterName, body.WebhookURL, time.Duration(body.WebhookReminder)*time.Second)
+	if err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	marks := markersFor(body.NoColor)
+
+	cm, err := newClusterManager(ctx, clusterName, body.Region, verbose)
 	if err != nil {
 		logrus.Errorf("Failed creating cluster manager: %s", err)
 		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
+	checkTimeout := time.Duration(body.CheckTimeout) * time.Second
+	if checkTimeout <= 0 {
+		checkTimeout = defaultMonitorCheckTimeout
+	}
+
+	check := func(checkCtx *gin.Context) (issues []MonitorIssue, err error) {
+		issues, err = monitorOnce(checkCtx, marks, checkClusterManager(checkCtx.Request.Context(), cm), clusterName, verbose, body.Cache, body.NodeSelector)
+		return issues, err
+	}
+
 	// Set up response writer for streaming
 	ctx.Writer.Header().Set("Content-Type", "text/plain")
 	ctx.Writer.Header().Set("Transfer-Encoding", "chunked")
+
+	if body.Once {
+		// The result is only known after the output is written, so it's sent as a trailer
+		ctx.Writer.Header().Set("Trailer", MonitorResultTrailer)
+		ctx.Writer.WriteHeader(http.StatusOK)
+
+		issues, checkErr := timedMonitorCheck(ctx, marks, checkTimeout, check)
+		result := strconv.Itoa(len(issues))
+		if checkErr != nil {
+			result = MonitorResultError
+		} else {
+			alerter.check(ctx, marks, issues)
+		}
+
+		var summary MonitorSummary
+		summary.Record(issues, checkErr != nil)
+		writeOutput(ctx, summary.String()+"\n")
+
+		ctx.Writer.Header().Set(MonitorResultTrailer, result)
+		return
+	}
+
 	ctx.Writer.WriteHeader(http.StatusOK)
 
-	ticker := time.NewTicker(time.Duration(interval) * time.Second)
-	defer ticker.Stop()
+	baseInterval := time.Duration(interval) * time.Second
+	failures := 0
 
-	// Run initial check immediately
-	monitorOnce(ctx, cm, clusterName, verbose)
+	// The session ends when the client disconnects, so its summary can only be logged here.  The client tallies its own.
+	var summary MonitorSummary
+	defer func() {
+		logrus.Infof("monitor of cluster %s ended. %s", clusterName, summary.String())
+	}()
 
-	// Then run on interval
+	// Run initial check immediately, then on interval.  While checks keep failing (e.g. AWS throttling), wait longer
+	// between them, so the monitor doesn't make an AWS incident worse.
 	for {
+		wait := baseInterval
+
+		issues, checkErr := timedMonitorCheck(ctx, marks, checkTimeout, check)
+		summary.Record(issues, checkErr != nil)
+		if checkErr != nil {
+			failures++
+			wait = monitorBackoff(baseInterval, failures)
+			writeOutput(ctx, fmt.Sprintf("  %s %d consecutive failed check(s), backing off: next check in %s\n\n", marks.Wait, failures, wait.Round(time.Second)))
+		} else if failures > 0 {
+			writeOutput(ctx, fmt.Sprintf("  %s Recovered after %d failed check(s), back to checking every %s\n\n", marks.OK, failures, baseInterval))
+			failures = 0
+		}
+
+		if checkErr == nil {
+			alerter.check(ctx, marks, issues)
+		}
+
 		select {
-		case <-ticker.C:
-			monitorOnce(ctx, cm, clusterName, verbose)
+		case <-time.After(wait):
 		case <-ctx.Request.Context().Done():
 			return
 		}
 	}
 }
 
-func monitorOnce(ctx *gin.Context, cm *aws.AWSClusterManager, clusterName string, verbose bool) {
+// monitorOnce checks the cluster's health once, on the nodes matching the selector.  With useCache, AWS data from a
+// recent check may be reused.  It's run by timedMonitorCheck, which bounds how long it may take.
+// It returns the issues found, or an error if the check itself couldn't be made.
+func monitorOnce(ctx *gin.Context, marks Markers, cm *aws.AWSClusterManager, clusterName string, verbose bool, useCache bool, selector NodeSelector) (issues []MonitorIssue, err error) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	writeOutput(ctx, fmt.Sprintf("[%s] Checking cluster health...\n", timestamp))
+	writeOutput(ctx, fmt.Sprintf("[%s] %s\n", timestamp, monitorCheckStart))
+
+	// The request's context, unlike ctx itself, is cancelled when the check times out or the client goes away
+	checkCtx := ctx.Request.Context()
 
 	// Get cluster info
-	clusterInfo, err := cm.DescribeCluster(clusterName)
+	clusterInfo, err := cachedDescribeCluster(checkCtx, cm, clusterName, useCache)
 	if err != nil {
-		writeOutput(ctx, fmt.Sprintf("❌ ERROR: Failed getting cluster info: %s\n\n", err))
-		return
+		writeOutput(ctx, fmt.Sprintf("%s Failed getting cluster info: %s\n", marks.Error, err))
+		return issues, err
 	}
 
 	// Get K8s nodes
-	k8sNodes, err := kubernetes.ListNodes(ctx, verbose)
+	k8sNodes, err := cachedK8sNodes(checkCtx, cm, clusterName, verbose, useCache)
 	if err != nil {
-		writeOutput(ctx, fmt.Sprintf("❌ ERROR: Failed listing Kubernetes nodes: %s\n\n", err))
-		return
+		writeOutput(ctx, fmt.Sprintf("%s Failed listing Kubernetes nodes: %s\n", marks.Error, err))
+		return issues, err
 	}
 
 	// Get nodes potentially missing Cluster tag
-	untaggedNodes, err := cm.GetNodesInSecurityGroup()
+	untaggedNodes, err := cachedNodesInSecurityGroup(cm, clusterName, useCache)
 	if err != nil {
-		writeOutput(ctx, fmt.Sprintf("❌ ERROR: Failed checking for untagged nodes: %s\n\n", err))
-		return
+		writeOutput(ctx, fmt.Sprintf("%s Failed checking for untagged nodes: %s\n", marks.Error, err))
+		return issues, err
+	}
+
+	clusterInfo, k8sNodes, untaggedNodes, err = applyNodeSelector(checkCtx, cm, selector, clusterInfo, k8sNodes, untaggedNodes)
+	if err != nil {
+		writeOutput(ctx, fmt.Sprintf("%s Failed selecting nodes: %s\n", marks.Error, err))
+		return issues, err
 	}
 
 	// Build maps for comparison
@@ -482,37 +1107,29 @@ func monitorOnce(ctx *gin.Context, cm *aws.AWSClusterManager, clusterName string
 	}
 
 	lbTargetMap := make(map[string]bool)
-	unhealthyTargets := make([]string, 0)
 	for _, lb := range clusterInfo.LoadBalancers {
 		for _, target := range lb.Targets {
 			shortName := stripDomainSuffix(target.Name)
 			lbTargetMap[shortName] = true
-			if target.State != "healthy" {
-				unhealthyTargets = append(unhealthyTargets, fmt.Sprintf("%s/%s:%d (%s)", lb.Name, target.Name, target.Port, target.State))
-			}
 		}
 	}
 
-	// Count issues
-	issueCount := 0
+	unhealthyTargets := findUnhealthyTargets(clusterInfo.LoadBalancers)
+	addTargetHealthReasons(checkCtx, cm, clusterInfo.LoadBalancers, unhealthyTargets)
 
 	// Check for unhealthy targets
-	if len(unhealthyTargets) > 0 {
-		issueCount++
-		writeOutput(ctx, fmt.Sprintf("  ⚠ Unhealthy Load Balancer Targets: %d\n", len(unhealthyTargets)))
-		for _, target := range unhealthyTargets {
-			writeOutput(ctx, fmt.Sprintf("    - %s\n", target))
-		}
+	unhealthy := make([]string, 0)
+	for _, target := range unhealthyTargets {
+		unhealthy = append(unhealthy, target.Summary())
 	}
+	issues = reportMonitorIssue(ctx, marks, issues, "Unhealthy Load Balancer Targets", unhealthy)
 
 	// Check for missing Cluster tags
-	if len(untaggedNodes) > 0 {
-		issueCount++
-		writeOutput(ctx, fmt.Sprintf("  ⚠ Instances Missing Cluster Tag: %d\n", len(untaggedNodes)))
-		for _, node := range untaggedNodes {
-			writeOutput(ctx, fmt.Sprintf("    - %s (%s)\n", node.Name, node.ID))
-		}
+	untagged := make([]string, 0)
+	for _, node := range untaggedNodes {
+		untagged = append(untagged, fmt.Sprintf("%s (%s)", node.Name, node.ID))
 	}
+	issues = reportMonitorIssue(ctx, marks, issues, "Instances Missing Cluster Tag", untagged)
 
 	// Check for EC2 not in K8s
 	notInK8s := make([]string, 0)
@@ -522,13 +1139,7 @@ func monitorOnce(ctx *gin.Context, cm *aws.AWSClusterManager, clusterName string
 			notInK8s = append(notInK8s, node.Name)
 		}
 	}
-	if len(notInK8s) > 0 {
-		issueCount++
-		writeOutput(ctx, fmt.Sprintf("  ⚠ EC2 Instances Not in Kubernetes: %d\n", len(notInK8s)))
-		for _, name := range notInK8s {
-			writeOutput(ctx, fmt.Sprintf("    - %s\n", name))
-		}
-	}
+	issues = reportMonitorIssue(ctx, marks, issues, "EC2 Instances Not in Kubernetes", notInK8s)
 
 	// Check for K8s not in EC2
 	notInEC2 := make([]string, 0)
@@ -537,13 +1148,7 @@ func monitorOnce(ctx *gin.Context, cm *aws.AWSClusterManager, clusterName string
 			notInEC2 = append(notInEC2, node)
 		}
 	}
-	if len(notInEC2) > 0 {
-		issueCount++
-		writeOutput(ctx, fmt.Sprintf("  ⚠ Kubernetes Nodes Not in EC2: %d\n", len(notInEC2)))
-		for _, name := range notInEC2 {
-			writeOutput(ctx, fmt.Sprintf("    - %s\n", name))
-		}
-	}
+	issues = reportMonitorIssue(ctx, marks, issues, "Kubernetes Nodes Not in EC2", notInEC2)
 
 	// Check for EC2 not in any LB
 	notInLB := make([]string, 0)
@@ -553,22 +1158,18 @@ func monitorOnce(ctx *gin.Context, cm *aws.AWSClusterManager, clusterName string
 			notInLB = append(notInLB, node.Name)
 		}
 	}
-	if len(notInLB) > 0 {
-		issueCount++
-		writeOutput(ctx, fmt.Sprintf("  ⚠ EC2 Instances Not in Any Load Balancer: %d\n", len(notInLB)))
-		for _, name := range notInLB {
-			writeOutput(ctx, fmt.Sprintf("    - %s\n", name))
-		}
-	}
+	issues = reportMonitorIssue(ctx, marks, issues, "EC2 Instances Not in Any Load Balancer", notInLB)
 
 	// Summary
-	if issueCount == 0 {
-		writeOutput(ctx, fmt.Sprintf("  ✓ All systems healthy - EC2: %d, K8s: %d, LB Targets: %d\n", len(clusterInfo.Nodes), len(k8sNodes), len(lbTargetMap)))
+	if len(issues) == 0 {
+		writeOutput(ctx, fmt.Sprintf("  %s %s - EC2: %d, K8s: %d, LB Targets: %d\n", marks.OK, monitorHealthy, len(clusterInfo.Nodes), len(k8sNodes), len(lbTargetMap)))
 	} else {
-		writeOutput(ctx, fmt.Sprintf("  Found %d issue(s)\n", issueCount))
+		writeOutput(ctx, fmt.Sprintf("  %s%d issue(s)\n", monitorFound, len(issues)))
 	}
 
 	writeOutput(ctx, "\n")
+
+	return issues, err
 }
 
 func writeOutput(ctx *gin.Context, message string) {
@@ -577,8 +1178,15 @@ func writeOutput(ctx *gin.Context, message string) {
 }
 
 // stripDomainSuffix removes domain suffix from node names for comparison.
-// E.g., "cluster1-cp-1.example.com" -> "cluster1-cp-1".
+// E.g., "cluster1-cp-1.example.com" -> "cluster1-cp-1".  An IPv4 or IPv6 address, such as the ID of an ip target, is
+// returned whole, since it has no domain to strip.
 func stripDomainSuffix(name string) (shortName string) {
+	_, err := netip.ParseAddr(name)
+	if err == nil {
+		shortName = name
+		return shortName
+	}
+
 	parts := strings.Split(name, ".")
 	shortName = parts[0]
 	return shortName
@@ -587,9 +1195,11 @@ func stripDomainSuffix(name string) (shortName string) {
 // AuthCheckHandler handles authentication check requests.
 func (c *K8sCtlCommands) AuthCheckHandler(ctx *gin.Context) {
 	// If we reached here, authentication was successful (middleware passed)
-	ctx.JSON(http.StatusOK, gin.H{
-		"status":  "authenticated",
-		"message": "Authentication successful",
+	ctx.JSON(http.StatusOK, AuthCheckResult{
+		Status:    "authenticated",
+		Message:   "Authentication successful",
+		UserEmail: ctx.GetString("user_email"),
+		Groups:    ctx.GetStringSlice("user_groups"),
 	})
 }
 
@@ -599,17 +1209,7 @@ func (c *K8sCtlCommands) UpgradeClusterHandler(ctx *gin.Context) {
 
 	logrus.Infof("upgrading cluster %s\n", clusterName)
 
-	var body struct {
-		Version           string `json:"version"`
-		ControlPlaneFirst bool   `json:"control_plane_first"`
-		MaxConcurrent     int    `json:"max_concurrent"`
-		Preserve          bool   `json:"preserve"`
-		Stage             bool   `json:"stage"`
-		WaitBetween       int    `json:"wait_between"`
-		DryRun            bool   `json:"dry_run"`
-		UpdateSecrets     bool   `json:"update_secrets"`
-		Verbose           bool   `json:"verbose"`
-	}
+	var body UpgradeClusterBody
 
 	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
 	if err != nil {
@@ -618,17 +1218,38 @@ func (c *K8sCtlCommands) UpgradeClusterHandler(ctx *gin.Context) {
 		return
 	}
 
+	// Upgrading is refused in maintenance mode.  A dry run isn't.
+	if !body.DryRun && refuseInMaintenance(ctx) {
+		return
+	}
+
 	if body.Version == "" {
 		err = errors.New("version is required")
 		_ = ctx.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
 
+	onFailure := body.OnFailure
+	if onFailure == "" {
+		onFailure = OnFailureContinue
+	}
+
+	if onFailure != OnFailureContinue && onFailure != OnFailureFailFast && onFailure != OnFailureRollback {
+		err = errors.New(fmt.Sprintf("invalid on_failure %q: must be %s, %s, or %s", onFailure, OnFailureContinue, OnFailureFailFast, OnFailureRollback))
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	// Rolling upgrades are health gated node by node, so they're always one node at a time
+	if body.MaxConcurrent > 1 {
+		err = errors.New(fmt.Sprintf("max_concurrent %d is not supported: rolling upgrades upgrade one node at a time", body.MaxConcurrent))
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
 	verbose := body.Verbose
 
-	// Create the cloudflare manager
-	dnsManager := cloudflare.NewCloudFlareManager(cfZoneID, cfAPIToken)
-	cm, err := aws.NewAWSClusterManager(ctx, clusterName, "", "", dnsManager, verbose)
+	cm, err := newClusterManager(ctx, clusterName, body.Region, verbose)
 	if err != nil {
 		logrus.Errorf("Failed creating cluster manager: %s", err)
 		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
@@ -636,21 +1257,50 @@ func (c *K8sCtlCommands) UpgradeClusterHandler(ctx *gin.Context) {
 	}
 
 	// Set upgrade options
-	options := manager.UpgradeOptions{
-		ControlPlaneFirst: body.ControlPlaneFirst,
-		MaxConcurrent:     body.MaxConcurrent,
-		Preserve:          body.Preserve,
-		Stage:             body.Stage,
-		WaitBetween:       time.Duration(body.WaitBetween) * time.Second,
-		DryRun:            body.DryRun,
-		UpdateSecrets:     body.UpdateSecrets,
-	}
+	options := rollingUpgradeOptions{
+		UpgradeOptions: manager.UpgradeOptions{
+			ControlPlaneFirst: body.ControlPlaneFirst,
+			MaxConcurrent:     body.MaxConcurrent,
+			Preserve:          body.Preserve,
+			Stage:             body.Stage,
+			WaitBetween:       time.Duration(body.WaitBetween) * time.Second,
+			DryRun:            body.DryRun,
+			UpdateSecrets:     body.UpdateSecrets,
+		},
+		OnFailure:     onFailure,
+		HealthTimeout: defaultHealthTimeout,
+		Nodes:         body.Nodes,
+	}
+
+	if body.HealthTimeout != nil {
+		options.HealthTimeout = time.Duration(*body.HealthTimeout) * time.Second
+	}
+
+	var keepAlive *keepAlive
+	if body.KeepAlive {
+		keepAlive = startKeepAlive(ctx, keepAliveInterval)
+	}
+
+	// Perform upgrade.  Individual node failures are reported per node in the result, rather than as an error.  The
+	// upgrade isn't canceled if the client disconnects, so it's never left half done.
+	result, err := upgradeCluster(context.WithoutCancel(ctx), cm, clusterName, body.Version, options, verbose)
+	if err == nil && body.Wait && !body.DryRun && !body.Stage && result.Failed == 0 && result.Error == "" {
+		waitTimeout := defaultUpgradeWaitTimeout
+		if body.WaitTimeout > 0 {
+			waitTimeout = time.Duration(body.WaitTimeout) * time.Second
+		}
 
-	// Perform upgrade
-	result, err := cm.UpgradeCluster(body.Version, options)
+		health := waitForClusterHealthy(context.WithoutCancel(ctx), cm, clusterName, waitTimeout)
+		result.Health = &health
+		result.TotalDuration += health.Waited
+	}
+	keepAlive.Stop()
 	if err != nil {
+		// A keep-alive may have sent the status already, so the error's in the result too
 		logrus.Errorf("Failed upgrading cluster: %s", err)
-		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		result.Error = err.Error()
+		_ = ctx.Error(err)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, result)
 		return
 	}
 
@@ -664,14 +1314,7 @@ func (c *K8sCtlCommands) UpgradeNodeHandler(ctx *gin.Context) {
 
 	logrus.Infof("upgrading node %s in cluster %s\n", nodeName, clusterName)
 
-	var body struct {
-		Version       string `json:"version"`
-		Preserve      bool   `json:"preserve"`
-		Stage         bool   `json:"stage"`
-		DryRun        bool   `json:"dry_run"`
-		UpdateSecrets bool   `json:"update_secrets"`
-		Verbose       bool   `json:"verbose"`
-	}
+	var body UpgradeNodeBody
 
 	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
 	if err != nil {
@@ -680,6 +1323,11 @@ func (c *K8sCtlCommands) UpgradeNodeHandler(ctx *gin.Context) {
 		return
 	}
 
+	// Upgrading is refused in maintenance mode.  A dry run isn't.
+	if !body.DryRun && refuseInMaintenance(ctx) {
+		return
+	}
+
 	if body.Version == "" {
 		err = errors.New("version is required")
 		_ = ctx.AbortWithError(http.StatusBadRequest, err)
@@ -688,9 +1336,7 @@ func (c *K8sCtlCommands) UpgradeNodeHandler(ctx *gin.Context) {
 
 	verbose := body.Verbose
 
-	// Create the cloudflare manager
-	dnsManager := cloudflare.NewCloudFlareManager(cfZoneID, cfAPIToken)
-	cm, err := aws.NewAWSClusterManager(ctx, clusterName, "", "", dnsManager, verbose)
+	cm, err := newClusterManager(ctx, clusterName, body.Region, verbose)
 	if err != nil {
 		logrus.Errorf("Failed creating cluster manager: %s", err)
 		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
@@ -705,11 +1351,19 @@ func (c *K8sCtlCommands) UpgradeNodeHandler(ctx *gin.Context) {
 		UpdateSecrets: body.UpdateSecrets,
 	}
 
-	// Perform upgrade
+	var keepAlive *keepAlive
+	if body.KeepAlive {
+		keepAlive = startKeepAlive(ctx, keepAliveInterval)
+	}
+
+	// Perform upgrade.  Like a cluster upgrade, it carries on if the client disconnects.
 	result, err := cm.UpgradeNode(nodeName, body.Version, options)
+	keepAlive.Stop()
 	if err != nil {
+		// A keep-alive may have sent the status already, so the error's in the body too
 		logrus.Errorf("Failed upgrading node: %s", err)
-		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		_ = ctx.Error(err)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -722,11 +1376,7 @@ func (c *K8sCtlCommands) SecretsSyncHandler(ctx *gin.Context) {
 
 	logrus.Infof("syncing secrets for cluster %s\n", clusterName)
 
-	var body struct {
-		Role    string `json:"role"`
-		DryRun  bool   `json:"dry_run"`
-		Verbose bool   `json:"verbose"`
-	}
+	var body SecretsSyncBody
 
 	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
 	if err != nil {
@@ -735,82 +1385,52 @@ func (c *K8sCtlCommands) SecretsSyncHandler(ctx *gin.Context) {
 		return
 	}
 
-	verbose := body.Verbose
-
-	// Create the cloudflare manager
-	dnsManager := cloudflare.NewCloudFlareManager(cfZoneID, cfAPIToken)
-	cm, err := aws.NewAWSClusterManager(ctx, clusterName, "", "", dnsManager, verbose)
-	if err != nil {
-		logrus.Errorf("Failed creating cluster manager: %s", err)
-		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+	// Syncing is refused in maintenance mode.  A dry run, which only checks the secrets, isn't.
+	if !body.DryRun && refuseInMaintenance(ctx) {
 		return
 	}
 
-	// Get cluster info to determine current versions
-	clusterInfo, err := cm.DescribeCluster(clusterName)
-	if err != nil {
-		logrus.Errorf("Failed getting cluster info: %s", err)
-		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
-		return
+	onFailure := body.OnFailure
+	if onFailure == "" {
+		onFailure = OnFailureContinue
 	}
 
-	// Determine which roles to sync
-	rolesToSync := []string{"controlplane", "worker"}
-	if body.Role != "" {
-		rolesToSync = []string{body.Role}
+	if onFailure != OnFailureContinue && onFailure != OnFailureFailFast {
+		err = errors.New(fmt.Sprintf("invalid on_failure %q: must be %s or %s", onFailure, OnFailureContinue, OnFailureFailFast))
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
 	}
 
-	type SyncResult struct {
-		Role          string `json:"role"`
-		CurrentAMI    string `json:"current_ami"`
-		Version       string `json:"version"`
-		UpdatedAMI    string `json:"updated_ami,omitempty"`
-		UpdatedConfig bool   `json:"updated_config"`
-		DryRun        bool   `json:"dry_run"`
+	if secretManager == nil {
+		err = errors.New("Vault isn't configured on this server, so secrets can't be synced")
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
 	}
 
-	results := make([]SyncResult, 0)
-
-	for _, role := range rolesToSync {
-		// Find a node with this role to get the current version
-		var targetNode *manager.NodeInfo
-		for i := range clusterInfo.Nodes {
-			if strings.Contains(strings.ToLower(clusterInfo.Nodes[i].Name), "cp") && role == "controlplane" {
-				targetNode = &clusterInfo.Nodes[i]
-				break
-			} else if !strings.Contains(strings.ToLower(clusterInfo.Nodes[i].Name), "cp") && role == "worker" {
-				targetNode = &clusterInfo.Nodes[i]
-				break
-			}
-		}
-
-		if targetNode == nil {
-			logrus.Warnf("No nodes found with role %s, skipping", role)
-			continue
-		}
-
-		result := SyncResult{
-			Role:   role,
-			DryRun: body.DryRun,
-		}
+	verbose := body.Verbose
 
-		// TODO: Get actual version from node via Talos API
-		// For now, return placeholder response
-		result.Version = "unknown"
-		result.CurrentAMI = targetNode.ID
+	cm, err := newClusterManager(ctx, clusterName, body.Region, verbose)
+	if err != nil {
+		logrus.Errorf("Failed creating cluster manager: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
 
-		if !body.DryRun {
-			// TODO: Implement actual secret update
-			result.UpdatedConfig = false
-		}
+	roles := secretRoles(body.Role)
 
-		results = append(results, result)
+	nodes, err := secretRoleNodes(ctx, cm, clusterName, roles)
+	if err != nil {
+		logrus.Errorf("Failed getting cluster info: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"cluster": clusterName,
-		"results": results,
+	result := syncRoleSecrets(ctx, clusterName, roles, body.DryRun, onFailure, func(role string) (roleResult SyncResult) {
+		roleResult = checkRoleSecret(ctx, cm, clusterName, role, nodes[role])
+		return roleResult
 	})
+
+	ctx.JSON(http.StatusOK, result)
 }
 
 // getCustomPricing returns custom pricing overrides for AWS instance types.
@@ -2,10 +2,14 @@ package k8sctl
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/pkg/errors"
 	"os"
 )
 
+// K8sCtlCommand is an external command the server runs on request, as `k8sctl run <name>`.  Command is the
+// executable, run with Args, then any args the caller gives.  Only members of the Role group may run it; with no
+// Role, anyone allowed to use the server may.
 type K8sCtlCommand struct {
 	Name        string   `json:"name"`
 	Command     string   `json:"command"`
@@ -14,14 +18,20 @@ type K8sCtlCommand struct {
 	Role        string   `json:"role"`
 }
 
+// K8sCtlCommands serves the API.  It holds the registered commands, if any were loaded, keyed by name in CommandsMap.
 type K8sCtlCommands struct {
 	Commands    []*K8sCtlCommand          `json:"commands"`
 	CommandsMap map[string]*K8sCtlCommand `json:"-"`
 }
 
+// K8sCtlCommandResult is what running a registered command did.
 type K8sCtlCommandResult struct {
 	CommandName string `json:"command_name"`
 	Command     string `json:"command"`
+
+	Args     []string `json:"args"`      // every arg the command was run with, registered and given
+	Output   string   `json:"output"`    // stdout and stderr, interleaved
+	ExitCode int      `json:"exit_code"` // nonzero if the command failed
 }
 
 func LoadK8sCtlCommandsFromFile(filepath string) (commands *K8sCtlCommands, err error) {
@@ -33,20 +43,43 @@ func LoadK8sCtlCommandsFromFile(filepath string) (commands *K8sCtlCommands, err
 
 	commands, err = LoadK8sCtlCommandsFromBytes(userBytes)
 	if err != nil {
-		err = errors.Wrapf(err, "failed loading Users from data in %s", filepath)
+		err = errors.Wrapf(err, "failed loading commands from data in %s", filepath)
 	}
 
 	return commands, err
 }
 
+// LoadK8sCtlCommandsFromBytes loads commands from JSON, and indexes them by name.  Every command needs a name and an
+// executable, and names must be unique.
 func LoadK8sCtlCommandsFromBytes(userBytes []byte) (commands *K8sCtlCommands, err error) {
 	commands = &K8sCtlCommands{}
 
 	err = json.Unmarshal(userBytes, commands)
 	if err != nil {
-		err = errors.Wrapf(err, "failed unmarshalling CliUsers")
+		err = errors.Wrapf(err, "failed unmarshalling commands")
 		return commands, err
 	}
 
+	commands.CommandsMap = make(map[string]*K8sCtlCommand, len(commands.Commands))
+
+	for i, command := range commands.Commands {
+		if command == nil || command.Name == "" {
+			err = errors.New(fmt.Sprintf("command %d has no name", i))
+			return commands, err
+		}
+
+		if command.Command == "" {
+			err = errors.New(fmt.Sprintf("command %s has no executable", command.Name))
+			return commands, err
+		}
+
+		if _, ok := commands.CommandsMap[command.Name]; ok {
+			err = errors.New(fmt.Sprintf("command %s is defined more than once", command.Name))
+			return commands, err
+		}
+
+		commands.CommandsMap[command.Name] = command
+	}
+
 	return commands, err
 }
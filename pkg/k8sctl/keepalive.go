@@ -0,0 +1,61 @@
+package k8sctl
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// keepAliveInterval is how often a long request writes to its response while it waits.  It's well inside the idle
+// timeouts of the load balancers in front of the server, e.g. an ALB's default of 60s.
+const keepAliveInterval = 20 * time.Second
+
+// keepAlive writes a newline to a JSON response every interval until it's stopped, so proxies don't close the
+// connection of a long request as idle.  The whitespace ahead of the JSON result doesn't change it.  Once the first
+// newline is written the status is 200, so an error after that can only be reported in the result itself.
+type keepAlive struct {
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startKeepAlive starts keeping a response alive.
+func startKeepAlive(ctx *gin.Context, interval time.Duration) (k *keepAlive) {
+	k = &keepAlive{done: make(chan struct{})}
+
+	ctx.Header("Content-Type", "application/json; charset=utf-8")
+
+	k.wg.Add(1)
+	go func() {
+		defer k.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-k.done:
+				return
+			case <-ticker.C:
+				ctx.Writer.WriteHeader(http.StatusOK)
+				// A client that's gone away is found out by the handler's own write, if at all
+				_, _ = ctx.Writer.Write([]byte("\n"))
+				ctx.Writer.Flush()
+			}
+		}
+	}()
+
+	return k
+}
+
+// Stop stops writing keep-alives, returning once the last one's written, so the handler can write the result.  A nil
+// keepAlive, for a request that didn't ask for one, is already stopped.
+func (k *keepAlive) Stop() {
+	if k == nil {
+		return
+	}
+
+	close(k.done)
+	k.wg.Wait()
+}
@@ -0,0 +1,65 @@
+package k8sctl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestKeepAlive checks a kept-alive response is still the handler's JSON once the leading whitespace is skipped, and
+// that an error after the first keep-alive arrives with a 200, in the result.
+func TestKeepAlive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/ok", func(ctx *gin.Context) {
+		keepAlive := startKeepAlive(ctx, 10*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		keepAlive.Stop()
+		ctx.JSON(http.StatusOK, ClusterUpgradeResult{Cluster: "cluster1"})
+	})
+	router.POST("/fail", func(ctx *gin.Context) {
+		keepAlive := startKeepAlive(ctx, 10*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		keepAlive.Stop()
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, ClusterUpgradeResult{Cluster: "cluster1", Error: "failed"})
+	})
+	router.POST("/fast", func(ctx *gin.Context) {
+		keepAlive := startKeepAlive(ctx, time.Hour)
+		keepAlive.Stop()
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, ClusterUpgradeResult{Cluster: "cluster1", Error: "failed"})
+	})
+
+	testCases := []struct {
+		path   string
+		status int
+		err    string
+	}{
+		{path: "/ok", status: http.StatusOK},
+		{path: "/fail", status: http.StatusOK, err: "failed"},
+		// Failing before the first keep-alive still sends the error status
+		{path: "/fast", status: http.StatusInternalServerError, err: "failed"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, tc.path, nil))
+
+			assert.Equal(t, tc.status, recorder.Code)
+
+			var result ClusterUpgradeResult
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+			assert.Equal(t, "cluster1", result.Cluster)
+			assert.Equal(t, tc.err, result.Error)
+		})
+	}
+
+	var stopped *keepAlive
+	stopped.Stop()
+}
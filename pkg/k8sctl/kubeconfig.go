@@ -0,0 +1,147 @@
+package k8sctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+type KubeconfigBody struct {
+	Verbose bool   `json:"verbose"`
+	Region  string `json:"region,omitempty"`
+}
+
+// KubeconfigResult is a cluster's admin kubeconfig, and the control plane node it was read from.
+type KubeconfigResult struct {
+	Cluster    string `json:"cluster"`
+	Node       string `json:"node"`
+	Kubeconfig string `json:"kubeconfig"`
+}
+
+// KubeconfigHandler returns a cluster's admin kubeconfig, read from one of its control plane nodes via the Talos API.
+// Each control plane node is tried in turn, until one answers.
+func (c *K8sCtlCommands) KubeconfigHandler(ctx *gin.Context) {
+	clusterName := ctx.Param("cluster")
+
+	var body KubeconfigBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	// Credentials are handed out, so record who to
+	logrus.Infof("kubeconfig for cluster %s requested by %s", clusterName, ctx.GetString("user_email"))
+
+	cm, err := newClusterManager(ctx, clusterName, body.Region, body.Verbose)
+	if err != nil {
+		logrus.Errorf("Failed creating cluster manager: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	nodes, err := cm.GetNodes(clusterName)
+	if err != nil {
+		logrus.Errorf("failed listing nodes in cluster %s: %s", clusterName, err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	instances := nodeInstances(ctx, cm, nodes)
+
+	controlPlane := make([]manager.NodeInfo, 0)
+	for _, node := range nodes {
+		if nodeRole(node, instances) == manager.NodeRoleCp {
+			controlPlane = append(controlPlane, node)
+		}
+	}
+
+	if len(controlPlane) == 0 {
+		err = errors.New(fmt.Sprintf("no control plane nodes found in cluster %s", clusterName))
+		_ = ctx.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+
+	sort.Slice(controlPlane, func(i, j int) bool { return controlPlane[i].Name < controlPlane[j].Name })
+
+	nodeIPs := nodePrivateIPs(instances)
+
+	err = errors.New(fmt.Sprintf("unable to determine IP addresses of the control plane nodes in cluster %s", clusterName))
+	for _, node := range controlPlane {
+		nodeIP, ok := nodeIPs[node.ID]
+		if !ok {
+			continue
+		}
+
+		kubeconfig, fetchErr := fetchKubeconfig(ctx, nodeIP)
+		if fetchErr != nil {
+			logrus.Warnf("failed fetching kubeconfig from %s: %s", node.Name, fetchErr)
+			err = fetchErr
+			continue
+		}
+
+		ctx.JSON(http.StatusOK, KubeconfigResult{
+			Cluster:    clusterName,
+			Node:       node.Name,
+			Kubeconfig: string(kubeconfig),
+		})
+		return
+	}
+
+	logrus.Errorf("failed fetching kubeconfig for cluster %s: %s", clusterName, err)
+	_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+}
+
+// MergeKubeconfig adds a kubeconfig's current context, with its cluster and user, to an existing kubeconfig under
+// contextName.  The existing kubeconfig is as loaded by clientcmd.LoadFromFile, or made by clientcmdapi.NewConfig.
+// The cluster and user are renamed to contextName as well, so they replace a previous merge of the same cluster,
+// rather than clashing with other clusters' entries.  The existing current context is kept, unless it has none.
+func MergeKubeconfig(existing *clientcmdapi.Config, kubeconfig []byte, contextName string) (err error) {
+	incoming, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		err = errors.Wrapf(err, "failed parsing kubeconfig")
+		return err
+	}
+
+	kubeContext, ok := incoming.Contexts[incoming.CurrentContext]
+	if !ok {
+		err = errors.New(fmt.Sprintf("kubeconfig has no current context %q", incoming.CurrentContext))
+		return err
+	}
+
+	cluster, ok := incoming.Clusters[kubeContext.Cluster]
+	if !ok {
+		err = errors.New(fmt.Sprintf("kubeconfig has no cluster %q", kubeContext.Cluster))
+		return err
+	}
+
+	authInfo, ok := incoming.AuthInfos[kubeContext.AuthInfo]
+	if !ok {
+		err = errors.New(fmt.Sprintf("kubeconfig has no user %q", kubeContext.AuthInfo))
+		return err
+	}
+
+	merged := kubeContext.DeepCopy()
+	merged.Cluster = contextName
+	merged.AuthInfo = contextName
+
+	existing.Clusters[contextName] = cluster.DeepCopy()
+	existing.AuthInfos[contextName] = authInfo.DeepCopy()
+	existing.Contexts[contextName] = merged
+
+	if existing.CurrentContext == "" {
+		existing.CurrentContext = contextName
+	}
+
+	return err
+}
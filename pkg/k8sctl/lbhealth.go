@@ -0,0 +1,209 @@
+package k8sctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type LBHealthBody struct {
+	Verbose bool   `json:"verbose"`
+	Region  string `json:"region,omitempty"`
+}
+
+// UnhealthyTarget is a load balancer target that is not in the healthy state.
+type UnhealthyTarget struct {
+	LoadBalancer string `json:"load_balancer"`
+	TargetGroup  string `json:"target_group,omitempty"` // the target group reporting the target unhealthy, when its health was fetched
+	Target       string `json:"target"`
+	ID           string `json:"id"`
+	Port         int32  `json:"port"`
+	State        string `json:"state"`
+	Reason       string `json:"reason,omitempty"`      // e.g. Target.FailedHealthChecks, Elb.RegistrationInProgress
+	Description  string `json:"description,omitempty"` // AWS' human readable explanation of the reason
+}
+
+// Summary formats the target on one line, e.g. "lb/node:443 (unhealthy: Target.FailedHealthChecks - Health checks failed)".
+func (t UnhealthyTarget) Summary() (summary string) {
+	if t.Reason == "" {
+		summary = fmt.Sprintf("%s/%s:%d (%s)", t.LoadBalancer, t.Target, t.Port, t.State)
+		return summary
+	}
+
+	summary = fmt.Sprintf("%s/%s:%d (%s: %s", t.LoadBalancer, t.Target, t.Port, t.State, t.Reason)
+	if t.Description != "" {
+		summary += " - " + t.Description
+	}
+	summary += ")"
+
+	return summary
+}
+
+// LBHealthResult lists the unhealthy targets across all of a cluster's load balancers.
+type LBHealthResult struct {
+	Cluster      string            `json:"cluster"`
+	TotalTargets int               `json:"total_targets"`
+	Unhealthy    []UnhealthyTarget `json:"unhealthy"`
+}
+
+// ConsolePrint prints the unhealthy targets as a table.
+func (r LBHealthResult) ConsolePrint() {
+	if len(r.Unhealthy) == 0 {
+		fmt.Printf("All %d load balancer targets in cluster %q are healthy\n", r.TotalTargets, r.Cluster)
+		return
+	}
+
+	fmt.Printf("%d of %d load balancer targets in cluster %q are unhealthy\n\n", len(r.Unhealthy), r.TotalTargets, r.Cluster)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "LOAD BALANCER\tTARGET GROUP\tTARGET\tPORT\tSTATE\tREASON\tDESCRIPTION\n")
+	for _, target := range r.Unhealthy {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", target.LoadBalancer, target.TargetGroup, target.Target, target.Port, target.State, target.Reason, target.Description)
+	}
+	_ = w.Flush()
+}
+
+// UnhealthyTargetsHandler lists the load balancer targets in a cluster that are not healthy.
+func (c *K8sCtlCommands) UnhealthyTargetsHandler(ctx *gin.Context) {
+	clusterName := ctx.Param("cluster")
+
+	logrus.Infof("checking load balancer target health for cluster %s\n", clusterName)
+
+	var body LBHealthBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	cm, err := newClusterManager(ctx, clusterName, body.Region, body.Verbose)
+	if err != nil {
+		logrus.Errorf("Failed creating cluster manager: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	lbs, err := cm.GetClusterLBs()
+	if err != nil {
+		logrus.Errorf("Failed getting load balancers: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	result := LBHealthResult{
+		Cluster:   clusterName,
+		Unhealthy: findUnhealthyTargets(lbs),
+	}
+
+	addTargetHealthReasons(ctx, cm, lbs, result.Unhealthy)
+
+	for _, lb := range lbs {
+		result.TotalTargets += len(lb.Targets)
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// findUnhealthyTargets returns every target, across the given load balancers, that is not healthy.
+func findUnhealthyTargets(lbs []manager.LBInfo) (unhealthy []UnhealthyTarget) {
+	unhealthy = make([]UnhealthyTarget, 0)
+
+	for _, lb := range lbs {
+		for _, target := range lb.Targets {
+			if target.State == targetStateHealthy {
+				continue
+			}
+
+			unhealthy = append(unhealthy, UnhealthyTarget{
+				LoadBalancer: lb.Name,
+				Target:       target.Name,
+				ID:           target.ID,
+				Port:         target.Port,
+				State:        target.State,
+			})
+		}
+	}
+
+	return unhealthy
+}
+
+// addTargetHealthReasons fills in the AWS health reason and description for each unhealthy target.
+// The cluster manager only reports target state, so this asks ELB for the target health of each target group directly,
+// several target groups at a time.
+// Failures are logged, not returned: the reason is useful detail, but the state alone is still worth reporting.
+func addTargetHealthReasons(ctx context.Context, cm *aws.AWSClusterManager, lbs []manager.LBInfo, unhealthy []UnhealthyTarget) {
+	if len(unhealthy) == 0 {
+		return
+	}
+
+	health, _ := describeTargetGroupsHealth(ctx, cm, lbs)
+
+	applyTargetHealthReasons(health, unhealthy)
+}
+
+// applyTargetHealthReasons fills in each unhealthy target's target group, reason and description from target health already fetched.
+// Reasons are keyed by target group as well as target, since the same instance and port can sit in several target groups of
+// a load balancer, healthy in some and not others.  Only non-healthy entries are recorded, so a healthy entry in one target
+// group never blanks the reason from another.
+func applyTargetHealthReasons(health []targetGroupHealth, unhealthy []UnhealthyTarget) {
+	type targetKey struct {
+		tgArn string
+		id    string
+		port  int32
+	}
+
+	type targetReason struct {
+		reason      string
+		description string
+	}
+
+	reasons := make(map[targetKey]targetReason)
+
+	for _, tgHealth := range health {
+		for _, desc := range tgHealth.output.TargetHealthDescriptions {
+			if desc.Target == nil || desc.Target.Id == nil || desc.Target.Port == nil || desc.TargetHealth == nil {
+				continue
+			}
+
+			if string(desc.TargetHealth.State) == targetStateHealthy {
+				continue
+			}
+
+			r := targetReason{reason: string(desc.TargetHealth.Reason)}
+			if desc.TargetHealth.Description != nil {
+				r.description = *desc.TargetHealth.Description
+			}
+
+			reasons[targetKey{tgArn: tgHealth.tg.Arn, id: *desc.Target.Id, port: *desc.Target.Port}] = r
+		}
+	}
+
+	for i := range unhealthy {
+		for _, tgHealth := range health {
+			if tgHealth.lb != unhealthy[i].LoadBalancer {
+				continue
+			}
+
+			r, ok := reasons[targetKey{tgArn: tgHealth.tg.Arn, id: unhealthy[i].ID, port: unhealthy[i].Port}]
+			if !ok {
+				continue
+			}
+
+			unhealthy[i].TargetGroup = tgHealth.tg.Name
+			unhealthy[i].Reason = r.reason
+			unhealthy[i].Description = r.description
+			break
+		}
+	}
+}
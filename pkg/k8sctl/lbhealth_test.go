@@ -0,0 +1,80 @@
+package k8sctl
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func healthDescription(id string, port int32, state elbtypes.TargetHealthStateEnum, reason elbtypes.TargetHealthReasonEnum, description string) (desc elbtypes.TargetHealthDescription) {
+	desc = elbtypes.TargetHealthDescription{
+		Target: &elbtypes.TargetDescription{Id: aws.String(id), Port: aws.Int32(port)},
+		TargetHealth: &elbtypes.TargetHealth{
+			State:  state,
+			Reason: reason,
+		},
+	}
+	if description != "" {
+		desc.TargetHealth.Description = aws.String(description)
+	}
+
+	return desc
+}
+
+func TestApplyTargetHealthReasons(t *testing.T) {
+	// The same instance and port is healthy in one target group and failing in another.  The healthy entry comes last,
+	// so a lookup keyed on id and port alone would blank the reason.
+	health := []targetGroupHealth{
+		{
+			lb: "lb-1",
+			tg: manager.LBTargetGroupInfo{Name: "tg-https", Arn: "arn:tg-https", Port: 443},
+			output: &elasticloadbalancingv2.DescribeTargetHealthOutput{
+				TargetHealthDescriptions: []elbtypes.TargetHealthDescription{
+					healthDescription("i-1", 30443, elbtypes.TargetHealthStateEnumUnhealthy, elbtypes.TargetHealthReasonEnumFailedHealthChecks, "Health checks failed"),
+				},
+			},
+		},
+		{
+			lb: "lb-1",
+			tg: manager.LBTargetGroupInfo{Name: "tg-http", Arn: "arn:tg-http", Port: 80},
+			output: &elasticloadbalancingv2.DescribeTargetHealthOutput{
+				TargetHealthDescriptions: []elbtypes.TargetHealthDescription{
+					healthDescription("i-1", 30443, elbtypes.TargetHealthStateEnumHealthy, "", ""),
+				},
+			},
+		},
+		{
+			lb: "lb-2",
+			tg: manager.LBTargetGroupInfo{Name: "tg-other", Arn: "arn:tg-other", Port: 443},
+			output: &elasticloadbalancingv2.DescribeTargetHealthOutput{
+				TargetHealthDescriptions: []elbtypes.TargetHealthDescription{
+					healthDescription("i-2", 30443, elbtypes.TargetHealthStateEnumDraining, elbtypes.TargetHealthReasonEnumDeregistrationInProgress, ""),
+				},
+			},
+		},
+	}
+
+	unhealthy := []UnhealthyTarget{
+		{LoadBalancer: "lb-1", Target: "node-1", ID: "i-1", Port: 30443, State: "unhealthy"},
+		{LoadBalancer: "lb-2", Target: "node-2", ID: "i-2", Port: 30443, State: "draining"},
+		// i-2 is only in lb-2, so lb-1 has nothing to say about it.
+		{LoadBalancer: "lb-1", Target: "node-2", ID: "i-2", Port: 30443, State: "unused"},
+	}
+
+	applyTargetHealthReasons(health, unhealthy)
+
+	assert.Equal(t, "tg-https", unhealthy[0].TargetGroup)
+	assert.Equal(t, "Target.FailedHealthChecks", unhealthy[0].Reason)
+	assert.Equal(t, "Health checks failed", unhealthy[0].Description)
+
+	assert.Equal(t, "tg-other", unhealthy[1].TargetGroup)
+	assert.Equal(t, "Target.DeregistrationInProgress", unhealthy[1].Reason)
+	assert.Empty(t, unhealthy[1].Description)
+
+	assert.Empty(t, unhealthy[2].TargetGroup)
+	assert.Empty(t, unhealthy[2].Reason)
+}
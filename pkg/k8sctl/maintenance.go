@@ -0,0 +1,145 @@
+package k8sctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// MaintenanceBody turns maintenance mode on or off.
+type MaintenanceBody struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"` // told to the callers refused while it's on
+}
+
+// MaintenanceStatus is whether the server is in maintenance mode, refusing the routes that change clusters, and if so,
+// since when, why, and who turned it on.
+type MaintenanceStatus struct {
+	Enabled   bool       `json:"enabled"`
+	Reason    string     `json:"reason,omitempty"`
+	EnabledBy string     `json:"enabled_by,omitempty"`
+	Since     *time.Time `json:"since,omitempty"`
+}
+
+// ConsolePrint prints the status on a line.
+func (s MaintenanceStatus) ConsolePrint() {
+	if !s.Enabled {
+		fmt.Printf("%s Maintenance mode is off\n", consoleMarkers.OK)
+		return
+	}
+
+	fmt.Printf("%s %s\n", consoleMarkers.Warn, s.Summary())
+}
+
+// Summary describes maintenance mode on one line, e.g. "Maintenance mode is on, since ... by alice@example.com: AWS
+// maintenance".
+func (s MaintenanceStatus) Summary() (summary string) {
+	summary = "Maintenance mode is on"
+	if s.Since != nil {
+		summary += fmt.Sprintf(", since %s", s.Since.Format(time.RFC3339))
+	}
+	if s.EnabledBy != "" {
+		summary += fmt.Sprintf(" by %s", s.EnabledBy)
+	}
+	if s.Reason != "" {
+		summary += ": " + s.Reason
+	}
+
+	return summary
+}
+
+var maintenanceMu sync.RWMutex
+var maintenance MaintenanceStatus
+
+// SetMaintenance turns maintenance mode on or off, logging who did, e.g. a user's email, or the environment variable
+// that turned it on at startup.
+func SetMaintenance(enabled bool, reason string, by string) (status MaintenanceStatus) {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+
+	if !enabled {
+		logrus.Warnf("maintenance mode turned off by %s", by)
+		maintenance = MaintenanceStatus{}
+		status = maintenance
+		return status
+	}
+
+	now := time.Now()
+	maintenance = MaintenanceStatus{Enabled: true, Reason: reason, EnabledBy: by, Since: &now}
+	logrus.Warnf("maintenance mode turned on by %s: %s", by, reason)
+
+	status = maintenance
+	return status
+}
+
+// Maintenance returns whether the server is in maintenance mode.
+func Maintenance() (status MaintenanceStatus) {
+	maintenanceMu.RLock()
+	defer maintenanceMu.RUnlock()
+
+	status = maintenance
+	return status
+}
+
+// RefuseInMaintenance returns middleware refusing requests with a 503 while the server is in maintenance mode.  The
+// router puts it in front of the Mutating routes.  Routes with a dry run call refuseInMaintenance themselves.
+func RefuseInMaintenance() (handler gin.HandlerFunc) {
+	handler = func(ctx *gin.Context) {
+		if refuseInMaintenance(ctx) {
+			return
+		}
+
+		ctx.Next()
+	}
+
+	return handler
+}
+
+// refuseInMaintenance aborts the request with a 503 if the server is in maintenance mode, for handlers that only
+// change clusters for some requests, e.g. a reconcile with fix_tags.  It returns whether the request was refused.
+func refuseInMaintenance(ctx *gin.Context) (refused bool) {
+	status := Maintenance()
+	if !status.Enabled {
+		return refused
+	}
+
+	logrus.Infof("refused %s %s from %s: %s", ctx.Request.Method, ctx.Request.URL.Path, ctx.GetString("user_email"), status.Summary())
+
+	ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+		"error":       status.Summary() + ".  Operations that change clusters are refused until it's turned off.",
+		"maintenance": status,
+	})
+
+	refused = true
+	return refused
+}
+
+// MaintenanceStatusHandler returns whether the server is in maintenance mode.
+func (c *K8sCtlCommands) MaintenanceStatusHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, Maintenance())
+}
+
+// SetMaintenanceHandler turns maintenance mode on or off, as the caller, whose email is logged.
+func (c *K8sCtlCommands) SetMaintenanceHandler(ctx *gin.Context) {
+	var body MaintenanceBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	by := ctx.GetString("user_email")
+	if by == "" {
+		by = "unknown user"
+	}
+
+	ctx.JSON(http.StatusOK, SetMaintenance(body.Enabled, body.Reason, by))
+}
@@ -0,0 +1,85 @@
+package k8sctl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEmptyELBClient serves no load balancers.  Other methods are left to the nil embedded interface.
+type fakeEmptyELBClient struct {
+	aws.ELBClient
+}
+
+func (f *fakeEmptyELBClient) DescribeLoadBalancers(_ context.Context, _ *elasticloadbalancingv2.DescribeLoadBalancersInput, _ ...func(*elasticloadbalancingv2.Options)) (output *elasticloadbalancingv2.DescribeLoadBalancersOutput, err error) {
+	output = &elasticloadbalancingv2.DescribeLoadBalancersOutput{}
+	return output, err
+}
+
+// TestDryRunInMaintenance checks dry runs of the routes that have them still work in maintenance mode, while the
+// requests that would change the cluster are refused.
+func TestDryRunInMaintenance(t *testing.T) {
+	saved := newClusterManager
+	t.Cleanup(func() {
+		newClusterManager = saved
+		SetMaintenance(false, "", "test cleanup")
+	})
+
+	newClusterManager = func(ctx context.Context, clusterName string, _ string, _ bool) (cm *aws.AWSClusterManager, err error) {
+		cm = &aws.AWSClusterManager{
+			Name:               clusterName,
+			Context:            ctx,
+			Ec2Client:          &fakeTagEC2Client{},
+			ELBClient:          &fakeEmptyELBClient{},
+			FetchedNodesById:   make(map[string]manager.NodeInfo),
+			FetchedNodesByName: make(map[string]manager.NodeInfo),
+		}
+		return cm, err
+	}
+
+	SetMaintenance(true, "AWS account migration", "alice@example.com")
+
+	c := &K8sCtlCommands{}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/cluster/:cluster/upgrade", c.UpgradeClusterHandler)
+	router.POST("/cluster/:cluster/node/upgrade/:node", c.UpgradeNodeHandler)
+	router.POST("/cluster/:cluster/node/delete/:name", c.DeleteNodeHandler)
+	router.POST("/cluster/:cluster/node/glass/:name", c.GlassNodeHandler)
+	router.POST("/cluster/:cluster/secrets/sync", c.SecretsSyncHandler)
+
+	request := func(path string, body string) (recorder *httptest.ResponseRecorder) {
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return recorder
+	}
+
+	recorder := request("/cluster/cluster1/upgrade", `{"version": "v1.10.8", "dry_run": true}`)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Contains(t, recorder.Body.String(), `"dry_run":true`)
+
+	for _, path := range []string{
+		"/cluster/cluster1/upgrade",
+		"/cluster/cluster1/node/upgrade/cluster1-worker-1",
+		"/cluster/cluster1/node/delete/cluster1-worker-1",
+		"/cluster/cluster1/node/glass/cluster1-worker-1",
+		"/cluster/cluster1/secrets/sync",
+	} {
+		recorder = request(path, `{"version": "v1.10.8", "cloud_provider": "aws"}`)
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code, path)
+		assert.Contains(t, recorder.Body.String(), "AWS account migration", path)
+
+		// Dry runs get past maintenance mode, whatever becomes of them after without real nodes or Vault
+		recorder = request(path, `{"version": "v1.10.8", "cloud_provider": "aws", "dry_run": true}`)
+		assert.NotEqual(t, http.StatusServiceUnavailable, recorder.Code, path)
+	}
+}
@@ -0,0 +1,42 @@
+package k8sctl
+
+// Markers are the symbols that flag lines of console output and of a monitor's stream: emoji by default, or plain
+// text for CI logs and other places emoji are noise in.
+type Markers struct {
+	Warn  string
+	OK    string
+	Fail  string
+	Error string // also says it's an error, since the emoji alone doesn't
+	Wait  string
+	Alert string
+}
+
+// EmojiMarkers are the default markers, for a terminal.
+var EmojiMarkers = Markers{Warn: "⚠", OK: "✓", Fail: "✗", Error: "❌ ERROR:", Wait: "⏳", Alert: "📣"}
+
+// PlainMarkers are the markers without emoji.
+var PlainMarkers = Markers{Warn: "[WARN]", OK: "[OK]", Fail: "[FAIL]", Error: "[ERROR]", Wait: "[WAIT]", Alert: "[ALERT]"}
+
+// consoleMarkers are the markers ConsolePrint methods use.
+var consoleMarkers = EmojiMarkers
+
+// SetPlainOutput makes ConsolePrint methods use PlainMarkers, rather than emoji.
+func SetPlainOutput(plain bool) {
+	consoleMarkers = markersFor(plain)
+}
+
+// ConsoleMarkers returns the markers ConsolePrint methods use, for the CLI's own output to match.
+func ConsoleMarkers() (markers Markers) {
+	markers = consoleMarkers
+	return markers
+}
+
+// markersFor returns the plain or the emoji markers.
+func markersFor(plain bool) (markers Markers) {
+	markers = EmojiMarkers
+	if plain {
+		markers = PlainMarkers
+	}
+
+	return markers
+}
@@ -0,0 +1,311 @@
+package k8sctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// MonitorResultTrailer is the HTTP trailer a one-shot monitor run reports its result in: the number of issues found, or
+// MonitorResultError if the check couldn't be made.
+const MonitorResultTrailer = "X-K8sctl-Monitor-Issues"
+
+// MonitorResultError is the MonitorResultTrailer value for a check that failed.
+const MonitorResultError = "error"
+
+// monitorMaxBackoff caps how long a monitor waits between checks while its checks keep failing.
+const monitorMaxBackoff = 10 * time.Minute
+
+// monitorBackoff returns how long to wait before the next check after the given number of consecutive failed checks.
+// The wait doubles with each failure, up to monitorMaxBackoff (or the interval itself, if that's longer), and half of
+// it is random jitter, so several monitors of a throttled account don't all retry at once.
+func monitorBackoff(interval time.Duration, failures int) (wait time.Duration) {
+	maxWait := max(interval, monitorMaxBackoff)
+
+	wait = interval
+	for i := 0; i < failures && wait < maxWait; i++ {
+		wait *= 2
+	}
+
+	wait = min(wait, maxWait)
+
+	half := wait / 2
+	wait = half + rand.N(wait-half+1)
+
+	return wait
+}
+
+// monitorWebhookReminder is how often an alert is re-sent while a cluster stays unhealthy, unless the request says otherwise.
+const monitorWebhookReminder = time.Hour
+
+// monitorWebhookTimeout bounds each webhook POST, so a slow webhook can't stall the monitor.
+const monitorWebhookTimeout = 10 * time.Second
+
+// Monitor alert statuses.
+const (
+	MonitorAlertUnhealthy = "unhealthy"
+	MonitorAlertReminder  = "reminder"
+	MonitorAlertResolved  = "resolved"
+)
+
+// MonitorIssue is one kind of problem a monitor check found, and the targets or nodes it was found on.
+type MonitorIssue struct {
+	Check string   `json:"check"`
+	Items []string `json:"items"`
+}
+
+// MonitorAlert is the JSON payload POSTed to a monitor's webhook.
+// Text summarizes the alert, so the payload works as is with a Slack incoming webhook.
+type MonitorAlert struct {
+	Cluster string         `json:"cluster"`
+	Status  string         `json:"status"`
+	Time    time.Time      `json:"time"`
+	Issues  []MonitorIssue `json:"issues"`
+	Text    string         `json:"text"`
+}
+
+// SetMonitorWebhookURL sets the webhook monitors alert when their request doesn't name one.
+// The server's webhook is trusted; webhooks named in requests are restricted, see newMonitorAlerter.
+func SetMonitorWebhookURL(url string) {
+	monitorWebhookURL = url
+}
+
+// reportMonitorIssue writes an issue to the monitor stream and adds it to issues.  No items, no issue.
+func reportMonitorIssue(ctx *gin.Context, marks Markers, issues []MonitorIssue, check string, items []string) (updated []MonitorIssue) {
+	if len(items) == 0 {
+		return issues
+	}
+
+	writeOutput(ctx, fmt.Sprintf("  %s %s: %d\n", marks.Warn, check, len(items)))
+	for _, item := range items {
+		writeOutput(ctx, fmt.Sprintf("    - %s\n", item))
+	}
+
+	updated = append(issues, MonitorIssue{Check: check, Items: items})
+
+	return updated
+}
+
+// monitorAlerter sends a monitor's webhook alerts.  To keep from spamming the webhook every interval, it only alerts
+// when the cluster becomes unhealthy or recovers, with a reminder every so often while it stays unhealthy.
+// A nil monitorAlerter, for a monitor without a webhook, does nothing.
+type monitorAlerter struct {
+	cluster   string
+	url       string
+	client    *http.Client
+	reminder  time.Duration
+	unhealthy bool
+	lastSent  time.Time
+}
+
+// newMonitorAlerter returns an alerter for the given webhook, or the server's if url is empty.
+// It returns nil if there's no webhook.
+// A webhook named in the request makes the server POST wherever the caller says, so it must be https, and it's only
+// ever connected to at a public address: loopback, private, link-local (which includes the cloud metadata endpoint),
+// and other non-public addresses are refused, as are redirects.
+func newMonitorAlerter(cluster string, webhookURL string, reminder time.Duration) (alerter *monitorAlerter, err error) {
+	client := http.DefaultClient
+
+	if webhookURL != "" {
+		err = validateWebhookURL(webhookURL)
+		if err != nil {
+			return alerter, err
+		}
+
+		client = publicWebhookClient()
+	} else {
+		webhookURL = monitorWebhookURL
+	}
+
+	if webhookURL == "" {
+		return alerter, err
+	}
+
+	if reminder <= 0 {
+		reminder = monitorWebhookReminder
+	}
+
+	alerter = &monitorAlerter{
+		cluster:  cluster,
+		url:      webhookURL,
+		client:   client,
+		reminder: reminder,
+	}
+
+	return alerter, err
+}
+
+// validateWebhookURL checks that a request-supplied webhook URL is https, and isn't a literal non-public address.
+// Hostnames are checked when they're resolved, by publicWebhookClient.
+func validateWebhookURL(webhookURL string) (err error) {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil {
+		err = errors.Wrapf(err, "invalid webhook URL")
+		return err
+	}
+
+	if parsed.Scheme != "https" || parsed.Hostname() == "" {
+		err = errors.New(fmt.Sprintf("webhook URL %q must be an https URL", webhookURL))
+		return err
+	}
+
+	if ip := net.ParseIP(parsed.Hostname()); ip != nil && !publicIP(ip) {
+		err = errors.New(fmt.Sprintf("webhook URL %q is not a public address", webhookURL))
+		return err
+	}
+
+	return err
+}
+
+// publicWebhookClient returns an HTTP client that only connects to public addresses, and doesn't follow redirects.
+// The address is checked after DNS resolution, so a hostname can't be pointed at an internal address.
+func publicWebhookClient() (client *http.Client) {
+	dialer := &net.Dialer{
+		Timeout: monitorWebhookTimeout,
+		Control: func(network string, address string, _ syscall.RawConn) (err error) {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !publicIP(ip) {
+				err = errors.New(fmt.Sprintf("webhook address %s is not a public address", host))
+				return err
+			}
+
+			return err
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	client = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(_ *http.Request, _ []*http.Request) (err error) {
+			err = http.ErrUseLastResponse
+			return err
+		},
+	}
+
+	return client
+}
+
+// publicIP returns false for loopback, private, link-local, multicast, and unspecified addresses.
+func publicIP(ip net.IP) (public bool) {
+	public = !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+
+	return public
+}
+
+// check alerts the webhook if a check's issues are a change of state, or it's time for a reminder.
+// If the alert can't be sent, the state is left as it was, so the next check tries again.
+func (a *monitorAlerter) check(ctx *gin.Context, marks Markers, issues []MonitorIssue) {
+	if a == nil {
+		return
+	}
+
+	var status string
+
+	switch {
+	case len(issues) > 0 && !a.unhealthy:
+		status = MonitorAlertUnhealthy
+	case len(issues) > 0 && time.Since(a.lastSent) >= a.reminder:
+		status = MonitorAlertReminder
+	case len(issues) == 0 && a.unhealthy:
+		status = MonitorAlertResolved
+	default:
+		return
+	}
+
+	alert := MonitorAlert{
+		Cluster: a.cluster,
+		Status:  status,
+		Time:    time.Now().UTC(),
+		Issues:  issues,
+		Text:    monitorAlertText(a.cluster, status, issues),
+	}
+
+	err := postMonitorAlert(ctx.Request.Context(), a.client, a.url, alert)
+	if err != nil {
+		logrus.Errorf("Failed sending monitor alert for cluster %s: %s", a.cluster, err)
+		writeOutput(ctx, fmt.Sprintf("  %s Failed sending %s alert to webhook: %s\n\n", marks.Warn, status, err))
+		return
+	}
+
+	a.unhealthy = len(issues) > 0
+	a.lastSent = alert.Time
+
+	writeOutput(ctx, fmt.Sprintf("  %s Sent %s alert to webhook\n\n", marks.Alert, status))
+}
+
+// monitorAlertText summarizes an alert in a line, e.g. "k8sctl: cluster cluster1 is unhealthy: Kubernetes Nodes Not in EC2 (2)".
+func monitorAlertText(cluster string, status string, issues []MonitorIssue) (text string) {
+	if status == MonitorAlertResolved {
+		text = fmt.Sprintf("k8sctl: cluster %s has recovered, all systems healthy", cluster)
+		return text
+	}
+
+	summaries := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		summaries = append(summaries, fmt.Sprintf("%s (%d)", issue.Check, len(issue.Items)))
+	}
+
+	verb := "is"
+	if status == MonitorAlertReminder {
+		verb = "is still"
+	}
+
+	text = fmt.Sprintf("k8sctl: cluster %s %s unhealthy: %s", cluster, verb, strings.Join(summaries, ", "))
+
+	return text
+}
+
+// postMonitorAlert POSTs an alert to a webhook as JSON.
+func postMonitorAlert(ctx context.Context, client *http.Client, webhookURL string, alert MonitorAlert) (err error) {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		err = errors.Wrapf(err, "failed marshalling alert")
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, monitorWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating webhook request")
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = errors.Wrapf(err, "failed posting to webhook")
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = errors.Errorf("webhook returned status %d", resp.StatusCode)
+		return err
+	}
+
+	return err
+}
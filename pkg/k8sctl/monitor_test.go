@@ -0,0 +1,186 @@
+package k8sctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitorBackoff(t *testing.T) {
+	testCases := []struct {
+		interval time.Duration
+		failures int
+		wait     time.Duration // the wait before jitter
+	}{
+		{interval: 10 * time.Second, failures: 1, wait: 20 * time.Second},
+		{interval: 10 * time.Second, failures: 2, wait: 40 * time.Second},
+		{interval: 10 * time.Second, failures: 3, wait: 80 * time.Second},
+		{interval: 10 * time.Second, failures: 6, wait: monitorMaxBackoff}, // 640s, capped at 10m
+		{interval: 10 * time.Second, failures: 1000, wait: monitorMaxBackoff},
+		{interval: time.Minute, failures: 4, wait: monitorMaxBackoff},
+		// An interval longer than monitorMaxBackoff is the cap itself, so failures don't make checks more frequent
+		{interval: time.Hour, failures: 1, wait: time.Hour},
+		{interval: time.Hour, failures: 5, wait: time.Hour},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s after %d failures", tc.interval, tc.failures), func(t *testing.T) {
+			for range 100 {
+				wait := monitorBackoff(tc.interval, tc.failures)
+				assert.GreaterOrEqual(t, wait, tc.wait/2)
+				assert.LessOrEqual(t, wait, tc.wait)
+			}
+		})
+	}
+}
+
+// alertRecorder is a webhook that records the alerts POSTed to it, failing while fail is set.
+type alertRecorder struct {
+	mu     sync.Mutex
+	fail   bool
+	alerts []MonitorAlert
+}
+
+func (r *alertRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var alert MonitorAlert
+	_ = json.NewDecoder(req.Body).Decode(&alert)
+	r.alerts = append(r.alerts, alert)
+}
+
+func (r *alertRecorder) statuses() (statuses []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, alert := range r.alerts {
+		statuses = append(statuses, alert.Status)
+	}
+
+	return statuses
+}
+
+func newTestGinContext() (ctx *gin.Context) {
+	ctx, _ = gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+
+	return ctx
+}
+
+func TestMonitorAlerterTransitions(t *testing.T) {
+	recorder := &alertRecorder{}
+	webhook := httptest.NewServer(recorder)
+	defer webhook.Close()
+
+	// The server's webhook is trusted, so a local test server can stand in for it
+	origURL := monitorWebhookURL
+	SetMonitorWebhookURL(webhook.URL)
+	t.Cleanup(func() { SetMonitorWebhookURL(origURL) })
+
+	alerter, err := newMonitorAlerter("cluster1", "", time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, alerter)
+
+	ctx := newTestGinContext()
+	issues := []MonitorIssue{{Check: "Unhealthy LB targets", Items: []string{"lb/node-1:443"}}}
+
+	alerter.check(ctx, EmojiMarkers, nil)
+	assert.Empty(t, recorder.statuses(), "healthy to start with, nothing to say")
+
+	alerter.check(ctx, EmojiMarkers, issues)
+	alerter.check(ctx, EmojiMarkers, issues)
+	assert.Equal(t, []string{MonitorAlertUnhealthy}, recorder.statuses(), "still unhealthy within the reminder interval is debounced")
+
+	alerter.lastSent = time.Now().Add(-2 * time.Hour)
+	alerter.check(ctx, EmojiMarkers, issues)
+	assert.Equal(t, []string{MonitorAlertUnhealthy, MonitorAlertReminder}, recorder.statuses())
+
+	alerter.check(ctx, EmojiMarkers, nil)
+	alerter.check(ctx, EmojiMarkers, nil)
+	assert.Equal(t, []string{MonitorAlertUnhealthy, MonitorAlertReminder, MonitorAlertResolved}, recorder.statuses())
+}
+
+func TestMonitorAlerterRetriesFailedSend(t *testing.T) {
+	recorder := &alertRecorder{fail: true}
+	webhook := httptest.NewServer(recorder)
+	defer webhook.Close()
+
+	origURL := monitorWebhookURL
+	SetMonitorWebhookURL(webhook.URL)
+	t.Cleanup(func() { SetMonitorWebhookURL(origURL) })
+
+	alerter, err := newMonitorAlerter("cluster1", "", time.Hour)
+	require.NoError(t, err)
+
+	ctx := newTestGinContext()
+	issues := []MonitorIssue{{Check: "Unhealthy LB targets", Items: []string{"lb/node-1:443"}}}
+
+	alerter.check(ctx, EmojiMarkers, issues)
+	assert.False(t, alerter.unhealthy, "a failed send leaves the state alone")
+
+	recorder.mu.Lock()
+	recorder.fail = false
+	recorder.mu.Unlock()
+
+	alerter.check(ctx, EmojiMarkers, issues)
+	assert.Equal(t, []string{MonitorAlertUnhealthy}, recorder.statuses(), "the next check retries the unhealthy alert")
+	assert.True(t, alerter.unhealthy)
+}
+
+func TestNewMonitorAlerterRequestURL(t *testing.T) {
+	origURL := monitorWebhookURL
+	SetMonitorWebhookURL("")
+	t.Cleanup(func() { SetMonitorWebhookURL(origURL) })
+
+	alerter, err := newMonitorAlerter("cluster1", "", 0)
+	require.NoError(t, err)
+	assert.Nil(t, alerter, "no webhook, no alerter")
+
+	for _, webhookURL := range []string{
+		"http://hooks.example.com/alert",
+		"ftp://hooks.example.com/alert",
+		"https://127.0.0.1/alert",
+		"https://10.1.2.3/alert",
+		"https://169.254.169.254/latest/meta-data/",
+		"https://[::1]/alert",
+		"https://[fd00::1]/alert",
+		"https://0.0.0.0/alert",
+		"not a url",
+	} {
+		_, err = newMonitorAlerter("cluster1", webhookURL, 0)
+		assert.Error(t, err, webhookURL)
+	}
+
+	alerter, err = newMonitorAlerter("cluster1", "https://hooks.example.com/alert", 0)
+	require.NoError(t, err)
+	assert.Equal(t, monitorWebhookReminder, alerter.reminder)
+}
+
+func TestPublicWebhookClientRefusesInternalAddresses(t *testing.T) {
+	webhook := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer webhook.Close()
+
+	// localhost passes the URL check, since it's a hostname, but resolves to loopback
+	webhookURL := strings.Replace(webhook.URL, "127.0.0.1", "localhost", 1)
+	require.NoError(t, validateWebhookURL(webhookURL))
+
+	err := postMonitorAlert(context.Background(), publicWebhookClient(), webhookURL, MonitorAlert{Cluster: "cluster1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a public address")
+}
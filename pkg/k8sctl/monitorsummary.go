@@ -0,0 +1,113 @@
+package k8sctl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Text in a monitor's stream, which MonitorSummary.ScanLine reads checks back from.  Issues, errors, and healthy checks
+// are flagged with markers, either the emoji or the plain ones.
+const (
+	monitorCheckStart = "Checking cluster health..."
+	monitorHealthy    = "All systems healthy"
+	monitorFound      = "Found "
+)
+
+// MonitorSummary tallies a monitor session: the checks made, how many of them found issues or failed, and how many
+// checks found each kind of issue.
+type MonitorSummary struct {
+	Checks      int
+	WithIssues  int
+	Failed      int
+	IssueCounts map[string]int // checks that found each kind of issue, by MonitorIssue.Check
+
+	// The check ScanLine is reading, until it's done
+	scanning      bool
+	scannedIssues []MonitorIssue
+}
+
+// Record adds a check to the tally: the issues it found, or whether it failed.
+func (s *MonitorSummary) Record(issues []MonitorIssue, failed bool) {
+	s.Checks++
+
+	switch {
+	case failed:
+		s.Failed++
+		return
+	case len(issues) > 0:
+		s.WithIssues++
+	}
+
+	if s.IssueCounts == nil {
+		s.IssueCounts = make(map[string]int)
+	}
+
+	for _, issue := range issues {
+		s.IssueCounts[issue.Check]++
+	}
+}
+
+// ScanLine reads a line of a monitor's stream, recording each check once its outcome is written.  This is how a client
+// tallies a session it interrupts, which the server can't send the summary of.  A check still under way isn't counted.
+func (s *MonitorSummary) ScanLine(line string) {
+	line = strings.TrimSpace(line)
+
+	switch {
+	case strings.HasSuffix(line, monitorCheckStart):
+		s.scanning = true
+		s.scannedIssues = nil
+	case !s.scanning:
+	case markedWith(line, func(m Markers) string { return m.Warn }) != "":
+		check := markedWith(line, func(m Markers) string { return m.Warn })
+		if i := strings.LastIndex(check, ": "); i > 0 {
+			check = check[:i]
+		}
+
+		s.scannedIssues = append(s.scannedIssues, MonitorIssue{Check: check})
+	case markedWith(line, func(m Markers) string { return m.Error }) != "":
+		s.Record(nil, true)
+		s.scanning = false
+	case strings.HasPrefix(markedWith(line, func(m Markers) string { return m.OK }), monitorHealthy), strings.HasPrefix(line, monitorFound):
+		s.Record(s.scannedIssues, false)
+		s.scanning = false
+	}
+}
+
+// markedWith returns the rest of a line flagged with a marker, emoji or plain, or "" if it isn't.
+func markedWith(line string, marker func(Markers) string) (rest string) {
+	for _, markers := range []Markers{EmojiMarkers, PlainMarkers} {
+		if after, ok := strings.CutPrefix(line, marker(markers)+" "); ok {
+			rest = after
+			return rest
+		}
+	}
+
+	return rest
+}
+
+// MostFrequent returns the kind of issue the most checks found, and how many did.  Ties go to the first by name.
+func (s *MonitorSummary) MostFrequent() (check string, count int) {
+	for name, n := range s.IssueCounts {
+		if n > count || (n == count && name < check) {
+			check = name
+			count = n
+		}
+	}
+
+	return check, count
+}
+
+// String describes the session on one line, e.g. "Session summary: 12 check(s), 3 found issues, 1 failed; most
+// frequent: Unhealthy LB Targets (3 check(s))".
+func (s *MonitorSummary) String() (summary string) {
+	summary = fmt.Sprintf("Session summary: %d check(s), %d found issues, %d failed", s.Checks, s.WithIssues, s.Failed)
+
+	check, count := s.MostFrequent()
+	if count == 0 {
+		summary += "; no issues found"
+		return summary
+	}
+
+	summary += fmt.Sprintf("; most frequent: %s (%d check(s))", check, count)
+	return summary
+}
@@ -0,0 +1,66 @@
+package k8sctl
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitorSummary(t *testing.T) {
+	var summary MonitorSummary
+	assert.Equal(t, "Session summary: 0 check(s), 0 found issues, 0 failed; no issues found", summary.String())
+
+	summary.Record(nil, false)
+	summary.Record([]MonitorIssue{{Check: "Unhealthy Load Balancer Targets"}, {Check: "Kubernetes Nodes Not in EC2"}}, false)
+	summary.Record([]MonitorIssue{{Check: "Kubernetes Nodes Not in EC2"}}, false)
+	summary.Record(nil, true)
+
+	assert.Equal(t, 4, summary.Checks)
+	assert.Equal(t, 2, summary.WithIssues)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, "Session summary: 4 check(s), 2 found issues, 1 failed; most frequent: Kubernetes Nodes Not in EC2 (2 check(s))", summary.String())
+
+	// Ties go to the first by name
+	summary.Record([]MonitorIssue{{Check: "Unhealthy Load Balancer Targets"}}, false)
+	check, count := summary.MostFrequent()
+	assert.Equal(t, "Kubernetes Nodes Not in EC2", check)
+	assert.Equal(t, 2, count)
+}
+
+func TestMonitorSummaryScanLine(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+
+	// Written as monitorOnce writes them, with either markers: one healthy check, one with issues, one failed, and one
+	// under way
+	for _, marks := range []Markers{EmojiMarkers, PlainMarkers} {
+		writeOutput(ctx, fmt.Sprintf("[2025-01-01 12:00:00] %s\n", monitorCheckStart))
+		writeOutput(ctx, fmt.Sprintf("  %s %s - EC2: 3, K8s: 3, LB Targets: 3\n\n", marks.OK, monitorHealthy))
+
+		writeOutput(ctx, fmt.Sprintf("[2025-01-01 12:01:00] %s\n", monitorCheckStart))
+		issues := reportMonitorIssue(ctx, marks, nil, "EC2 Instances Not in Kubernetes", []string{"cluster1-worker-3.example.com"})
+		issues = reportMonitorIssue(ctx, marks, issues, "EC2 Instances Not in Any Load Balancer", []string{"cluster1-worker-3.example.com"})
+		writeOutput(ctx, fmt.Sprintf("  %s%d issue(s)\n\n", monitorFound, len(issues)))
+
+		writeOutput(ctx, fmt.Sprintf("[2025-01-01 12:02:00] %s\n", monitorCheckStart))
+		writeOutput(ctx, fmt.Sprintf("%s Failed getting cluster info: throttled\n", marks.Error))
+	}
+
+	writeOutput(ctx, fmt.Sprintf("[2025-01-01 12:03:00] %s\n", monitorCheckStart))
+	reportMonitorIssue(ctx, PlainMarkers, nil, "Kubernetes Nodes Not in EC2", []string{"cluster1-worker-4"})
+
+	var summary MonitorSummary
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		summary.ScanLine(line)
+	}
+
+	assert.Equal(t, 6, summary.Checks)
+	assert.Equal(t, 2, summary.WithIssues)
+	assert.Equal(t, 2, summary.Failed)
+	assert.Equal(t, map[string]int{"EC2 Instances Not in Kubernetes": 2, "EC2 Instances Not in Any Load Balancer": 2}, summary.IssueCounts)
+}
@@ -0,0 +1,131 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+)
+
+// defaultMonitorCheckTimeout bounds each monitor check, unless the request says otherwise.
+const defaultMonitorCheckTimeout = 30 * time.Second
+
+// errMonitorCheckTimedOut is the error of a monitor check abandoned for running past its timeout.
+var errMonitorCheckTimedOut = errors.New("check timed out")
+
+// monitorCheck is one monitor check, writing its progress to ctx.
+type monitorCheck func(ctx *gin.Context) (issues []MonitorIssue, err error)
+
+// timedMonitorCheck runs a monitor check with a deadline, so one hung AWS or Kubernetes call can't stall the monitor.
+// The check's request context is cancelled at the deadline, but not every call it makes honours that, so if the check
+// hasn't returned by then it's abandoned: a "check timed out" line is written, anything the check writes afterwards is
+// dropped, and errMonitorCheckTimedOut is returned, for the monitor to carry on with its next check.
+func timedMonitorCheck(ctx *gin.Context, marks Markers, timeout time.Duration, check monitorCheck) (issues []MonitorIssue, err error) {
+	deadlineCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+	defer cancel()
+
+	writer := &monitorCheckWriter{ResponseWriter: ctx.Writer}
+
+	checkCtx := ctx.Copy()
+	checkCtx.Request = ctx.Request.WithContext(deadlineCtx)
+	checkCtx.Writer = writer
+
+	type checkResult struct {
+		issues []MonitorIssue
+		err    error
+	}
+
+	done := make(chan checkResult, 1)
+	go func() {
+		checkIssues, checkErr := check(checkCtx)
+		done <- checkResult{issues: checkIssues, err: checkErr}
+	}()
+
+	select {
+	case result := <-done:
+		issues, err = result.issues, result.err
+	case <-deadlineCtx.Done():
+		writer.abandon()
+
+		// The client went away, which ends the monitor anyway
+		err = ctx.Request.Context().Err()
+		if err != nil {
+			return issues, err
+		}
+
+		err = errMonitorCheckTimedOut
+		writeOutput(ctx, fmt.Sprintf("%s Check timed out after %s, abandoned\n", marks.Error, timeout))
+	}
+
+	return issues, err
+}
+
+// checkClusterManager returns a copy of the cluster manager for one monitor check, making its AWS calls with the
+// check's context, so they're cancelled along with it.  An abandoned check may still be using its copy when the next
+// check starts, so each copy has its own node caches, which the cluster manager doesn't lock.
+func checkClusterManager(ctx context.Context, cm *aws.AWSClusterManager) (checkCM *aws.AWSClusterManager) {
+	scoped := *cm
+	scoped.Context = ctx
+	scoped.FetchedNodesById = make(map[string]manager.NodeInfo)
+	scoped.FetchedNodesByName = make(map[string]manager.NodeInfo)
+
+	checkCM = &scoped
+	return checkCM
+}
+
+// monitorCheckWriter passes a monitor check's output through to the stream, until the check is abandoned.  From then
+// on, its writes are dropped, so a check left running can't write into the output of the checks after it.
+type monitorCheckWriter struct {
+	gin.ResponseWriter
+
+	mu        sync.Mutex
+	abandoned bool
+}
+
+func (w *monitorCheckWriter) Write(data []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.abandoned {
+		n = len(data)
+		return n, err
+	}
+
+	n, err = w.ResponseWriter.Write(data)
+	return n, err
+}
+
+func (w *monitorCheckWriter) WriteString(s string) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.abandoned {
+		n = len(s)
+		return n, err
+	}
+
+	n, err = w.ResponseWriter.WriteString(s)
+	return n, err
+}
+
+func (w *monitorCheckWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.abandoned {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// abandon drops the check's writes from now on.  Once it returns, the check's writes no longer reach the stream.
+func (w *monitorCheckWriter) abandon() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.abandoned = true
+}
@@ -0,0 +1,119 @@
+package k8sctl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMonitorTestContext() (ctx *gin.Context, recorder *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder = httptest.NewRecorder()
+	ctx, _ = gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+
+	return ctx, recorder
+}
+
+func TestTimedMonitorCheck(t *testing.T) {
+	ctx, recorder := newMonitorTestContext()
+
+	issues, err := timedMonitorCheck(ctx, PlainMarkers, time.Second, func(checkCtx *gin.Context) (issues []MonitorIssue, err error) {
+		writeOutput(checkCtx, "checking\n")
+		issues = []MonitorIssue{{Check: "Kubernetes Nodes Not in EC2", Items: []string{"cluster1-worker-4"}}}
+		return issues, err
+	})
+
+	require.NoError(t, err)
+	assert.Len(t, issues, 1)
+	assert.Equal(t, "checking\n", recorder.Body.String())
+}
+
+func TestTimedMonitorCheckTimesOut(t *testing.T) {
+	ctx, recorder := newMonitorTestContext()
+
+	release := make(chan struct{})
+	finished := make(chan struct{})
+
+	// A check stuck on a call that ignores its context, which writes once it's finally let go
+	_, err := timedMonitorCheck(ctx, PlainMarkers, 50*time.Millisecond, func(checkCtx *gin.Context) (issues []MonitorIssue, err error) {
+		defer close(finished)
+
+		writeOutput(checkCtx, "checking\n")
+		<-release
+		writeOutput(checkCtx, "too late\n")
+
+		return issues, err
+	})
+
+	assert.ErrorIs(t, err, errMonitorCheckTimedOut)
+
+	close(release)
+	<-finished
+
+	assert.Equal(t, "checking\n[ERROR] Check timed out after 50ms, abandoned\n", recorder.Body.String())
+
+	// The timed out line counts as a failed check in the session summary
+	var summary MonitorSummary
+	summary.ScanLine("[2025-01-01 12:00:00] " + monitorCheckStart)
+	summary.ScanLine("[ERROR] Check timed out after 50ms, abandoned")
+	assert.Equal(t, 1, summary.Failed)
+}
+
+func TestTimedMonitorCheckCancelsContext(t *testing.T) {
+	ctx, _ := newMonitorTestContext()
+
+	checkErr := make(chan error, 1)
+	_, err := timedMonitorCheck(ctx, PlainMarkers, 50*time.Millisecond, func(checkCtx *gin.Context) (issues []MonitorIssue, err error) {
+		<-checkCtx.Request.Context().Done()
+		checkErr <- checkCtx.Request.Context().Err()
+		return issues, err
+	})
+
+	assert.ErrorIs(t, err, errMonitorCheckTimedOut)
+	assert.ErrorIs(t, <-checkErr, context.DeadlineExceeded)
+}
+
+func TestTimedMonitorCheckClientGone(t *testing.T) {
+	ctx, recorder := newMonitorTestContext()
+
+	requestCtx, cancel := context.WithCancel(context.Background())
+	ctx.Request = ctx.Request.WithContext(requestCtx)
+	cancel()
+
+	_, err := timedMonitorCheck(ctx, PlainMarkers, time.Minute, func(checkCtx *gin.Context) (issues []MonitorIssue, err error) {
+		<-checkCtx.Request.Context().Done()
+		return issues, err
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, recorder.Body.String(), "no timed out line for a client that's gone")
+}
+
+func TestCheckClusterManager(t *testing.T) {
+	cm := &aws.AWSClusterManager{
+		Name:               "cluster1",
+		Context:            context.Background(),
+		FetchedNodesById:   map[string]manager.NodeInfo{"i-1": {Name: "cluster1-worker-1", ID: "i-1"}},
+		FetchedNodesByName: map[string]manager.NodeInfo{"cluster1-worker-1": {Name: "cluster1-worker-1", ID: "i-1"}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	checkCM := checkClusterManager(ctx, cm)
+	checkCM.FetchedNodesById["i-2"] = manager.NodeInfo{Name: "cluster1-worker-2", ID: "i-2"}
+
+	assert.Equal(t, ctx, checkCM.Context)
+	assert.Equal(t, "cluster1", checkCM.Name)
+	assert.Len(t, cm.FetchedNodesById, 1, "the check's node cache is its own")
+	assert.Equal(t, context.Background(), cm.Context)
+}
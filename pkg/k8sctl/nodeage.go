@@ -0,0 +1,33 @@
+package k8sctl
+
+import (
+	"fmt"
+	"time"
+)
+
+// humanAge formats how long a node's been up to two units at most, e.g. 45m, 3h12m, or 5d3h, which is as precise as
+// spotting nodes due for rotation, or just launched, needs.
+func humanAge(age time.Duration) (formatted string) {
+	switch {
+	case age < time.Minute:
+		formatted = "<1m"
+	case age < time.Hour:
+		formatted = fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 24*time.Hour:
+		formatted = fmt.Sprintf("%dh%dm", int(age.Hours()), int(age.Minutes())%60)
+	default:
+		formatted = fmt.Sprintf("%dd%dh", int(age.Hours())/24, int(age.Hours())%24)
+	}
+
+	return formatted
+}
+
+// formatNodeTime formats a node's launch or join time for the console, in UTC, or - if it isn't known.
+func formatNodeTime(t *time.Time) (formatted string) {
+	formatted = "-"
+	if t != nil {
+		formatted = t.UTC().Format(time.RFC3339)
+	}
+
+	return formatted
+}
@@ -0,0 +1,22 @@
+package k8sctl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHumanAge(t *testing.T) {
+	assert.Equal(t, "<1m", humanAge(30*time.Second))
+	assert.Equal(t, "45m", humanAge(45*time.Minute+10*time.Second))
+	assert.Equal(t, "3h12m", humanAge(3*time.Hour+12*time.Minute))
+	assert.Equal(t, "5d3h", humanAge(5*24*time.Hour+3*time.Hour+59*time.Minute))
+}
+
+func TestFormatNodeTime(t *testing.T) {
+	launched := time.Date(2026, 10, 14, 9, 12, 0, 0, time.FixedZone("EDT", -4*3600))
+
+	assert.Equal(t, "2026-10-14T13:12:00Z", formatNodeTime(&launched))
+	assert.Equal(t, "-", formatNodeTime(nil))
+}
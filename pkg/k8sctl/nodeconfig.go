@@ -0,0 +1,155 @@
+package k8sctl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// clusterConfigDir is where per-cluster, per-role node configuration is mounted on the server.
+const clusterConfigDir = "/etc/clusters"
+
+// Where a new node's instance type came from, in order of precedence.
+const (
+	instanceTypeFromRequest       = "request"
+	instanceTypeFromNodeConfig    = "node config"
+	instanceTypeFromConfigDefault = "config default"
+)
+
+// nodeConfigFiles holds the on-disk configuration used to build a node of a given role.
+type nodeConfigFiles struct {
+	MachineConfig []byte
+	NodeConfig    aws.AWSNodeConfig
+	Patches       []string
+}
+
+// loadNodeConfigFiles loads the machine config, cloud node config, and machine config patches for a cluster and node role.
+// NB: this could be more efficiently done at pod start up, but that would require loading ALL the configs for all clusters and roles.  Not bothering with that now.  This isn't a high speed app.  Loading at run time is acceptable for now.
+func loadNodeConfigFiles(clusterName string, nodeRole string, cloudProvider string) (files nodeConfigFiles, err error) {
+	// develop the expected paths for this cluster and node role
+	machineConfigPath := fmt.Sprintf("%s/%s/%s/config.yaml", clusterConfigDir, clusterName, nodeRole)
+	nodeConfigPath := fmt.Sprintf("%s/%s/%s/node-%s.yaml", clusterConfigDir, clusterName, nodeRole, cloudProvider)
+	roleDir := fmt.Sprintf("%s/%s/%s", clusterConfigDir, clusterName, nodeRole)
+
+	logrus.Infof("Machine Config Path: %s", machineConfigPath)
+	logrus.Infof("Node Config Path: %s", nodeConfigPath)
+
+	// Load the machine config
+	files.MachineConfig, err = os.ReadFile(machineConfigPath)
+	if err != nil {
+		err = errors.Wrapf(err, "Failed loading machine config file %s", machineConfigPath)
+		return files, err
+	}
+
+	// Load the node config
+	nodeConfigBytes, err := os.ReadFile(nodeConfigPath)
+	if err != nil {
+		err = errors.Wrapf(err, "Failed loading node config file %s", nodeConfigPath)
+		return files, err
+	}
+
+	// Create the AWS Node Config struct
+	files.NodeConfig, err = aws.LoadAWSNodeConfig(nodeConfigBytes)
+	if err != nil {
+		err = errors.Wrapf(err, "failed making node struct from config %s", nodeConfigPath)
+		return files, err
+	}
+
+	// Load the machine config patches
+	patchPaths, err := findPatchFiles(roleDir)
+	if err != nil {
+		return files, err
+	}
+
+	files.Patches = make([]string, 0, len(patchPaths))
+	for _, patchPath := range patchPaths {
+		logrus.Infof("Patch Path: %s", patchPath)
+
+		patchBytes, readErr := os.ReadFile(patchPath)
+		if readErr != nil {
+			err = errors.Wrapf(readErr, "Failed loading patch file %s", patchPath)
+			return files, err
+		}
+
+		files.Patches = append(files.Patches, string(patchBytes))
+	}
+
+	return files, err
+}
+
+// findPatchFiles returns the machine config patches in a role directory, in the order they are to be applied:
+//  1. patch.yaml
+//  2. any other patch*.yaml, sorted by name (e.g. patch-10-env.yaml, patch-20-node.yaml)
+//  3. patches.d/*.yaml, sorted by name
+//
+// Patches are applied in sequence by Talos' config patcher, so a later patch overrides values set by an earlier one.
+// At least one patch file must exist.
+func findPatchFiles(roleDir string) (patchPaths []string, err error) {
+	basePatch := filepath.Join(roleDir, "patch.yaml")
+
+	_, statErr := os.Stat(basePatch)
+	if statErr == nil {
+		patchPaths = append(patchPaths, basePatch)
+	}
+
+	layered, globErr := filepath.Glob(filepath.Join(roleDir, "patch*.yaml"))
+	if globErr != nil {
+		err = errors.Wrapf(globErr, "failed listing patch files in %s", roleDir)
+		return patchPaths, err
+	}
+
+	sort.Strings(layered)
+	for _, p := range layered {
+		if p != basePatch {
+			patchPaths = append(patchPaths, p)
+		}
+	}
+
+	patchDir, globErr := filepath.Glob(filepath.Join(roleDir, "patches.d", "*.yaml"))
+	if globErr != nil {
+		err = errors.Wrapf(globErr, "failed listing patch files in %s/patches.d", roleDir)
+		return patchPaths, err
+	}
+
+	sort.Strings(patchDir)
+	patchPaths = append(patchPaths, patchDir...)
+
+	if len(patchPaths) == 0 {
+		err = errors.New(fmt.Sprintf("no patch files found in %s (expected patch.yaml, patch*.yaml, or patches.d/*.yaml)", roleDir))
+		return patchPaths, err
+	}
+
+	return patchPaths, err
+}
+
+// chooseInstanceType picks a new node's instance type: the one requested, else the one in the role's node config, else
+// the server config's default for the role.  It returns "" for both if none of them name one.
+func chooseInstanceType(requested string, nodeConfigType string, defaultType string) (instanceType string, source string) {
+	switch {
+	case requested != "":
+		instanceType = requested
+		source = instanceTypeFromRequest
+	case nodeConfigType != "":
+		instanceType = nodeConfigType
+		source = instanceTypeFromNodeConfig
+	case defaultType != "":
+		instanceType = defaultType
+		source = instanceTypeFromConfigDefault
+	}
+
+	return instanceType, source
+}
+
+// defaultInstanceType returns the server config's default instance type for a node role, if there is one.
+func defaultInstanceType(nodeRole string) (instanceType string) {
+	if clusterConfig != nil {
+		instanceType = clusterConfig.GetDefaultInstanceType(nodeRole)
+	}
+
+	return instanceType
+}
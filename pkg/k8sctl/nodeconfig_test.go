@@ -0,0 +1,79 @@
+package k8sctl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nikogura/k8sctl/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindPatchFiles(t *testing.T) {
+	roleDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(roleDir, "patches.d"), 0755))
+
+	for _, name := range []string{
+		"patch-20-node.yaml",
+		"patch.yaml",
+		"patch-10-env.yaml",
+		"config.yaml",
+		"patches.d/20-b.yaml",
+		"patches.d/10-a.yaml",
+		"patches.d/notes.txt",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(roleDir, name), []byte("{}"), 0600))
+	}
+
+	patchPaths, err := findPatchFiles(roleDir)
+	require.NoError(t, err)
+
+	expected := []string{
+		filepath.Join(roleDir, "patch.yaml"),
+		filepath.Join(roleDir, "patch-10-env.yaml"),
+		filepath.Join(roleDir, "patch-20-node.yaml"),
+		filepath.Join(roleDir, "patches.d", "10-a.yaml"),
+		filepath.Join(roleDir, "patches.d", "20-b.yaml"),
+	}
+	assert.Equal(t, expected, patchPaths)
+
+	_, err = findPatchFiles(t.TempDir())
+	require.Error(t, err)
+}
+
+func TestChooseInstanceType(t *testing.T) {
+	testCases := []struct {
+		name           string
+		requested      string
+		nodeConfigType string
+		defaultType    string
+		instanceType   string
+		source         string
+	}{
+		{name: "request wins", requested: "m5.2xlarge", nodeConfigType: "m5.large", defaultType: "m5.xlarge", instanceType: "m5.2xlarge", source: instanceTypeFromRequest},
+		{name: "node config over default", nodeConfigType: "m5.large", defaultType: "m5.xlarge", instanceType: "m5.large", source: instanceTypeFromNodeConfig},
+		{name: "config default", defaultType: "m5.xlarge", instanceType: "m5.xlarge", source: instanceTypeFromConfigDefault},
+		{name: "none"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			instanceType, source := chooseInstanceType(tc.requested, tc.nodeConfigType, tc.defaultType)
+			assert.Equal(t, tc.instanceType, instanceType)
+			assert.Equal(t, tc.source, source)
+		})
+	}
+}
+
+func TestDefaultInstanceType(t *testing.T) {
+	origConfig := clusterConfig
+	t.Cleanup(func() { clusterConfig = origConfig })
+
+	clusterConfig = nil
+	assert.Empty(t, defaultInstanceType("worker"))
+
+	clusterConfig = &config.Config{DefaultInstanceTypes: map[string]string{"controlplane": "m5.large", "worker": "m5.xlarge"}}
+	assert.Equal(t, "m5.xlarge", defaultInstanceType("worker"))
+	assert.Empty(t, defaultInstanceType("ingress"))
+}
@@ -0,0 +1,260 @@
+package k8sctl
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+	"github.com/siderolabs/talos/pkg/machinery/api/common"
+	"github.com/siderolabs/talos/pkg/machinery/client"
+	"github.com/siderolabs/talos/pkg/machinery/constants"
+	"github.com/sirupsen/logrus"
+)
+
+// Node log sources.  The console is read from EC2, so it's there even when Talos never came up.  The others are read
+// from the node's Talos API.
+const (
+	LogSourceConsole = "console"
+	LogSourceDmesg   = "dmesg"
+	LogSourceService = "service"
+)
+
+// NodeLogsBody says which of a node's logs to fetch.
+type NodeLogsBody struct {
+	Source    string `json:"source,omitempty"`     // console (default), dmesg, or service
+	Service   string `json:"service,omitempty"`    // the Talos service whose logs to fetch, e.g. kubelet, for the service source
+	Follow    bool   `json:"follow,omitempty"`     // keep streaming new lines, for dmesg and service logs
+	TailLines int    `json:"tail_lines,omitempty"` // only the last lines of service logs, default all of them
+	Verbose   bool   `json:"verbose"`
+	Region    string `json:"region,omitempty"`
+}
+
+// consoleOutputGetter is the part of the EC2 API that reads an instance's serial console.  The cluster manager's EC2
+// client interface doesn't include it, but the real EC2 client has it.
+type consoleOutputGetter interface {
+	GetConsoleOutput(ctx context.Context, params *ec2.GetConsoleOutputInput, optFns ...func(*ec2.Options)) (*ec2.GetConsoleOutputOutput, error)
+}
+
+// validateNodeLogs checks a node logs request, defaulting its source to the console.
+func validateNodeLogs(body *NodeLogsBody) (err error) {
+	if body.Source == "" {
+		body.Source = LogSourceConsole
+	}
+
+	switch body.Source {
+	case LogSourceConsole:
+		if body.Follow {
+			err = errors.New("the console can't be followed; follow dmesg or service logs instead")
+		}
+	case LogSourceDmesg:
+	case LogSourceService:
+		if body.Service == "" {
+			err = errors.New("service logs need the service to read, e.g. kubelet")
+		}
+	default:
+		err = errors.New(fmt.Sprintf("unknown log source %q: use %s, %s, or %s", body.Source, LogSourceConsole, LogSourceDmesg, LogSourceService))
+	}
+
+	if err == nil && body.TailLines < 0 {
+		err = errors.New("tail lines can't be negative")
+	}
+
+	return err
+}
+
+// NodeLogsHandler streams a node's logs as plain text: its EC2 serial console output, or its kernel or a service's
+// logs from Talos.  The console is for nodes that never came up far enough to answer the Talos API.  Followed logs
+// stream until the client disconnects.
+func (c *K8sCtlCommands) NodeLogsHandler(ctx *gin.Context) {
+	clusterName := ctx.Param("cluster")
+	nodeName := ctx.Param("node")
+
+	var body NodeLogsBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err = validateNodeLogs(&body)
+	if err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	logrus.Infof("fetching %s logs of node %s in cluster %s", body.Source, nodeName, clusterName)
+
+	cm, err := newClusterManager(ctx, clusterName, body.Region, body.Verbose)
+	if err != nil {
+		logrus.Errorf("Failed creating cluster manager: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	var logs io.ReadCloser
+	if body.Source == LogSourceConsole {
+		logs, err = consoleOutput(ctx, cm, nodeName)
+	} else {
+		logs, err = talosLogs(ctx.Request.Context(), cm, nodeName, body)
+	}
+
+	if err != nil {
+		logrus.Errorf("failed fetching %s logs of node %s: %s", body.Source, nodeName, err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	defer logs.Close()
+
+	ctx.Writer.Header().Set("Content-Type", "text/plain")
+	ctx.Writer.Header().Set("Transfer-Encoding", "chunked")
+	ctx.Writer.WriteHeader(http.StatusOK)
+
+	err = copyFlushing(ctx, logs)
+	if err != nil && ctx.Request.Context().Err() == nil {
+		// The status is already sent, so the failure can only be reported in the output
+		logrus.Warnf("streaming %s logs of node %s failed: %s", body.Source, nodeName, err)
+		writeOutput(ctx, fmt.Sprintf("\nerror: %s\n", err))
+	}
+}
+
+// consoleOutput reads a node's most recent EC2 serial console output.
+func consoleOutput(ctx context.Context, cm *aws.AWSClusterManager, nodeName string) (logs io.ReadCloser, err error) {
+	getter, ok := cm.Ec2Client.(consoleOutputGetter)
+	if !ok {
+		err = errors.New("the EC2 client can't read console output")
+		return logs, err
+	}
+
+	nodeInfo, err := cm.GetNode(nodeName)
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting node %s", nodeName)
+		return logs, err
+	}
+
+	output, err := getter.GetConsoleOutput(ctx, &ec2.GetConsoleOutputInput{
+		InstanceId: awssdk.String(nodeInfo.ID),
+		Latest:     awssdk.Bool(true),
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting console output of node %s (%s)", nodeName, nodeInfo.ID)
+		return logs, err
+	}
+
+	text, err := decodeConsoleOutput(awssdk.ToString(output.Output))
+	if err != nil {
+		err = errors.Wrapf(err, "failed decoding console output of node %s (%s)", nodeName, nodeInfo.ID)
+		return logs, err
+	}
+
+	if text == "" {
+		text = fmt.Sprintf("no console output from %s (%s) yet\n", nodeName, nodeInfo.ID)
+	}
+
+	logs = io.NopCloser(strings.NewReader(text))
+	return logs, err
+}
+
+// decodeConsoleOutput decodes EC2's base64 encoding of console output.
+func decodeConsoleOutput(encoded string) (text string, err error) {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return text, err
+	}
+
+	text = string(decoded)
+	return text, err
+}
+
+// talosLogs streams a node's kernel log, or a service's log, from its Talos API.  The stream ends with the context,
+// as well as at the end of the logs when they're not followed.
+func talosLogs(ctx context.Context, cm *aws.AWSClusterManager, nodeName string, body NodeLogsBody) (logs io.ReadCloser, err error) {
+	_, nodeIP, err := runningNodeAddress(cm, nodeName)
+	if err != nil {
+		return logs, err
+	}
+
+	tClient, err := newTalosClient(ctx, nodeIP)
+	if err != nil {
+		return logs, err
+	}
+
+	// Talos takes a negative tail to mean all the lines
+	tailLines := int32(-1)
+	if body.TailLines > 0 {
+		tailLines = int32(body.TailLines)
+	}
+
+	var stream client.MachineStream
+	if body.Source == LogSourceDmesg {
+		stream, err = tClient.Dmesg(ctx, body.Follow, false)
+	} else {
+		stream, err = tClient.Logs(ctx, constants.SystemContainerdNamespace, common.ContainerDriver_CONTAINERD, body.Service, body.Follow, tailLines)
+	}
+
+	if err != nil {
+		_ = tClient.Close()
+		err = errors.Wrapf(err, "failed reading %s logs of node %s", body.Source, nodeName)
+		return logs, err
+	}
+
+	reader, err := client.ReadStream(stream)
+	if err != nil {
+		_ = tClient.Close()
+		err = errors.Wrapf(err, "failed reading %s logs of node %s", body.Source, nodeName)
+		return logs, err
+	}
+
+	logs = &talosLogReader{ReadCloser: reader, tClient: tClient}
+	return logs, err
+}
+
+// talosLogReader closes the Talos client along with the log stream read through it.
+type talosLogReader struct {
+	io.ReadCloser
+	tClient *client.Client
+}
+
+// Close closes the stream, then the client.
+func (r *talosLogReader) Close() (err error) {
+	err = r.ReadCloser.Close()
+	_ = r.tClient.Close()
+
+	return err
+}
+
+// copyFlushing copies logs to the response, flushing after each read, so followed logs reach the client as they come.
+func copyFlushing(ctx *gin.Context, logs io.Reader) (err error) {
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, readErr := logs.Read(buf)
+		if n > 0 {
+			_, err = ctx.Writer.Write(buf[:n])
+			if err != nil {
+				return err
+			}
+			ctx.Writer.Flush()
+		}
+
+		if readErr == io.EOF {
+			return err
+		}
+
+		if readErr != nil {
+			err = readErr
+			return err
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package k8sctl
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateNodeLogs(t *testing.T) {
+	body := NodeLogsBody{}
+	require.NoError(t, validateNodeLogs(&body))
+	assert.Equal(t, LogSourceConsole, body.Source)
+
+	assert.NoError(t, validateNodeLogs(&NodeLogsBody{Source: LogSourceDmesg, Follow: true}))
+	assert.NoError(t, validateNodeLogs(&NodeLogsBody{Source: LogSourceService, Service: "kubelet", Follow: true, TailLines: 100}))
+
+	assert.Error(t, validateNodeLogs(&NodeLogsBody{Follow: true}))
+	assert.Error(t, validateNodeLogs(&NodeLogsBody{Source: LogSourceService}))
+	assert.Error(t, validateNodeLogs(&NodeLogsBody{Source: "journal"}))
+	assert.Error(t, validateNodeLogs(&NodeLogsBody{Source: LogSourceService, Service: "kubelet", TailLines: -1}))
+}
+
+func TestDecodeConsoleOutput(t *testing.T) {
+	text, err := decodeConsoleOutput(base64.StdEncoding.EncodeToString([]byte("[    0.000000] Linux version 6.12\n")))
+	require.NoError(t, err)
+	assert.Equal(t, "[    0.000000] Linux version 6.12\n", text)
+
+	text, err = decodeConsoleOutput("")
+	require.NoError(t, err)
+	assert.Empty(t, text)
+
+	_, err = decodeConsoleOutput("not base64!")
+	require.Error(t, err)
+}
@@ -0,0 +1,216 @@
+package k8sctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gin-gonic/gin"
+	k8s_utility_client "github.com/nikogura/k8s-utility-client/pkg/k8s-utility-client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NodePodsBody is the request to list the pods on a node.
+type NodePodsBody struct {
+	Verbose bool `json:"verbose"`
+}
+
+// NodePod is a pod on a node, with what decides whether it can safely be moved off it.
+type NodePod struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Phase     string `json:"phase"`
+	OwnerKind string `json:"owner_kind"` // the kind of the pod's controller, e.g. ReplicaSet, or empty if it has none
+	Owner     string `json:"owner"`
+	// PDBs are the PodDisruptionBudgets covering the pod, which a drain has to honour.
+	PDBs []string `json:"pdbs,omitempty"`
+	// LocalStorage are the pod's emptyDir and hostPath volumes, whose data stays behind if the pod moves.
+	LocalStorage []string `json:"local_storage,omitempty"`
+	// Evictable is whether a drain evicts the pod.  DaemonSet and mirror pods are left in place.
+	Evictable bool `json:"evictable"`
+}
+
+// Unmanaged is whether nothing recreates the pod once it's gone: it has no controller, or is a mirror pod of a static
+// pod, which only exists on its node.
+func (p NodePod) Unmanaged() (unmanaged bool) {
+	unmanaged = p.OwnerKind == "" || p.OwnerKind == "Node"
+	return unmanaged
+}
+
+// NodePodsResult is the pods on a node.
+type NodePodsResult struct {
+	Node string    `json:"node"`
+	Pods []NodePod `json:"pods"`
+}
+
+// ConsolePrint prints a pod per line, flagging the ones that need a look before the node is deleted: those nothing
+// recreates, and those with local storage.
+func (r NodePodsResult) ConsolePrint() {
+	fmt.Printf("Pods on node %s: (%d)\n\n", r.Node, len(r.Pods))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "NAMESPACE\tNAME\tPHASE\tOWNER\tPDBS\tLOCAL STORAGE\tEVICTABLE\n")
+	for _, pod := range r.Pods {
+		owner := "none"
+		if pod.OwnerKind != "" {
+			owner = fmt.Sprintf("%s/%s", pod.OwnerKind, pod.Owner)
+		}
+
+		name := pod.Name
+		if pod.Unmanaged() || len(pod.LocalStorage) > 0 {
+			name = consoleMarkers.Warn + " " + name
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%t\n", pod.Namespace, name, pod.Phase, owner, listOrNone(pod.PDBs), listOrNone(pod.LocalStorage), pod.Evictable)
+	}
+	_ = w.Flush()
+}
+
+// listOrNone joins a list for a table cell, with "-" for an empty one.
+func listOrNone(items []string) (joined string) {
+	if len(items) == 0 {
+		joined = "-"
+		return joined
+	}
+
+	joined = strings.Join(items, ",")
+	return joined
+}
+
+// NodePodsHandler lists the pods on a node, with their owners, disruption budgets, and local storage, so it can be
+// checked what deleting or glassing the node would disrupt.
+func (c *K8sCtlCommands) NodePodsHandler(ctx *gin.Context) {
+	clusterName := ctx.Param("cluster")
+	nodeName := ctx.Param("node")
+
+	var body NodePodsBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	logrus.Infof("listing pods on node %s in cluster %s", nodeName, clusterName)
+
+	clients, err := k8s_utility_client.NewK8sClients()
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating k8s clients")
+		logrus.Errorf("%s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	_, err = clients.ClientSet.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if apierrors.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		err = errors.Wrapf(err, "failed getting node %s", nodeName)
+		logrus.Errorf("%s", err)
+		_ = ctx.AbortWithError(status, err)
+		return
+	}
+
+	pods, err := clients.ClientSet.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", nodeName),
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed listing pods on node %s", nodeName)
+		logrus.Errorf("%s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	pdbs, err := clients.ClientSet.PolicyV1().PodDisruptionBudgets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = errors.Wrapf(err, "failed listing pod disruption budgets")
+		logrus.Errorf("%s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	result := NodePodsResult{
+		Node: nodeName,
+		Pods: nodePods(pods.Items, pdbs.Items),
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// nodePods describes each pod, in namespace and name order.  Finished pods are left out, as they hold nothing a
+// delete would lose.
+func nodePods(pods []corev1.Pod, pdbs []policyv1.PodDisruptionBudget) (described []NodePod) {
+	described = make([]NodePod, 0, len(pods))
+
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		described = append(described, describePod(pod, pdbs))
+	}
+
+	sort.Slice(described, func(i, j int) bool {
+		if described[i].Namespace != described[j].Namespace {
+			return described[i].Namespace < described[j].Namespace
+		}
+		return described[i].Name < described[j].Name
+	})
+
+	return described
+}
+
+// describePod describes a pod, given the disruption budgets to check it against.
+func describePod(pod corev1.Pod, pdbs []policyv1.PodDisruptionBudget) (described NodePod) {
+	described = NodePod{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Phase:     string(pod.Status.Phase),
+		Evictable: evictable(pod),
+	}
+
+	if owner := metav1.GetControllerOf(&pod); owner != nil {
+		described.OwnerKind = owner.Kind
+		described.Owner = owner.Name
+	}
+
+	for _, pdb := range pdbs {
+		if pdb.Namespace != pod.Namespace || pdb.Spec.Selector == nil {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil {
+			continue
+		}
+
+		// An empty selector matches every pod in the namespace
+		if selector.Matches(labels.Set(pod.Labels)) {
+			described.PDBs = append(described.PDBs, pdb.Name)
+		}
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		switch {
+		case volume.EmptyDir != nil:
+			described.LocalStorage = append(described.LocalStorage, fmt.Sprintf("emptyDir:%s", volume.Name))
+		case volume.HostPath != nil:
+			described.LocalStorage = append(described.LocalStorage, fmt.Sprintf("hostPath:%s", volume.HostPath.Path))
+		}
+	}
+
+	return described
+}
@@ -0,0 +1,82 @@
+package k8sctl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func controlledBy(kind string, name string) (refs []metav1.OwnerReference) {
+	controller := true
+	refs = []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+	return refs
+}
+
+func TestNodePods(t *testing.T) {
+	pods := []corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "web-abc12", Labels: map[string]string{"app": "web"}, OwnerReferences: controlledBy("ReplicaSet", "web-5d8f")},
+			Spec: corev1.PodSpec{Volumes: []corev1.Volume{
+				{Name: "cache", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+				{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{}}},
+			}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "node-exporter-x1", OwnerReferences: controlledBy("DaemonSet", "node-exporter")},
+			Spec: corev1.PodSpec{Volumes: []corev1.Volume{
+				{Name: "proc", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/proc"}}},
+			}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "debug", Labels: map[string]string{"app": "debug"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodPending},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "migrate-z9", OwnerReferences: controlledBy("Job", "migrate")},
+			Status:     corev1.PodStatus{Phase: corev1.PodSucceeded},
+		},
+	}
+
+	pdbs := []policyv1.PodDisruptionBudget{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "web"}, Spec: policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "everything"}, Spec: policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "web"}, Spec: policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "no-selector"}},
+	}
+
+	described := nodePods(pods, pdbs)
+
+	// The finished job pod is left out, and the rest sorted by namespace and name
+	require.Len(t, described, 3)
+
+	exporter := described[0]
+	assert.Equal(t, "node-exporter-x1", exporter.Name)
+	assert.Equal(t, "DaemonSet", exporter.OwnerKind)
+	assert.Equal(t, []string{"hostPath:/proc"}, exporter.LocalStorage)
+	assert.Empty(t, exporter.PDBs)
+	assert.False(t, exporter.Evictable)
+	assert.False(t, exporter.Unmanaged())
+
+	debug := described[1]
+	assert.Equal(t, "debug", debug.Name)
+	assert.Equal(t, "", debug.OwnerKind)
+	assert.Equal(t, []string{"everything"}, debug.PDBs)
+	assert.True(t, debug.Evictable)
+	assert.True(t, debug.Unmanaged())
+
+	web := described[2]
+	assert.Equal(t, "web-abc12", web.Name)
+	assert.Equal(t, "ReplicaSet", web.OwnerKind)
+	assert.Equal(t, "web-5d8f", web.Owner)
+	assert.Equal(t, "Running", web.Phase)
+	assert.Equal(t, []string{"web", "everything"}, web.PDBs)
+	assert.Equal(t, []string{"emptyDir:cache"}, web.LocalStorage)
+	assert.True(t, web.Evictable)
+	assert.False(t, web.Unmanaged())
+}
@@ -0,0 +1,301 @@
+package k8sctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	k8s_utility_client "github.com/nikogura/k8s-utility-client/pkg/k8s-utility-client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeReconcileBody is the request to reconcile one node.
+type NodeReconcileBody struct {
+	Verbose bool   `json:"verbose"`
+	Region  string `json:"region,omitempty"`
+}
+
+// NodeReconcileResult is one node's state in EC2, Kubernetes, and the load balancers, with the same discrepancies a
+// cluster reconcile looks for, scoped to the node.
+type NodeReconcileResult struct {
+	Node string `json:"node"`
+	ID   string `json:"id,omitempty"`
+	Role string `json:"role"`
+
+	InEC2      bool   `json:"in_ec2"`                // a running instance has the node's Name tag
+	ClusterTag string `json:"cluster_tag,omitempty"` // the instance's Cluster tag, "" if it has none
+	Tagged     bool   `json:"tagged"`                // the Cluster tag names this cluster
+	InK8s      bool   `json:"in_k8s"`
+	Ready      bool   `json:"ready"`
+
+	// ExpectedLBs are the load balancers a node of its role is registered with.  MissingFromLBs are those it isn't a
+	// target of, and UnhealthyTargets its targets that aren't healthy.
+	ExpectedLBs      []string          `json:"expected_lbs"`
+	MissingFromLBs   []string          `json:"missing_from_lbs,omitempty"`
+	UnhealthyTargets []UnhealthyTarget `json:"unhealthy_targets,omitempty"`
+
+	Issues           []string `json:"issues,omitempty"`
+	Message          string   `json:"message"`
+	TotalIssuesFound int      `json:"total_issues_found"`
+}
+
+// ConsolePrint prints each check, flagging the ones that failed, then the issues found.
+func (r NodeReconcileResult) ConsolePrint() {
+	fmt.Printf("Node %s (%s, %s)\n\n", r.Node, r.Role, orNone(r.ID))
+
+	check := func(ok bool, format string, args ...interface{}) {
+		marker := consoleMarkers.OK
+		if !ok {
+			marker = consoleMarkers.Error
+		}
+		fmt.Printf("  %s %s\n", marker, fmt.Sprintf(format, args...))
+	}
+
+	check(r.InEC2, "in EC2")
+	if r.InEC2 {
+		check(r.Tagged, "Cluster tag: %s", orNone(r.ClusterTag))
+	}
+	check(r.InK8s, "in Kubernetes")
+	if r.InK8s {
+		check(r.Ready, "Ready")
+	}
+	if r.InEC2 {
+		check(len(r.MissingFromLBs) == 0, "in load balancers: %s", listOrNone(r.ExpectedLBs))
+		check(len(r.UnhealthyTargets) == 0, "load balancer targets healthy")
+	}
+
+	for _, target := range r.UnhealthyTargets {
+		fmt.Printf("      %s\n", target.Summary())
+	}
+
+	fmt.Printf("\n%s\n", r.Message)
+	for _, issue := range r.Issues {
+		fmt.Printf("  %s %s\n", consoleMarkers.Warn, issue)
+	}
+}
+
+// orNone returns the value, or "none" if it's empty.
+func orNone(value string) (s string) {
+	s = value
+	if s == "" {
+		s = "none"
+	}
+
+	return s
+}
+
+// ReconcileNodeHandler checks one node: that it's in EC2 with the cluster's Cluster tag, a Ready Kubernetes node, and a
+// healthy target of the load balancers its role belongs in.  It's the cluster reconcile for a single suspect node,
+// without describing the whole cluster.
+func (c *K8sCtlCommands) ReconcileNodeHandler(ctx *gin.Context) {
+	clusterName := ctx.Param("cluster")
+	nodeName := ctx.Param("node")
+
+	logrus.Infof("reconciling node %s in cluster %s", nodeName, clusterName)
+
+	var body NodeReconcileBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	cm, err := newClusterManager(ctx, clusterName, body.Region, body.Verbose)
+	if err != nil {
+		logrus.Errorf("Failed creating cluster manager: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	result, err := reconcileNode(ctx, cm, nodeName)
+	if err != nil {
+		logrus.Errorf("Failed reconciling node %s: %s", nodeName, err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// reconcileNode gathers the node's state in EC2, Kubernetes, and the load balancers, and the discrepancies in it.
+func reconcileNode(ctx context.Context, cm *aws.AWSClusterManager, nodeName string) (result NodeReconcileResult, err error) {
+	result = NodeReconcileResult{
+		Node: nodeName,
+		Role: inferNodeRole(nodeName),
+	}
+
+	nodeInfo, err := cm.GetNode(nodeName)
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting node %s", nodeName)
+		return result, err
+	}
+
+	var instances []ec2types.Instance
+	if nodeInfo.ID != "" {
+		result.ID = nodeInfo.ID
+		result.InEC2 = true
+
+		instances, err = cm.GetEC2InstancesByNodeID(nodeInfo.ID)
+		if err != nil {
+			err = errors.Wrapf(err, "failed getting tags of node %s", nodeName)
+			return result, err
+		}
+	}
+
+	result.ClusterTag = previousTags(instances, map[string]string{aws.EC2TagCluster: ""})[aws.EC2TagCluster]
+
+	// The instance's role tag says which load balancers the node belongs in, over its name
+	if len(instances) > 0 && instanceRole(instances[0]) != "" {
+		result.Role = instanceRole(instances[0])
+	}
+
+	result.InK8s, result.Ready, err = k8sNodeReady(ctx, stripDomainSuffix(nodeName))
+	if err != nil {
+		return result, err
+	}
+
+	var lbs []manager.LBInfo
+	if result.InEC2 {
+		lbs, err = cm.GetClusterLBs()
+		if err != nil {
+			err = errors.Wrapf(err, "failed getting load balancers")
+			return result, err
+		}
+	}
+
+	checkNodeLBs(&result, lbs, cm.GetScheduleWorkloadsOnCPNodes())
+	addTargetHealthReasons(ctx, cm, lbs, result.UnhealthyTargets)
+
+	summarizeNodeReconcile(&result, cm.ClusterName())
+
+	return result, err
+}
+
+// k8sNodeReady returns whether there's a Kubernetes node of the name, and whether it's Ready.
+func k8sNodeReady(ctx context.Context, nodeName string) (exists bool, ready bool, err error) {
+	clients, err := k8s_utility_client.NewK8sClients()
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating k8s clients")
+		return exists, ready, err
+	}
+
+	node, err := clients.ClientSet.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		err = nil
+		return exists, ready, err
+	}
+
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting Kubernetes node %s", nodeName)
+		return exists, ready, err
+	}
+
+	exists = true
+
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return exists, ready, err
+}
+
+// checkNodeLBs fills in the load balancers the node should be a target of, those it's missing from, and its unhealthy
+// targets.  Control plane nodes belong in the API server load balancers, and workers in the rest, as do control plane
+// nodes if workloads are scheduled on them, the same as when a node is registered.
+func checkNodeLBs(result *NodeReconcileResult, lbs []manager.LBInfo, scheduleOnCP bool) {
+	result.ExpectedLBs = make([]string, 0)
+	shortName := stripDomainSuffix(result.Node)
+
+	for _, lb := range lbs {
+		controlPlane := result.Role == manager.NodeRoleCp
+		if lb.IsAPIServer && !controlPlane {
+			continue
+		}
+		if !lb.IsAPIServer && controlPlane && !scheduleOnCP {
+			continue
+		}
+
+		result.ExpectedLBs = append(result.ExpectedLBs, lb.Name)
+
+		found := false
+		for _, target := range lb.Targets {
+			if isNodeTarget(target.ID, target.Name, result.ID, shortName) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			result.MissingFromLBs = append(result.MissingFromLBs, lb.Name)
+		}
+	}
+
+	for _, target := range findUnhealthyTargets(lbs) {
+		if isNodeTarget(target.ID, target.Target, result.ID, shortName) {
+			result.UnhealthyTargets = append(result.UnhealthyTargets, target)
+		}
+	}
+}
+
+// isNodeTarget returns whether a load balancer target is the node's: its instance, or failing an ID, its name.
+func isNodeTarget(targetID string, targetName string, nodeID string, shortName string) (match bool) {
+	match = (nodeID != "" && targetID == nodeID) || stripDomainSuffix(targetName) == shortName
+	return match
+}
+
+// summarizeNodeReconcile checks the node's Cluster tag, then lists the node's issues, in the terms of a cluster reconcile's, and counts them.
+func summarizeNodeReconcile(result *NodeReconcileResult, clusterName string) {
+	result.Issues = nil
+	result.Tagged = result.InEC2 && result.ClusterTag == clusterName
+
+	switch {
+	case !result.InEC2 && !result.InK8s:
+		result.Issues = append(result.Issues, "no running EC2 instance or Kubernetes node has the name")
+	case !result.InEC2:
+		result.Issues = append(result.Issues, "in Kubernetes, but not in EC2 (k8s_not_in_ec2)")
+	case !result.InK8s:
+		result.Issues = append(result.Issues, "in EC2, but not in Kubernetes (ec2_not_in_k8s)")
+	}
+
+	if result.InEC2 && result.ClusterTag == "" {
+		result.Issues = append(result.Issues, "missing its Cluster tag (untagged_nodes)")
+	}
+
+	if result.InEC2 && result.ClusterTag != "" && !result.Tagged {
+		result.Issues = append(result.Issues, fmt.Sprintf("Cluster tag is %q, not %q (untagged_nodes)", result.ClusterTag, clusterName))
+	}
+
+	if result.InK8s && !result.Ready {
+		result.Issues = append(result.Issues, "Kubernetes node isn't Ready")
+	}
+
+	for _, lb := range result.MissingFromLBs {
+		result.Issues = append(result.Issues, fmt.Sprintf("not a target of load balancer %s (ec2_not_in_lb)", lb))
+	}
+
+	for _, target := range result.UnhealthyTargets {
+		result.Issues = append(result.Issues, fmt.Sprintf("unhealthy load balancer target %s", target.Summary()))
+	}
+
+	result.TotalIssuesFound = len(result.Issues)
+
+	if result.TotalIssuesFound == 0 {
+		result.Message = "No discrepancies found - node state is consistent"
+		return
+	}
+
+	result.Message = fmt.Sprintf("Found %d issue(s) with node %s", result.TotalIssuesFound, result.Node)
+}
@@ -0,0 +1,74 @@
+package k8sctl
+
+import (
+	"testing"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckNodeLBs(t *testing.T) {
+	lbs := []manager.LBInfo{
+		{Name: "cluster1-apiserver", IsAPIServer: true, Targets: []manager.LBTargetInfo{
+			{ID: "i-cp1", Name: "cluster1-cp-1.example.com", Port: 6443, State: "healthy"},
+		}},
+		{Name: "cluster1-ingress", Targets: []manager.LBTargetInfo{
+			{ID: "i-w1", Name: "cluster1-worker-1.example.com", Port: 443, State: "unhealthy"},
+			{ID: "i-w1", Name: "cluster1-worker-1.example.com", Port: 80, State: "healthy"},
+		}},
+	}
+
+	worker := NodeReconcileResult{Node: "cluster1-worker-1.example.com", ID: "i-w1", Role: manager.NodeRoleWorker}
+	checkNodeLBs(&worker, lbs, false)
+	assert.Equal(t, []string{"cluster1-ingress"}, worker.ExpectedLBs)
+	assert.Empty(t, worker.MissingFromLBs)
+	assert.Len(t, worker.UnhealthyTargets, 1)
+	assert.Equal(t, int32(443), worker.UnhealthyTargets[0].Port)
+
+	controlPlane := NodeReconcileResult{Node: "cluster1-cp-1.example.com", ID: "i-cp1", Role: manager.NodeRoleCp}
+	checkNodeLBs(&controlPlane, lbs, false)
+	assert.Equal(t, []string{"cluster1-apiserver"}, controlPlane.ExpectedLBs)
+	assert.Empty(t, controlPlane.MissingFromLBs)
+
+	// Scheduling workloads on the control plane puts it in the other load balancers too
+	controlPlane = NodeReconcileResult{Node: "cluster1-cp-1.example.com", ID: "i-cp1", Role: manager.NodeRoleCp}
+	checkNodeLBs(&controlPlane, lbs, true)
+	assert.Equal(t, []string{"cluster1-apiserver", "cluster1-ingress"}, controlPlane.ExpectedLBs)
+	assert.Equal(t, []string{"cluster1-ingress"}, controlPlane.MissingFromLBs)
+
+	// A new worker, registered with nothing
+	missing := NodeReconcileResult{Node: "cluster1-worker-2", ID: "i-w2", Role: manager.NodeRoleWorker}
+	checkNodeLBs(&missing, lbs, false)
+	assert.Equal(t, []string{"cluster1-ingress"}, missing.MissingFromLBs)
+	assert.Empty(t, missing.UnhealthyTargets)
+}
+
+func TestSummarizeNodeReconcile(t *testing.T) {
+	healthy := NodeReconcileResult{Node: "cluster1-worker-1", ID: "i-w1", InEC2: true, ClusterTag: "cluster1", InK8s: true, Ready: true}
+	summarizeNodeReconcile(&healthy, "cluster1")
+	assert.True(t, healthy.Tagged)
+	assert.Empty(t, healthy.Issues)
+	assert.Equal(t, 0, healthy.TotalIssuesFound)
+	assert.Equal(t, "No discrepancies found - node state is consistent", healthy.Message)
+
+	orphan := NodeReconcileResult{Node: "cluster1-worker-2", ID: "i-w2", InEC2: true, MissingFromLBs: []string{"cluster1-ingress"}}
+	summarizeNodeReconcile(&orphan, "cluster1")
+	assert.False(t, orphan.Tagged)
+	assert.Equal(t, []string{
+		"in EC2, but not in Kubernetes (ec2_not_in_k8s)",
+		"missing its Cluster tag (untagged_nodes)",
+		"not a target of load balancer cluster1-ingress (ec2_not_in_lb)",
+	}, orphan.Issues)
+	assert.Equal(t, 3, orphan.TotalIssuesFound)
+
+	mistagged := NodeReconcileResult{Node: "cluster1-worker-3", ID: "i-w3", InEC2: true, ClusterTag: "cluster2", InK8s: true}
+	summarizeNodeReconcile(&mistagged, "cluster1")
+	assert.Equal(t, []string{
+		`Cluster tag is "cluster2", not "cluster1" (untagged_nodes)`,
+		"Kubernetes node isn't Ready",
+	}, mistagged.Issues)
+
+	gone := NodeReconcileResult{Node: "cluster1-worker-4", InK8s: true, Ready: true}
+	summarizeNodeReconcile(&gone, "cluster1")
+	assert.Equal(t, []string{"in Kubernetes, but not in EC2 (k8s_not_in_ec2)"}, gone.Issues)
+}
@@ -0,0 +1,76 @@
+package k8sctl
+
+import (
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+)
+
+// nodeRoleTag is the EC2 tag a node's role is recorded under when k8sctl creates it, namespaced like
+// nodeTargetGroupsTag, so a plain role tag set for something else isn't taken for it.  The cluster manager's NodeInfo
+// has no role, so it's read from the instance's tags.
+const nodeRoleTag = "k8sctl:role"
+
+// instanceRole returns the role an instance's role tag records, or "" if it has none, or one that isn't a node role.
+func instanceRole(instance ec2types.Instance) (role string) {
+	for _, tag := range instance.Tags {
+		if awssdk.ToString(tag.Key) != nodeRoleTag {
+			continue
+		}
+
+		switch value := awssdk.ToString(tag.Value); value {
+		case manager.NodeRoleCp, manager.NodeRoleWorker:
+			role = value
+		}
+	}
+
+	return role
+}
+
+// nodeRole returns a node's role, from its instance's role tag, or if the instance has none, or wasn't found, guessed
+// from the node's name.
+func nodeRole(node manager.NodeInfo, instances map[string]ec2types.Instance) (role string) {
+	role = instanceRole(instances[node.ID])
+	if role == "" {
+		role = inferNodeRole(node.Name)
+	}
+
+	return role
+}
+
+// nodeRoles returns the nodes' roles, by name without the domain, from their instances' role tags.  Nodes without one
+// are left out, for roleOf to guess.
+func nodeRoles(nodes []manager.NodeInfo, instances map[string]ec2types.Instance) (roles map[string]string) {
+	roles = make(map[string]string, len(nodes))
+
+	for _, node := range nodes {
+		if role := instanceRole(instances[node.ID]); role != "" {
+			roles[stripDomainSuffix(node.Name)] = role
+		}
+	}
+
+	return roles
+}
+
+// roleOf returns a node's role from roles, by its name with or without the domain, or if it isn't there, as for
+// untagged instances and Kubernetes nodes not in EC2, guessed from its name.
+func roleOf(roles map[string]string, nodeName string) (role string) {
+	role = roles[stripDomainSuffix(nodeName)]
+	if role == "" {
+		role = inferNodeRole(nodeName)
+	}
+
+	return role
+}
+
+// describedRoles returns the roles a describe reported for the nodes' instances, by name without the domain, for
+// roleOf.
+func describedRoles(instances []NodeInstance) (roles map[string]string) {
+	roles = make(map[string]string, len(instances))
+
+	for _, instance := range instances {
+		roles[stripDomainSuffix(instance.Name)] = instance.Role
+	}
+
+	return roles
+}
@@ -0,0 +1,75 @@
+package k8sctl
+
+import (
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func roleTagged(role string) (instance ec2types.Instance) {
+	instance = ec2types.Instance{Tags: []ec2types.Tag{{Key: awssdk.String(nodeRoleTag), Value: awssdk.String(role)}}}
+	return instance
+}
+
+func TestNodeRole(t *testing.T) {
+	nodes := []manager.NodeInfo{
+		{Name: "cluster1-cpu-worker-1.example.com", ID: "i-1"}, // "cp" in its name, but tagged a worker
+		{Name: "cluster1-cp-1", ID: "i-2"},
+		{Name: "cluster1-cpu-worker-2", ID: "i-3"}, // untagged, so guessed from its name
+		{Name: "cluster1-worker-1", ID: "i-4"},     // a role tag that isn't a node role
+		{Name: "cluster1-worker-2", ID: "i-5"},     // no instance found
+		{Name: "cluster1-cpu-worker-3", ID: "i-6"}, // someone else's plain role tag, so guessed from its name
+	}
+
+	instances := map[string]ec2types.Instance{
+		"i-1": roleTagged(manager.NodeRoleWorker),
+		"i-2": roleTagged(manager.NodeRoleCp),
+		"i-3": {},
+		"i-4": roleTagged("web"),
+		"i-6": {Tags: []ec2types.Tag{{Key: awssdk.String("role"), Value: awssdk.String(manager.NodeRoleWorker)}}},
+	}
+
+	var roles []string
+	for _, node := range nodes {
+		roles = append(roles, nodeRole(node, instances))
+	}
+	assert.Equal(t, []string{manager.NodeRoleWorker, manager.NodeRoleCp, manager.NodeRoleCp, manager.NodeRoleWorker, manager.NodeRoleWorker, manager.NodeRoleCp}, roles)
+
+	tagged := nodeRoles(nodes, instances)
+	assert.Equal(t, map[string]string{"cluster1-cpu-worker-1": manager.NodeRoleWorker, "cluster1-cp-1": manager.NodeRoleCp}, tagged)
+
+	assert.Equal(t, manager.NodeRoleWorker, roleOf(tagged, "cluster1-cpu-worker-1"))
+	assert.Equal(t, manager.NodeRoleWorker, roleOf(tagged, "cluster1-cpu-worker-1.example.com"))
+	assert.Equal(t, manager.NodeRoleCp, roleOf(tagged, "cluster1-cpu-worker-2"))
+	assert.Equal(t, manager.NodeRoleCp, roleOf(nil, "cluster1-cp-2"))
+}
+
+func TestNodeMatchesRoleTag(t *testing.T) {
+	roles := map[string]string{"cluster1-cpu-worker-1": manager.NodeRoleWorker}
+
+	assert.True(t, nodeMatches("cluster1-cpu-worker-1.example.com", roles, manager.NodeRoleWorker, ""))
+	assert.False(t, nodeMatches("cluster1-cpu-worker-1", roles, manager.NodeRoleCp, ""))
+	assert.False(t, nodeMatches("cluster1-cpu-worker-1", nil, manager.NodeRoleWorker, ""))
+
+	info := manager.ClusterInfo{
+		Nodes: []manager.NodeInfo{{Name: "cluster1-cp-1"}, {Name: "cluster1-cpu-worker-1"}},
+		LoadBalancers: []manager.LBInfo{{Name: "cluster1-ingress", Targets: []manager.LBTargetInfo{
+			{Name: "cluster1-cp-1"},
+			{Name: "cluster1-cpu-worker-1.example.com"},
+		}}},
+	}
+
+	filtered := filterClusterInfo(info, roles, manager.NodeRoleWorker, "", false)
+	assert.Equal(t, []manager.NodeInfo{{Name: "cluster1-cpu-worker-1"}}, filtered.Nodes)
+	assert.Equal(t, []manager.LBTargetInfo{{Name: "cluster1-cpu-worker-1.example.com"}}, filtered.LoadBalancers[0].Targets)
+}
+
+func TestUpgradeOrderRoleTag(t *testing.T) {
+	nodes := []manager.NodeInfo{{Name: "cluster1-cpu-worker-1"}, {Name: "cluster1-cp-1"}, {Name: "cluster1-a-worker"}}
+	roles := map[string]string{"cluster1-cpu-worker-1": manager.NodeRoleWorker}
+
+	assert.Equal(t, []string{"cluster1-cp-1", "cluster1-a-worker", "cluster1-cpu-worker-1"}, nodeNames(upgradeOrder(nodes, roles, true)))
+}
@@ -0,0 +1,167 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// maxNodeTags is how many tags a create may add.  EC2 allows 50 per instance, the cluster manager sets Name and
+// Cluster, and k8sctl role, and with extra target groups, those.
+const maxNodeTags = 46
+
+// ec2TagPattern is the characters EC2 allows in tag keys and values.
+var ec2TagPattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// taggingEC2Client launches instances with extra tags, on top of the Name and Cluster tags the cluster manager sets.
+// The cluster manager builds its RunInstances requests itself, so this is how a node create adds its own.
+type taggingEC2Client struct {
+	aws.Ec2Client
+	tags     map[string]string
+	launched bool // an instance was launched, so a create that fails afterwards has left one behind
+}
+
+// RunInstances launches the instances with the extra tags, noting whether any were.
+func (c *taggingEC2Client) RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (output *ec2.RunInstancesOutput, err error) {
+	extra := make([]ec2types.Tag, 0, len(c.tags))
+	for _, key := range sortedKeys(c.tags) {
+		extra = append(extra, ec2types.Tag{Key: awssdk.String(key), Value: awssdk.String(c.tags[key])})
+	}
+
+	tagged := false
+	for i, spec := range params.TagSpecifications {
+		if spec.ResourceType == ec2types.ResourceTypeInstance {
+			params.TagSpecifications[i].Tags = append(spec.Tags, extra...)
+			tagged = true
+		}
+	}
+
+	if !tagged {
+		params.TagSpecifications = append(params.TagSpecifications, ec2types.TagSpecification{ResourceType: ec2types.ResourceTypeInstance, Tags: extra})
+	}
+
+	output, err = c.Ec2Client.RunInstances(ctx, params, optFns...)
+	if err == nil && output != nil && len(output.Instances) > 0 {
+		c.launched = true
+	}
+
+	return output, err
+}
+
+// validateNodeTags checks the extra EC2 tags for a node create.  The Name, Cluster, purpose, role, and target groups
+// tags are k8sctl's own, and aws: tags are AWS's, so they can't be given.
+func validateNodeTags(tags map[string]string) (err error) {
+	if len(tags) > maxNodeTags {
+		err = errors.New(fmt.Sprintf("%d tags given, but at most %d can be added to a node", len(tags), maxNodeTags))
+		return err
+	}
+
+	for _, key := range sortedKeys(tags) {
+		value := tags[key]
+
+		switch {
+		case key == "" || len(key) > 128:
+			err = errors.New(fmt.Sprintf("invalid tag key %q: must be 1 to 128 characters", key))
+		case len(value) > 256:
+			err = errors.New(fmt.Sprintf("invalid value for tag %s: must be at most 256 characters", key))
+		case !ec2TagPattern.MatchString(key) || !ec2TagPattern.MatchString(value):
+			err = errors.New(fmt.Sprintf("invalid tag %s=%q: only letters, numbers, spaces, and _ . : / = + - @ are allowed", key, value))
+		case strings.HasPrefix(strings.ToLower(key), "aws:"):
+			err = errors.New(fmt.Sprintf("invalid tag key %q: the aws: prefix is reserved by AWS", key))
+		case key == aws.EC2TagName || key == aws.EC2TagCluster || key == purposeKey:
+			err = errors.New(fmt.Sprintf("tag %s is set by k8sctl itself: use the node name, the cluster, or purpose", key))
+		case key == nodeTargetGroupsTag:
+			err = errors.New(fmt.Sprintf("tag %s is set by k8sctl itself: use target_groups", key))
+		case key == nodeRoleTag:
+			err = errors.New(fmt.Sprintf("tag %s is set by k8sctl itself: use the node role", key))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// validateNodeLabels checks the Kubernetes labels for a node create.  The purpose label is set by purpose, and the
+// kubernetes.io and k8s.io namespaces are refused, since the node's kubelet may not set most labels in them.
+func validateNodeLabels(labels map[string]string) (err error) {
+	for _, key := range sortedKeys(labels) {
+		value := labels[key]
+
+		if problems := validation.IsQualifiedName(key); len(problems) > 0 {
+			err = errors.New(fmt.Sprintf("invalid label key %q: %s", key, strings.Join(problems, "; ")))
+			return err
+		}
+
+		if problems := validation.IsValidLabelValue(value); len(problems) > 0 {
+			err = errors.New(fmt.Sprintf("invalid value %q for label %s: %s", value, key, strings.Join(problems, "; ")))
+			return err
+		}
+
+		if key == purposeKey {
+			err = errors.New(fmt.Sprintf("label %s is set by purpose", key))
+			return err
+		}
+
+		prefix, _, found := strings.Cut(key, "/")
+		if found && isKubernetesNamespace(prefix) {
+			err = errors.New(fmt.Sprintf("invalid label key %q: the %s namespace is reserved for Kubernetes", key, prefix))
+			return err
+		}
+	}
+
+	return err
+}
+
+// isKubernetesNamespace returns whether a label prefix is in the kubernetes.io or k8s.io namespaces.
+func isKubernetesNamespace(prefix string) (reserved bool) {
+	for _, domain := range []string{"kubernetes.io", "k8s.io"} {
+		if prefix == domain || strings.HasSuffix(prefix, "."+domain) {
+			reserved = true
+			return reserved
+		}
+	}
+
+	return reserved
+}
+
+// nodeLabelsPatch is a machine config patch setting the node's Kubernetes labels, applied after the role's own
+// patches, so the node registers with them.
+func nodeLabelsPatch(labels map[string]string) (patch string, err error) {
+	patchBytes, err := yaml.Marshal(map[string]any{
+		"machine": map[string]any{
+			"nodeLabels": labels,
+		},
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed building node labels patch")
+		return patch, err
+	}
+
+	patch = string(patchBytes)
+	return patch, err
+}
+
+// sortedKeys returns a map's keys, sorted.
+func sortedKeys(m map[string]string) (keys []string) {
+	keys = make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
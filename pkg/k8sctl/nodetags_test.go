@@ -0,0 +1,95 @@
+package k8sctl
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTaggingEC2Client(t *testing.T) {
+	fake := &fakeRunEC2Client{}
+	client := &taggingEC2Client{Ec2Client: fake, tags: map[string]string{"team": "platform", "cost-center": "1234"}}
+
+	_, err := client.RunInstances(context.Background(), &ec2.RunInstancesInput{
+		TagSpecifications: []ec2types.TagSpecification{{
+			ResourceType: ec2types.ResourceTypeInstance,
+			Tags:         []ec2types.Tag{{Key: awssdk.String("Name"), Value: awssdk.String("cluster1-worker-4")}},
+		}},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, fake.input.TagSpecifications, 1)
+	tags := make(map[string]string)
+	for _, tag := range fake.input.TagSpecifications[0].Tags {
+		tags[awssdk.ToString(tag.Key)] = awssdk.ToString(tag.Value)
+	}
+	assert.Equal(t, map[string]string{"Name": "cluster1-worker-4", "team": "platform", "cost-center": "1234"}, tags)
+
+	// Without an instance tag specification, one's added
+	_, err = client.RunInstances(context.Background(), &ec2.RunInstancesInput{})
+	require.NoError(t, err)
+	require.Len(t, fake.input.TagSpecifications, 1)
+	assert.Len(t, fake.input.TagSpecifications[0].Tags, 2)
+}
+
+func TestValidateNodeTags(t *testing.T) {
+	assert.NoError(t, validateNodeTags(nil))
+	assert.NoError(t, validateNodeTags(map[string]string{"team": "platform", "cost-center": "1234", "owner": "sre@example.com", "empty": ""}))
+
+	for _, tags := range []map[string]string{
+		{"": "x"},
+		{strings.Repeat("k", 129): "x"},
+		{"team": strings.Repeat("v", 257)},
+		{"team": "plat#form"},
+		{"aws:cloudformation:stack-name": "x"},
+		{"Name": "other"},
+		{"Cluster": "other"},
+		{"purpose": "ingress"},
+		{nodeTargetGroupsTag: "canary-443"},
+		{nodeRoleTag: "worker"},
+	} {
+		assert.Error(t, validateNodeTags(tags), "%v", tags)
+	}
+
+	tooMany := make(map[string]string)
+	for i := range maxNodeTags + 1 {
+		tooMany[strings.Repeat("k", i+1)] = "x"
+	}
+	assert.Error(t, validateNodeTags(tooMany))
+}
+
+func TestValidateNodeLabels(t *testing.T) {
+	assert.NoError(t, validateNodeLabels(nil))
+	assert.NoError(t, validateNodeLabels(map[string]string{"team": "platform", "example.com/cost-center": "1234", "spare": ""}))
+
+	for _, labels := range []map[string]string{
+		{"-team": "platform"},
+		{"team": "plat form"},
+		{"team": strings.Repeat("v", 64)},
+		{"purpose": "ingress"},
+		{"node-role.kubernetes.io/ingress": ""},
+		{"k8s.io/team": "platform"},
+	} {
+		assert.Error(t, validateNodeLabels(labels), "%v", labels)
+	}
+}
+
+func TestNodeLabelsPatch(t *testing.T) {
+	patch, err := nodeLabelsPatch(map[string]string{"team": "platform", "example.com/cost-center": "1234"})
+	require.NoError(t, err)
+
+	var parsed struct {
+		Machine struct {
+			NodeLabels map[string]string `yaml:"nodeLabels"`
+		} `yaml:"machine"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(patch), &parsed))
+	assert.Equal(t, map[string]string{"team": "platform", "example.com/cost-center": "1234"}, parsed.Machine.NodeLabels)
+}
@@ -0,0 +1,336 @@
+package k8sctl
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// APIPathPrefix is the prefix the authenticated API routes are served under.
+const APIPathPrefix = "/v1"
+
+// openAPIVersion is the version of the OpenAPI specification the generated spec conforms to.
+const openAPIVersion = "3.0.3"
+
+// OpenAPISpec is an OpenAPI 3 document.  Only the parts of the spec k8sctl uses are modelled.
+type OpenAPISpec struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       OpenAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                      `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIOperation struct {
+	Summary     string                     `json:"summary,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]*OpenAPISchema        `json:"schemas"`
+	SecuritySchemes map[string]OpenAPISecurityScheme `json:"securitySchemes"`
+}
+
+type OpenAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// OpenAPISchema is a JSON schema, as used by OpenAPI.  An empty schema accepts any value.
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// bearerAuthScheme is the name of the security scheme for the OIDC bearer token.
+const bearerAuthScheme = "bearerAuth"
+
+// GenerateOpenAPISpec builds an OpenAPI spec for the given routes.
+// Request and response schemas are derived from the route's Go types by reflection, following their json tags, so
+// the spec stays in step with the structs the handlers actually decode and encode.
+func GenerateOpenAPISpec(routes []APIRoute) (spec OpenAPISpec) {
+	spec = OpenAPISpec{
+		OpenAPI: openAPIVersion,
+		Info: OpenAPIInfo{
+			Title:   "k8sctl",
+			Version: strings.TrimPrefix(APIPathPrefix, "/"),
+		},
+		Paths: make(map[string]map[string]OpenAPIOperation),
+		Components: OpenAPIComponents{
+			Schemas: make(map[string]*OpenAPISchema),
+			SecuritySchemes: map[string]OpenAPISecurityScheme{
+				bearerAuthScheme: {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+				},
+			},
+		},
+	}
+
+	gen := schemaGenerator{
+		schemas: spec.Components.Schemas,
+		names:   make(map[string]reflect.Type),
+	}
+
+	for _, route := range routes {
+		path, params := openAPIPath(APIPathPrefix + route.Path)
+
+		op := OpenAPIOperation{
+			Summary:    route.Summary,
+			Parameters: params,
+			Responses:  make(map[string]OpenAPIResponse),
+			Security:   []map[string][]string{{bearerAuthScheme: {}}},
+		}
+
+		if route.Request != nil {
+			op.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content: map[string]OpenAPIMediaType{
+					"application/json": {Schema: gen.schemaFor(reflect.TypeOf(route.Request))},
+				},
+			}
+		}
+
+		ok := OpenAPIResponse{Description: "Success"}
+
+		switch {
+		case route.ContentType != "":
+			ok.Content = map[string]OpenAPIMediaType{
+				route.ContentType: {Schema: &OpenAPISchema{Type: "string"}},
+			}
+		case route.Response != nil:
+			ok.Content = map[string]OpenAPIMediaType{
+				"application/json": {Schema: gen.schemaFor(reflect.TypeOf(route.Response))},
+			}
+		}
+
+		op.Responses[fmt.Sprintf("%d", http.StatusOK)] = ok
+		op.Responses[fmt.Sprintf("%d", http.StatusUnauthorized)] = OpenAPIResponse{Description: "Missing or invalid bearer token"}
+
+		if route.Credentials {
+			op.Responses[fmt.Sprintf("%d", http.StatusForbidden)] = OpenAPIResponse{Description: "Caller is not in a credential group"}
+		}
+
+		if route.RoleGated {
+			op.Responses[fmt.Sprintf("%d", http.StatusForbidden)] = OpenAPIResponse{Description: "Caller is not in the group the request needs"}
+		}
+
+		if route.Admin {
+			op.Responses[fmt.Sprintf("%d", http.StatusForbidden)] = OpenAPIResponse{Description: "Caller is not in an admin group"}
+		}
+
+		if route.Mutating {
+			op.Responses[fmt.Sprintf("%d", http.StatusServiceUnavailable)] = OpenAPIResponse{Description: "The server is in maintenance mode"}
+		}
+
+		if route.Idempotent {
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:   IdempotencyKeyHeader,
+				In:     "header",
+				Schema: &OpenAPISchema{Type: "string"},
+			})
+			op.Responses[fmt.Sprintf("%d", http.StatusConflict)] = OpenAPIResponse{Description: "A request with this idempotency key is still in progress"}
+			op.Responses[fmt.Sprintf("%d", http.StatusUnprocessableEntity)] = OpenAPIResponse{Description: "The idempotency key was used for a different request"}
+		}
+
+		if route.Request != nil {
+			op.Responses[fmt.Sprintf("%d", http.StatusBadRequest)] = OpenAPIResponse{Description: "Invalid request body"}
+		}
+
+		if spec.Paths[path] == nil {
+			spec.Paths[path] = make(map[string]OpenAPIOperation)
+		}
+
+		spec.Paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	return spec
+}
+
+// openAPIPath converts a gin path (/cluster/:cluster) to an OpenAPI path (/cluster/{cluster}) and its path parameters.
+func openAPIPath(ginPath string) (path string, params []OpenAPIParameter) {
+	segments := strings.Split(ginPath, "/")
+
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+
+		name := segment[1:]
+		segments[i] = "{" + name + "}"
+		params = append(params, OpenAPIParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &OpenAPISchema{Type: "string"},
+		})
+	}
+
+	path = strings.Join(segments, "/")
+
+	return path, params
+}
+
+// schemaGenerator turns Go types into schemas.  Named structs are added to schemas once and referenced by $ref.
+type schemaGenerator struct {
+	schemas map[string]*OpenAPISchema
+	names   map[string]reflect.Type // schema name -> the type it was generated from, to detect name collisions
+}
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) (schema *OpenAPISchema) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeFor[time.Time]():
+		schema = &OpenAPISchema{Type: "string", Format: "date-time"}
+		return schema
+	case reflect.TypeFor[time.Duration]():
+		schema = &OpenAPISchema{Type: "integer", Format: "int64", Description: "duration in nanoseconds"}
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		schema = &OpenAPISchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		schema = &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		schema = &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		schema = &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		schema = &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		schema = &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			schema = &OpenAPISchema{Type: "string", Format: "byte"}
+			break
+		}
+		schema = &OpenAPISchema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		schema = &OpenAPISchema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		schema = g.structSchema(t)
+	default:
+		// interface{} and anything else json can encode arbitrarily
+		schema = &OpenAPISchema{}
+	}
+
+	return schema
+}
+
+// structSchema returns a $ref to the schema for a named struct, generating it on first use.  Anonymous structs are inlined.
+func (g *schemaGenerator) structSchema(t reflect.Type) (schema *OpenAPISchema) {
+	if t.Name() == "" {
+		schema = g.objectSchema(t)
+		return schema
+	}
+
+	name := t.Name()
+	if existing, ok := g.names[name]; ok && existing != t {
+		// Same name from a different package, e.g. two packages' NodeInfo
+		parts := strings.Split(t.PkgPath(), "/")
+		name = parts[len(parts)-1] + "." + name
+	}
+
+	schema = &OpenAPISchema{Ref: "#/components/schemas/" + name}
+
+	if _, ok := g.schemas[name]; ok {
+		return schema
+	}
+
+	g.names[name] = t
+
+	// Reserve the name before generating the properties, so self-referencing types terminate
+	g.schemas[name] = &OpenAPISchema{}
+	*g.schemas[name] = *g.objectSchema(t)
+
+	return schema
+}
+
+// objectSchema lists a struct's fields the way encoding/json would: by json tag, skipping "-" and unexported fields,
+// and flattening embedded structs.
+func (g *schemaGenerator) objectSchema(t reflect.Type) (schema *OpenAPISchema) {
+	schema = &OpenAPISchema{
+		Type:       "object",
+		Properties: make(map[string]*OpenAPISchema),
+	}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			embedded := g.objectSchema(fieldType)
+			for propName, prop := range embedded.Properties {
+				if _, ok := schema.Properties[propName]; !ok {
+					schema.Properties[propName] = prop
+				}
+			}
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = g.schemaFor(field.Type)
+	}
+
+	return schema
+}
@@ -0,0 +1,101 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+)
+
+// subnetDescriber is the part of the EC2 API a create needs to find a subnet in an availability zone.  The cluster
+// manager's EC2 client interface doesn't include it, but the real EC2 client has it.
+type subnetDescriber interface {
+	DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error)
+}
+
+// validatePlacement checks a node create asks for at most one of a subnet and an availability zone.  A subnet is in a
+// single zone, so asking for both could only conflict.
+func validatePlacement(subnetID string, zone string) (err error) {
+	if subnetID != "" && zone != "" {
+		err = errors.New("give a subnet or an availability zone, not both")
+		return err
+	}
+
+	return err
+}
+
+// describeZoneSubnets fetches the node config's subnet, and the available subnets in the same VPC in an availability
+// zone.
+func describeZoneSubnets(ctx context.Context, cm *aws.AWSClusterManager, defaultSubnetID string, zone string) (defaultSubnet ec2types.Subnet, candidates []ec2types.Subnet, err error) {
+	client, ok := cm.Ec2Client.(subnetDescriber)
+	if !ok {
+		err = errors.New("the EC2 client can't describe subnets")
+		return defaultSubnet, candidates, err
+	}
+
+	output, err := client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{SubnetIds: []string{defaultSubnetID}})
+	if err != nil {
+		err = errors.Wrapf(err, "failed describing the node config's subnet %s", defaultSubnetID)
+		return defaultSubnet, candidates, err
+	}
+
+	if len(output.Subnets) == 0 {
+		err = errors.New(fmt.Sprintf("the node config's subnet %s wasn't found", defaultSubnetID))
+		return defaultSubnet, candidates, err
+	}
+
+	defaultSubnet = output.Subnets[0]
+
+	output, err = client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []ec2types.Filter{
+			{Name: awssdk.String("vpc-id"), Values: []string{awssdk.ToString(defaultSubnet.VpcId)}},
+			{Name: awssdk.String("availability-zone"), Values: []string{zone}},
+			{Name: awssdk.String("state"), Values: []string{string(ec2types.SubnetStateAvailable)}},
+		},
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed describing subnets in availability zone %s", zone)
+		return defaultSubnet, candidates, err
+	}
+
+	candidates = output.Subnets
+
+	return defaultSubnet, candidates, err
+}
+
+// pickZoneSubnet chooses the subnet to launch a node in to put it in an availability zone.  The node config's own
+// subnet is used if it's in the zone.  Otherwise it's the zone's subnet of the same kind, public or private, as the node
+// config's: a node meant for a private subnet shouldn't land in a public one just because it's in the right zone.
+// More than one such subnet is ambiguous, so the subnet has to be given instead.
+func pickZoneSubnet(defaultSubnet ec2types.Subnet, candidates []ec2types.Subnet, zone string) (subnetID string, err error) {
+	if awssdk.ToString(defaultSubnet.AvailabilityZone) == zone {
+		subnetID = awssdk.ToString(defaultSubnet.SubnetId)
+		return subnetID, err
+	}
+
+	matching := make([]string, 0, len(candidates))
+	for _, subnet := range candidates {
+		if awssdk.ToBool(subnet.MapPublicIpOnLaunch) == awssdk.ToBool(defaultSubnet.MapPublicIpOnLaunch) {
+			matching = append(matching, awssdk.ToString(subnet.SubnetId))
+		}
+	}
+
+	sort.Strings(matching)
+
+	switch len(matching) {
+	case 0:
+		err = errors.New(fmt.Sprintf("no subnet like %s in VPC %s is in availability zone %s", awssdk.ToString(defaultSubnet.SubnetId), awssdk.ToString(defaultSubnet.VpcId), zone))
+	case 1:
+		subnetID = matching[0]
+	default:
+		err = errors.New(fmt.Sprintf("availability zone %s has several subnets like %s (%s); give the subnet instead", zone, awssdk.ToString(defaultSubnet.SubnetId), strings.Join(matching, ", ")))
+	}
+
+	return subnetID, err
+}
@@ -0,0 +1,54 @@
+package k8sctl
+
+import (
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSubnet(id string, zone string, public bool) (subnet ec2types.Subnet) {
+	subnet = ec2types.Subnet{
+		SubnetId:            awssdk.String(id),
+		VpcId:               awssdk.String("vpc-1"),
+		AvailabilityZone:    awssdk.String(zone),
+		MapPublicIpOnLaunch: awssdk.Bool(public),
+	}
+
+	return subnet
+}
+
+func TestPickZoneSubnet(t *testing.T) {
+	defaultSubnet := testSubnet("subnet-a-private", "us-east-1a", false)
+
+	// The node config's subnet is used when it's already in the zone
+	subnetID, err := pickZoneSubnet(defaultSubnet, nil, "us-east-1a")
+	require.NoError(t, err)
+	assert.Equal(t, "subnet-a-private", subnetID)
+
+	zoneB := []ec2types.Subnet{
+		testSubnet("subnet-b-public", "us-east-1b", true),
+		testSubnet("subnet-b-private", "us-east-1b", false),
+	}
+
+	// Otherwise the zone's subnet of the same kind
+	subnetID, err = pickZoneSubnet(defaultSubnet, zoneB, "us-east-1b")
+	require.NoError(t, err)
+	assert.Equal(t, "subnet-b-private", subnetID)
+
+	_, err = pickZoneSubnet(defaultSubnet, zoneB[:1], "us-east-1b")
+	require.Error(t, err)
+
+	_, err = pickZoneSubnet(defaultSubnet, append(zoneB, testSubnet("subnet-b-private-2", "us-east-1b", false)), "us-east-1b")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "subnet-b-private, subnet-b-private-2")
+}
+
+func TestValidatePlacement(t *testing.T) {
+	assert.NoError(t, validatePlacement("", ""))
+	assert.NoError(t, validatePlacement("subnet-1", ""))
+	assert.NoError(t, validatePlacement("", "us-east-1a"))
+	assert.Error(t, validatePlacement("subnet-1", "us-east-1a"))
+}
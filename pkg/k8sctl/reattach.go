@@ -0,0 +1,260 @@
+package k8sctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultReattachWait is how long a reattach waits, by default, for the re-registered targets to finish their health
+// checks before reporting their health.
+const defaultReattachWait = 2 * time.Minute
+
+// reattachPollInterval is how often a reattach checks the re-registered targets' health while it waits.
+const reattachPollInterval = 5 * time.Second
+
+// NodeLBReattachBody asks to deregister a node from its load balancer target groups and register it again.
+type NodeLBReattachBody struct {
+	Verbose bool   `json:"verbose"`
+	Region  string `json:"region,omitempty"`
+	// UnhealthyOnly reattaches the node only to the target groups it isn't healthy in, leaving the rest alone.
+	UnhealthyOnly bool `json:"unhealthy_only,omitempty"`
+	// Wait, in seconds, is how long to wait for the re-registered targets to pass or fail their health checks, default
+	// 120.  Targets still being checked then are reported as initial.
+	Wait int `json:"wait,omitempty"`
+}
+
+// NodeLBReattachResult is a node's health in each target group it was reattached to, before and after.
+type NodeLBReattachResult struct {
+	Node         string           `json:"node"`
+	ID           string           `json:"id"`
+	TargetGroups []LBReattachment `json:"target_groups"`
+	Failed       int              `json:"failed,omitempty"` // the target groups the node couldn't be reattached to
+}
+
+// LBReattachment is a node's reattachment to one load balancer target group.
+type LBReattachment struct {
+	LoadBalancer string `json:"load_balancer"`
+	TargetGroup  string `json:"target_group"`
+	Port         int32  `json:"port"`
+	Before       string `json:"before"`          // the target's state before it was deregistered
+	After        string `json:"after,omitempty"` // its state once registered again
+	Error        string `json:"error,omitempty"`
+
+	target elbtypes.TargetDescription
+	arn    string
+}
+
+// ConsolePrint prints the node's state in each target group before and after.
+func (r NodeLBReattachResult) ConsolePrint() {
+	fmt.Printf("Reattached %s (%s) to %d target group(s)\n", r.Node, r.ID, len(r.TargetGroups))
+
+	for _, reattachment := range r.TargetGroups {
+		fmt.Printf("  %s\n", reattachment.Summary())
+	}
+}
+
+// Summary formats the reattachment on one line, e.g. "cluster1-ingress/cluster1-ingress-443:31443: unhealthy -> healthy".
+func (r LBReattachment) Summary() (summary string) {
+	summary = fmt.Sprintf("%s/%s:%d: %s -> %s", r.LoadBalancer, r.TargetGroup, r.Port, r.Before, orDash(r.After))
+	if r.Error != "" {
+		summary += fmt.Sprintf(" (%s)", r.Error)
+	}
+
+	return summary
+}
+
+// NodeLBReattachHandler deregisters a node from the cluster's load balancer target groups it's registered with, and
+// registers it again, with the same target ID and port, for targets stuck unhealthy although the node is fine.
+func (c *K8sCtlCommands) NodeLBReattachHandler(ctx *gin.Context) {
+	clusterName := ctx.Param("cluster")
+	nodeName := ctx.Param("node")
+
+	var body NodeLBReattachBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	logrus.Infof("reattaching node %s in cluster %s to its load balancers", nodeName, clusterName)
+
+	cm, err := newClusterManager(ctx, clusterName, body.Region, body.Verbose)
+	if err != nil {
+		logrus.Errorf("Failed creating cluster manager: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	info, err := describeClusterInfo(ctx, cm, clusterName)
+	if err != nil {
+		logrus.Errorf("Failed describing cluster: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	idx := slices.IndexFunc(info.Nodes, func(node manager.NodeInfo) bool {
+		return stripDomainSuffix(node.Name) == stripDomainSuffix(nodeName)
+	})
+	if idx < 0 || info.Nodes[idx].ID == "" {
+		err = errors.New(fmt.Sprintf("no running instance found for node %s", nodeName))
+		_ = ctx.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+	node := info.Nodes[idx]
+
+	health, unchecked := describeTargetGroupsHealth(ctx, cm, info.LoadBalancers)
+	if len(unchecked) > 0 {
+		err = errors.New(fmt.Sprintf("failed getting target health of %v, so the node's registrations there aren't known", unchecked))
+		logrus.Errorf("%s", err)
+		_ = ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	instances := nodeInstances(ctx, cm, []manager.NodeInfo{node})
+	reattachments := nodeRegistrations(node, nodeAddresses(instances)[node.ID], health, body.UnhealthyOnly)
+	if len(reattachments) == 0 {
+		err = errors.New(fmt.Sprintf("node %s isn't registered with any of the cluster's target groups", nodeName))
+		if body.UnhealthyOnly {
+			err = errors.New(fmt.Sprintf("node %s isn't unhealthy in any of the cluster's target groups", nodeName))
+		}
+		_ = ctx.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+
+	wait := defaultReattachWait
+	if body.Wait > 0 {
+		wait = time.Duration(body.Wait) * time.Second
+	}
+
+	result := NodeLBReattachResult{Node: node.Name, ID: node.ID}
+	result.TargetGroups = reattachTargets(ctx, cm.ELBClient, reattachments, wait, reattachPollInterval)
+
+	for _, reattachment := range result.TargetGroups {
+		if reattachment.Error != "" {
+			result.Failed++
+		}
+		logrus.Infof("reattached node %s: %s", nodeName, reattachment.Summary())
+	}
+
+	recordRepair(ctx, RepairRecord{Cluster: clusterName, Kind: RepairKindLBReattach, Node: node.Name, LBReattachments: result.TargetGroups})
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// nodeRegistrations finds the node's targets in the target groups, matched by instance ID, or for target groups of
+// type ip, by one of its addresses.  With unhealthyOnly, its healthy targets are left out.
+func nodeRegistrations(node manager.NodeInfo, addresses []string, health []targetGroupHealth, unhealthyOnly bool) (reattachments []LBReattachment) {
+	for _, tgHealth := range health {
+		for _, desc := range tgHealth.output.TargetHealthDescriptions {
+			if desc.Target == nil || desc.Target.Id == nil {
+				continue
+			}
+
+			if *desc.Target.Id != node.ID && !isNodeAddress(*desc.Target.Id, addresses) {
+				continue
+			}
+
+			reattachment := LBReattachment{
+				LoadBalancer: tgHealth.lb,
+				TargetGroup:  tgHealth.tg.Name,
+				Port:         tgHealth.tg.Port,
+				target:       *desc.Target,
+				arn:          tgHealth.tg.Arn,
+			}
+			if desc.Target.Port != nil {
+				reattachment.Port = *desc.Target.Port
+			}
+			if desc.TargetHealth != nil {
+				reattachment.Before = string(desc.TargetHealth.State)
+			}
+
+			if unhealthyOnly && reattachment.Before == targetStateHealthy {
+				continue
+			}
+
+			reattachments = append(reattachments, reattachment)
+		}
+	}
+
+	return reattachments
+}
+
+// reattachTargets deregisters each target and registers it again straight away, which cancels its draining, then
+// waits up to wait for the targets to finish their health checks, and records their states.  A target that can't be
+// reattached gets its error, and the others carry on.
+func reattachTargets(ctx context.Context, client aws.ELBClient, reattachments []LBReattachment, wait time.Duration, poll time.Duration) (reattached []LBReattachment) {
+	reattached = slices.Clone(reattachments)
+
+	for i := range reattached {
+		reattachment := &reattached[i]
+		targets := []elbtypes.TargetDescription{reattachment.target}
+
+		_, err := client.DeregisterTargets(ctx, &elasticloadbalancingv2.DeregisterTargetsInput{TargetGroupArn: &reattachment.arn, Targets: targets})
+		if err != nil {
+			reattachment.Error = fmt.Sprintf("failed deregistering: %s", err)
+			continue
+		}
+
+		_, err = client.RegisterTargets(ctx, &elasticloadbalancingv2.RegisterTargetsInput{TargetGroupArn: &reattachment.arn, Targets: targets})
+		if err != nil {
+			// The target's deregistered, and left so, which its state says
+			reattachment.Error = fmt.Sprintf("deregistered, but failed registering again: %s", err)
+			reattachment.After = string(elbtypes.TargetHealthStateEnumDraining)
+		}
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		checking := false
+		for i := range reattached {
+			reattachment := &reattached[i]
+			if reattachment.Error != "" {
+				continue
+			}
+
+			output, err := client.DescribeTargetHealth(ctx, &elasticloadbalancingv2.DescribeTargetHealthInput{
+				TargetGroupArn: &reattachment.arn,
+				Targets:        []elbtypes.TargetDescription{reattachment.target},
+			})
+			if err != nil {
+				logrus.Warnf("failed getting target health in %s after reattaching: %s", reattachment.TargetGroup, err)
+				checking = true
+				continue
+			}
+
+			if len(output.TargetHealthDescriptions) == 0 || output.TargetHealthDescriptions[0].TargetHealth == nil {
+				checking = true
+				continue
+			}
+
+			reattachment.After = string(output.TargetHealthDescriptions[0].TargetHealth.State)
+			if reattachment.After == string(elbtypes.TargetHealthStateEnumInitial) {
+				checking = true
+			}
+		}
+
+		if !checking || time.Now().Add(poll).After(deadline) {
+			return reattached
+		}
+
+		select {
+		case <-time.After(poll):
+		case <-ctx.Done():
+			return reattached
+		}
+	}
+}
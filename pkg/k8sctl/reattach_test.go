@@ -0,0 +1,122 @@
+package k8sctl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReattachELBClient records the targets deregistered and registered, by target group ARN, and reports registered
+// targets initial for the first healthChecks health checks, then healthy.
+type fakeReattachELBClient struct {
+	aws.ELBClient
+	deregistered   []string
+	registered     []string
+	failRegister   map[string]bool
+	healthChecks   int
+	healthRequests int
+}
+
+func (f *fakeReattachELBClient) DeregisterTargets(_ context.Context, params *elasticloadbalancingv2.DeregisterTargetsInput, _ ...func(*elasticloadbalancingv2.Options)) (output *elasticloadbalancingv2.DeregisterTargetsOutput, err error) {
+	f.deregistered = append(f.deregistered, *params.TargetGroupArn+"="+*params.Targets[0].Id)
+	output = &elasticloadbalancingv2.DeregisterTargetsOutput{}
+	return output, err
+}
+
+func (f *fakeReattachELBClient) RegisterTargets(_ context.Context, params *elasticloadbalancingv2.RegisterTargetsInput, _ ...func(*elasticloadbalancingv2.Options)) (output *elasticloadbalancingv2.RegisterTargetsOutput, err error) {
+	if f.failRegister[*params.TargetGroupArn] {
+		err = errors.New("throttled")
+		return output, err
+	}
+
+	f.registered = append(f.registered, *params.TargetGroupArn+"="+*params.Targets[0].Id)
+	output = &elasticloadbalancingv2.RegisterTargetsOutput{}
+	return output, err
+}
+
+func (f *fakeReattachELBClient) DescribeTargetHealth(_ context.Context, params *elasticloadbalancingv2.DescribeTargetHealthInput, _ ...func(*elasticloadbalancingv2.Options)) (output *elasticloadbalancingv2.DescribeTargetHealthOutput, err error) {
+	f.healthRequests++
+
+	state := elbtypes.TargetHealthStateEnumHealthy
+	if f.healthRequests <= f.healthChecks {
+		state = elbtypes.TargetHealthStateEnumInitial
+	}
+
+	output = &elasticloadbalancingv2.DescribeTargetHealthOutput{
+		TargetHealthDescriptions: []elbtypes.TargetHealthDescription{{Target: &params.Targets[0], TargetHealth: &elbtypes.TargetHealth{State: state}}},
+	}
+	return output, err
+}
+
+func TestNodeRegistrations(t *testing.T) {
+	health := []targetGroupHealth{
+		{
+			lb: "cluster1-ingress",
+			tg: manager.LBTargetGroupInfo{Name: "cluster1-ingress-443", Arn: "arn:tg/443", Port: 443},
+			output: &elasticloadbalancingv2.DescribeTargetHealthOutput{TargetHealthDescriptions: []elbtypes.TargetHealthDescription{
+				{Target: &elbtypes.TargetDescription{Id: awssdk.String("i-1"), Port: awssdk.Int32(31443)}, TargetHealth: &elbtypes.TargetHealth{State: elbtypes.TargetHealthStateEnumUnhealthy}},
+				{Target: &elbtypes.TargetDescription{Id: awssdk.String("i-2"), Port: awssdk.Int32(31443)}, TargetHealth: &elbtypes.TargetHealth{State: elbtypes.TargetHealthStateEnumHealthy}},
+			}},
+		},
+		{
+			lb: "cluster1-ingress",
+			tg: manager.LBTargetGroupInfo{Name: "cluster1-ingress-80", Arn: "arn:tg/80", Port: 80},
+			output: &elasticloadbalancingv2.DescribeTargetHealthOutput{TargetHealthDescriptions: []elbtypes.TargetHealthDescription{
+				// ip target group
+				{Target: &elbtypes.TargetDescription{Id: awssdk.String("10.0.1.11"), Port: awssdk.Int32(31080)}, TargetHealth: &elbtypes.TargetHealth{State: elbtypes.TargetHealthStateEnumHealthy}},
+			}},
+		},
+	}
+
+	node := manager.NodeInfo{Name: "cluster1-worker-1", ID: "i-1"}
+
+	reattachments := nodeRegistrations(node, []string{"10.0.1.11"}, health, false)
+	require.Len(t, reattachments, 2)
+	assert.Equal(t, "cluster1-ingress-443", reattachments[0].TargetGroup)
+	assert.Equal(t, int32(31443), reattachments[0].Port)
+	assert.Equal(t, "unhealthy", reattachments[0].Before)
+	assert.Equal(t, "arn:tg/443", reattachments[0].arn)
+	assert.Equal(t, "10.0.1.11", *reattachments[1].target.Id)
+
+	reattachments = nodeRegistrations(node, []string{"10.0.1.11"}, health, true)
+	require.Len(t, reattachments, 1)
+	assert.Equal(t, "cluster1-ingress-443", reattachments[0].TargetGroup)
+
+	assert.Empty(t, nodeRegistrations(manager.NodeInfo{Name: "cluster1-worker-9", ID: "i-9"}, nil, health, false))
+}
+
+func TestReattachTargets(t *testing.T) {
+	client := &fakeReattachELBClient{healthChecks: 2, failRegister: map[string]bool{"arn:tg/80": true}}
+
+	reattachments := []LBReattachment{
+		{TargetGroup: "cluster1-ingress-443", Before: "unhealthy", arn: "arn:tg/443", target: elbtypes.TargetDescription{Id: awssdk.String("i-1")}},
+		{TargetGroup: "cluster1-ingress-80", Before: "unhealthy", arn: "arn:tg/80", target: elbtypes.TargetDescription{Id: awssdk.String("i-1")}},
+	}
+
+	reattached := reattachTargets(context.Background(), client, reattachments, time.Second, time.Millisecond)
+
+	assert.Equal(t, []string{"arn:tg/443=i-1", "arn:tg/80=i-1"}, client.deregistered)
+	assert.Equal(t, []string{"arn:tg/443=i-1"}, client.registered)
+
+	// Polled until it was no longer initial
+	assert.Equal(t, "healthy", reattached[0].After)
+	assert.Empty(t, reattached[0].Error)
+	assert.Equal(t, 3, client.healthRequests)
+
+	assert.Equal(t, "draining", reattached[1].After)
+	assert.Contains(t, reattached[1].Error, "throttled")
+
+	// Without time to wait, the initial state is reported
+	client = &fakeReattachELBClient{healthChecks: 5}
+	reattached = reattachTargets(context.Background(), client, reattachments[:1], 0, time.Millisecond)
+	assert.Equal(t, "initial", reattached[0].After)
+}
@@ -0,0 +1,409 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// TagFix records the tags a reconcile set on an instance, or would set, and what they were before.
+type TagFix struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Added    map[string]string `json:"added"`           // the tags set
+	Previous map[string]string `json:"previous"`        // their values before, "" if the instance didn't have them
+	Error    string            `json:"error,omitempty"` // why the tags couldn't be set, in which case they weren't
+}
+
+// tagFixBatchSize is the most instances a reconcile tags in one call, so large clusters don't send one huge request.
+const tagFixBatchSize = 20
+
+// tagFixAttempts is how many times a throttled tag call is tried before its instances are given up on.
+const tagFixAttempts = 5
+
+// tagFixRetryDelay is the wait before retrying a throttled tag call, doubled for each retry after.
+var tagFixRetryDelay = time.Second
+
+// planClusterTags returns the tags fixClusterTags would set on the given instances, with each instance's current
+// values, without setting them.
+func planClusterTags(ctx context.Context, cm *aws.AWSClusterManager, nodes []manager.NodeInfo) (plan []TagFix, err error) {
+	if len(nodes) == 0 {
+		return plan, err
+	}
+
+	instanceIDs := make([]string, len(nodes))
+	for i, node := range nodes {
+		instanceIDs[i] = node.ID
+	}
+
+	output, err := cm.Ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: instanceIDs})
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting current tags of untagged instances")
+		return plan, err
+	}
+
+	instances := make(map[string]ec2types.Instance, len(nodes))
+	for _, reservation := range output.Reservations {
+		for _, instance := range reservation.Instances {
+			if instance.InstanceId != nil {
+				instances[*instance.InstanceId] = instance
+			}
+		}
+	}
+
+	added := map[string]string{aws.EC2TagCluster: cm.ClusterName()}
+
+	for _, node := range nodes {
+		var current []ec2types.Instance
+		if instance, ok := instances[node.ID]; ok {
+			current = append(current, instance)
+		}
+
+		plan = append(plan, TagFix{
+			ID:       node.ID,
+			Name:     node.Name,
+			Added:    map[string]string{aws.EC2TagCluster: cm.ClusterName()},
+			Previous: previousTags(current, added),
+		})
+	}
+
+	return plan, err
+}
+
+// fixClusterTags sets the Cluster tag on the given instances, and reports each instance's tags before and after.
+func fixClusterTags(ctx context.Context, cm *aws.AWSClusterManager, nodes []manager.NodeInfo) (fixes []TagFix, err error) {
+	fixes, err = planClusterTags(ctx, cm, nodes)
+	if err != nil || len(fixes) == 0 {
+		return fixes, err
+	}
+
+	applyTagFixes(ctx, cm, fixes, cm.FixMissingClusterTags)
+
+	return fixes, err
+}
+
+// applyTagFixes makes the fixes, with the given call tagging a batch of instances.  Instances are tagged in batches,
+// and a throttled batch is retried with backoff.  A batch that still fails is tagged an instance at a time, so one bad
+// instance doesn't fail the rest: each fix that couldn't be made says why, rather than the whole fix failing.
+func applyTagFixes(ctx context.Context, cm *aws.AWSClusterManager, fixes []TagFix, tag func(instanceIDs []string) (err error)) {
+	failed := make(map[string]error)
+
+	for start := 0; start < len(fixes); start += tagFixBatchSize {
+		var batch []string
+		for _, fix := range fixes[start:min(start+tagFixBatchSize, len(fixes))] {
+			batch = append(batch, fix.ID)
+		}
+
+		batchErr := tagWithRetry(ctx, cm, batch, tag)
+		if batchErr == nil {
+			continue
+		}
+
+		if len(batch) == 1 {
+			failed[batch[0]] = batchErr
+			continue
+		}
+
+		logrus.Warnf("failed fixing tags on %d instance(s) of cluster %s at once, fixing them one by one: %s", len(batch), cm.ClusterName(), batchErr)
+
+		for _, instanceID := range batch {
+			instanceErr := tagWithRetry(ctx, cm, []string{instanceID}, tag)
+			if instanceErr != nil {
+				failed[instanceID] = instanceErr
+			}
+		}
+	}
+
+	for i, fix := range fixes {
+		if fixErr, ok := failed[fix.ID]; ok {
+			fixes[i].Error = fixErr.Error()
+		}
+	}
+}
+
+// tagWithRetry tags the instances, retrying with backoff while AWS throttles the calls.  Other errors aren't retried,
+// since trying again won't help.
+func tagWithRetry(ctx context.Context, cm *aws.AWSClusterManager, instanceIDs []string, tag func(instanceIDs []string) (err error)) (err error) {
+	delay := tagFixRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		err = tag(instanceIDs)
+		if err == nil || attempt == tagFixAttempts || !isThrottle(err) {
+			return err
+		}
+
+		// Half of the wait is random jitter, so concurrent fixes don't all retry at once
+		wait := delay/2 + rand.N(delay/2+1)
+
+		logrus.Warnf("fixing tags on %d instance(s) of cluster %s was throttled, retrying in %s", len(instanceIDs), cm.ClusterName(), wait.Round(time.Millisecond))
+
+		select {
+		case <-ctx.Done():
+			err = errors.Wrapf(ctx.Err(), "gave up fixing tags on %d instance(s)", len(instanceIDs))
+			return err
+		case <-time.After(wait):
+		}
+
+		delay *= 2
+	}
+}
+
+// isThrottle returns whether an AWS error is throttling, e.g. RequestLimitExceeded.
+func isThrottle(err error) (throttle bool) {
+	throttle = retry.ThrottleErrorCode{Codes: retry.DefaultThrottleErrorCodes}.IsErrorThrottle(err) == awssdk.TrueTernary
+	return throttle
+}
+
+// failedTagFixes counts the fixes that couldn't be made.
+func failedTagFixes(fixes []TagFix) (failed int) {
+	for _, fix := range fixes {
+		if fix.Error != "" {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+// Summary formats the fix on one line, e.g. "cluster1-worker-2 (i-0123): Cluster "" -> "cluster1"", or with why it
+// failed.
+func (f TagFix) Summary() (summary string) {
+	summary = fmt.Sprintf("%s (%s):", f.Name, f.ID)
+	for key, value := range f.Added {
+		summary += fmt.Sprintf(" %s %q -> %q", key, f.Previous[key], value)
+	}
+
+	if f.Error != "" {
+		summary += fmt.Sprintf(" failed: %s", f.Error)
+	}
+
+	return summary
+}
+
+// ReconcileSummary is a reconcile's counts of each kind of discrepancy, without the nodes, for dashboards and metrics
+// collectors that poll often.  The counts are those of the ReconcileResult fields of the same names.
+type ReconcileSummary struct {
+	UntaggedNodes     int `json:"untagged_nodes"`
+	EC2NotInK8s       int `json:"ec2_not_in_k8s"`
+	JoiningNodes      int `json:"joining_nodes"`
+	StuckJoining      int `json:"stuck_joining,omitempty"`
+	K8sNotInEC2       int `json:"k8s_not_in_ec2"`
+	EC2NotInLB        int `json:"ec2_not_in_lb"`
+	DuplicateEC2Names int `json:"duplicate_ec2_names"`
+	DuplicateK8sNames int `json:"duplicate_k8s_names"`
+	MissingTags       int `json:"missing_tags,omitempty"`
+	// UnbalancedControlPlane is 1 if the control plane spans too few availability zones, which counts as an issue.
+	UnbalancedControlPlane int      `json:"unbalanced_control_plane"`
+	TagFixes               int      `json:"tag_fixes"`
+	TagFixesFailed         int      `json:"tag_fixes_failed,omitempty"`
+	TagPlan                int      `json:"tag_plan,omitempty"`
+	OrphanCost             *float64 `json:"orphan_estimated_monthly_cost,omitempty"`
+	Message                string   `json:"message"`
+	TotalIssuesFound       int      `json:"total_issues_found"`
+
+	ClusterInfo *manager.ClusterInfo `json:"cluster_info,omitempty"` // with include_cluster_info
+}
+
+// Summary counts the result's discrepancies.
+func (r ReconcileResult) Summary() (summary ReconcileSummary) {
+	summary = ReconcileSummary{
+		UntaggedNodes:     len(r.UntaggedNodes),
+		EC2NotInK8s:       len(r.EC2NotInK8s),
+		JoiningNodes:      len(r.JoiningNodes),
+		StuckJoining:      len(r.StuckJoining),
+		K8sNotInEC2:       len(r.K8sNotInEC2),
+		EC2NotInLB:        len(r.EC2NotInLB),
+		DuplicateEC2Names: len(r.DuplicateEC2Names),
+		DuplicateK8sNames: len(r.DuplicateK8sNames),
+		MissingTags:       len(r.MissingTags),
+		TagFixes:          len(r.TagFixes) - r.TagFixesFailed,
+		TagFixesFailed:    r.TagFixesFailed,
+		TagPlan:           len(r.TagPlan),
+		OrphanCost:        r.OrphanCost,
+		Message:           r.Message,
+		TotalIssuesFound:  r.TotalIssuesFound,
+		ClusterInfo:       r.ClusterInfo,
+	}
+
+	if r.ControlPlaneSpread != nil && !r.ControlPlaneSpread.Balanced {
+		summary.UnbalancedControlPlane = 1
+	}
+
+	return summary
+}
+
+// DuplicateName is a node name shared by more than one EC2 instance, or Kubernetes node.
+type DuplicateName struct {
+	Name string   `json:"name"`
+	IDs  []string `json:"ids"` // the instance IDs, or Kubernetes node names, sharing the name
+}
+
+// duplicateEC2Names returns the Name tags, without their domain, shared by more than one instance.  An instance listed
+// more than once is only counted once.
+func duplicateEC2Names(nodes []manager.NodeInfo) (duplicates []DuplicateName) {
+	ids := make(map[string][]string)
+	seen := make(map[string]bool)
+
+	for _, node := range nodes {
+		if seen[node.ID] {
+			continue
+		}
+		seen[node.ID] = true
+
+		shortName := stripDomainSuffix(node.Name)
+		ids[shortName] = append(ids[shortName], node.ID)
+	}
+
+	duplicates = sharedNames(ids)
+	return duplicates
+}
+
+// duplicateK8sNames returns the Kubernetes node names that are the same once their domain is stripped, since that's
+// how they're matched to EC2 instances.
+func duplicateK8sNames(nodes []string) (duplicates []DuplicateName) {
+	names := make(map[string][]string)
+
+	for _, node := range nodes {
+		shortName := stripDomainSuffix(node)
+		names[shortName] = append(names[shortName], node)
+	}
+
+	duplicates = sharedNames(names)
+	return duplicates
+}
+
+// sharedNames returns the names with more than one ID, sorted by name.
+func sharedNames(ids map[string][]string) (duplicates []DuplicateName) {
+	for name, nameIDs := range ids {
+		if len(nameIDs) > 1 {
+			sort.Strings(nameIDs)
+			duplicates = append(duplicates, DuplicateName{Name: name, IDs: nameIDs})
+		}
+	}
+
+	sort.Slice(duplicates, func(i, j int) bool { return duplicates[i].Name < duplicates[j].Name })
+
+	return duplicates
+}
+
+// hoursPerMonth is the hours in an average month, as AWS bills them.
+const hoursPerMonth = 730
+
+// OrphanNode is an EC2 instance in the cluster with no matching Kubernetes node.  These are often left over, and still
+// costing money.
+type OrphanNode struct {
+	Name         string     `json:"name"`
+	ID           string     `json:"id"`
+	InstanceType string     `json:"instance_type,omitempty"`
+	LaunchTime   *time.Time `json:"launch_time,omitempty"`
+	Age          string     `json:"age,omitempty"`                    // how long the instance has existed
+	MonthlyCost  *float64   `json:"estimated_monthly_cost,omitempty"` // USD, unset if the instance type's price is unknown
+}
+
+// orphanNodes describes the EC2 instances with no Kubernetes node, with their launch times and estimated monthly cost.
+// Instances launched less than minAge ago are returned as joining instead, since they've likely just not joined yet.
+// Not knowing launch times isn't fatal: the orphans are still reported, without them.
+func orphanNodes(ctx context.Context, cm *aws.AWSClusterManager, nodes []manager.NodeInfo, minAge time.Duration) (orphans []OrphanNode, joining []string) {
+	launchTimes, err := instanceLaunchTimes(ctx, cm, nodes)
+	if err != nil {
+		logrus.Warnf("reconcile of cluster %s can't tell how old its orphaned instances are: %s", cm.ClusterName(), err)
+	}
+
+	orphans, joining = describeOrphans(nodes, launchTimes, cm.CostEstimator, minAge, time.Now())
+	return orphans, joining
+}
+
+// instanceLaunchTimes returns the launch times of the nodes' instances, by instance ID.
+func instanceLaunchTimes(ctx context.Context, cm *aws.AWSClusterManager, nodes []manager.NodeInfo) (launchTimes map[string]time.Time, err error) {
+	launchTimes = make(map[string]time.Time)
+
+	instanceIDs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if node.ID != "" {
+			instanceIDs = append(instanceIDs, node.ID)
+		}
+	}
+
+	if len(instanceIDs) == 0 {
+		return launchTimes, err
+	}
+
+	output, err := cm.Ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: instanceIDs})
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting launch times of %d instance(s)", len(instanceIDs))
+		return launchTimes, err
+	}
+
+	for _, reservation := range output.Reservations {
+		for _, instance := range reservation.Instances {
+			if instance.InstanceId != nil && instance.LaunchTime != nil {
+				launchTimes[*instance.InstanceId] = *instance.LaunchTime
+			}
+		}
+	}
+
+	return launchTimes, err
+}
+
+// describeOrphans is orphanNodes, given the instances' launch times and the time now.  An instance whose launch time
+// isn't known is always reported, since it can't be shown to be new.
+func describeOrphans(nodes []manager.NodeInfo, launchTimes map[string]time.Time, estimator manager.CostEstimator, minAge time.Duration, now time.Time) (orphans []OrphanNode, joining []string) {
+	for _, node := range nodes {
+		orphan := OrphanNode{
+			Name:         node.Name,
+			ID:           node.ID,
+			InstanceType: node.InstanceType,
+		}
+
+		if launchTime, ok := launchTimes[node.ID]; ok {
+			age := now.Sub(launchTime)
+			if age < minAge {
+				joining = append(joining, node.Name)
+				continue
+			}
+
+			orphan.LaunchTime = &launchTime
+			orphan.Age = age.Round(time.Minute).String()
+		}
+
+		if estimator != nil && node.InstanceType != "" {
+			hourlyCost, costErr := estimator.EstimateHourlyCost(node.InstanceType)
+			if costErr == nil {
+				monthlyCost := hourlyCost * hoursPerMonth
+				orphan.MonthlyCost = &monthlyCost
+			}
+		}
+
+		orphans = append(orphans, orphan)
+	}
+
+	return orphans, joining
+}
+
+// orphanMonthlyCost totals the orphans' estimated monthly cost, or returns nil if none of their costs are known.
+func orphanMonthlyCost(orphans []OrphanNode) (total *float64) {
+	for _, orphan := range orphans {
+		if orphan.MonthlyCost == nil {
+			continue
+		}
+
+		if total == nil {
+			total = new(float64)
+		}
+
+		*total += *orphan.MonthlyCost
+	}
+
+	return total
+}
@@ -0,0 +1,300 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTagEC2Client serves canned instances and records the tags created.  Other methods are left to the nil embedded
+// interface.
+type fakeTagEC2Client struct {
+	aws.Ec2Client
+	instances []ec2types.Instance
+	created   []*ec2.CreateTagsInput
+
+	// throttles is how many CreateTags calls to throttle before the rest go through.  Calls tagging any of the failing
+	// instances fail.
+	throttles int
+	failing   []string
+}
+
+func (f *fakeTagEC2Client) DescribeInstances(_ context.Context, params *ec2.DescribeInstancesInput, _ ...func(*ec2.Options)) (output *ec2.DescribeInstancesOutput, err error) {
+	output = &ec2.DescribeInstancesOutput{}
+	for _, instance := range f.instances {
+		if len(params.InstanceIds) > 0 && !slices.Contains(params.InstanceIds, *instance.InstanceId) {
+			continue
+		}
+		output.Reservations = append(output.Reservations, ec2types.Reservation{Instances: []ec2types.Instance{instance}})
+	}
+
+	return output, err
+}
+
+func (f *fakeTagEC2Client) CreateTags(_ context.Context, params *ec2.CreateTagsInput, _ ...func(*ec2.Options)) (output *ec2.CreateTagsOutput, err error) {
+	if f.throttles > 0 {
+		f.throttles--
+		err = &smithy.GenericAPIError{Code: "RequestLimitExceeded", Message: "Request limit exceeded."}
+		return output, err
+	}
+
+	for _, instanceID := range params.Resources {
+		if slices.Contains(f.failing, instanceID) {
+			err = &smithy.GenericAPIError{Code: "InvalidInstanceID.NotFound", Message: "The instance ID '" + instanceID + "' does not exist"}
+			return output, err
+		}
+	}
+
+	f.created = append(f.created, params)
+	output = &ec2.CreateTagsOutput{}
+	return output, err
+}
+
+func TestFixClusterTags(t *testing.T) {
+	ec2Client := &fakeTagEC2Client{
+		instances: []ec2types.Instance{
+			{
+				InstanceId: awssdk.String("i-1"),
+				Tags:       []ec2types.Tag{{Key: awssdk.String("Name"), Value: awssdk.String("cluster1-worker-1")}},
+			},
+			{
+				InstanceId: awssdk.String("i-2"),
+				Tags: []ec2types.Tag{
+					{Key: awssdk.String("Name"), Value: awssdk.String("cluster1-worker-2")},
+					{Key: awssdk.String("Cluster"), Value: awssdk.String("cluster2")},
+				},
+			},
+		},
+	}
+
+	cm := &aws.AWSClusterManager{Name: "cluster1", Context: context.Background(), Ec2Client: ec2Client}
+
+	fixes, err := fixClusterTags(context.Background(), cm, []manager.NodeInfo{
+		{Name: "cluster1-worker-1", ID: "i-1"},
+		{Name: "cluster1-worker-2", ID: "i-2"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, ec2Client.created, 1)
+	assert.Equal(t, []string{"i-1", "i-2"}, ec2Client.created[0].Resources)
+
+	assert.Equal(t, []TagFix{
+		{ID: "i-1", Name: "cluster1-worker-1", Added: map[string]string{"Cluster": "cluster1"}, Previous: map[string]string{"Cluster": ""}},
+		{ID: "i-2", Name: "cluster1-worker-2", Added: map[string]string{"Cluster": "cluster1"}, Previous: map[string]string{"Cluster": "cluster2"}},
+	}, fixes)
+
+	assert.Equal(t, `cluster1-worker-2 (i-2): Cluster "cluster2" -> "cluster1"`, fixes[1].Summary())
+
+	fixes, err = fixClusterTags(context.Background(), cm, nil)
+	require.NoError(t, err)
+	assert.Empty(t, fixes)
+	assert.Len(t, ec2Client.created, 1, "nothing to fix, no tags created")
+}
+
+func TestPlanClusterTags(t *testing.T) {
+	ec2Client := &fakeTagEC2Client{
+		instances: []ec2types.Instance{
+			{
+				InstanceId: awssdk.String("i-1"),
+				Tags:       []ec2types.Tag{{Key: awssdk.String("Cluster"), Value: awssdk.String("cluster2")}},
+			},
+		},
+	}
+
+	cm := &aws.AWSClusterManager{Name: "cluster1", Context: context.Background(), Ec2Client: ec2Client}
+
+	plan, err := planClusterTags(context.Background(), cm, []manager.NodeInfo{
+		{Name: "cluster1-worker-1", ID: "i-1"},
+		{Name: "cluster1-worker-2", ID: "i-gone"},
+	})
+	require.NoError(t, err)
+
+	assert.Empty(t, ec2Client.created, "a plan sets no tags")
+	assert.Equal(t, []TagFix{
+		{ID: "i-1", Name: "cluster1-worker-1", Added: map[string]string{"Cluster": "cluster1"}, Previous: map[string]string{"Cluster": "cluster2"}},
+		{ID: "i-gone", Name: "cluster1-worker-2", Added: map[string]string{"Cluster": "cluster1"}, Previous: map[string]string{"Cluster": ""}},
+	}, plan)
+}
+
+func TestFixClusterTagsPartialFailure(t *testing.T) {
+	retryDelay := tagFixRetryDelay
+	tagFixRetryDelay = time.Millisecond
+	t.Cleanup(func() { tagFixRetryDelay = retryDelay })
+
+	var nodes []manager.NodeInfo
+	for i := range tagFixBatchSize + 2 {
+		nodes = append(nodes, manager.NodeInfo{Name: fmt.Sprintf("cluster1-worker-%d", i), ID: fmt.Sprintf("i-%d", i)})
+	}
+
+	ec2Client := &fakeTagEC2Client{throttles: 2, failing: []string{"i-3"}}
+	cm := &aws.AWSClusterManager{Name: "cluster1", Context: context.Background(), Ec2Client: ec2Client}
+
+	fixes, err := fixClusterTags(context.Background(), cm, nodes)
+	require.NoError(t, err)
+	require.Len(t, fixes, len(nodes))
+
+	// The first batch is throttled twice then fails on i-3, so its instances are tagged one by one; the second batch
+	// goes through at once
+	var tagged []string
+	for _, created := range ec2Client.created {
+		tagged = append(tagged, created.Resources...)
+	}
+	assert.Len(t, tagged, len(nodes)-1)
+	assert.NotContains(t, tagged, "i-3")
+	assert.Equal(t, []string{"i-20", "i-21"}, ec2Client.created[len(ec2Client.created)-1].Resources)
+
+	assert.Equal(t, 1, failedTagFixes(fixes))
+	assert.Contains(t, fixes[3].Error, "InvalidInstanceID.NotFound")
+	assert.Contains(t, fixes[3].Summary(), "failed: ")
+	assert.Empty(t, fixes[4].Error)
+}
+
+func TestIsThrottle(t *testing.T) {
+	assert.True(t, isThrottle(errors.Wrapf(&smithy.GenericAPIError{Code: "RequestLimitExceeded"}, "failed tagging")))
+	assert.True(t, isThrottle(&smithy.GenericAPIError{Code: "Throttling"}))
+	assert.False(t, isThrottle(&smithy.GenericAPIError{Code: "UnauthorizedOperation"}))
+	assert.False(t, isThrottle(errors.New("connection reset")))
+}
+
+func TestStripDomainSuffix(t *testing.T) {
+	assert.Equal(t, "cluster1-cp-1", stripDomainSuffix("cluster1-cp-1.example.com"))
+	assert.Equal(t, "cluster1-cp-1", stripDomainSuffix("cluster1-cp-1"))
+	assert.Equal(t, "10.0.1.11", stripDomainSuffix("10.0.1.11"))
+	assert.Equal(t, "2600:1f18:aaaa::11", stripDomainSuffix("2600:1f18:aaaa::11"))
+	assert.Equal(t, "fe80::1%eth0", stripDomainSuffix("fe80::1%eth0"))
+}
+
+func TestDuplicateEC2Names(t *testing.T) {
+	nodes := []manager.NodeInfo{
+		{Name: "cluster1-worker-1.example.com", ID: "i-2"},
+		{Name: "cluster1-worker-1", ID: "i-1"},
+		{Name: "cluster1-worker-2", ID: "i-3"},
+		// The same instance, listed twice, isn't a duplicate
+		{Name: "cluster1-worker-2", ID: "i-3"},
+	}
+
+	assert.Equal(t, []DuplicateName{{Name: "cluster1-worker-1", IDs: []string{"i-1", "i-2"}}}, duplicateEC2Names(nodes))
+	assert.Empty(t, duplicateEC2Names(nodes[2:]))
+}
+
+func TestDuplicateK8sNames(t *testing.T) {
+	nodes := []string{"cluster1-worker-1", "cluster1-worker-1.example.com", "cluster1-cp-1"}
+
+	assert.Equal(t, []DuplicateName{{Name: "cluster1-worker-1", IDs: []string{"cluster1-worker-1", "cluster1-worker-1.example.com"}}}, duplicateK8sNames(nodes))
+	assert.Empty(t, duplicateK8sNames(nodes[1:]))
+}
+
+// fakeCostEstimator prices every instance type at a dollar an hour, except unknown ones.
+type fakeCostEstimator struct{}
+
+func (fakeCostEstimator) EstimateHourlyCost(instanceType string) (costPerHour float64, err error) {
+	if instanceType == "unknown" {
+		err = errors.New("unknown instance type")
+		return costPerHour, err
+	}
+
+	costPerHour = 1
+	return costPerHour, err
+}
+
+func (f fakeCostEstimator) EstimateDailyCost(instanceType string) (costPerDay float64, err error) {
+	costPerHour, err := f.EstimateHourlyCost(instanceType)
+	costPerDay = costPerHour * 24
+	return costPerDay, err
+}
+
+func TestOrphanNodes(t *testing.T) {
+	now := time.Now()
+	ec2Client := &fakeTagEC2Client{
+		instances: []ec2types.Instance{
+			{InstanceId: awssdk.String("i-old"), LaunchTime: awssdk.Time(now.Add(-72 * time.Hour))},
+			{InstanceId: awssdk.String("i-new"), LaunchTime: awssdk.Time(now.Add(-2 * time.Minute))},
+			{InstanceId: awssdk.String("i-unpriced"), LaunchTime: awssdk.Time(now.Add(-time.Hour))},
+		},
+	}
+
+	cm := &aws.AWSClusterManager{Name: "cluster1", Context: context.Background(), Ec2Client: ec2Client, CostEstimator: fakeCostEstimator{}}
+
+	nodes := []manager.NodeInfo{
+		{Name: "cluster1-worker-1", ID: "i-old", InstanceType: "m5.large"},
+		{Name: "cluster1-worker-2", ID: "i-new", InstanceType: "m5.large"},
+		{Name: "cluster1-worker-3", ID: "i-unpriced", InstanceType: "unknown"},
+		{Name: "cluster1-worker-4", ID: "i-gone", InstanceType: "m5.large"},
+	}
+
+	orphans, joining := orphanNodes(context.Background(), cm, nodes, 15*time.Minute)
+
+	assert.Equal(t, []string{"cluster1-worker-2"}, joining)
+	require.Len(t, orphans, 3)
+
+	assert.Equal(t, "cluster1-worker-1", orphans[0].Name)
+	require.NotNil(t, orphans[0].LaunchTime)
+	assert.Equal(t, "72h0m0s", orphans[0].Age)
+	require.NotNil(t, orphans[0].MonthlyCost)
+	assert.InDelta(t, hoursPerMonth, *orphans[0].MonthlyCost, 0)
+
+	assert.Equal(t, "cluster1-worker-3", orphans[1].Name)
+	assert.Nil(t, orphans[1].MonthlyCost)
+
+	// An instance whose launch time isn't known is still reported
+	assert.Equal(t, "cluster1-worker-4", orphans[2].Name)
+	assert.Nil(t, orphans[2].LaunchTime)
+	require.NotNil(t, orphans[2].MonthlyCost)
+
+	total := orphanMonthlyCost(orphans)
+	require.NotNil(t, total)
+	assert.InDelta(t, 2*hoursPerMonth, *total, 0)
+
+	assert.Nil(t, orphanMonthlyCost(orphans[1:2]))
+}
+
+func TestReconcileSummary(t *testing.T) {
+	cost := 61.32
+	result := ReconcileResult{
+		UntaggedNodes:     []string{"cluster1-worker-4 (i-4)"},
+		EC2NotInK8s:       []OrphanNode{{Name: "cluster1-worker-5", ID: "i-5"}, {Name: "cluster1-worker-6", ID: "i-6"}},
+		JoiningNodes:      []string{"cluster1-worker-7"},
+		OrphanCost:        &cost,
+		EC2NotInLB:        []string{"cluster1-worker-5", "cluster1-worker-6"},
+		DuplicateK8sNames: []DuplicateName{{Name: "cluster1-cp-1", IDs: []string{"cluster1-cp-1", "cluster1-cp-1.example.com"}}},
+		Message:           "Found 7 issue(s) in cluster state",
+		TotalIssuesFound:  7,
+		ControlPlaneSpread: &ZoneSpread{
+			Zones:    map[string]int{"us-east-1a": 3},
+			MinZones: 3,
+		},
+	}
+
+	assert.Equal(t, ReconcileSummary{
+		UntaggedNodes:          1,
+		EC2NotInK8s:            2,
+		JoiningNodes:           1,
+		EC2NotInLB:             2,
+		DuplicateK8sNames:      1,
+		UnbalancedControlPlane: 1,
+		OrphanCost:             &cost,
+		Message:                "Found 7 issue(s) in cluster state",
+		TotalIssuesFound:       7,
+	}, result.Summary())
+
+	result.ControlPlaneSpread.Balanced = true
+	assert.Equal(t, 0, result.Summary().UnbalancedControlPlane)
+	assert.Equal(t, 0, ReconcileResult{}.Summary().TotalIssuesFound)
+	assert.Nil(t, result.Summary().ClusterInfo)
+
+	info := manager.ClusterInfo{Name: "cluster1", Nodes: []manager.NodeInfo{{Name: "cluster1-worker-1", ID: "i-1"}}}
+	result.ClusterInfo = &info
+	assert.Equal(t, &info, result.Summary().ClusterInfo)
+}
@@ -0,0 +1,127 @@
+package k8sctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ReconcileStreamContentType is the content type of a streamed reconcile.
+const ReconcileStreamContentType = "application/x-ndjson"
+
+// The types of the events in a streamed reconcile.
+const (
+	ReconcileEventProgress    = "progress"
+	ReconcileEventDiscrepancy = "discrepancy"
+	ReconcileEventSummary     = "summary"
+	ReconcileEventError       = "error"
+)
+
+// ReconcileEvent is one line of a streamed reconcile's JSON Lines: its progress, each discrepancy as it's found, then
+// the summary, or an error that ended the reconcile.
+type ReconcileEvent struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"` // what a progress event reports, or the error
+
+	// Kind is the ReconcileResult field a discrepancy is listed in, e.g. ec2_not_in_k8s, and Node is what it lists.
+	// Detail is the rest of what the field has on it, e.g. an ec2_not_in_k8s instance's OrphanNode.
+	Kind   string `json:"kind,omitempty"`
+	Node   string `json:"node,omitempty"`
+	Detail any    `json:"detail,omitempty"`
+
+	Summary *ReconcileSummary `json:"summary,omitempty"`
+}
+
+// ConsolePrint prints a progress, discrepancy, or error event on one line.  The summary's left to the caller, since
+// it's the result.
+func (e ReconcileEvent) ConsolePrint() {
+	switch e.Type {
+	case ReconcileEventProgress:
+		fmt.Printf("%s %s\n", consoleMarkers.Wait, e.Message)
+	case ReconcileEventDiscrepancy:
+		if e.Node == "" {
+			fmt.Printf("  %s %s\n", consoleMarkers.Warn, e.Kind)
+			return
+		}
+		fmt.Printf("  %s %s: %s\n", consoleMarkers.Warn, e.Kind, e.Node)
+	case ReconcileEventError:
+		fmt.Printf("%s %s\n", consoleMarkers.Error, e.Message)
+	}
+}
+
+// reconcileStream writes a reconcile's events to its response as they happen.  A nil reconcileStream, for a reconcile
+// that isn't streamed, writes nothing, so the handler can report to it either way.
+type reconcileStream struct {
+	ctx     *gin.Context
+	started bool
+}
+
+// newReconcileStream returns a stream for the response, if the reconcile's asked to stream, or nil.
+func newReconcileStream(ctx *gin.Context, stream bool) (s *reconcileStream) {
+	if !stream {
+		return s
+	}
+
+	s = &reconcileStream{ctx: ctx}
+	return s
+}
+
+// Progress reports what the reconcile's doing, or what it's found that isn't a discrepancy.
+func (s *reconcileStream) Progress(format string, args ...interface{}) {
+	s.write(ReconcileEvent{Type: ReconcileEventProgress, Message: fmt.Sprintf(format, args...)})
+}
+
+// Discrepancy reports a discrepancy, listed in the result's kind field.
+func (s *reconcileStream) Discrepancy(kind string, node string, detail any) {
+	s.write(ReconcileEvent{Type: ReconcileEventDiscrepancy, Kind: kind, Node: node, Detail: detail})
+}
+
+// Discrepancies reports each of the nodes listed in the result's kind field.
+func (s *reconcileStream) Discrepancies(kind string, nodes []string) {
+	for _, node := range nodes {
+		s.Discrepancy(kind, node, nil)
+	}
+}
+
+// Summary ends the stream with the reconcile's summary.
+func (s *reconcileStream) Summary(summary ReconcileSummary) {
+	s.write(ReconcileEvent{Type: ReconcileEventSummary, Summary: &summary})
+}
+
+// Abort ends the reconcile with an error.  Once the stream's started, the status is 200, so the error's written to it.
+// Before then, and for a reconcile that isn't streamed, the request is aborted with a 500.
+func (s *reconcileStream) Abort(ctx *gin.Context, err error) {
+	if s == nil || !s.started {
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	_ = ctx.Error(err)
+	s.write(ReconcileEvent{Type: ReconcileEventError, Message: err.Error()})
+}
+
+// write writes an event as a line of JSON, starting the response with the first.
+func (s *reconcileStream) write(event ReconcileEvent) {
+	if s == nil {
+		return
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		logrus.Errorf("failed marshalling reconcile event: %s", err)
+		return
+	}
+
+	if !s.started {
+		s.ctx.Writer.Header().Set("Content-Type", ReconcileStreamContentType)
+		s.ctx.Writer.Header().Set("Transfer-Encoding", "chunked")
+		s.ctx.Writer.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+
+	// A client that's gone away is found out when the reconcile's request context is cancelled, if at all
+	writeOutput(s.ctx, string(line)+"\n")
+}
@@ -0,0 +1,84 @@
+package k8sctl
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReconcileStream checks a streamed reconcile is a line of JSON per event, that an error once it's started is
+// written to it, and that a reconcile that isn't streamed, or hasn't started, is aborted as usual.
+func TestReconcileStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stream", func(ctx *gin.Context) {
+		stream := newReconcileStream(ctx, true)
+		stream.Progress("describing cluster %s", "cluster1")
+		stream.Discrepancies("k8s_not_in_ec2", []string{"cluster1-worker-1", "cluster1-worker-2"})
+		stream.Discrepancy("duplicate_ec2_names", "cluster1-worker-3", DuplicateName{Name: "cluster1-worker-3", IDs: []string{"i-1", "i-2"}})
+		stream.Summary(ReconcileResult{K8sNotInEC2: []string{"cluster1-worker-1", "cluster1-worker-2"}, TotalIssuesFound: 3}.Summary())
+	})
+	router.POST("/fail", func(ctx *gin.Context) {
+		stream := newReconcileStream(ctx, true)
+		stream.Progress("describing cluster %s", "cluster1")
+		stream.Abort(ctx, errors.New("throttled"))
+	})
+	router.POST("/fail-early", func(ctx *gin.Context) {
+		stream := newReconcileStream(ctx, true)
+		stream.Abort(ctx, errors.New("throttled"))
+	})
+	router.POST("/unstreamed", func(ctx *gin.Context) {
+		stream := newReconcileStream(ctx, false)
+		stream.Progress("describing cluster %s", "cluster1")
+		stream.Abort(ctx, errors.New("throttled"))
+	})
+
+	events := func(body string) (events []ReconcileEvent) {
+		scanner := bufio.NewScanner(strings.NewReader(body))
+		for scanner.Scan() {
+			var event ReconcileEvent
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), scanner.Text())
+			events = append(events, event)
+		}
+
+		return events
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/stream", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, ReconcileStreamContentType, recorder.Header().Get("Content-Type"))
+
+	streamed := events(recorder.Body.String())
+	require.Len(t, streamed, 5)
+	assert.Equal(t, ReconcileEvent{Type: ReconcileEventProgress, Message: "describing cluster cluster1"}, streamed[0])
+	assert.Equal(t, ReconcileEvent{Type: ReconcileEventDiscrepancy, Kind: "k8s_not_in_ec2", Node: "cluster1-worker-2"}, streamed[2])
+	assert.Equal(t, "duplicate_ec2_names", streamed[3].Kind)
+	assert.NotNil(t, streamed[3].Detail)
+	assert.Equal(t, ReconcileEventSummary, streamed[4].Type)
+	require.NotNil(t, streamed[4].Summary)
+	assert.Equal(t, 2, streamed[4].Summary.K8sNotInEC2)
+	assert.Equal(t, 3, streamed[4].Summary.TotalIssuesFound)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/fail", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	failed := events(recorder.Body.String())
+	require.Len(t, failed, 2)
+	assert.Equal(t, ReconcileEvent{Type: ReconcileEventError, Message: "throttled"}, failed[1])
+
+	for _, path := range []string{"/fail-early", "/unstreamed"} {
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, nil))
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code, path)
+		assert.Empty(t, recorder.Body.String(), path)
+	}
+}
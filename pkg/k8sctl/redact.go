@@ -0,0 +1,162 @@
+package k8sctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// RedactedValue stands in for a secret in log and debug output.
+const RedactedValue = "[REDACTED]"
+
+// redactedTokenPrefix is how much of a token RedactToken leaves, enough to tell tokens apart, but not to use one.
+const redactedTokenPrefix = 8
+
+// sensitiveFieldNames are what the names of fields holding secrets contain, compared without case, '_', or '-'.
+var sensitiveFieldNames = []string{"token", "secret", "password", "passwd", "credential", "privatekey", "apikey"}
+
+// RedactToken returns a token safe to log: its first few characters and its length.  Tokens too short to keep any of
+// are redacted entirely.
+func RedactToken(token string) (redacted string) {
+	if token == "" {
+		return redacted
+	}
+
+	if len(token) < 4*redactedTokenPrefix {
+		redacted = fmt.Sprintf("%s (%d chars)", RedactedValue, len(token))
+		return redacted
+	}
+
+	redacted = fmt.Sprintf("%s...%s (%d chars)", token[:redactedTokenPrefix], RedactedValue, len(token))
+	return redacted
+}
+
+// SensitiveField returns whether a field of this name holds a secret, e.g. client_secret, id_token, or Password.
+func SensitiveField(name string) (sensitive bool) {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+	for _, pattern := range sensitiveFieldNames {
+		if strings.Contains(normalized, pattern) {
+			sensitive = true
+			return sensitive
+		}
+	}
+
+	return sensitive
+}
+
+// RedactConfig returns a struct, or a map with string keys, safe to log: its fields, named as in its YAML or JSON,
+// with the values of sensitive ones redacted.  Anything else is formatted as is.
+func RedactConfig(v any) (redacted string) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			redacted = "<nil>"
+			return redacted
+		}
+		value = value.Elem()
+	}
+
+	var fields []string
+
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := range value.NumField() {
+			field := value.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name := fieldName(field)
+			fields = append(fields, fmt.Sprintf("%s: %s", name, redactValue(name, value.Field(i).Interface())))
+		}
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			redacted = fmt.Sprintf("%v", v)
+			return redacted
+		}
+
+		for _, key := range value.MapKeys() {
+			name := key.String()
+			fields = append(fields, fmt.Sprintf("%s: %s", name, redactValue(name, value.MapIndex(key).Interface())))
+		}
+		sort.Strings(fields)
+	default:
+		redacted = fmt.Sprintf("%v", v)
+		return redacted
+	}
+
+	redacted = "{" + strings.Join(fields, ", ") + "}"
+	return redacted
+}
+
+// RedactJSON returns a JSON document safe to log, with the values of its sensitive fields redacted, at any depth, e.g.
+// a token response's id_token.  A body that isn't JSON is redacted entirely, as there's no telling what's in it.
+func RedactJSON(body []byte) (redacted string) {
+	var document any
+	err := json.Unmarshal(body, &document)
+	if err != nil {
+		redacted = fmt.Sprintf("%s (%d bytes, not JSON)", RedactedValue, len(body))
+		return redacted
+	}
+
+	out, err := json.Marshal(redactJSONValue(document))
+	if err != nil {
+		redacted = fmt.Sprintf("%s (%d bytes)", RedactedValue, len(body))
+		return redacted
+	}
+
+	redacted = string(out)
+	return redacted
+}
+
+// redactJSONValue redacts the sensitive fields of a decoded JSON value, and of the objects nested in it.
+func redactJSONValue(value any) (redacted any) {
+	switch typed := value.(type) {
+	case map[string]any:
+		object := make(map[string]any, len(typed))
+		for name, field := range typed {
+			if SensitiveField(name) && field != nil && field != "" {
+				object[name] = RedactedValue
+				continue
+			}
+			object[name] = redactJSONValue(field)
+		}
+		redacted = object
+	case []any:
+		list := make([]any, len(typed))
+		for i, item := range typed {
+			list[i] = redactJSONValue(item)
+		}
+		redacted = list
+	default:
+		redacted = value
+	}
+
+	return redacted
+}
+
+// fieldName is a struct field's name in its YAML or JSON, or failing those, in Go.
+func fieldName(field reflect.StructField) (name string) {
+	for _, tag := range []string{"yaml", "json"} {
+		tagName, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if tagName != "" && tagName != "-" {
+			name = tagName
+			return name
+		}
+	}
+
+	name = field.Name
+	return name
+}
+
+// redactValue formats a field's value, redacted if the field's sensitive and set.
+func redactValue(name string, value any) (formatted string) {
+	formatted = fmt.Sprintf("%v", value)
+	if SensitiveField(name) && formatted != "" {
+		formatted = RedactedValue
+	}
+
+	return formatted
+}
@@ -0,0 +1,206 @@
+package k8sctl
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// repairHistorySize is how many repairs the server remembers, across all its clusters.  Older ones are dropped.
+const repairHistorySize = 1000
+
+// Repair kinds.
+const (
+	RepairKindFixTags    = "fix_tags"    // a reconcile with fix_tags
+	RepairKindLBReattach = "lb_reattach" // a node lb-reattach
+)
+
+// RepairRecord is one repair made to a cluster: when, by whom, and what was changed.  Changes that failed are recorded
+// too, each with its error, since they may have been partly made.
+type RepairRecord struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user,omitempty"`
+	Cluster string    `json:"cluster"`
+	Kind    string    `json:"kind"`
+	Node    string    `json:"node,omitempty"` // the node repaired, for a repair of one node
+
+	TagFixes        []TagFix         `json:"tag_fixes,omitempty"`        // the tags set, with fix_tags
+	LBReattachments []LBReattachment `json:"lb_reattachments,omitempty"` // the target groups reattached to, with lb_reattach
+}
+
+// RepairHistoryResult is a cluster's recent repairs, newest first.
+type RepairHistoryResult struct {
+	Cluster string         `json:"cluster"`
+	Repairs []RepairRecord `json:"repairs"`
+}
+
+// ConsolePrint prints each repair, then what it changed, a line each.
+func (r RepairHistoryResult) ConsolePrint() {
+	if len(r.Repairs) == 0 {
+		fmt.Printf("No repairs recorded for cluster %s since the server started\n", r.Cluster)
+		return
+	}
+
+	for _, repair := range r.Repairs {
+		fmt.Printf("%s\n", repair.Summary())
+
+		for _, fix := range repair.TagFixes {
+			marker := consoleMarkers.OK
+			if fix.Error != "" {
+				marker = consoleMarkers.Error
+			}
+			fmt.Printf("  %s tags on %s\n", marker, fix.Summary())
+		}
+
+		for _, reattachment := range repair.LBReattachments {
+			marker := consoleMarkers.OK
+			if reattachment.Error != "" {
+				marker = consoleMarkers.Error
+			}
+			fmt.Printf("  %s %s\n", marker, reattachment.Summary())
+		}
+	}
+}
+
+// Summary formats the repair on one line, e.g. "2025-06-01T12:00:00Z fix_tags by alice@example.com: 3 instance(s), 1
+// failed".
+func (r RepairRecord) Summary() (summary string) {
+	summary = fmt.Sprintf("%s %s", r.Time.UTC().Format(time.RFC3339), r.Kind)
+	if r.Node != "" {
+		summary += " of " + r.Node
+	}
+	if r.User != "" {
+		summary += " by " + r.User
+	}
+
+	var failed int
+	for _, fix := range r.TagFixes {
+		if fix.Error != "" {
+			failed++
+		}
+	}
+	for _, reattachment := range r.LBReattachments {
+		if reattachment.Error != "" {
+			failed++
+		}
+	}
+
+	var parts []string
+	if len(r.TagFixes) > 0 {
+		parts = append(parts, fmt.Sprintf("%d instance(s)", len(r.TagFixes)))
+	}
+	if len(r.LBReattachments) > 0 {
+		parts = append(parts, fmt.Sprintf("%d target group(s)", len(r.LBReattachments)))
+	}
+	if failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", failed))
+	}
+
+	if len(parts) > 0 {
+		summary += ": " + strings.Join(parts, ", ")
+	}
+
+	return summary
+}
+
+// repairHistory is a ring buffer of the latest repairs, in memory.  It's lost on a restart: the server logs hold the
+// older record.
+type repairHistory struct {
+	mu      sync.Mutex
+	records []RepairRecord
+	next    int // where the next record goes, once the buffer's full
+	size    int
+}
+
+var repairs = newRepairHistory(repairHistorySize)
+
+func newRepairHistory(size int) (history *repairHistory) {
+	history = &repairHistory{
+		records: make([]RepairRecord, 0, size),
+		size:    size,
+	}
+
+	return history
+}
+
+// record adds a repair, dropping the oldest if the history's full.
+func (h *repairHistory) record(repair RepairRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.records) < h.size {
+		h.records = append(h.records, repair)
+		return
+	}
+
+	h.records[h.next] = repair
+	h.next = (h.next + 1) % h.size
+}
+
+// cluster returns the cluster's repairs, newest first, at most limit of them, or all of them if limit is 0.
+func (h *repairHistory) cluster(clusterName string, limit int) (records []RepairRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records = make([]RepairRecord, 0)
+
+	for i := range h.records {
+		// Newest first: back from the last record written
+		repair := h.records[(h.next+len(h.records)-1-i)%len(h.records)]
+		if repair.Cluster != clusterName {
+			continue
+		}
+
+		records = append(records, repair)
+		if limit > 0 && len(records) == limit {
+			break
+		}
+	}
+
+	return records
+}
+
+// recordRepair adds a repair made by the request's caller to the repair history.
+func recordRepair(ctx *gin.Context, repair RepairRecord) {
+	repair.Time = time.Now()
+	repair.User = ctx.GetString("user_email")
+
+	repairs.record(repair)
+}
+
+// recordTagFixes records the tag fixes a reconcile made, if it made any.
+func recordTagFixes(ctx *gin.Context, clusterName string, fixes []TagFix) {
+	if len(fixes) == 0 {
+		return
+	}
+
+	recordRepair(ctx, RepairRecord{Cluster: clusterName, Kind: RepairKindFixTags, TagFixes: slices.Clone(fixes)})
+}
+
+// RepairHistoryHandler returns the cluster's recent repairs, newest first, optionally at most ?limit= of them.
+func (c *K8sCtlCommands) RepairHistoryHandler(ctx *gin.Context) {
+	clusterName := ctx.Param("cluster")
+
+	var limit int
+	if value := ctx.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			err = errors.New(fmt.Sprintf("invalid limit %q: must be a number, 0 or more", value))
+			_ = ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	logrus.Infof("listing repair history of cluster %s", clusterName)
+
+	ctx.JSON(http.StatusOK, RepairHistoryResult{Cluster: clusterName, Repairs: repairs.cluster(clusterName, limit)})
+}
@@ -0,0 +1,95 @@
+package k8sctl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepairHistory(t *testing.T) {
+	history := newRepairHistory(3)
+	assert.Empty(t, history.cluster("cluster1", 0))
+
+	history.record(RepairRecord{Cluster: "cluster1", Node: "1"})
+	history.record(RepairRecord{Cluster: "cluster2", Node: "2"})
+	history.record(RepairRecord{Cluster: "cluster1", Node: "3"})
+
+	nodes := func(records []RepairRecord) (names []string) {
+		for _, record := range records {
+			names = append(names, record.Node)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"3", "1"}, nodes(history.cluster("cluster1", 0)))
+	assert.Equal(t, []string{"3"}, nodes(history.cluster("cluster1", 1)))
+	assert.Equal(t, []string{"2"}, nodes(history.cluster("cluster2", 0)))
+
+	// Once full, the oldest are dropped
+	history.record(RepairRecord{Cluster: "cluster1", Node: "4"})
+	history.record(RepairRecord{Cluster: "cluster1", Node: "5"})
+	assert.Equal(t, []string{"5", "4", "3"}, nodes(history.cluster("cluster1", 0)))
+	assert.Empty(t, history.cluster("cluster2", 0))
+
+	history.record(RepairRecord{Cluster: "cluster1", Node: "6"})
+	assert.Equal(t, []string{"6", "5", "4"}, nodes(history.cluster("cluster1", 0)))
+}
+
+func TestRepairRecordSummary(t *testing.T) {
+	repair := RepairRecord{
+		Cluster:  "cluster1",
+		Kind:     RepairKindFixTags,
+		User:     "alice@example.com",
+		TagFixes: []TagFix{{ID: "i-1"}, {ID: "i-2", Error: "throttled"}},
+	}
+	assert.Equal(t, "0001-01-01T00:00:00Z fix_tags by alice@example.com: 2 instance(s), 1 failed", repair.Summary())
+
+	repair = RepairRecord{Cluster: "cluster1", Kind: RepairKindLBReattach, Node: "cluster1-worker-2", LBReattachments: []LBReattachment{{}}}
+	assert.Equal(t, "0001-01-01T00:00:00Z lb_reattach of cluster1-worker-2: 1 target group(s)", repair.Summary())
+}
+
+func TestRepairHistoryHandler(t *testing.T) {
+	saved := repairs
+	t.Cleanup(func() { repairs = saved })
+	repairs = newRepairHistory(10)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) { ctx.Set("user_email", "alice@example.com") })
+	router.GET("/cluster/:cluster/reconcile/history", (&K8sCtlCommands{}).RepairHistoryHandler)
+	router.POST("/cluster/:cluster/record", func(ctx *gin.Context) {
+		recordTagFixes(ctx, ctx.Param("cluster"), []TagFix{{ID: "i-1", Name: "cluster1-worker-1", Added: map[string]string{"Cluster": "cluster1"}}})
+		recordTagFixes(ctx, ctx.Param("cluster"), nil)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/cluster/cluster1/record", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/cluster/cluster1/reconcile/history", nil))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var result RepairHistoryResult
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Equal(t, "cluster1", result.Cluster)
+	require.Len(t, result.Repairs, 1)
+	assert.Equal(t, "alice@example.com", result.Repairs[0].User)
+	assert.Equal(t, RepairKindFixTags, result.Repairs[0].Kind)
+	assert.False(t, result.Repairs[0].Time.IsZero())
+	assert.Equal(t, "i-1", result.Repairs[0].TagFixes[0].ID)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/cluster/cluster2/reconcile/history", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"cluster": "cluster2", "repairs": []}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/cluster/cluster1/reconcile/history?limit=-1", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -0,0 +1,142 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+)
+
+// MissingTags is an instance missing some of the tags the server config requires.
+type MissingTags struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Missing []string `json:"missing"` // the required tags it doesn't have, or has empty
+}
+
+// Summary formats the instance and its missing tags on one line, e.g. "cluster1-worker-2 (i-0123): team, cost-center".
+func (m MissingTags) Summary() (summary string) {
+	summary = fmt.Sprintf("%s (%s): %s", m.Name, m.ID, strings.Join(m.Missing, ", "))
+	return summary
+}
+
+// requiredTags returns the tags a cluster's instances must have, each with the value fix_tags sets when it's missing,
+// from the server config.
+func requiredTags(clusterName string) (tags map[string]string) {
+	if clusterConfig == nil {
+		return tags
+	}
+
+	tags = clusterConfig.GetRequiredTags(clusterName)
+	return tags
+}
+
+// missingRequiredTags finds the nodes whose instances are missing any of the required tags, each instance once.  Nodes
+// whose instance wasn't found are left out, since what tags they have isn't known.
+func missingRequiredTags(nodes []manager.NodeInfo, instances map[string]ec2types.Instance, required map[string]string) (missing []MissingTags) {
+	if len(required) == 0 {
+		return missing
+	}
+
+	keys := sortedKeys(required)
+
+	seen := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		instance, ok := instances[node.ID]
+		if !ok || seen[node.ID] {
+			continue
+		}
+		seen[node.ID] = true
+
+		tags := make(map[string]string, len(instance.Tags))
+		for _, tag := range instance.Tags {
+			tags[awssdk.ToString(tag.Key)] = awssdk.ToString(tag.Value)
+		}
+
+		var absent []string
+		for _, key := range keys {
+			if tags[key] == "" {
+				absent = append(absent, key)
+			}
+		}
+
+		if len(absent) > 0 {
+			missing = append(missing, MissingTags{ID: node.ID, Name: node.Name, Missing: absent})
+		}
+	}
+
+	return missing
+}
+
+// planRequiredTags returns the fixes fix_tags would make to instances missing required tags: setting each missing
+// tag that has a default.  Instances missing only tags without defaults are left to be tagged by hand.
+func planRequiredTags(missing []MissingTags, required map[string]string) (plan []TagFix) {
+	for _, instance := range missing {
+		added := make(map[string]string)
+		previous := make(map[string]string)
+		for _, key := range instance.Missing {
+			if required[key] != "" {
+				added[key] = required[key]
+				previous[key] = ""
+			}
+		}
+
+		if len(added) > 0 {
+			plan = append(plan, TagFix{ID: instance.ID, Name: instance.Name, Added: added, Previous: previous})
+		}
+	}
+
+	return plan
+}
+
+// fixRequiredTags sets the missing required tags with defaults, as planRequiredTags plans.  Instances missing the same
+// tags are tagged together, in batches, as the Cluster tag is.
+func fixRequiredTags(ctx context.Context, cm *aws.AWSClusterManager, missing []MissingTags, required map[string]string) (fixes []TagFix) {
+	fixes = planRequiredTags(missing, required)
+
+	groups := make(map[string][]int)
+	for i, fix := range fixes {
+		group := tagsString(fix.Added)
+		groups[group] = append(groups[group], i)
+	}
+
+	for _, indexes := range groups {
+		group := make([]TagFix, len(indexes))
+		for i, index := range indexes {
+			group[i] = fixes[index]
+		}
+
+		var tags []ec2types.Tag
+		for _, key := range sortedKeys(group[0].Added) {
+			tags = append(tags, ec2types.Tag{Key: awssdk.String(key), Value: awssdk.String(group[0].Added[key])})
+		}
+
+		applyTagFixes(ctx, cm, group, func(instanceIDs []string) (err error) {
+			_, err = cm.Ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{Resources: instanceIDs, Tags: tags})
+			return err
+		})
+
+		for i, index := range indexes {
+			fixes[index] = group[i]
+		}
+	}
+
+	return fixes
+}
+
+// tagsString formats tags as quoted key=value pairs, sorted, so identical sets of tags, and only those, format the same.
+func tagsString(tags map[string]string) (s string) {
+	pairs := make([]string, 0, len(tags))
+	for _, key := range sortedKeys(tags) {
+		pairs = append(pairs, strconv.Quote(key)+"="+strconv.Quote(tags[key]))
+	}
+
+	s = strings.Join(pairs, ",")
+	return s
+}
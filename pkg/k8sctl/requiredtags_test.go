@@ -0,0 +1,78 @@
+package k8sctl
+
+import (
+	"context"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMissingRequiredTags(t *testing.T) {
+	instances := map[string]ec2types.Instance{
+		"i-1": {InstanceId: awssdk.String("i-1"), Tags: []ec2types.Tag{
+			{Key: awssdk.String("team"), Value: awssdk.String("platform")},
+			{Key: awssdk.String("cost-center"), Value: awssdk.String("1234")},
+		}},
+		"i-2": {InstanceId: awssdk.String("i-2"), Tags: []ec2types.Tag{
+			{Key: awssdk.String("team"), Value: awssdk.String("")},
+		}},
+	}
+
+	nodes := []manager.NodeInfo{
+		{Name: "cluster1-worker-1", ID: "i-1"},
+		{Name: "cluster1-worker-2", ID: "i-2"},
+		{Name: "cluster1-worker-2", ID: "i-2"},
+		{Name: "cluster1-worker-3", ID: "i-gone"},
+	}
+
+	required := map[string]string{"team": "", "cost-center": "1234"}
+
+	missing := missingRequiredTags(nodes, instances, required)
+	assert.Equal(t, []MissingTags{{ID: "i-2", Name: "cluster1-worker-2", Missing: []string{"cost-center", "team"}}}, missing)
+	assert.Equal(t, "cluster1-worker-2 (i-2): cost-center, team", missing[0].Summary())
+
+	assert.Empty(t, missingRequiredTags(nodes, instances, nil))
+
+	// Only the tags with defaults are planned
+	assert.Equal(t, []TagFix{
+		{ID: "i-2", Name: "cluster1-worker-2", Added: map[string]string{"cost-center": "1234"}, Previous: map[string]string{"cost-center": ""}},
+	}, planRequiredTags(missing, required))
+	assert.Empty(t, planRequiredTags(missing, map[string]string{"team": "", "cost-center": ""}))
+}
+
+func TestFixRequiredTags(t *testing.T) {
+	ec2Client := &fakeTagEC2Client{failing: []string{"i-3"}}
+	cm := &aws.AWSClusterManager{Name: "cluster1", Context: context.Background(), Ec2Client: ec2Client}
+
+	required := map[string]string{"team": "platform", "cost-center": "1234", "owner": ""}
+	missing := []MissingTags{
+		{ID: "i-1", Name: "cluster1-worker-1", Missing: []string{"cost-center", "team"}},
+		{ID: "i-2", Name: "cluster1-worker-2", Missing: []string{"cost-center"}},
+		{ID: "i-3", Name: "cluster1-worker-3", Missing: []string{"cost-center"}},
+		{ID: "i-4", Name: "cluster1-worker-4", Missing: []string{"owner"}},
+	}
+
+	fixes := fixRequiredTags(context.Background(), cm, missing, required)
+	require.Len(t, fixes, 3)
+
+	// Instances missing the same tags are tagged together, and one that fails doesn't fail the rest
+	created := make(map[string][]ec2types.Tag)
+	for _, input := range ec2Client.created {
+		for _, instanceID := range input.Resources {
+			created[instanceID] = input.Tags
+		}
+	}
+
+	require.Len(t, created, 2)
+	assert.Len(t, created["i-1"], 2)
+	assert.Equal(t, []ec2types.Tag{{Key: awssdk.String("cost-center"), Value: awssdk.String("1234")}}, created["i-2"])
+
+	assert.Equal(t, "i-3", fixes[2].ID)
+	assert.Contains(t, fixes[2].Error, "InvalidInstanceID.NotFound")
+	assert.Equal(t, 1, failedTagFixes(fixes))
+}
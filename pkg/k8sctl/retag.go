@@ -0,0 +1,320 @@
+package k8sctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	k8s_utility_client "github.com/nikogura/k8s-utility-client/pkg/k8s-utility-client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// purposeKey is the EC2 tag, Kubernetes label, and Kubernetes taint key a node's purpose is recorded under.
+const purposeKey = "purpose"
+
+// NodeRetagBody names the tags to change on a node.  Empty fields are left as they are.
+type NodeRetagBody struct {
+	Name    string `json:"name,omitempty"`    // new EC2 Name tag
+	Purpose string `json:"purpose,omitempty"` // new purpose tag, label and taint
+	Cluster string `json:"cluster,omitempty"` // new Cluster tag
+	Verbose bool   `json:"verbose"`
+	Region  string `json:"region,omitempty"`
+}
+
+// NodeRetagResult reports the tags and labels a retag changed, with their values before and after.
+type NodeRetagResult struct {
+	Node         string            `json:"node"`
+	ID           string            `json:"id"`
+	PreviousTags map[string]string `json:"previous_tags"`
+	Tags         map[string]string `json:"tags"`             // the tags applied
+	Labels       map[string]string `json:"labels,omitempty"` // the Kubernetes labels applied
+	Error        string            `json:"error,omitempty"`  // set when the tags were applied, but the labels weren't
+}
+
+// ConsolePrint prints each changed tag and label, before and after.
+func (r NodeRetagResult) ConsolePrint() {
+	fmt.Printf("Retagged %s (%s)\n", r.Node, r.ID)
+
+	keys := make([]string, 0, len(r.Tags))
+	for key := range r.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Printf("  tag %s: %q -> %q\n", key, r.PreviousTags[key], r.Tags[key])
+	}
+
+	for key, value := range r.Labels {
+		fmt.Printf("  label %s: %q\n", key, value)
+	}
+
+	if r.Error != "" {
+		fmt.Printf("  failed updating labels: %s\n", r.Error)
+	}
+}
+
+// RetagNodeHandler corrects the Name, purpose, and Cluster tags on a node's EC2 instance, without recreating it.
+// A new purpose is also applied to the Kubernetes node's purpose label and taint.  The Kubernetes node name is the
+// node's hostname, so a new Name tag doesn't rename the Kubernetes node.
+func (c *K8sCtlCommands) RetagNodeHandler(ctx *gin.Context) {
+	clusterName := ctx.Param("cluster")
+	nodeName := ctx.Param("node")
+
+	var body NodeRetagBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	tags := retagTags(body)
+	if len(tags) == 0 {
+		err = errors.New("nothing to retag: give a new name, purpose, or cluster")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	logrus.Infof("retagging node %s in cluster %s: %v", nodeName, clusterName, tags)
+
+	cm, err := newClusterManager(ctx, clusterName, body.Region, body.Verbose)
+	if err != nil {
+		logrus.Errorf("Failed creating cluster manager: %s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	nodeInfo, err := cm.GetNode(nodeName)
+	if err != nil {
+		logrus.Errorf("failed getting node %s: %s", nodeName, err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if nodeInfo.ID == "" {
+		err = errors.New(fmt.Sprintf("no running instance found for node %s", nodeName))
+		_ = ctx.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+
+	if body.Name != "" && body.Name != nodeName {
+		err = checkNodeNameFree(ctx, cm, body.Name, nodeInfo.ID)
+		if err != nil {
+			logrus.Errorf("%s", err)
+			_ = ctx.AbortWithError(http.StatusConflict, err)
+			return
+		}
+	}
+
+	instances, err := cm.GetEC2InstancesByNodeID(nodeInfo.ID)
+	if err != nil {
+		logrus.Errorf("%s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	result := NodeRetagResult{
+		Node:         nodeName,
+		ID:           nodeInfo.ID,
+		PreviousTags: previousTags(instances, tags),
+		Tags:         tags,
+	}
+
+	err = applyInstanceTags(ctx, cm, nodeInfo.ID, tags)
+	if err != nil {
+		logrus.Errorf("%s", err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if body.Purpose != "" {
+		// The Kubernetes node is named for the node's hostname, which a corrected Name tag matches
+		k8sNodeName := nodeName
+		if body.Name != "" {
+			k8sNodeName = body.Name
+		}
+
+		err = setK8sNodePurpose(ctx, stripDomainSuffix(k8sNodeName), body.Purpose)
+		if err != nil {
+			// The tags are already changed, so say so, rather than just failing
+			logrus.Errorf("retagged node %s, but %s", nodeName, err)
+			result.Error = err.Error()
+			_ = ctx.Error(err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, result)
+			return
+		}
+
+		result.Labels = map[string]string{purposeKey: body.Purpose}
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// retagTags returns the EC2 tags a retag request asks for.
+func retagTags(body NodeRetagBody) (tags map[string]string) {
+	tags = make(map[string]string)
+
+	if body.Name != "" {
+		tags[aws.EC2TagName] = body.Name
+	}
+
+	if body.Purpose != "" {
+		tags[purposeKey] = body.Purpose
+	}
+
+	if body.Cluster != "" {
+		tags[aws.EC2TagCluster] = body.Cluster
+	}
+
+	return tags
+}
+
+// previousTags returns the current values of the given tags on an instance.  Tags the instance doesn't have are "".
+func previousTags(instances []ec2types.Instance, tags map[string]string) (previous map[string]string) {
+	previous = make(map[string]string, len(tags))
+
+	for key := range tags {
+		previous[key] = ""
+	}
+
+	for _, instance := range instances {
+		for _, tag := range instance.Tags {
+			if tag.Key == nil || tag.Value == nil {
+				continue
+			}
+
+			if _, ok := tags[*tag.Key]; ok {
+				previous[*tag.Key] = *tag.Value
+			}
+		}
+	}
+
+	return previous
+}
+
+// checkNodeNameFree returns an error if a running instance other than the node's, or a Kubernetes node backed by
+// another instance, already has the name.
+func checkNodeNameFree(ctx context.Context, cm *aws.AWSClusterManager, name string, nodeID string) (err error) {
+	existing, err := cm.GetNode(name)
+	if err != nil {
+		err = errors.Wrapf(err, "failed checking for an existing node named %s", name)
+		return err
+	}
+
+	if existing.ID != "" && existing.ID != nodeID {
+		err = errors.New(fmt.Sprintf("node name %s is already used by instance %s", name, existing.ID))
+		return err
+	}
+
+	clients, err := k8s_utility_client.NewK8sClients()
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating k8s clients")
+		return err
+	}
+
+	k8sNode, err := clients.ClientSet.CoreV1().Nodes().Get(ctx, stripDomainSuffix(name), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		err = nil
+		return err
+	}
+
+	if err != nil {
+		err = errors.Wrapf(err, "failed checking for a Kubernetes node named %s", name)
+		return err
+	}
+
+	// Correcting a Name tag to match the node's own hostname is the point of a retag, not a collision
+	if !providerIDIsInstance(k8sNode.Spec.ProviderID, nodeID) {
+		err = errors.New(fmt.Sprintf("node name %s is already used by a Kubernetes node", name))
+		return err
+	}
+
+	return err
+}
+
+// providerIDIsInstance returns true if a Kubernetes node's provider ID, e.g. aws:///us-east-1a/i-0123456789abcdef0,
+// names the given instance.
+func providerIDIsInstance(providerID string, instanceID string) (match bool) {
+	match = instanceID != "" && strings.HasSuffix(providerID, "/"+instanceID)
+	return match
+}
+
+// applyInstanceTags sets tags on an EC2 instance, overwriting any existing values.
+func applyInstanceTags(ctx context.Context, cm *aws.AWSClusterManager, instanceID string, tags map[string]string) (err error) {
+	ec2Tags := make([]ec2types.Tag, 0, len(tags))
+	for key, value := range tags {
+		ec2Tags = append(ec2Tags, ec2types.Tag{Key: awssdk.String(key), Value: awssdk.String(value)})
+	}
+
+	_, err = cm.Ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{instanceID},
+		Tags:      ec2Tags,
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed tagging instance %s", instanceID)
+		return err
+	}
+
+	return err
+}
+
+// setK8sNodePurpose sets a Kubernetes node's purpose label, and replaces its purpose taint.
+func setK8sNodePurpose(ctx context.Context, nodeName string, purpose string) (err error) {
+	clients, err := k8s_utility_client.NewK8sClients()
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating k8s clients")
+		return err
+	}
+
+	node, err := clients.ClientSet.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting Kubernetes node %s", nodeName)
+		return err
+	}
+
+	applyPurpose(node, purpose)
+
+	_, err = clients.ClientSet.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+	if err != nil {
+		err = errors.Wrapf(err, "failed updating purpose of Kubernetes node %s", nodeName)
+		return err
+	}
+
+	return err
+}
+
+// applyPurpose sets a node's purpose label, and replaces any purpose taint with one for the new purpose.
+// The taint matches the one the cluster manager applies when it creates a node with a purpose.
+func applyPurpose(node *corev1.Node, purpose string) {
+	if node.Labels == nil {
+		node.Labels = make(map[string]string)
+	}
+	node.Labels[purposeKey] = purpose
+
+	taints := make([]corev1.Taint, 0, len(node.Spec.Taints)+1)
+	for _, taint := range node.Spec.Taints {
+		if taint.Key != purposeKey {
+			taints = append(taints, taint)
+		}
+	}
+
+	node.Spec.Taints = append(taints, corev1.Taint{
+		Key:    purposeKey,
+		Value:  purpose,
+		Effect: corev1.TaintEffectNoSchedule,
+	})
+}
@@ -0,0 +1,59 @@
+package k8sctl
+
+import (
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRetagTags(t *testing.T) {
+	assert.Empty(t, retagTags(NodeRetagBody{Verbose: true}))
+
+	assert.Equal(t, map[string]string{
+		"Name":    "cluster1-worker-2",
+		"purpose": "ingress",
+		"Cluster": "cluster1",
+	}, retagTags(NodeRetagBody{Name: "cluster1-worker-2", Purpose: "ingress", Cluster: "cluster1"}))
+}
+
+func TestPreviousTags(t *testing.T) {
+	instances := []ec2types.Instance{{
+		Tags: []ec2types.Tag{
+			{Key: awssdk.String("Name"), Value: awssdk.String("cluster1-worker-7")},
+			{Key: awssdk.String("Cluster"), Value: awssdk.String("cluster1")},
+		},
+	}}
+
+	previous := previousTags(instances, map[string]string{"Name": "cluster1-worker-2", "purpose": "ingress"})
+
+	assert.Equal(t, map[string]string{"Name": "cluster1-worker-7", "purpose": ""}, previous)
+}
+
+func TestProviderIDIsInstance(t *testing.T) {
+	assert.True(t, providerIDIsInstance("aws:///us-east-1a/i-0123456789abcdef0", "i-0123456789abcdef0"))
+	assert.False(t, providerIDIsInstance("aws:///us-east-1a/i-0123456789abcdef0", "i-0fedcba9876543210"))
+	assert.False(t, providerIDIsInstance("", "i-0123456789abcdef0"))
+	assert.False(t, providerIDIsInstance("aws:///us-east-1a/", ""))
+}
+
+func TestApplyPurpose(t *testing.T) {
+	node := &corev1.Node{
+		Spec: corev1.NodeSpec{
+			Taints: []corev1.Taint{
+				{Key: "purpose", Value: "batch", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoExecute},
+			},
+		},
+	}
+
+	applyPurpose(node, "ingress")
+
+	assert.Equal(t, "ingress", node.Labels["purpose"])
+	assert.Equal(t, []corev1.Taint{
+		{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoExecute},
+		{Key: "purpose", Value: "ingress", Effect: corev1.TaintEffectNoSchedule},
+	}, node.Spec.Taints)
+}
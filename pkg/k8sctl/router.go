@@ -0,0 +1,100 @@
+package k8sctl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8sctl/pkg/oidc"
+	"github.com/pkg/errors"
+)
+
+// UnauthenticatedRoute is a route served to anyone, without a token, e.g. a health check or the server's build info.
+type UnauthenticatedRoute struct {
+	Method  string
+	Path    string
+	Handler gin.HandlerFunc
+}
+
+// RouterConfig is what NewRouter builds the server's router from.
+type RouterConfig struct {
+	Authenticate     gin.HandlerFunc   // validates the caller's token, e.g. oidc.Middleware
+	AccessLog        gin.HandlerFunc   // logs each authenticated request, nil for none
+	Middleware       []gin.HandlerFunc // run for every request, before anything else, e.g. gin.Recovery
+	CredentialGroups []string          // the groups allowed to call the routes returning credentials
+	AdminGroups      []string          // the groups allowed to call the admin routes, e.g. to turn maintenance mode on
+
+	// Unauthenticated are the only routes served without a token.  They can't be under APIPathPrefix.
+	Unauthenticated []UnauthenticatedRoute
+}
+
+// NewRouter returns the server's router, serving the APIRoutes under APIPathPrefix, and the unauthenticated routes.
+// Every request is authenticated unless it's for one of the unauthenticated routes, so a route added anywhere else,
+// under /v1 or not, needs a token by default.  Routes returning credentials are further restricted to the credential
+// groups, admin routes to the admin groups, and those taking idempotency keys only carry out a retried request once.
+// Routes changing clusters are refused while the server is in maintenance mode, before an idempotency key is looked at,
+// so a refusal isn't replayed once it's over.
+func (c *K8sCtlCommands) NewRouter(config RouterConfig) (router *gin.Engine, err error) {
+	if config.Authenticate == nil {
+		err = errors.New("no authentication middleware given")
+		return router, err
+	}
+
+	unauthenticated := make(map[string]bool)
+	for _, route := range config.Unauthenticated {
+		if route.Path == APIPathPrefix || strings.HasPrefix(route.Path, APIPathPrefix+"/") {
+			err = errors.New(fmt.Sprintf("unauthenticated route %s is under %s, which is always authenticated", route.Path, APIPathPrefix))
+			return router, err
+		}
+
+		unauthenticated[route.Method+" "+route.Path] = true
+	}
+
+	router = gin.New()
+	router.Use(config.Middleware...)
+
+	// Requests not matching an unauthenticated route, including those matching no route at all, are logged, then
+	// authenticated
+	authenticated := func(handler gin.HandlerFunc) (wrapped gin.HandlerFunc) {
+		wrapped = func(ctx *gin.Context) {
+			if unauthenticated[ctx.Request.Method+" "+ctx.FullPath()] {
+				ctx.Next()
+				return
+			}
+
+			handler(ctx)
+		}
+
+		return wrapped
+	}
+
+	if config.AccessLog != nil {
+		router.Use(authenticated(config.AccessLog))
+	}
+
+	router.Use(authenticated(config.Authenticate))
+
+	for _, route := range config.Unauthenticated {
+		router.Handle(route.Method, route.Path, route.Handler)
+	}
+
+	for _, route := range c.APIRoutes() {
+		handlers := []gin.HandlerFunc{route.Handler}
+		if route.Idempotent {
+			handlers = append([]gin.HandlerFunc{Idempotent()}, handlers...)
+		}
+		if route.Mutating {
+			handlers = append([]gin.HandlerFunc{RefuseInMaintenance()}, handlers...)
+		}
+		if route.Admin {
+			handlers = append([]gin.HandlerFunc{oidc.RequireGroups(config.AdminGroups)}, handlers...)
+		}
+		if route.Credentials {
+			handlers = append([]gin.HandlerFunc{oidc.RequireGroups(config.CredentialGroups)}, handlers...)
+		}
+
+		router.Handle(route.Method, APIPathPrefix+route.Path, handlers...)
+	}
+
+	return router, err
+}
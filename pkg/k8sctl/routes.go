@@ -0,0 +1,67 @@
+package k8sctl
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+)
+
+// APIRoute describes one route served under /v1.
+// The server registers its handlers from these, and the OpenAPI spec is generated from them, so the two can't drift.
+type APIRoute struct {
+	Method      string
+	Path        string // gin style, e.g. /cluster/:cluster/upgrade
+	Summary     string
+	Handler     gin.HandlerFunc
+	Request     interface{} // zero value of the request body type, nil if the route takes no body
+	Response    interface{} // zero value of the response body type, nil if the route returns no JSON body
+	ContentType string      // response content type, if not application/json
+	Credentials bool        // the route returns cluster credentials, so only the credential groups may call it
+	Idempotent  bool        // the route honours an Idempotency-Key header, so a retried request isn't carried out twice
+	RoleGated   bool        // the route checks the caller's groups itself, against the group the request needs
+	Destructive bool        // the route can delete, replace, or restart nodes, or evict their pods
+
+	// Mutating routes change clusters, so they're refused while the server is in maintenance mode, and Admin routes
+	// are restricted to the admin groups.  Routes with a dry_run aren't Mutating: their handlers refuse only the
+	// requests that aren't dry runs, so previews keep working.
+	Mutating bool
+	Admin    bool
+}
+
+// APIRoutes returns the routes served under /v1.
+func (c *K8sCtlCommands) APIRoutes() (routes []APIRoute) {
+	routes = []APIRoute{
+		{Method: http.MethodPost, Path: "/cluster/describe/:cluster", Summary: "Describe a cluster's nodes, load balancers, and costs", Handler: c.DescribeClusterHandler, Request: DescribeClusterBody{}, Response: DescribeClusterResult{}},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/create", Summary: "Create a cluster's first control plane node, bootstrap it, and return its kubeconfig", Handler: c.CreateClusterHandler, Request: ClusterCreateBody{}, Response: ClusterCreateResult{}, Credentials: true, Mutating: true},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/kubeconfig", Summary: "Get a cluster's admin kubeconfig", Handler: c.KubeconfigHandler, Request: KubeconfigBody{}, Response: KubeconfigResult{}, Credentials: true},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/create", Summary: "Create a node and attach it to the cluster's load balancers", Handler: c.CreateNodeHandler, Request: NodeCreateBody{}, Response: NodeCreateResult{}, Idempotent: true, Mutating: true},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/delete/:name", Summary: "Delete a node, or with dry_run, show what deleting it would do", Handler: c.DeleteNodeHandler, Request: NodeDeleteBody{}, Response: NodeDeletePlan{}, Destructive: true},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/glass/:name", Summary: "Glass (destroy and recreate) a node.  Only dry_run is implemented so far", Handler: c.GlassNodeHandler, Request: NodeGlassBody{}, Response: NodeDeletePlan{}, Destructive: true},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/describe/:name", Summary: "Describe a node", Handler: c.DescribeNodeHandler},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/upgrade/:node", Summary: "Upgrade a node's Talos version", Handler: c.UpgradeNodeHandler, Request: UpgradeNodeBody{}, Response: manager.UpgradeResult{}, Destructive: true},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/diff/:node", Summary: "Diff a node's intended and running machine config", Handler: c.DiffNodeHandler, Request: NodeDiffBody{}, Response: NodeDiffResult{}},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/cordon/:node", Summary: "Cordon a node, optionally draining it", Handler: c.CordonNodeHandler, Request: NodeCordonBody{}, Response: NodeCordonResult{}, Destructive: true, Mutating: true},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/uncordon/:node", Summary: "Uncordon a node", Handler: c.UncordonNodeHandler, Request: NodeCordonBody{}, Response: NodeCordonResult{}, Mutating: true},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/pods/:node", Summary: "List the pods on a node, with their owners, disruption budgets, and local storage", Handler: c.NodePodsHandler, Request: NodePodsBody{}, Response: NodePodsResult{}},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/logs/:node", Summary: "Stream a node's serial console, kernel, or service logs", Handler: c.NodeLogsHandler, Request: NodeLogsBody{}, ContentType: "text/plain"},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/retag/:node", Summary: "Correct a node's Name, purpose, and Cluster tags", Handler: c.RetagNodeHandler, Request: NodeRetagBody{}, Response: NodeRetagResult{}, Mutating: true},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/lb-reattach/:node", Summary: "Deregister a node from its load balancer target groups and register it again, reporting its target health before and after", Handler: c.NodeLBReattachHandler, Request: NodeLBReattachBody{}, Response: NodeLBReattachResult{}, Mutating: true},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/node/reconcile/:node", Summary: "Check one node is in EC2 with its Cluster tag, a Ready Kubernetes node, and a healthy target of the load balancers its role belongs in", Handler: c.ReconcileNodeHandler, Request: NodeReconcileBody{}, Response: NodeReconcileResult{}},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/reconcile", Summary: "Find discrepancies between EC2, Kubernetes, and the load balancers.  With summary, just count them, as a ReconcileSummary.  With include_cluster_info, the cluster info compared is included.  With stream, JSON Lines of ReconcileEvents, ending in the summary", Handler: c.ReconcileClusterHandler, Request: ReconcileClusterBody{}, Response: ReconcileResult{}},
+		{Method: http.MethodGet, Path: "/cluster/:cluster/reconcile/history", Summary: "List the repairs made to a cluster since the server started, newest first: the tags reconcile's fix_tags set, and the target groups node lb-reattach reattached to.  At most ?limit= of them", Handler: c.RepairHistoryHandler, Response: RepairHistoryResult{}},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/lb/unhealthy", Summary: "List unhealthy load balancer targets", Handler: c.UnhealthyTargetsHandler, Request: LBHealthBody{}, Response: LBHealthResult{}},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/upgrade", Summary: "Rolling upgrade of every node in a cluster, or with nodes, of just those", Handler: c.UpgradeClusterHandler, Request: UpgradeClusterBody{}, Response: ClusterUpgradeResult{}, Destructive: true},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/secrets/sync", Summary: "Sync machine config secrets and AMIs", Handler: c.SecretsSyncHandler, Request: SecretsSyncBody{}, Response: SecretsSyncResult{}},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/run/:command", Summary: "Run a registered command, if the caller is in its role group", Handler: c.RunCommandHandler, Request: RunCommandBody{}, Response: K8sCtlCommandResult{}, RoleGated: true, Mutating: true},
+		{Method: http.MethodPost, Path: "/cluster/:cluster/secrets/status", Summary: "Compare a cluster's stored secrets with the versions its nodes run", Handler: c.SecretsStatusHandler, Request: SecretsStatusBody{}, Response: SecretsStatusResult{}},
+		{Method: http.MethodPost, Path: "/secrets/status", Summary: "Compare every configured cluster's stored secrets with the versions its nodes run", Handler: c.SecretsStatusHandler, Request: SecretsStatusBody{}, Response: SecretsStatusResult{}},
+		{Method: http.MethodPost, Path: "/monitor/:cluster", Summary: "Stream cluster health checks", Handler: c.MonitorClusterHandler, Request: MonitorClusterBody{}, ContentType: "text/plain"},
+		{Method: http.MethodPost, Path: "/auth-check", Summary: "Check that the caller's token is accepted", Handler: c.AuthCheckHandler, Response: AuthCheckResult{}},
+		{Method: http.MethodGet, Path: "/capabilities", Summary: "List the operations the server supports, the groups they need, and whether they're destructive", Handler: c.CapabilitiesHandler, Response: CapabilitiesResult{}},
+		{Method: http.MethodGet, Path: "/maintenance", Summary: "Show whether the server is in maintenance mode, refusing the operations that change clusters", Handler: c.MaintenanceStatusHandler, Response: MaintenanceStatus{}},
+		{Method: http.MethodPost, Path: "/maintenance", Summary: "Turn maintenance mode on or off", Handler: c.SetMaintenanceHandler, Request: MaintenanceBody{}, Response: MaintenanceStatus{}, Admin: true},
+	}
+
+	return routes
+}
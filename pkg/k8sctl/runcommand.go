@@ -0,0 +1,98 @@
+package k8sctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8sctl/pkg/oidc"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// RunCommandBody is the args to run a registered command with, after its own.
+type RunCommandBody struct {
+	Args    []string `json:"args,omitempty"`
+	Verbose bool     `json:"verbose"`
+}
+
+// RunCommandHandler runs one of the registered commands, if the caller is in its Role group, and returns its output.
+// A command that runs but fails is still a 200, with its exit code in the result.
+func (c *K8sCtlCommands) RunCommandHandler(ctx *gin.Context) {
+	clusterName := ctx.Param("cluster")
+	commandName := ctx.Param("command")
+
+	var body RunCommandBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	command, ok := c.CommandsMap[commandName]
+	if !ok {
+		err = errors.New(fmt.Sprintf("no command %s is registered", commandName))
+		_ = ctx.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+
+	if command.Role != "" && !oidc.InGroup(ctx, command.Role) {
+		logrus.Warnf("refused running command %s in cluster %s for %s: not in group %s", commandName, clusterName, ctx.GetString("user_email"), command.Role)
+		err = errors.New(fmt.Sprintf("command %s is restricted to group %s", commandName, command.Role))
+		_ = ctx.AbortWithError(http.StatusForbidden, err)
+		return
+	}
+
+	logrus.Infof("running command %s in cluster %s for %s", commandName, clusterName, ctx.GetString("user_email"))
+
+	result, err := runCommand(ctx.Request.Context(), command, clusterName, body.Args)
+	if err != nil {
+		logrus.Errorf("failed running command %s: %s", commandName, err)
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if body.Verbose {
+		logrus.Infof("command %s exited %d", commandName, result.ExitCode)
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// runCommand runs a registered command with its args, then the caller's, and captures its output.  It's run directly,
+// not through a shell, so the caller's args are only ever args.  The cluster is passed in $K8SCTL_CLUSTER.  A command
+// that exits nonzero isn't an error: its exit code is in the result.
+func runCommand(ctx context.Context, command *K8sCtlCommand, clusterName string, args []string) (result K8sCtlCommandResult, err error) {
+	result = K8sCtlCommandResult{
+		CommandName: command.Name,
+		Command:     command.Command,
+		Args:        append(slices.Clone(command.Args), args...),
+	}
+
+	execCmd := exec.CommandContext(ctx, command.Command, result.Args...)
+	execCmd.Env = append(os.Environ(), "K8SCTL_CLUSTER="+clusterName)
+
+	output, err := execCmd.CombinedOutput()
+	result.Output = string(output)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+		err = nil
+		return result, err
+	}
+
+	if err != nil {
+		err = errors.Wrapf(err, "failed running %s", command.Command)
+		return result, err
+	}
+
+	return result, err
+}
@@ -0,0 +1,418 @@
+package k8sctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// secretsStatusConcurrency is how many clusters a fleet-wide secrets status checks at once.
+const secretsStatusConcurrency = 4
+
+var secretManager manager.SecretManager
+
+// SecretsStatusBody says which roles' secrets to check.  A region is only used when a single cluster is checked.
+type SecretsStatusBody struct {
+	Role    string `json:"role,omitempty"`
+	Verbose bool   `json:"verbose"`
+	Region  string `json:"region,omitempty"`
+}
+
+// SecretsStatusResult is how each cluster's stored secrets compare with what its nodes are running.
+type SecretsStatusResult struct {
+	Clusters []SecretsSyncResult `json:"clusters"`
+	Stale    int                 `json:"stale"`  // roles whose secret doesn't match what they're running
+	Errors   int                 `json:"errors"` // clusters and roles that couldn't be checked
+}
+
+// SetSecretManager sets where the package reads and writes each cluster role's stored AMI and installer version.
+// Without one, secrets can't be synced.
+func SetSecretManager(sm manager.SecretManager) {
+	secretManager = sm
+}
+
+// ConsolePrint prints each cluster's roles, then how many are stale.
+func (r SecretsStatusResult) ConsolePrint() {
+	for _, cluster := range r.Clusters {
+		cluster.ConsolePrint()
+		fmt.Println()
+	}
+
+	fmt.Printf("Stale: %d, Errors: %d\n", r.Stale, r.Errors)
+}
+
+// ConsolePrint prints a role per line, with what a sync changes, or would change on a dry run.
+func (r SecretsSyncResult) ConsolePrint() {
+	if r.Error != "" {
+		fmt.Printf("Secrets of cluster %q: error: %s\n", r.Cluster, r.Error)
+		return
+	}
+
+	fmt.Printf("Secrets of cluster %q\n\n", r.Cluster)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "ROLE\tNODE\tSTORED VERSION\tRUNNING VERSION\tSTORED AMI\tRUNNING VERSION'S AMI\tSTATUS\n")
+	for _, result := range r.Results {
+		status := result.Status()
+		if result.WouldUpdate && !result.UpdatedConfig {
+			status = consoleMarkers.Warn + " " + status
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", result.Role, result.Node, result.StoredVersion, result.Version, result.CurrentAMI, result.DetectedAMI, status)
+	}
+	_ = w.Flush()
+
+	for _, result := range r.Results {
+		if result.Error != "" {
+			fmt.Printf("\n%s: %s", result.Role, result.Error)
+		}
+	}
+
+	fmt.Println()
+
+	if len(r.Succeeded) > 0 || len(r.Failed) > 0 {
+		fmt.Printf("\nSucceeded: %s\nFailed: %s\n", listOrNone(r.Succeeded), listOrNone(r.Failed))
+	}
+
+	if len(r.NotTried) > 0 {
+		fmt.Printf("Not tried: %s\n", strings.Join(r.NotTried, ", "))
+	}
+}
+
+// Status is what a sync did to the role's secret, or on a dry run, what it would do.
+func (r SyncResult) Status() (status string) {
+	switch {
+	case r.Error != "":
+		status = "error"
+	case r.UpdatedConfig:
+		status = "updated"
+	case r.WouldUpdate && r.DryRun:
+		status = "would update"
+	case r.WouldUpdate:
+		status = "stale"
+	default:
+		status = "up to date"
+	}
+
+	return status
+}
+
+// SecretsStatusHandler compares the secrets of a cluster's roles, or with no cluster, those of every cluster in the
+// server config, with what their nodes are running.  It's read only: stale secrets are reported, not synced.
+func (c *K8sCtlCommands) SecretsStatusHandler(ctx *gin.Context) {
+	clusterName := ctx.Param("cluster")
+
+	var body SecretsStatusBody
+
+	err := json.NewDecoder(ctx.Request.Body).Decode(&body)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to decode request body")
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if secretManager == nil {
+		err = errors.New("Vault isn't configured on this server, so secrets can't be checked")
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	clusters := []string{clusterName}
+	region := body.Region
+
+	if clusterName == "" {
+		clusters = configuredClusters()
+		region = ""
+
+		if len(clusters) == 0 {
+			err = errors.New("no clusters are configured on this server: name a cluster")
+			_ = ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	logrus.Infof("checking secrets of clusters %v", clusters)
+
+	result := SecretsStatusResult{Clusters: make([]SecretsSyncResult, len(clusters))}
+	reqCtx := ctx.Request.Context()
+
+	var group errgroup.Group
+	group.SetLimit(secretsStatusConcurrency)
+
+	for i, name := range clusters {
+		group.Go(func() (statusErr error) {
+			result.Clusters[i] = clusterSecretsStatus(reqCtx, name, region, secretRoles(body.Role), body.Verbose)
+			return statusErr
+		})
+	}
+
+	_ = group.Wait()
+
+	for _, cluster := range result.Clusters {
+		if cluster.Error != "" {
+			result.Errors++
+		}
+
+		for _, role := range cluster.Results {
+			switch {
+			case role.Error != "":
+				result.Errors++
+			case role.WouldUpdate:
+				result.Stale++
+			}
+		}
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// clusterSecretsStatus checks one cluster's secrets for a secrets status.  A failure to check the cluster at all is
+// reported in the result, so the other clusters can still be checked.
+func clusterSecretsStatus(ctx context.Context, clusterName string, region string, roles []string, verbose bool) (result SecretsSyncResult) {
+	result = SecretsSyncResult{Cluster: clusterName, Results: make([]SyncResult, 0)}
+
+	cm, err := newClusterManager(ctx, clusterName, region, verbose)
+	if err != nil {
+		logrus.Errorf("Failed creating cluster manager for %s: %s", clusterName, err)
+		result.Error = err.Error()
+		return result
+	}
+
+	results, err := clusterSecretStates(ctx, cm, clusterName, roles)
+	if err != nil {
+		logrus.Errorf("failed checking secrets of cluster %s: %s", clusterName, err)
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Results = results
+	return result
+}
+
+// configuredClusters returns the names of the clusters in the server config, sorted.
+func configuredClusters() (names []string) {
+	if clusterConfig == nil {
+		return names
+	}
+
+	for name := range clusterConfig.Clusters {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// secretRoles returns the roles whose secrets to check or sync: the one asked for, or all of them.
+func secretRoles(role string) (roles []string) {
+	roles = []string{manager.NodeRoleCp, manager.NodeRoleWorker}
+	if role != "" {
+		roles = []string{role}
+	}
+
+	return roles
+}
+
+// clusterSecretStates compares the secret of each of a cluster's roles with what a node of that role is running.
+// A role without nodes is reported as an error, rather than left out.
+func clusterSecretStates(ctx context.Context, cm *aws.AWSClusterManager, clusterName string, roles []string) (results []SyncResult, err error) {
+	results = make([]SyncResult, 0)
+
+	nodes, err := secretRoleNodes(ctx, cm, clusterName, roles)
+	if err != nil {
+		return results, err
+	}
+
+	for _, role := range roles {
+		results = append(results, checkRoleSecret(ctx, cm, clusterName, role, nodes[role]))
+	}
+
+	return results, err
+}
+
+// secretRoleNodes returns a node of each role, to compare the role's secret with.  Roles without nodes are left out.
+func secretRoleNodes(ctx context.Context, cm *aws.AWSClusterManager, clusterName string, roles []string) (nodes map[string]string, err error) {
+	nodes = make(map[string]string, len(roles))
+
+	clusterInfo, err := cm.DescribeCluster(clusterName)
+	if err != nil {
+		err = errors.Wrapf(err, "failed describing cluster %s", clusterName)
+		return nodes, err
+	}
+
+	instances := nodeInstances(ctx, cm, clusterInfo.Nodes)
+
+	for _, role := range roles {
+		for _, node := range clusterInfo.Nodes {
+			if nodeRole(node, instances) == role {
+				nodes[role] = node.Name
+				break
+			}
+		}
+	}
+
+	return nodes, err
+}
+
+// checkRoleSecret compares a role's secret with what its node is running, or if it has no node, reports that.
+func checkRoleSecret(ctx context.Context, cm *aws.AWSClusterManager, clusterName string, role string, nodeName string) (result SyncResult) {
+	if nodeName == "" {
+		logrus.Warnf("No nodes found with role %s in cluster %s", role, clusterName)
+		result = SyncResult{Role: role, Error: fmt.Sprintf("no nodes with role %s in cluster %s", role, clusterName)}
+		return result
+	}
+
+	result = roleSecretState(ctx, cm, clusterName, role, nodeName)
+	return result
+}
+
+// syncRoleSecrets syncs each role's secret in turn, from check's comparison of it with what the role's node is
+// running, updating the secrets that differ unless it's a dry run.  With OnFailureContinue, every role is tried and
+// the failures are reported at the end.  With OnFailureFailFast, the first role to fail stops the sync, and the roles
+// after it are reported as not tried.
+func syncRoleSecrets(ctx context.Context, clusterName string, roles []string, dryRun bool, onFailure string, check func(role string) SyncResult) (result SecretsSyncResult) {
+	result = SecretsSyncResult{Cluster: clusterName, Results: make([]SyncResult, 0, len(roles))}
+
+	for i, role := range roles {
+		roleResult := check(role)
+		roleResult.DryRun = dryRun
+
+		if roleResult.Error == "" && !dryRun && roleResult.WouldUpdate {
+			err := secretManager.UpdateVersionInfo(ctx, clusterName, role, roleResult.DetectedAMI, roleResult.Version)
+			if err != nil {
+				logrus.Errorf("failed updating %s secret of cluster %s: %s", role, clusterName, err)
+				roleResult.Error = err.Error()
+			} else {
+				logrus.Infof("updated %s secret of cluster %s to %s (%s)", role, clusterName, roleResult.Version, roleResult.DetectedAMI)
+				roleResult.UpdatedAMI = roleResult.DetectedAMI
+				roleResult.UpdatedConfig = true
+			}
+		}
+
+		result.Results = append(result.Results, roleResult)
+
+		if roleResult.Error == "" {
+			result.Succeeded = append(result.Succeeded, role)
+			continue
+		}
+
+		result.Failed = append(result.Failed, role)
+
+		if onFailure == OnFailureFailFast {
+			result.NotTried = roles[i+1:]
+			if len(result.NotTried) > 0 {
+				logrus.Warnf("stopping secrets sync of cluster %s: role %s failed", clusterName, role)
+			}
+			break
+		}
+	}
+
+	return result
+}
+
+// roleSecretState compares a role's secret with what its node is running: the Talos version, and the AMI for that
+// version.  A failure is reported in the result, so the other roles can still be checked.
+func roleSecretState(ctx context.Context, cm *aws.AWSClusterManager, clusterName string, role string, nodeName string) (result SyncResult) {
+	result = SyncResult{Role: role, Node: nodeName}
+
+	secret, err := secretManager.GetClusterSecret(ctx, clusterName, role)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	version, err := nodeTalosVersion(ctx, cm, nodeName)
+	if err != nil {
+		result = secretDiff(role, nodeName, secret, "", "")
+		result.Error = err.Error()
+		return result
+	}
+
+	detectedAMI, err := discoverTalosAMI(ctx, cm, version)
+	if err != nil {
+		result = secretDiff(role, nodeName, secret, version, "")
+		result.Error = err.Error()
+		return result
+	}
+
+	result = secretDiff(role, nodeName, secret, version, detectedAMI)
+	return result
+}
+
+// secretDiff compares the version and AMI stored in a role's secret with those its node is running.  The secret needs
+// updating if either differs.
+func secretDiff(role string, nodeName string, secret manager.ClusterSecret, version string, detectedAMI string) (result SyncResult) {
+	result = SyncResult{
+		Role:          role,
+		Node:          nodeName,
+		CurrentAMI:    secret.ImageID,
+		StoredVersion: secret.InstallerVersion,
+		Version:       version,
+		DetectedAMI:   detectedAMI,
+	}
+
+	result.WouldUpdate = version != "" && detectedAMI != "" && (secret.ImageID != detectedAMI || secret.InstallerVersion != version)
+
+	return result
+}
+
+// nodeTalosVersion asks a node's Talos API which version it's running, e.g. v1.10.8.
+func nodeTalosVersion(ctx context.Context, cm *aws.AWSClusterManager, nodeName string) (version string, err error) {
+	_, nodeIP, err := runningNodeAddress(cm, nodeName)
+	if err != nil {
+		return version, err
+	}
+
+	tClient, err := newTalosClient(ctx, nodeIP)
+	if err != nil {
+		return version, err
+	}
+
+	defer tClient.Close()
+
+	resp, err := tClient.Version(ctx)
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting Talos version of node %s", nodeName)
+		return version, err
+	}
+
+	if len(resp.GetMessages()) == 0 {
+		err = errors.New(fmt.Sprintf("node %s returned no Talos version", nodeName))
+		return version, err
+	}
+
+	version = resp.GetMessages()[0].GetVersion().GetTag()
+	return version, err
+}
+
+// discoverTalosAMI finds the Talos AMI for a version in the cluster's region.
+func discoverTalosAMI(ctx context.Context, cm *aws.AWSClusterManager, version string) (imageID string, err error) {
+	ec2Client, ok := cm.Ec2Client.(*ec2.Client)
+	if !ok {
+		err = errors.New("the EC2 client can't look up AMIs")
+		return imageID, err
+	}
+
+	discovery := &aws.AWSImageDiscovery{EC2Client: ec2Client, Region: cm.Config.Region}
+
+	imageID, err = discovery.DiscoverImage(ctx, version, "")
+	if err != nil {
+		err = errors.Wrapf(err, "failed finding the AMI for Talos %s", version)
+		return imageID, err
+	}
+
+	return imageID, err
+}
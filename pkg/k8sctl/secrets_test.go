@@ -0,0 +1,101 @@
+package k8sctl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretDiff(t *testing.T) {
+	secret := manager.ClusterSecret{ImageID: "ami-old", InstallerVersion: "v1.10.7"}
+
+	result := secretDiff("worker", "cluster1-worker-1", secret, "v1.10.8", "ami-new")
+	assert.Equal(t, "ami-old", result.CurrentAMI)
+	assert.Equal(t, "v1.10.7", result.StoredVersion)
+	assert.Equal(t, "v1.10.8", result.Version)
+	assert.Equal(t, "ami-new", result.DetectedAMI)
+	assert.True(t, result.WouldUpdate)
+
+	result = secretDiff("worker", "cluster1-worker-1", secret, "v1.10.7", "ami-old")
+	assert.False(t, result.WouldUpdate, "secret matches the running version")
+
+	result = secretDiff("worker", "cluster1-worker-1", secret, "v1.10.7", "ami-new")
+	assert.True(t, result.WouldUpdate, "same version, but the stored AMI isn't that version's")
+
+	result = secretDiff("worker", "cluster1-worker-1", secret, "v1.10.8", "")
+	assert.False(t, result.WouldUpdate, "without the running version's AMI there's nothing to update to")
+}
+
+func TestSyncResultStatus(t *testing.T) {
+	assert.Equal(t, "up to date", SyncResult{}.Status())
+	assert.Equal(t, "would update", SyncResult{WouldUpdate: true, DryRun: true}.Status())
+	assert.Equal(t, "stale", SyncResult{WouldUpdate: true}.Status())
+	assert.Equal(t, "updated", SyncResult{WouldUpdate: true, UpdatedConfig: true}.Status())
+	assert.Equal(t, "error", SyncResult{WouldUpdate: true, Error: "boom"}.Status())
+}
+
+// recordingSecretManager records the version updates made to it, failing those of the roles in fail.
+type recordingSecretManager struct {
+	manager.SecretManager
+	fail    map[string]bool
+	updated []string
+}
+
+func (m *recordingSecretManager) UpdateVersionInfo(_ context.Context, _ string, role string, _ string, _ string) (err error) {
+	if m.fail[role] {
+		err = errors.New("permission denied")
+		return err
+	}
+
+	m.updated = append(m.updated, role)
+	return err
+}
+
+func TestSyncRoleSecrets(t *testing.T) {
+	sm := &recordingSecretManager{fail: map[string]bool{"controlplane": true}}
+	SetSecretManager(sm)
+	defer SetSecretManager(nil)
+
+	nodes := map[string]string{"controlplane": "cluster1-cp-1", "worker": "cluster1-worker-1"}
+	check := func(role string) (result SyncResult) {
+		if nodes[role] == "" {
+			result = checkRoleSecret(context.Background(), nil, "cluster1", role, "")
+			return result
+		}
+
+		result = SyncResult{Role: role, Node: nodes[role], Version: "v1.10.8", DetectedAMI: "ami-new", WouldUpdate: true}
+		return result
+	}
+
+	roles := []string{"controlplane", "worker", "gpu"}
+
+	result := syncRoleSecrets(context.Background(), "cluster1", roles, false, OnFailureContinue, check)
+	assert.Equal(t, []string{"worker"}, result.Succeeded)
+	assert.Equal(t, []string{"controlplane", "gpu"}, result.Failed)
+	assert.Empty(t, result.NotTried)
+	assert.Equal(t, []string{"worker"}, sm.updated)
+	require.Len(t, result.Results, 3)
+	assert.Equal(t, "permission denied", result.Results[0].Error)
+	assert.True(t, result.Results[1].UpdatedConfig)
+	assert.Equal(t, "no nodes with role gpu in cluster cluster1", result.Results[2].Error, "a role without nodes is reported, not skipped")
+
+	sm.updated = nil
+	result = syncRoleSecrets(context.Background(), "cluster1", roles, false, OnFailureFailFast, check)
+	assert.Empty(t, result.Succeeded)
+	assert.Equal(t, []string{"controlplane"}, result.Failed)
+	assert.Equal(t, []string{"worker", "gpu"}, result.NotTried)
+	assert.Empty(t, sm.updated, "nothing after the first failure is tried")
+	assert.Len(t, result.Results, 1)
+
+	// A dry run updates nothing, so only the checks can fail
+	result = syncRoleSecrets(context.Background(), "cluster1", roles, true, OnFailureFailFast, check)
+	assert.Equal(t, []string{"controlplane", "worker"}, result.Succeeded)
+	assert.Equal(t, []string{"gpu"}, result.Failed)
+	assert.Empty(t, result.NotTried)
+	assert.Empty(t, sm.updated)
+	assert.Equal(t, "would update", result.Results[0].Status())
+}
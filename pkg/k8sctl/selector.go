@@ -0,0 +1,131 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	k8s_utility_client "github.com/nikogura/k8s-utility-client/pkg/k8s-utility-client"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeSelector restricts a reconcile or monitor to the nodes matching all of its fields.  Empty fields match every
+// node, so an empty selector considers the whole cluster.
+type NodeSelector struct {
+	Role       string `json:"role,omitempty"`        // controlplane or worker, from the instance's role tag, or the node name
+	Purpose    string `json:"purpose,omitempty"`     // the Kubernetes node's purpose label
+	NamePrefix string `json:"name_prefix,omitempty"` // node name prefix
+	Since      int    `json:"since,omitempty"`       // seconds: only nodes launched, or joined to Kubernetes, in this window
+}
+
+// empty returns true if the selector matches every node.
+func (s NodeSelector) empty() (empty bool) {
+	empty = s.Role == "" && s.Purpose == "" && s.NamePrefix == "" && s.Since <= 0
+	return empty
+}
+
+// matches returns true if a node satisfies the selector.  roles holds the nodes' roles from their role tags, by short
+// name, for roleOf.  purposeNodes holds the short names of the Kubernetes nodes with the selector's purpose label, so a
+// node only found in EC2 never matches a purpose.  recentNodes holds the short names of the nodes launched or joined
+// within the selector's window.
+func (s NodeSelector) matches(nodeName string, roles map[string]string, purposeNodes map[string]bool, recentNodes map[string]bool) (matches bool) {
+	if !nodeMatches(nodeName, roles, s.Role, s.NamePrefix) {
+		return matches
+	}
+
+	if s.Purpose != "" && !purposeNodes[stripDomainSuffix(nodeName)] {
+		return matches
+	}
+
+	if s.Since > 0 && !recentNodes[stripDomainSuffix(nodeName)] {
+		return matches
+	}
+
+	matches = true
+	return matches
+}
+
+// purposeNodeNames returns the names of the Kubernetes nodes labelled with a purpose.
+func purposeNodeNames(ctx context.Context, purpose string) (names map[string]bool, err error) {
+	names = make(map[string]bool)
+
+	clients, err := k8s_utility_client.NewK8sClients()
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating k8s clients")
+		return names, err
+	}
+
+	nodes, err := clients.ClientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", purposeKey, purpose),
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed listing Kubernetes nodes with purpose %s", purpose)
+		return names, err
+	}
+
+	for _, node := range nodes.Items {
+		names[node.Name] = true
+	}
+
+	return names, err
+}
+
+// applyNodeSelector trims the EC2, Kubernetes, and untagged nodes a reconcile or monitor compares down to those
+// matching the selector.
+func applyNodeSelector(ctx context.Context, cm *aws.AWSClusterManager, selector NodeSelector, info manager.ClusterInfo, k8sNodes []string, untagged []manager.NodeInfo) (selectedInfo manager.ClusterInfo, selectedK8s []string, selectedUntagged []manager.NodeInfo, err error) {
+	if selector.empty() {
+		return info, k8sNodes, untagged, err
+	}
+
+	var purposeNodes map[string]bool
+	if selector.Purpose != "" {
+		purposeNodes, err = purposeNodeNames(ctx, selector.Purpose)
+		if err != nil {
+			return info, k8sNodes, untagged, err
+		}
+	}
+
+	ec2Nodes := append(slices.Clone(info.Nodes), untagged...)
+
+	var roles map[string]string
+	if selector.Role != "" {
+		roles = nodeRoles(ec2Nodes, nodeInstances(ctx, cm, ec2Nodes))
+	}
+
+	var recent map[string]bool
+	if selector.Since > 0 {
+		since := time.Now().Add(-time.Duration(selector.Since) * time.Second)
+		recent = recentNodeNames(ctx, cm, ec2Nodes, since)
+	}
+
+	selectedInfo, selectedK8s, selectedUntagged = selectNodes(selector, roles, purposeNodes, recent, info, k8sNodes, untagged)
+	return selectedInfo, selectedK8s, selectedUntagged, err
+}
+
+// selectNodes is applyNodeSelector, given the nodes' roles from their role tags, and the short names of the nodes with
+// the selector's purpose, and of those launched or joined within its window.
+func selectNodes(selector NodeSelector, roles map[string]string, purposeNodes map[string]bool, recentNodes map[string]bool, info manager.ClusterInfo, k8sNodes []string, untagged []manager.NodeInfo) (selectedInfo manager.ClusterInfo, selectedK8s []string, selectedUntagged []manager.NodeInfo) {
+	match := func(nodeName string) bool { return selector.matches(nodeName, roles, purposeNodes, recentNodes) }
+
+	selectedInfo = filterClusterInfoBy(info, match, false)
+
+	selectedK8s = make([]string, 0, len(k8sNodes))
+	for _, node := range k8sNodes {
+		if match(node) {
+			selectedK8s = append(selectedK8s, node)
+		}
+	}
+
+	selectedUntagged = make([]manager.NodeInfo, 0, len(untagged))
+	for _, node := range untagged {
+		if match(node.Name) {
+			selectedUntagged = append(selectedUntagged, node)
+		}
+	}
+
+	return selectedInfo, selectedK8s, selectedUntagged
+}
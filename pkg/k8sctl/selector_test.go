@@ -0,0 +1,65 @@
+package k8sctl
+
+import (
+	"testing"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeSelectorMatches(t *testing.T) {
+	purposeNodes := map[string]bool{"cluster1-worker-2": true}
+
+	assert.True(t, NodeSelector{}.matches("cluster1-cp-1.example.com", nil, purposeNodes, nil))
+	assert.True(t, NodeSelector{Role: manager.NodeRoleCp}.matches("cluster1-cp-1.example.com", nil, purposeNodes, nil))
+	assert.False(t, NodeSelector{Role: manager.NodeRoleWorker}.matches("cluster1-cp-1.example.com", nil, purposeNodes, nil))
+	assert.True(t, NodeSelector{Purpose: "ingress"}.matches("cluster1-worker-2.example.com", nil, purposeNodes, nil))
+	assert.False(t, NodeSelector{Purpose: "ingress"}.matches("cluster1-worker-3", nil, purposeNodes, nil))
+	assert.False(t, NodeSelector{Purpose: "ingress", NamePrefix: "cluster2"}.matches("cluster1-worker-2", nil, purposeNodes, nil))
+}
+
+func TestSelectNodes(t *testing.T) {
+	dailyCost := 3.0
+	info := manager.ClusterInfo{
+		Nodes: []manager.NodeInfo{
+			{Name: "cluster1-cp-1.example.com", ID: "i-1", VCPUs: 2, DailyCost: 1},
+			{Name: "cluster1-worker-1.example.com", ID: "i-2", VCPUs: 4, DailyCost: 2},
+		},
+		TotalVCPUs:         6,
+		EstimatedDailyCost: &dailyCost,
+		LoadBalancers: []manager.LBInfo{{
+			Name: "lb1",
+			Targets: []manager.LBTargetInfo{
+				{Name: "cluster1-cp-1.example.com", State: "healthy"},
+				{Name: "cluster1-worker-1.example.com", State: "healthy"},
+			},
+		}},
+	}
+	k8sNodes := []string{"cluster1-cp-1", "cluster1-worker-1", "cluster1-worker-9"}
+	untagged := []manager.NodeInfo{{Name: "cluster1-worker-5.example.com", ID: "i-5"}}
+
+	selectedInfo, selectedK8s, selectedUntagged := selectNodes(NodeSelector{Role: manager.NodeRoleWorker}, nil, nil, nil, info, k8sNodes, untagged)
+
+	assert.Len(t, selectedInfo.Nodes, 1)
+	assert.Equal(t, "i-2", selectedInfo.Nodes[0].ID)
+	assert.Equal(t, 4, selectedInfo.TotalVCPUs)
+	assert.InDelta(t, 2.0, *selectedInfo.EstimatedDailyCost, 0.001)
+	assert.Len(t, selectedInfo.LoadBalancers[0].Targets, 1)
+	assert.Equal(t, []string{"cluster1-worker-1", "cluster1-worker-9"}, selectedK8s)
+	assert.Equal(t, untagged, selectedUntagged)
+
+	// An unselective selector leaves everything in
+	selectedInfo, selectedK8s, selectedUntagged = selectNodes(NodeSelector{}, nil, nil, nil, info, k8sNodes, untagged)
+	assert.Len(t, selectedInfo.Nodes, 2)
+	assert.Equal(t, k8sNodes, selectedK8s)
+	assert.Equal(t, untagged, selectedUntagged)
+}
+
+func TestNodeSelectorSince(t *testing.T) {
+	recent := map[string]bool{"cluster1-worker-4": true}
+
+	assert.True(t, NodeSelector{Since: 3600}.matches("cluster1-worker-4.example.com", nil, nil, recent))
+	assert.False(t, NodeSelector{Since: 3600}.matches("cluster1-worker-1", nil, nil, recent))
+	assert.False(t, NodeSelector{Since: 3600, Role: manager.NodeRoleCp}.matches("cluster1-worker-4", nil, nil, recent))
+	assert.False(t, NodeSelector{Since: 3600}.empty())
+}
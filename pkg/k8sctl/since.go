@@ -0,0 +1,73 @@
+package k8sctl
+
+import (
+	"context"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	k8s_utility_client "github.com/nikogura/k8s-utility-client/pkg/k8s-utility-client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// k8sNodeJoinTimes returns when each Kubernetes node joined the cluster, that is, when its node object was created,
+// by its name without the domain.
+func k8sNodeJoinTimes(ctx context.Context) (joined map[string]time.Time, err error) {
+	joined = make(map[string]time.Time)
+
+	clients, err := k8s_utility_client.NewK8sClients()
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating k8s clients")
+		return joined, err
+	}
+
+	nodes, err := clients.ClientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = errors.Wrapf(err, "failed listing Kubernetes nodes")
+		return joined, err
+	}
+
+	for _, node := range nodes.Items {
+		joined[stripDomainSuffix(node.Name)] = node.CreationTimestamp.Time
+	}
+
+	return joined, err
+}
+
+// recentNodes returns the names, without the domain, of the nodes whose EC2 instance launched, or whose Kubernetes
+// node joined, after since.  Kubernetes nodes that joined since are included whether or not they're in EC2.
+func recentNodes(nodes []manager.NodeInfo, instances map[string]ec2types.Instance, joined map[string]time.Time, since time.Time) (recent map[string]bool) {
+	recent = make(map[string]bool)
+
+	for _, node := range nodes {
+		instance, ok := instances[node.ID]
+		if ok && instance.LaunchTime != nil && instance.LaunchTime.After(since) {
+			recent[stripDomainSuffix(node.Name)] = true
+		}
+	}
+
+	for name, joinTime := range joined {
+		if joinTime.After(since) {
+			recent[name] = true
+		}
+	}
+
+	return recent
+}
+
+// recentNodeNames is recentNodes, fetching the nodes' instances and Kubernetes join times.  Without the join times,
+// nodes are judged by their launch times alone.
+func recentNodeNames(ctx context.Context, cm *aws.AWSClusterManager, nodes []manager.NodeInfo, since time.Time) (recent map[string]bool) {
+	instances := nodeInstances(ctx, cm, nodes)
+
+	joined, err := k8sNodeJoinTimes(ctx)
+	if err != nil {
+		logrus.Warnf("failed getting Kubernetes node join times, selecting recent nodes by launch time alone: %s", err)
+	}
+
+	recent = recentNodes(nodes, instances, joined, since)
+	return recent
+}
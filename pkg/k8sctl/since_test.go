@@ -0,0 +1,42 @@
+package k8sctl
+
+import (
+	"testing"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentNodes(t *testing.T) {
+	now := time.Now()
+	hourAgo := now.Add(-time.Hour)
+	dayAgo := now.Add(-24 * time.Hour)
+
+	nodes := []manager.NodeInfo{
+		{Name: "cluster1-worker-1.example.com", ID: "i-1"},
+		{Name: "cluster1-worker-2.example.com", ID: "i-2"},
+		{Name: "cluster1-worker-3.example.com", ID: "i-3"},
+		{Name: "cluster1-worker-4.example.com", ID: "i-4"},
+	}
+	instances := map[string]ec2types.Instance{
+		"i-1": {LaunchTime: &dayAgo},
+		"i-2": {LaunchTime: &hourAgo},
+		"i-3": {LaunchTime: &dayAgo},
+		// i-4 wasn't found, so only its join time counts
+	}
+	joined := map[string]time.Time{
+		"cluster1-worker-1": dayAgo,
+		"cluster1-worker-3": hourAgo, // rejoined, e.g. after a reset
+		"cluster1-worker-4": dayAgo,
+		"cluster1-worker-9": hourAgo, // only in Kubernetes
+	}
+
+	recent := recentNodes(nodes, instances, joined, now.Add(-2*time.Hour))
+	assert.Equal(t, map[string]bool{"cluster1-worker-2": true, "cluster1-worker-3": true, "cluster1-worker-9": true}, recent)
+
+	// Without the join times, launch times alone decide
+	recent = recentNodes(nodes, instances, nil, now.Add(-2*time.Hour))
+	assert.Equal(t, map[string]bool{"cluster1-worker-2": true}, recent)
+}
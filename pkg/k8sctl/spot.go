@@ -0,0 +1,74 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+)
+
+// spotEC2Client launches instances as one-time spot instances, rather than on demand.  The cluster manager builds its
+// RunInstances requests itself, and its node config has no market options, so this is how a node create asks for spot.
+type spotEC2Client struct {
+	aws.Ec2Client
+	maxPrice string // USD per hour, or empty for the on-demand price
+}
+
+// RunInstances launches the instances as spot.
+func (c *spotEC2Client) RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (output *ec2.RunInstancesOutput, err error) {
+	params.InstanceMarketOptions = spotMarketOptions(c.maxPrice)
+
+	output, err = c.Ec2Client.RunInstances(ctx, params, optFns...)
+	return output, err
+}
+
+// spotMarketOptions are the market options for a one-time spot instance, which is terminated when interrupted.  A
+// node that's interrupted is replaced with a new create, rather than left stopped.
+func spotMarketOptions(maxPrice string) (options *ec2types.InstanceMarketOptionsRequest) {
+	spotOptions := &ec2types.SpotMarketOptions{
+		SpotInstanceType:             ec2types.SpotInstanceTypeOneTime,
+		InstanceInterruptionBehavior: ec2types.InstanceInterruptionBehaviorTerminate,
+	}
+
+	if maxPrice != "" {
+		spotOptions.MaxPrice = &maxPrice
+	}
+
+	options = &ec2types.InstanceMarketOptionsRequest{
+		MarketType:  ec2types.MarketTypeSpot,
+		SpotOptions: spotOptions,
+	}
+
+	return options
+}
+
+// validateSpot checks a node create's spot settings.  Control plane nodes can't be spot: an interruption takes out an
+// etcd member with no warning worth the name.
+func validateSpot(nodeRole string, spot bool, maxPrice string) (err error) {
+	if !spot {
+		if maxPrice != "" {
+			err = errors.New("a spot max price was given, but spot wasn't asked for")
+		}
+		return err
+	}
+
+	if nodeRole == manager.NodeRoleCp {
+		err = errors.New("control plane nodes can't be spot instances")
+		return err
+	}
+
+	if maxPrice != "" {
+		price, parseErr := strconv.ParseFloat(maxPrice, 64)
+		if parseErr != nil || price <= 0 {
+			err = errors.New(fmt.Sprintf("invalid spot max price %q: must be a positive USD per hour price", maxPrice))
+			return err
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,54 @@
+package k8sctl
+
+import (
+	"context"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunEC2Client records the RunInstances request it's given.
+type fakeRunEC2Client struct {
+	aws.Ec2Client
+	input *ec2.RunInstancesInput
+}
+
+func (f *fakeRunEC2Client) RunInstances(_ context.Context, params *ec2.RunInstancesInput, _ ...func(*ec2.Options)) (output *ec2.RunInstancesOutput, err error) {
+	f.input = params
+	output = &ec2.RunInstancesOutput{}
+	return output, err
+}
+
+func TestSpotEC2Client(t *testing.T) {
+	fake := &fakeRunEC2Client{}
+	client := &spotEC2Client{Ec2Client: fake, maxPrice: "0.05"}
+
+	_, err := client.RunInstances(context.Background(), &ec2.RunInstancesInput{InstanceType: ec2types.InstanceTypeM5Large})
+	require.NoError(t, err)
+
+	require.NotNil(t, fake.input.InstanceMarketOptions)
+	assert.Equal(t, ec2types.InstanceTypeM5Large, fake.input.InstanceType)
+	assert.Equal(t, ec2types.MarketTypeSpot, fake.input.InstanceMarketOptions.MarketType)
+	assert.Equal(t, ec2types.SpotInstanceTypeOneTime, fake.input.InstanceMarketOptions.SpotOptions.SpotInstanceType)
+	assert.Equal(t, "0.05", awssdk.ToString(fake.input.InstanceMarketOptions.SpotOptions.MaxPrice))
+
+	assert.Nil(t, spotMarketOptions("").SpotOptions.MaxPrice)
+}
+
+func TestValidateSpot(t *testing.T) {
+	assert.NoError(t, validateSpot(manager.NodeRoleWorker, false, ""))
+	assert.NoError(t, validateSpot(manager.NodeRoleWorker, true, ""))
+	assert.NoError(t, validateSpot(manager.NodeRoleWorker, true, "0.05"))
+	assert.NoError(t, validateSpot(manager.NodeRoleCp, false, ""))
+
+	assert.Error(t, validateSpot(manager.NodeRoleCp, true, ""))
+	assert.Error(t, validateSpot(manager.NodeRoleWorker, false, "0.05"))
+	assert.Error(t, validateSpot(manager.NodeRoleWorker, true, "cheap"))
+	assert.Error(t, validateSpot(manager.NodeRoleWorker, true, "-1"))
+}
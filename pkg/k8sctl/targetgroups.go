@@ -0,0 +1,241 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// nodeTargetGroupsTag is the instance tag recording the extra target groups a node was created in, by name, comma
+// separated.  Their load balancers needn't be the cluster's, so describe finds them from the tag.
+const nodeTargetGroupsTag = "k8sctl:target-groups"
+
+// resolveTargetGroups looks up the extra target groups for a node create, each given by ARN or name, so a target group
+// that doesn't exist fails the create before an instance is launched.  Each must be attached to a load balancer, for
+// the node to take traffic, and take instance or ip targets.
+func resolveTargetGroups(ctx context.Context, client aws.ELBClient, refs []string) (tgs []elbtypes.TargetGroup, err error) {
+	if len(refs) == 0 {
+		return tgs, err
+	}
+
+	var arns, names []string
+	for _, ref := range refs {
+		switch {
+		case ref == "":
+			err = errors.New("empty target group given")
+			return tgs, err
+		case strings.HasPrefix(ref, "arn:"):
+			arns = append(arns, ref)
+		default:
+			names = append(names, ref)
+		}
+	}
+
+	var found []elbtypes.TargetGroup
+
+	for _, input := range []*elasticloadbalancingv2.DescribeTargetGroupsInput{{TargetGroupArns: arns}, {Names: names}} {
+		if len(input.TargetGroupArns) == 0 && len(input.Names) == 0 {
+			continue
+		}
+
+		output, describeErr := client.DescribeTargetGroups(ctx, input)
+		if describeErr != nil {
+			err = errors.Wrapf(describeErr, "failed looking up target groups %s", strings.Join(append(input.TargetGroupArns, input.Names...), ", "))
+			return tgs, err
+		}
+
+		found = append(found, output.TargetGroups...)
+	}
+
+	seen := make(map[string]bool, len(found))
+	for _, tg := range found {
+		name := awssdk.ToString(tg.TargetGroupName)
+
+		switch {
+		case seen[awssdk.ToString(tg.TargetGroupArn)]:
+			continue
+		case len(tg.LoadBalancerArns) == 0:
+			err = errors.New(fmt.Sprintf("target group %s isn't attached to a load balancer", name))
+			return tgs, err
+		case tg.TargetType != elbtypes.TargetTypeEnumInstance && tg.TargetType != elbtypes.TargetTypeEnumIp:
+			err = errors.New(fmt.Sprintf("target group %s takes %s targets, not instances or IPs", name, tg.TargetType))
+			return tgs, err
+		}
+
+		seen[awssdk.ToString(tg.TargetGroupArn)] = true
+		tgs = append(tgs, tg)
+	}
+
+	return tgs, err
+}
+
+// targetGroupsTagValue is the value of the node's nodeTargetGroupsTag for the target groups.
+func targetGroupsTagValue(tgs []elbtypes.TargetGroup) (value string, err error) {
+	names := make([]string, 0, len(tgs))
+	for _, tg := range tgs {
+		names = append(names, awssdk.ToString(tg.TargetGroupName))
+	}
+
+	sort.Strings(names)
+	value = strings.Join(names, ",")
+
+	if len(value) > 256 {
+		err = errors.New(fmt.Sprintf("too many target groups given: their names, %s, don't fit in a 256 character tag", value))
+		return value, err
+	}
+
+	return value, err
+}
+
+// registerTargetGroups registers a new node with the extra target groups, by instance ID, or by private IP for target
+// groups of type ip, on each target group's port.  It carries on past a target group it can't register the node with,
+// and returns the ones it did, and an error naming those it didn't.
+func registerTargetGroups(ctx context.Context, client aws.ELBClient, tgs []elbtypes.TargetGroup, instanceID string, privateIP string) (registered []string, err error) {
+	var failures []string
+
+	for _, tg := range tgs {
+		name := awssdk.ToString(tg.TargetGroupName)
+
+		target := elbtypes.TargetDescription{Id: awssdk.String(instanceID), Port: tg.Port}
+		if tg.TargetType == elbtypes.TargetTypeEnumIp {
+			if privateIP == "" {
+				failures = append(failures, fmt.Sprintf("%s: the instance's private IP isn't known", name))
+				continue
+			}
+			target.Id = awssdk.String(privateIP)
+		}
+
+		_, registerErr := client.RegisterTargets(ctx, &elasticloadbalancingv2.RegisterTargetsInput{
+			TargetGroupArn: tg.TargetGroupArn,
+			Targets:        []elbtypes.TargetDescription{target},
+		})
+		if registerErr != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", name, registerErr))
+			continue
+		}
+
+		registered = append(registered, name)
+	}
+
+	if len(failures) > 0 {
+		err = errors.New(fmt.Sprintf("failed registering with target groups: %s", strings.Join(failures, "; ")))
+	}
+
+	return registered, err
+}
+
+// registerNewNode registers a node just created with the extra target groups, once its instance is found.
+func registerNewNode(ctx context.Context, cm *aws.AWSClusterManager, nodeName string, tgs []elbtypes.TargetGroup) (registered []string, err error) {
+	node, err := cm.GetNode(nodeName)
+	if err != nil {
+		err = errors.Wrapf(err, "failed finding the instance of node %s", nodeName)
+		return registered, err
+	}
+
+	if node.ID == "" {
+		err = errors.New(fmt.Sprintf("no instance found for node %s", nodeName))
+		return registered, err
+	}
+
+	instances := nodeInstances(ctx, cm, []manager.NodeInfo{node})
+
+	registered, err = registerTargetGroups(ctx, cm.ELBClient, tgs, node.ID, nodePrivateIPs(instances)[node.ID])
+	return registered, err
+}
+
+// extraTargetGroupNames returns the extra target groups the nodes' instances were created in, from their
+// nodeTargetGroupsTag, each once, sorted.
+func extraTargetGroupNames(instances map[string]ec2types.Instance) (names []string) {
+	seen := make(map[string]bool)
+
+	for _, instance := range instances {
+		for _, tag := range instance.Tags {
+			if awssdk.ToString(tag.Key) != nodeTargetGroupsTag {
+				continue
+			}
+
+			for _, name := range strings.Split(awssdk.ToString(tag.Value), ",") {
+				if name != "" && !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// extraLoadBalancers builds the info for the load balancers of the nodes' extra target groups that aren't among the
+// cluster's own, known.  A target group that's since been deleted is skipped, with a warning.
+func extraLoadBalancers(ctx context.Context, cm *aws.AWSClusterManager, nodes []manager.NodeInfo, instances map[string]ec2types.Instance, known []manager.LBInfo) (infos []manager.LBInfo, err error) {
+	names := extraTargetGroupNames(instances)
+	if len(names) == 0 {
+		return infos, err
+	}
+
+	var lbArns []string
+	for _, name := range names {
+		output, describeErr := cm.ELBClient.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{Names: []string{name}})
+		if describeErr != nil {
+			logrus.Warnf("failed looking up extra target group %s: %s", name, describeErr)
+			continue
+		}
+
+		for _, tg := range output.TargetGroups {
+			for _, arn := range tg.LoadBalancerArns {
+				if !slices.Contains(lbArns, arn) {
+					lbArns = append(lbArns, arn)
+				}
+			}
+		}
+	}
+
+	if len(lbArns) == 0 {
+		return infos, err
+	}
+
+	output, err := cm.ELBClient.DescribeLoadBalancers(ctx, &elasticloadbalancingv2.DescribeLoadBalancersInput{LoadBalancerArns: lbArns})
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting the load balancers of extra target groups %s", strings.Join(names, ", "))
+		return infos, err
+	}
+
+	// As in describeClusterLBs, the cluster manager makes its calls with ctx, and its node cache is seeded, and its own
+	worker := *cm
+	worker.Context = ctx
+	worker.FetchedNodesById = make(map[string]manager.NodeInfo, len(nodes))
+	worker.FetchedNodesByName = make(map[string]manager.NodeInfo, len(nodes))
+	for _, node := range nodes {
+		worker.FetchedNodesById[node.ID] = node
+		worker.FetchedNodesByName[node.Name] = node
+	}
+
+	for _, lb := range output.LoadBalancers {
+		if slices.ContainsFunc(known, func(info manager.LBInfo) bool { return info.Name == awssdk.ToString(lb.LoadBalancerName) }) {
+			continue
+		}
+
+		lbInfo, lbErr := describeLB(ctx, &worker, lb)
+		if lbErr != nil {
+			err = errors.Wrapf(lbErr, "failed describing load balancer %s", awssdk.ToString(lb.LoadBalancerName))
+			return infos, err
+		}
+
+		infos = append(infos, *lbInfo)
+	}
+
+	return infos, err
+}
@@ -0,0 +1,145 @@
+package k8sctl
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTargetGroupsELBClient looks up its target groups by ARN or name, failing on any not among them, as AWS does, and
+// records the targets registered, by target group ARN.
+type fakeTargetGroupsELBClient struct {
+	aws.ELBClient
+	targetGroups []elbtypes.TargetGroup
+	registered   []string
+	failRegister map[string]bool
+}
+
+func (f *fakeTargetGroupsELBClient) DescribeTargetGroups(_ context.Context, params *elasticloadbalancingv2.DescribeTargetGroupsInput, _ ...func(*elasticloadbalancingv2.Options)) (output *elasticloadbalancingv2.DescribeTargetGroupsOutput, err error) {
+	output = &elasticloadbalancingv2.DescribeTargetGroupsOutput{}
+
+	for _, ref := range append(params.TargetGroupArns, params.Names...) {
+		found := false
+		for _, tg := range f.targetGroups {
+			if ref == *tg.TargetGroupArn || ref == *tg.TargetGroupName {
+				output.TargetGroups = append(output.TargetGroups, tg)
+				found = true
+			}
+		}
+
+		if !found {
+			err = &elbtypes.TargetGroupNotFoundException{Message: awssdk.String("One or more target groups not found")}
+			return output, err
+		}
+	}
+
+	return output, err
+}
+
+func (f *fakeTargetGroupsELBClient) RegisterTargets(_ context.Context, params *elasticloadbalancingv2.RegisterTargetsInput, _ ...func(*elasticloadbalancingv2.Options)) (output *elasticloadbalancingv2.RegisterTargetsOutput, err error) {
+	if f.failRegister[*params.TargetGroupArn] {
+		err = errors.New("throttled")
+		return output, err
+	}
+
+	f.registered = append(f.registered, *params.TargetGroupArn+"="+*params.Targets[0].Id)
+	output = &elasticloadbalancingv2.RegisterTargetsOutput{}
+	return output, err
+}
+
+func testTargetGroup(name string, targetType elbtypes.TargetTypeEnum, lbArns ...string) (tg elbtypes.TargetGroup) {
+	tg = elbtypes.TargetGroup{
+		TargetGroupName:  awssdk.String(name),
+		TargetGroupArn:   awssdk.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/" + name + "/0123"),
+		TargetType:       targetType,
+		Port:             awssdk.Int32(443),
+		LoadBalancerArns: lbArns,
+	}
+	return tg
+}
+
+func TestResolveTargetGroups(t *testing.T) {
+	client := &fakeTargetGroupsELBClient{targetGroups: []elbtypes.TargetGroup{
+		testTargetGroup("canary-443", elbtypes.TargetTypeEnumInstance, "arn:lb/canary"),
+		testTargetGroup("canary-ip-443", elbtypes.TargetTypeEnumIp, "arn:lb/canary"),
+		testTargetGroup("unattached", elbtypes.TargetTypeEnumInstance),
+		testTargetGroup("lambda", elbtypes.TargetTypeEnumLambda, "arn:lb/canary"),
+	}}
+
+	tgs, err := resolveTargetGroups(context.Background(), client, nil)
+	require.NoError(t, err)
+	assert.Empty(t, tgs)
+
+	// By name and ARN, each once
+	tgs, err = resolveTargetGroups(context.Background(), client, []string{"canary-443", *client.targetGroups[1].TargetGroupArn, *client.targetGroups[0].TargetGroupArn})
+	require.NoError(t, err)
+	require.Len(t, tgs, 2)
+	names := []string{*tgs[0].TargetGroupName, *tgs[1].TargetGroupName}
+	assert.ElementsMatch(t, []string{"canary-443", "canary-ip-443"}, names)
+
+	for _, refs := range [][]string{{"missing"}, {"canary-443", "missing"}, {""}, {"unattached"}, {"lambda"}} {
+		_, err = resolveTargetGroups(context.Background(), client, refs)
+		assert.Error(t, err, "%v", refs)
+	}
+}
+
+func TestTargetGroupsTagValue(t *testing.T) {
+	value, err := targetGroupsTagValue([]elbtypes.TargetGroup{
+		testTargetGroup("canary-80", elbtypes.TargetTypeEnumInstance),
+		testTargetGroup("canary-443", elbtypes.TargetTypeEnumInstance),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "canary-443,canary-80", value)
+
+	var tooMany []elbtypes.TargetGroup
+	for i := range 9 {
+		tooMany = append(tooMany, testTargetGroup(strings.Repeat(string(rune('a'+i)), 32), elbtypes.TargetTypeEnumInstance))
+	}
+	_, err = targetGroupsTagValue(tooMany)
+	assert.Error(t, err)
+}
+
+func TestRegisterTargetGroups(t *testing.T) {
+	instanceTG := testTargetGroup("canary-443", elbtypes.TargetTypeEnumInstance, "arn:lb/canary")
+	ipTG := testTargetGroup("canary-ip-443", elbtypes.TargetTypeEnumIp, "arn:lb/canary")
+	failingTG := testTargetGroup("canary-80", elbtypes.TargetTypeEnumInstance, "arn:lb/canary")
+
+	client := &fakeTargetGroupsELBClient{failRegister: map[string]bool{*failingTG.TargetGroupArn: true}}
+
+	registered, err := registerTargetGroups(context.Background(), client, []elbtypes.TargetGroup{instanceTG, ipTG}, "i-1", "10.0.1.11")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"canary-443", "canary-ip-443"}, registered)
+	assert.Equal(t, []string{*instanceTG.TargetGroupArn + "=i-1", *ipTG.TargetGroupArn + "=10.0.1.11"}, client.registered)
+
+	// A failure doesn't stop the rest, and is named in the error
+	client.registered = nil
+	registered, err = registerTargetGroups(context.Background(), client, []elbtypes.TargetGroup{failingTG, instanceTG, ipTG}, "i-1", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "canary-80: throttled")
+	assert.Contains(t, err.Error(), "canary-ip-443: the instance's private IP isn't known")
+	assert.Equal(t, []string{"canary-443"}, registered)
+	assert.Equal(t, []string{*instanceTG.TargetGroupArn + "=i-1"}, client.registered)
+}
+
+func TestExtraTargetGroupNames(t *testing.T) {
+	instances := map[string]ec2types.Instance{
+		"i-1": {Tags: []ec2types.Tag{
+			{Key: awssdk.String("Name"), Value: awssdk.String("cluster1-worker-1")},
+			{Key: awssdk.String(nodeTargetGroupsTag), Value: awssdk.String("canary-80,canary-443")},
+		}},
+		"i-2": {Tags: []ec2types.Tag{{Key: awssdk.String(nodeTargetGroupsTag), Value: awssdk.String("canary-443")}}},
+		"i-3": {Tags: []ec2types.Tag{{Key: awssdk.String("Name"), Value: awssdk.String("cluster1-worker-3")}}},
+	}
+
+	assert.Equal(t, []string{"canary-443", "canary-80"}, extraTargetGroupNames(instances))
+	assert.Empty(t, extraTargetGroupNames(map[string]ec2types.Instance{"i-3": instances["i-3"]}))
+}
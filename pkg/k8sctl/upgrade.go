@@ -0,0 +1,452 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/kubernetes"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/talos"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Per-node upgrade outcomes.
+const (
+	NodeUpgradeStatusUpgraded = "upgraded"
+	NodeUpgradeStatusSkipped  = "skipped"
+	NodeUpgradeStatusFailed   = "failed"
+	// NodeUpgradeStatusRolledBack marks a node that was upgraded, then reverted to its previous version after it or a
+	// later node failed.
+	NodeUpgradeStatusRolledBack = "rolled-back"
+)
+
+// What to do with the rest of a batch operation, a rolling upgrade's nodes or a secrets sync's roles, when one fails.
+const (
+	OnFailureContinue = "continue"  // carry on with the rest, and report every failure at the end
+	OnFailureFailFast = "fail-fast" // stop at the first failure, leaving the rest untried
+	OnFailureRollback = "rollback"  // rolling upgrades only: stop, and revert the nodes already upgraded to their previous version
+)
+
+// unknownVersion is reported when a node's running version can't be determined.
+const unknownVersion = "unknown"
+
+// phaseHealthGate is the failure phase reported when an upgraded node doesn't recover within the health timeout.
+const phaseHealthGate = "health-gate"
+
+// healthGatePollInterval is how often the health gate re-checks a node's load balancer targets.
+const healthGatePollInterval = 10 * time.Second
+
+// defaultHealthTimeout is used when a request does not set a health timeout.
+const defaultHealthTimeout = 10 * time.Minute
+
+// NodeUpgradeDetail is the outcome of upgrading a single node during a rolling upgrade.
+type NodeUpgradeDetail struct {
+	Node            string        `json:"node"`
+	Role            string        `json:"role"`
+	PreviousVersion string        `json:"previous_version"`
+	TargetVersion   string        `json:"target_version"`
+	Status          string        `json:"status"`
+	Duration        time.Duration `json:"duration"`
+	Phase           string        `json:"phase,omitempty"` // phase the upgrade failed in, e.g. "upgrade", "health-check"
+	Error           string        `json:"error,omitempty"`
+	RollbackError   string        `json:"rollback_error,omitempty"`
+}
+
+// rollingUpgradeOptions are the options for a rolling upgrade, on top of the per-node upgrade options.
+type rollingUpgradeOptions struct {
+	manager.UpgradeOptions
+	OnFailure     string
+	HealthTimeout time.Duration // how long an upgraded node has to become Ready with healthy LB targets.  0 disables the health gate.
+	Nodes         []string      // upgrade only these nodes, by name with or without the domain.  Empty upgrades every node.
+}
+
+// ClusterUpgradeResult is the per-node outcome of a rolling cluster upgrade.
+type ClusterUpgradeResult struct {
+	Cluster       string              `json:"cluster"`
+	Version       string              `json:"version"`
+	DryRun        bool                `json:"dry_run"`
+	Nodes         []NodeUpgradeDetail `json:"nodes"`
+	Upgraded      int                 `json:"upgraded"`
+	Skipped       int                 `json:"skipped"`
+	Failed        int                 `json:"failed"`
+	RolledBack    int                 `json:"rolled_back"`
+	TotalDuration time.Duration       `json:"total_duration"`
+	Error         string              `json:"error,omitempty"` // set if the upgrade stopped before every node was tried
+
+	NotTried []string `json:"not_tried,omitempty"` // the nodes an upgrade that stopped early didn't get to
+
+	// Health is the whole cluster's health after the upgrade, with wait.
+	Health *ClusterHealth `json:"health,omitempty"`
+}
+
+// Succeeded returns whether every node was upgraded or skipped, and with wait, the cluster recovered.
+func (r ClusterUpgradeResult) Succeeded() (ok bool) {
+	ok = r.Failed == 0 && r.Error == "" && (r.Health == nil || r.Health.Healthy)
+	return ok
+}
+
+// ConsolePrint prints the upgrade result as a per-node table.
+func (r ClusterUpgradeResult) ConsolePrint() {
+	header := fmt.Sprintf("Upgrade of cluster %q to %s", r.Cluster, r.Version)
+	if r.DryRun {
+		header += " [DRY RUN]"
+	}
+	fmt.Printf("%s\n\n", header)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "NODE\tROLE\tPREVIOUS\tTARGET\tSTATUS\tDURATION\n")
+	for _, node := range r.Nodes {
+		status := node.Status
+		if r.DryRun && status == NodeUpgradeStatusUpgraded {
+			status = "would upgrade"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", node.Node, node.Role, node.PreviousVersion, node.TargetVersion, status, node.Duration.Round(time.Second))
+	}
+	_ = w.Flush()
+
+	for _, node := range r.Nodes {
+		if node.Error != "" {
+			fmt.Printf("\n%s failed during %s: %s", node.Node, node.Phase, node.Error)
+		}
+		if node.RollbackError != "" {
+			fmt.Printf("\n%s rollback to %s: %s", node.Node, node.PreviousVersion, node.RollbackError)
+		}
+	}
+
+	fmt.Printf("\nUpgraded: %d, Skipped: %d, Failed: %d, Rolled Back: %d, Total Duration: %s\n", r.Upgraded, r.Skipped, r.Failed, r.RolledBack, r.TotalDuration.Round(time.Second))
+
+	if r.Error != "" {
+		fmt.Printf("Upgrade stopped: %s\n", r.Error)
+	}
+
+	if len(r.NotTried) > 0 {
+		fmt.Printf("Not tried: %s\n", strings.Join(r.NotTried, ", "))
+	}
+
+	if r.Health != nil {
+		fmt.Printf("Cluster %s\n", r.Health.Summary())
+	}
+}
+
+// upgradeCluster performs a rolling upgrade of a cluster, one node at a time, recording the outcome (and previous version) of each node.
+// With ControlPlaneFirst, control plane nodes are upgraded before workers; otherwise nodes go in name order.
+// MaxConcurrent is not supported (the handler rejects values above 1).  Nodes already running the target version are skipped.
+// With Nodes, only those nodes are upgraded, in the same order, and it's an error if any of them isn't in the cluster.
+// When a node fails, OnFailureContinue carries on with the remaining nodes, and OnFailureFailFast stops.
+// OnFailureRollback stops, and moves the nodes already upgraded, and the failed node if it's no longer on its previous
+// version, back to that version.  The nodes a stopped upgrade didn't get to are listed as NotTried.
+//
+// After each node is upgraded, the health gate waits (up to HealthTimeout) for it to be Ready in Kubernetes and for its
+// load balancer targets to be healthy.  A node that doesn't recover always aborts the upgrade of the remaining nodes,
+// since carrying on risks taking down the next node while this one is still out.
+func upgradeCluster(ctx context.Context, cm *aws.AWSClusterManager, clusterName string, version string, options rollingUpgradeOptions, verbose bool) (result ClusterUpgradeResult, err error) {
+	startTime := time.Now()
+
+	result.Cluster = clusterName
+	result.Version = version
+	result.DryRun = options.DryRun
+	result.Nodes = make([]NodeUpgradeDetail, 0)
+
+	_, err = aws.ValidateTalosVersion(version)
+	if err != nil {
+		err = errors.Wrapf(err, "invalid version format")
+		return result, err
+	}
+
+	clusterInfo, err := cm.DescribeCluster(clusterName)
+	if err != nil {
+		err = errors.Wrapf(err, "failed describing cluster %s", clusterName)
+		return result, err
+	}
+
+	if len(options.Nodes) > 0 {
+		clusterInfo.Nodes, err = selectUpgradeNodes(clusterInfo.Nodes, options.Nodes)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	roles := nodeRoles(clusterInfo.Nodes, nodeInstances(ctx, cm, clusterInfo.Nodes))
+	nodes := upgradeOrder(clusterInfo.Nodes, roles, options.ControlPlaneFirst)
+
+	nodesByName := make(map[string]manager.NodeInfo, len(nodes))
+	for _, node := range nodes {
+		nodesByName[node.Name] = node
+	}
+
+	for i, node := range nodes {
+		detail := upgradeClusterNode(ctx, cm, node, roleOf(roles, node.Name), version, options.UpgradeOptions, verbose)
+
+		if detail.Status == NodeUpgradeStatusUpgraded && options.HealthTimeout > 0 && !options.DryRun && !options.Stage {
+			gateStart := time.Now()
+			gateErr := waitForNodeHealthy(ctx, cm, node.Name, options.HealthTimeout, verbose)
+			detail.Duration += time.Since(gateStart)
+			if gateErr != nil {
+				detail.Status = NodeUpgradeStatusFailed
+				detail.Phase = phaseHealthGate
+				detail.Error = gateErr.Error()
+				logrus.Errorf("node %s did not recover after upgrade: %s", node.Name, gateErr)
+			}
+		}
+
+		result.Nodes = append(result.Nodes, detail)
+
+		switch detail.Status {
+		case NodeUpgradeStatusUpgraded:
+			result.Upgraded++
+		case NodeUpgradeStatusSkipped:
+			result.Skipped++
+		case NodeUpgradeStatusFailed:
+			result.Failed++
+		}
+
+		if detail.Status == NodeUpgradeStatusFailed && options.OnFailure == OnFailureRollback {
+			logrus.Warnf("node %s failed, rolling back nodes already upgraded to %s", node.Name, version)
+			rollbackUpgradedNodes(ctx, cm, &result, nodesByName, options, verbose)
+			result.NotTried = nodeNames(nodes[i+1:])
+			break
+		}
+
+		if detail.Status == NodeUpgradeStatusFailed && options.OnFailure == OnFailureFailFast {
+			logrus.Warnf("stopping upgrade of cluster %s: node %s failed", clusterName, node.Name)
+			result.Error = fmt.Sprintf("node %s failed, and on_failure is %s", node.Name, OnFailureFailFast)
+			result.NotTried = nodeNames(nodes[i+1:])
+			break
+		}
+
+		if detail.Phase == phaseHealthGate {
+			logrus.Warnf("aborting upgrade of cluster %s: node %s failed the health gate", clusterName, node.Name)
+			result.NotTried = nodeNames(nodes[i+1:])
+			break
+		}
+
+		// Wait between nodes, but only if we actually touched this one
+		if detail.Status == NodeUpgradeStatusUpgraded && !options.DryRun && options.WaitBetween > 0 && i < len(nodes)-1 {
+			logrus.Infof("waiting %s before upgrading next node", options.WaitBetween)
+			time.Sleep(options.WaitBetween)
+		}
+	}
+
+	result.TotalDuration = time.Since(startTime)
+
+	logrus.Infof("cluster %s upgrade to %s complete: %d upgraded, %d skipped, %d failed, %d rolled back", clusterName, version, result.Upgraded, result.Skipped, result.Failed, result.RolledBack)
+
+	return result, err
+}
+
+// rollbackUpgradedNodes reverts every node upgraded so far to its previous version, most recently upgraded first.
+// A failed node is reverted too, if it's running something other than its previous version (e.g. it upgraded, but
+// failed the health gate).  Each reverted node is health gated like an upgraded one.
+// Nodes whose previous version is unknown can't be rolled back, and are left on the new version.
+func rollbackUpgradedNodes(ctx context.Context, cm *aws.AWSClusterManager, result *ClusterUpgradeResult, nodes map[string]manager.NodeInfo, options rollingUpgradeOptions, verbose bool) {
+	for i := len(result.Nodes) - 1; i >= 0; i-- {
+		detail := &result.Nodes[i]
+
+		switch detail.Status {
+		case NodeUpgradeStatusUpgraded:
+		case NodeUpgradeStatusFailed:
+			if detail.PreviousVersion == unknownVersion {
+				continue
+			}
+
+			running, versionErr := runningVersion(ctx, cm, nodes[detail.Node], detail.PreviousVersion, verbose)
+			if versionErr != nil {
+				detail.RollbackError = fmt.Sprintf("unable to determine running version: %s", versionErr)
+				logrus.Errorf("cannot tell whether failed node %s needs rolling back: %s", detail.Node, versionErr)
+				continue
+			}
+
+			if running == detail.PreviousVersion {
+				continue
+			}
+		default:
+			continue
+		}
+
+		if detail.PreviousVersion == unknownVersion {
+			detail.RollbackError = "previous version unknown"
+			logrus.Errorf("cannot roll back node %s: previous version unknown", detail.Node)
+			continue
+		}
+
+		logrus.Infof("rolling back node %s to %s", detail.Node, detail.PreviousVersion)
+
+		_, rollbackErr := cm.UpgradeNode(detail.Node, detail.PreviousVersion, options.UpgradeOptions)
+		if rollbackErr != nil {
+			detail.RollbackError = rollbackErr.Error()
+			logrus.Errorf("failed rolling back node %s to %s: %s", detail.Node, detail.PreviousVersion, rollbackErr)
+			continue
+		}
+
+		if options.HealthTimeout > 0 && !options.Stage {
+			gateErr := waitForNodeHealthy(ctx, cm, detail.Node, options.HealthTimeout, verbose)
+			if gateErr != nil {
+				detail.RollbackError = fmt.Sprintf("rolled back, but not healthy: %s", gateErr)
+				logrus.Errorf("node %s did not recover after rolling back to %s: %s", detail.Node, detail.PreviousVersion, gateErr)
+			}
+		}
+
+		// A failed node still counts as failed, so the upgrade is reported as unsuccessful
+		if detail.Status == NodeUpgradeStatusUpgraded {
+			result.Upgraded--
+		}
+
+		detail.Status = NodeUpgradeStatusRolledBack
+		result.RolledBack++
+	}
+}
+
+// waitForNodeHealthy waits until a node is Ready in Kubernetes, and all of its load balancer targets are healthy.
+func waitForNodeHealthy(ctx context.Context, cm *aws.AWSClusterManager, nodeName string, timeout time.Duration, verbose bool) (err error) {
+	deadline := time.Now().Add(timeout)
+	shortName := stripDomainSuffix(nodeName)
+
+	logrus.Infof("waiting up to %s for node %s to become healthy", timeout, shortName)
+
+	err = kubernetes.WaitForNodeReady(ctx, shortName, timeout, verbose)
+	if err != nil {
+		err = errors.Wrapf(err, "node %s did not become Ready", shortName)
+		return err
+	}
+
+	for {
+		unhealthy, checkErr := unhealthyNodeTargets(cm, shortName)
+		if checkErr == nil && len(unhealthy) == 0 {
+			logrus.Infof("node %s is Ready and its load balancer targets are healthy", shortName)
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			if checkErr != nil {
+				err = errors.Wrapf(checkErr, "unable to check load balancer targets for node %s within %s", shortName, timeout)
+				return err
+			}
+			err = errors.New(fmt.Sprintf("load balancer targets for node %s not healthy after %s: %s", shortName, timeout, strings.Join(unhealthy, ", ")))
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			err = errors.Wrapf(ctx.Err(), "gave up waiting for node %s to become healthy", shortName)
+			return err
+		case <-time.After(healthGatePollInterval):
+		}
+	}
+}
+
+// unhealthyNodeTargets returns the load balancer targets for a node that are not healthy.
+func unhealthyNodeTargets(cm *aws.AWSClusterManager, shortName string) (unhealthy []string, err error) {
+	lbs, err := cm.GetClusterLBs()
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting load balancers")
+		return unhealthy, err
+	}
+
+	for _, target := range findUnhealthyTargets(lbs) {
+		if stripDomainSuffix(target.Target) == shortName {
+			unhealthy = append(unhealthy, fmt.Sprintf("%s:%d (%s)", target.LoadBalancer, target.Port, target.State))
+		}
+	}
+
+	return unhealthy, err
+}
+
+// upgradeOrder returns the nodes in the order they should be upgraded: sorted by name, with control plane nodes first
+// if controlPlaneFirst is set, with the nodes' roles from roles.
+func upgradeOrder(nodes []manager.NodeInfo, roles map[string]string, controlPlaneFirst bool) (ordered []manager.NodeInfo) {
+	ordered = make([]manager.NodeInfo, len(nodes))
+	copy(ordered, nodes)
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		iCp := roleOf(roles, ordered[i].Name) == manager.NodeRoleCp
+		jCp := roleOf(roles, ordered[j].Name) == manager.NodeRoleCp
+		if controlPlaneFirst && iCp != jCp {
+			return iCp
+		}
+		return ordered[i].Name < ordered[j].Name
+	})
+
+	return ordered
+}
+
+// nodeNames returns the nodes' names, in order.
+func nodeNames(nodes []manager.NodeInfo) (names []string) {
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+
+	return names
+}
+
+// upgradeClusterNode upgrades a single node, unless it is already on the target version.
+func upgradeClusterNode(ctx context.Context, cm *aws.AWSClusterManager, node manager.NodeInfo, role string, version string, options manager.UpgradeOptions, verbose bool) (detail NodeUpgradeDetail) {
+	startTime := time.Now()
+
+	detail = NodeUpgradeDetail{
+		Node:            node.Name,
+		Role:            role,
+		PreviousVersion: unknownVersion,
+		TargetVersion:   version,
+	}
+
+	previous, versionErr := runningVersion(ctx, cm, node, version, verbose)
+	if versionErr != nil {
+		logrus.Warnf("unable to determine current version of node %s: %s", node.Name, versionErr)
+	} else {
+		detail.PreviousVersion = previous
+	}
+
+	if detail.PreviousVersion == version {
+		detail.Status = NodeUpgradeStatusSkipped
+		detail.Duration = time.Since(startTime)
+		logrus.Infof("node %s is already running %s, skipping", node.Name, version)
+		return detail
+	}
+
+	nodeResult, upgradeErr := cm.UpgradeNode(node.Name, version, options)
+	detail.Duration = time.Since(startTime)
+
+	if upgradeErr != nil {
+		detail.Status = NodeUpgradeStatusFailed
+		detail.Error = upgradeErr.Error()
+		detail.Phase = "upgrade"
+		if len(nodeResult.NodesFailed) > 0 {
+			detail.Phase = nodeResult.NodesFailed[0].Phase
+		}
+		logrus.Errorf("failed upgrading node %s: %s", node.Name, upgradeErr)
+		return detail
+	}
+
+	detail.Status = NodeUpgradeStatusUpgraded
+
+	return detail
+}
+
+// runningVersion returns the Talos version a node is currently running.
+func runningVersion(ctx context.Context, cm *aws.AWSClusterManager, node manager.NodeInfo, version string, verbose bool) (running string, err error) {
+	ip, err := nodePrivateIP(cm, node.Name, node.ID)
+	if err != nil {
+		return running, err
+	}
+
+	talosNode := aws.AWSNode{
+		NodeName:  node.Name,
+		IPAddress: ip,
+		NodeID:    node.ID,
+	}
+
+	_, running, err = talos.VerifyNodeVersion(ctx, talosNode, version, verbose)
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting version of node %s", node.Name)
+		return running, err
+	}
+
+	return running, err
+}
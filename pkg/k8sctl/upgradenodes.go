@@ -0,0 +1,44 @@
+package k8sctl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/pkg/errors"
+)
+
+// selectUpgradeNodes returns the cluster's nodes named, by name with or without the domain, for a rolling upgrade of
+// just those.  It's an error if any of them isn't in the cluster, so a typo can't quietly upgrade fewer nodes than
+// asked for.
+func selectUpgradeNodes(nodes []manager.NodeInfo, names []string) (selected []manager.NodeInfo, err error) {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[stripDomainSuffix(name)] = true
+	}
+
+	found := make(map[string]bool, len(names))
+	for _, node := range nodes {
+		shortName := stripDomainSuffix(node.Name)
+		if !wanted[shortName] || found[shortName] {
+			continue
+		}
+
+		found[shortName] = true
+		selected = append(selected, node)
+	}
+
+	var missing []string
+	for _, name := range names {
+		if !found[stripDomainSuffix(name)] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		err = errors.New(fmt.Sprintf("no node named %s in the cluster", strings.Join(missing, ", ")))
+		return selected, err
+	}
+
+	return selected, err
+}
@@ -0,0 +1,29 @@
+package k8sctl
+
+import (
+	"testing"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectUpgradeNodes(t *testing.T) {
+	nodes := []manager.NodeInfo{
+		{Name: "cluster1-cp-1.example.com", ID: "i-1"},
+		{Name: "cluster1-cp-2.example.com", ID: "i-2"},
+		{Name: "cluster1-worker-1.example.com", ID: "i-3"},
+		{Name: "cluster1-worker-2.example.com", ID: "i-4"},
+	}
+
+	// By name with or without the domain, each once, in the cluster's order for upgradeOrder to sort
+	selected, err := selectUpgradeNodes(nodes, []string{"cluster1-worker-2", "cluster1-cp-2.example.com", "cluster1-worker-2.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cluster1-cp-2.example.com", "cluster1-worker-2.example.com"}, nodeNames(selected))
+
+	assert.Equal(t, []string{"cluster1-cp-2.example.com", "cluster1-worker-2.example.com"}, nodeNames(upgradeOrder(selected, nil, true)))
+
+	_, err = selectUpgradeNodes(nodes, []string{"cluster1-worker-1", "cluster1-worker-9", "cluster1-cp-7"})
+	require.Error(t, err)
+	assert.Equal(t, "no node named cluster1-worker-9, cluster1-cp-7 in the cluster", err.Error())
+}
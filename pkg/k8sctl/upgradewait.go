@@ -0,0 +1,154 @@
+package k8sctl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	k8s_utility_client "github.com/nikogura/k8s-utility-client/pkg/k8s-utility-client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultUpgradeWaitTimeout is how long an upgrade with wait waits for the cluster to recover, if the request doesn't
+// say.
+const defaultUpgradeWaitTimeout = 15 * time.Minute
+
+// ClusterHealth is whether every node in a cluster is Ready in Kubernetes with healthy load balancer targets, and if
+// not, what isn't.
+type ClusterHealth struct {
+	Healthy          bool              `json:"healthy"`
+	NotReady         []string          `json:"not_ready,omitempty"` // nodes in EC2 that aren't Ready Kubernetes nodes, or aren't in Kubernetes at all
+	UnhealthyTargets []UnhealthyTarget `json:"unhealthy_targets,omitempty"`
+	Waited           time.Duration     `json:"waited"`
+	Error            string            `json:"error,omitempty"` // why the health couldn't be checked, the last time it was
+}
+
+// Summary formats the health on one line, e.g. "healthy after 1m30s", or what's still unhealthy.
+func (h ClusterHealth) Summary() (summary string) {
+	if h.Healthy {
+		summary = fmt.Sprintf("healthy after %s", h.Waited.Round(time.Second))
+		return summary
+	}
+
+	var problems []string
+	if len(h.NotReady) > 0 {
+		problems = append(problems, fmt.Sprintf("not Ready: %s", strings.Join(h.NotReady, ", ")))
+	}
+
+	for _, target := range h.UnhealthyTargets {
+		problems = append(problems, target.Summary())
+	}
+
+	if h.Error != "" {
+		problems = append(problems, h.Error)
+	}
+
+	summary = fmt.Sprintf("not healthy after %s: %s", h.Waited.Round(time.Second), strings.Join(problems, "; "))
+	return summary
+}
+
+// waitForClusterHealthy waits, up to timeout, until every node in the cluster is Ready in Kubernetes and every load
+// balancer target is healthy, and returns the cluster's health when it is, or when time's up.
+func waitForClusterHealthy(ctx context.Context, cm *aws.AWSClusterManager, clusterName string, timeout time.Duration) (health ClusterHealth) {
+	start := time.Now()
+	deadline := start.Add(timeout)
+
+	logrus.Infof("waiting up to %s for cluster %s to be healthy", timeout, clusterName)
+
+	for {
+		health = checkClusterHealth(ctx, cm, clusterName)
+		health.Waited = time.Since(start)
+
+		if health.Healthy {
+			logrus.Infof("cluster %s is %s", clusterName, health.Summary())
+			return health
+		}
+
+		if time.Now().After(deadline) {
+			logrus.Warnf("cluster %s is %s", clusterName, health.Summary())
+			return health
+		}
+
+		select {
+		case <-ctx.Done():
+			health.Error = fmt.Sprintf("gave up waiting: %s", ctx.Err())
+			return health
+		case <-time.After(healthGatePollInterval):
+		}
+	}
+}
+
+// checkClusterHealth checks once whether every node in the cluster is Ready, with healthy load balancer targets.
+func checkClusterHealth(ctx context.Context, cm *aws.AWSClusterManager, clusterName string) (health ClusterHealth) {
+	nodes, err := cm.GetNodes(clusterName)
+	if err != nil {
+		health.Error = fmt.Sprintf("failed getting cluster nodes: %s", err)
+		return health
+	}
+
+	ready, err := k8sNodesReady(ctx)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+
+	lbs, err := cm.GetClusterLBs()
+	if err != nil {
+		health.Error = fmt.Sprintf("failed getting load balancers: %s", err)
+		return health
+	}
+
+	health = clusterHealth(nodes, ready, findUnhealthyTargets(lbs))
+	return health
+}
+
+// clusterHealth is checkClusterHealth, given the cluster's nodes, whether each Kubernetes node is Ready, by name, and
+// the unhealthy load balancer targets.
+func clusterHealth(nodes []manager.NodeInfo, ready map[string]bool, unhealthy []UnhealthyTarget) (health ClusterHealth) {
+	for _, node := range nodes {
+		if !ready[stripDomainSuffix(node.Name)] {
+			health.NotReady = append(health.NotReady, node.Name)
+		}
+	}
+
+	health.UnhealthyTargets = unhealthy
+	health.Healthy = len(health.NotReady) == 0 && len(health.UnhealthyTargets) == 0
+
+	return health
+}
+
+// k8sNodesReady returns whether each Kubernetes node is Ready, by its name without the domain.
+func k8sNodesReady(ctx context.Context) (ready map[string]bool, err error) {
+	ready = make(map[string]bool)
+
+	clients, err := k8s_utility_client.NewK8sClients()
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating k8s clients")
+		return ready, err
+	}
+
+	nodes, err := clients.ClientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = errors.Wrapf(err, "failed listing Kubernetes nodes")
+		return ready, err
+	}
+
+	for _, node := range nodes.Items {
+		name := stripDomainSuffix(node.Name)
+		ready[name] = false
+
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady {
+				ready[name] = condition.Status == corev1.ConditionTrue
+			}
+		}
+	}
+
+	return ready, err
+}
@@ -0,0 +1,38 @@
+package k8sctl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterHealth(t *testing.T) {
+	nodes := []manager.NodeInfo{
+		{Name: "cluster1-cp-1.example.com", ID: "i-1"},
+		{Name: "cluster1-worker-1.example.com", ID: "i-2"},
+		{Name: "cluster1-worker-2.example.com", ID: "i-3"},
+	}
+
+	health := clusterHealth(nodes, map[string]bool{"cluster1-cp-1": true, "cluster1-worker-1": true, "cluster1-worker-2": true}, nil)
+	assert.True(t, health.Healthy)
+
+	// Not Ready, not in Kubernetes at all, or with an unhealthy target
+	unhealthy := []UnhealthyTarget{{LoadBalancer: "cluster1-ingress", Target: "cluster1-worker-1", Port: 443, State: "unhealthy"}}
+	health = clusterHealth(nodes, map[string]bool{"cluster1-cp-1": false, "cluster1-worker-1": true}, unhealthy)
+	assert.False(t, health.Healthy)
+	assert.Equal(t, []string{"cluster1-cp-1.example.com", "cluster1-worker-2.example.com"}, health.NotReady)
+	assert.Equal(t, unhealthy, health.UnhealthyTargets)
+
+	health.Waited = 15 * time.Minute
+	assert.Equal(t, "not healthy after 15m0s: not Ready: cluster1-cp-1.example.com, cluster1-worker-2.example.com; cluster1-ingress/cluster1-worker-1:443 (unhealthy)", health.Summary())
+}
+
+func TestClusterUpgradeResultSucceeded(t *testing.T) {
+	assert.True(t, ClusterUpgradeResult{Upgraded: 3}.Succeeded())
+	assert.True(t, ClusterUpgradeResult{Upgraded: 3, Health: &ClusterHealth{Healthy: true}}.Succeeded())
+	assert.False(t, ClusterUpgradeResult{Upgraded: 3, Health: &ClusterHealth{}}.Succeeded())
+	assert.False(t, ClusterUpgradeResult{Upgraded: 2, Failed: 1}.Succeeded())
+	assert.False(t, ClusterUpgradeResult{Upgraded: 1, Error: "node cluster1-cp-2 failed, and on_failure is fail-fast"}.Succeeded())
+}
@@ -0,0 +1,209 @@
+package k8sctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	k8s_utility_client "github.com/nikogura/k8s-utility-client/pkg/k8s-utility-client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nodeMetricsPath is the metrics server's API for the nodes' current usage.
+const nodeMetricsPath = "/apis/metrics.k8s.io/v1beta1/nodes"
+
+// usageUnavailable is what's shown for usage the metrics server has no figure for.
+const usageUnavailable = "unavailable"
+
+// NodeUtilization is a node's CPU and memory: what it can allot to pods, what the pods on it request, and what it's
+// using.  CPU is in millicores, memory in bytes.  The usage comes from the metrics server, and is nil for a node it has
+// no metrics for, or if it isn't installed.
+type NodeUtilization struct {
+	Node              string `json:"node"`
+	CPUAllocatable    int64  `json:"cpu_allocatable"`
+	CPURequested      int64  `json:"cpu_requested"`
+	CPUUsage          *int64 `json:"cpu_usage"`
+	MemoryAllocatable int64  `json:"memory_allocatable"`
+	MemoryRequested   int64  `json:"memory_requested"`
+	MemoryUsage       *int64 `json:"memory_usage"`
+	Pods              int    `json:"pods"`
+}
+
+// ConsolePrint prints the node's CPU and memory on one line, e.g. "cluster1-worker-1 (12 pods): CPU requested
+// 1500m of 3920m (38%), using 420m (11%); memory requested 2.1Gi of 14.9Gi (14%), using unavailable".
+func (u NodeUtilization) ConsolePrint() {
+	cpu := fmt.Sprintf("CPU requested %dm of %dm (%s), using %s", u.CPURequested, u.CPUAllocatable, percentOf(u.CPURequested, u.CPUAllocatable), usageOf(u.CPUUsage, u.CPUAllocatable, formatMillicores))
+	memory := fmt.Sprintf("memory requested %s of %s (%s), using %s", formatGibibytes(u.MemoryRequested), formatGibibytes(u.MemoryAllocatable), percentOf(u.MemoryRequested, u.MemoryAllocatable), usageOf(u.MemoryUsage, u.MemoryAllocatable, formatGibibytes))
+
+	fmt.Printf("  %s (%d pods): %s; %s\n", u.Node, u.Pods, cpu, memory)
+}
+
+// percentOf formats part as a whole percentage of total.
+func percentOf(part int64, total int64) (percent string) {
+	if total <= 0 {
+		percent = "-"
+		return percent
+	}
+
+	percent = fmt.Sprintf("%d%%", part*100/total)
+	return percent
+}
+
+// usageOf formats a usage and its percentage of what's allocatable, or says it's unavailable.
+func usageOf(usage *int64, allocatable int64, format func(int64) string) (formatted string) {
+	if usage == nil {
+		formatted = usageUnavailable
+		return formatted
+	}
+
+	formatted = fmt.Sprintf("%s (%s)", format(*usage), percentOf(*usage, allocatable))
+	return formatted
+}
+
+func formatMillicores(millicores int64) (formatted string) {
+	formatted = fmt.Sprintf("%dm", millicores)
+	return formatted
+}
+
+func formatGibibytes(bytes int64) (formatted string) {
+	formatted = fmt.Sprintf("%.1fGi", float64(bytes)/(1<<30))
+	return formatted
+}
+
+// nodeMetricsList is the part of the metrics server's node metrics the utilization needs.  It's decoded here, rather
+// than with the metrics API's client, which is a whole module for one request.
+type nodeMetricsList struct {
+	Items []struct {
+		metav1.ObjectMeta `json:"metadata"`
+		Usage             corev1.ResourceList `json:"usage"`
+	} `json:"items"`
+}
+
+// nodeUtilization reports the CPU and memory of the Kubernetes nodes among the given nodes, in name order.  A failure
+// to read the metrics server is logged, and the usage left unavailable, since the rest still stands without it.
+func nodeUtilization(ctx context.Context, nodes []manager.NodeInfo) (utilization []NodeUtilization, err error) {
+	clients, err := k8s_utility_client.NewK8sClients()
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating k8s clients")
+		return utilization, err
+	}
+
+	k8sNodes, err := clients.ClientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = errors.Wrapf(err, "failed listing Kubernetes nodes")
+		return utilization, err
+	}
+
+	// Finished pods don't hold on to what they requested
+	pods, err := clients.ClientSet.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("status.phase!=%s,status.phase!=%s", corev1.PodSucceeded, corev1.PodFailed),
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed listing pods")
+		return utilization, err
+	}
+
+	usage := make(map[string]corev1.ResourceList)
+
+	raw, metricsErr := clients.ClientSet.Discovery().RESTClient().Get().AbsPath(nodeMetricsPath).DoRaw(ctx)
+	if metricsErr == nil {
+		var metrics nodeMetricsList
+		metricsErr = json.Unmarshal(raw, &metrics)
+		for _, item := range metrics.Items {
+			usage[item.Name] = item.Usage
+		}
+	}
+
+	if metricsErr != nil {
+		logrus.Warnf("failed reading node metrics, is the metrics server installed? %s", metricsErr)
+	}
+
+	names := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		names[stripDomainSuffix(node.Name)] = true
+	}
+
+	utilization = buildNodeUtilization(names, k8sNodes.Items, pods.Items, usage)
+	return utilization, err
+}
+
+// buildNodeUtilization is nodeUtilization, given the short names of the nodes to report on, the Kubernetes nodes and
+// the pods running, and the metrics server's usage for each node.
+func buildNodeUtilization(names map[string]bool, k8sNodes []corev1.Node, pods []corev1.Pod, usage map[string]corev1.ResourceList) (utilization []NodeUtilization) {
+	utilization = make([]NodeUtilization, 0, len(names))
+
+	byNode := make(map[string]*NodeUtilization, len(k8sNodes))
+	for _, node := range k8sNodes {
+		if !names[stripDomainSuffix(node.Name)] {
+			continue
+		}
+
+		u := &NodeUtilization{
+			Node:              node.Name,
+			CPUAllocatable:    node.Status.Allocatable.Cpu().MilliValue(),
+			MemoryAllocatable: node.Status.Allocatable.Memory().Value(),
+		}
+
+		if nodeUsage, ok := usage[node.Name]; ok {
+			cpu := nodeUsage.Cpu().MilliValue()
+			memory := nodeUsage.Memory().Value()
+			u.CPUUsage = &cpu
+			u.MemoryUsage = &memory
+		}
+
+		byNode[node.Name] = u
+	}
+
+	for _, pod := range pods {
+		u, ok := byNode[pod.Spec.NodeName]
+		if !ok {
+			continue
+		}
+
+		requests := podRequests(pod)
+		u.CPURequested += requests.Cpu().MilliValue()
+		u.MemoryRequested += requests.Memory().Value()
+		u.Pods++
+	}
+
+	for _, u := range byNode {
+		utilization = append(utilization, *u)
+	}
+
+	sort.Slice(utilization, func(i, j int) bool { return utilization[i].Node < utilization[j].Node })
+
+	return utilization
+}
+
+// podRequests is what a pod requests of its node, as the scheduler counts it: the larger of its containers' requests
+// and any one init container's, plus the pod's overhead.
+func podRequests(pod corev1.Pod) (requests corev1.ResourceList) {
+	requests = corev1.ResourceList{}
+
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		var total resource.Quantity
+		for _, container := range pod.Spec.Containers {
+			total.Add(container.Resources.Requests[name])
+		}
+
+		for _, container := range pod.Spec.InitContainers {
+			if init := container.Resources.Requests[name]; init.Cmp(total) > 0 {
+				total = init.DeepCopy()
+			}
+		}
+
+		if overhead, ok := pod.Spec.Overhead[name]; ok {
+			total.Add(overhead)
+		}
+
+		requests[name] = total
+	}
+
+	return requests
+}
@@ -0,0 +1,97 @@
+package k8sctl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testResources(cpu string, memory string) (resources corev1.ResourceList) {
+	resources = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse(memory),
+	}
+	return resources
+}
+
+func testNode(name string, cpu string, memory string) (node corev1.Node) {
+	node = corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     corev1.NodeStatus{Allocatable: testResources(cpu, memory)},
+	}
+	return node
+}
+
+func testPod(nodeName string, requests ...corev1.ResourceList) (pod corev1.Pod) {
+	pod.Spec.NodeName = nodeName
+	for _, r := range requests {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Resources: corev1.ResourceRequirements{Requests: r}})
+	}
+	return pod
+}
+
+func TestPodRequests(t *testing.T) {
+	pod := testPod("cluster1-worker-1", testResources("250m", "256Mi"), testResources("500m", "512Mi"))
+	requests := podRequests(pod)
+	assert.Equal(t, int64(750), requests.Cpu().MilliValue())
+	assert.Equal(t, int64(768<<20), requests.Memory().Value())
+
+	// An init container asking for more than the containers together sets the CPU, but not the memory
+	pod.Spec.InitContainers = []corev1.Container{{Resources: corev1.ResourceRequirements{Requests: testResources("1", "128Mi")}}}
+	pod.Spec.Overhead = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}
+	requests = podRequests(pod)
+	assert.Equal(t, int64(1100), requests.Cpu().MilliValue())
+	assert.Equal(t, int64(768<<20), requests.Memory().Value())
+
+	// Containers without requests ask for nothing
+	requests = podRequests(testPod("cluster1-worker-1", nil))
+	assert.Equal(t, int64(0), requests.Cpu().MilliValue())
+	assert.Equal(t, int64(0), requests.Memory().Value())
+}
+
+func TestBuildNodeUtilization(t *testing.T) {
+	names := map[string]bool{"cluster1-worker-1": true, "cluster1-worker-2": true}
+	k8sNodes := []corev1.Node{
+		testNode("cluster1-worker-2", "4", "16Gi"),
+		testNode("cluster1-worker-1", "2", "8Gi"),
+		testNode("cluster1-cp-1", "2", "8Gi"), // not asked about, e.g. filtered out by role
+	}
+	pods := []corev1.Pod{
+		testPod("cluster1-worker-1", testResources("500m", "1Gi")),
+		testPod("cluster1-worker-1", testResources("1", "2Gi")),
+		testPod("cluster1-worker-2", testResources("100m", "256Mi")),
+		testPod("cluster1-cp-1", testResources("1", "1Gi")),
+		testPod("", testResources("1", "1Gi")), // pending
+	}
+	// The metrics server has nothing for worker-2
+	usage := map[string]corev1.ResourceList{"cluster1-worker-1": testResources("300m", "3Gi")}
+
+	utilization := buildNodeUtilization(names, k8sNodes, pods, usage)
+	require.Len(t, utilization, 2)
+
+	worker1 := utilization[0]
+	assert.Equal(t, "cluster1-worker-1", worker1.Node)
+	assert.Equal(t, 2, worker1.Pods)
+	assert.Equal(t, int64(2000), worker1.CPUAllocatable)
+	assert.Equal(t, int64(1500), worker1.CPURequested)
+	assert.Equal(t, int64(8<<30), worker1.MemoryAllocatable)
+	assert.Equal(t, int64(3<<30), worker1.MemoryRequested)
+	require.NotNil(t, worker1.CPUUsage)
+	require.NotNil(t, worker1.MemoryUsage)
+	assert.Equal(t, int64(300), *worker1.CPUUsage)
+	assert.Equal(t, int64(3<<30), *worker1.MemoryUsage)
+
+	worker2 := utilization[1]
+	assert.Equal(t, "cluster1-worker-2", worker2.Node)
+	assert.Equal(t, 1, worker2.Pods)
+	assert.Equal(t, int64(100), worker2.CPURequested)
+	assert.Nil(t, worker2.CPUUsage)
+	assert.Nil(t, worker2.MemoryUsage)
+	assert.Equal(t, usageUnavailable, usageOf(worker2.CPUUsage, worker2.CPUAllocatable, formatMillicores))
+	assert.Equal(t, "300m (15%)", usageOf(worker1.CPUUsage, worker1.CPUAllocatable, formatMillicores))
+	assert.Equal(t, "-", percentOf(1, 0))
+}
@@ -0,0 +1,53 @@
+package k8sctl
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// BuildInfo identifies a build of k8sctl.  Version, Commit, and Date are injected at build time via ldflags.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+}
+
+// NewBuildInfo returns the build info for this binary.
+func NewBuildInfo(version string, commit string, date string) (info BuildInfo) {
+	info = BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+	}
+
+	return info
+}
+
+// String formats the build info on one line, e.g. "1.2.3 (commit abc1234, built 2025-06-01T12:00:00Z, go1.25.0)".
+func (b BuildInfo) String() (s string) {
+	s = fmt.Sprintf("%s (commit %s, built %s, %s)", b.Version, b.Commit, b.Date, b.GoVersion)
+	return s
+}
+
+// APIVersionsHeader is the response header in which the server advertises the API versions it serves, e.g. "v1".
+const APIVersionsHeader = "X-K8sctl-API-Versions"
+
+// SupportedAPIVersions returns the API versions this server serves.
+func SupportedAPIVersions() (versions []string) {
+	versions = []string{strings.TrimPrefix(APIPathPrefix, "/")}
+	return versions
+}
+
+// ServerVersionInfo is the server's build info, plus the API versions it serves.
+type ServerVersionInfo struct {
+	BuildInfo
+	APIVersions []string `json:"api_versions"`
+
+	// The server's identity, if configured: the environment it serves, and the clusters it manages.  Clients check
+	// these before destructive operations, to be sure they're talking to the server they meant to.
+	Environment string   `json:"environment,omitempty"`
+	Clusters    []string `json:"clusters,omitempty"`
+}
@@ -0,0 +1,85 @@
+package k8sctl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+)
+
+// defaultMinControlPlaneZones is how many availability zones a reconcile expects the control plane to span, when the
+// request doesn't say.  Three is the fewest that lets a three node etcd keep quorum when a zone is lost.
+const defaultMinControlPlaneZones = 3
+
+// unknownZone is what a node whose instance's zone couldn't be found is counted under.
+const unknownZone = "unknown"
+
+// ZoneSpread is how a reconcile found the control plane spread across availability zones.
+type ZoneSpread struct {
+	Zones    map[string]int `json:"zones"`     // control plane nodes in each zone
+	MinZones int            `json:"min_zones"` // zones the nodes were expected to span: fewer if there are fewer nodes
+	Balanced bool           `json:"balanced"`
+}
+
+// Summary describes the spread on one line, e.g. "2 zones, 3 expected: us-east-1a=2, us-east-1b=1".
+func (s ZoneSpread) Summary() (summary string) {
+	zones := make([]string, 0, len(s.Zones))
+	for zone := range s.Zones {
+		zones = append(zones, zone)
+	}
+
+	sort.Strings(zones)
+
+	counts := make([]string, 0, len(zones))
+	for _, zone := range zones {
+		counts = append(counts, fmt.Sprintf("%s=%d", zone, s.Zones[zone]))
+	}
+
+	summary = fmt.Sprintf("%d zone(s), %d expected: %s", len(s.Zones), s.MinZones, strings.Join(counts, ", "))
+	return summary
+}
+
+// controlPlaneSpread counts the control plane nodes in each availability zone, from their instances, which also give
+// their roles, and checks they span at least minZones of them.  A control plane smaller than minZones can't span that
+// many, so it's only expected to have each node in its own zone.  Nodes whose zone isn't known are counted under
+// "unknown", which doesn't count as a zone spanned.  If there are no control plane nodes, as when a reconcile selects
+// only workers, there's no spread.
+func controlPlaneSpread(nodes []manager.NodeInfo, instances map[string]ec2types.Instance, minZones int) (spread *ZoneSpread) {
+	zones := make(map[string]int)
+	for _, node := range nodes {
+		if nodeRole(node, instances) != manager.NodeRoleCp {
+			continue
+		}
+
+		zone := instanceZone(instances[node.ID])
+		if zone == "" {
+			zone = unknownZone
+		}
+		zones[zone]++
+	}
+
+	if len(zones) == 0 {
+		return spread
+	}
+
+	total := 0
+	for _, count := range zones {
+		total += count
+	}
+
+	spread = &ZoneSpread{
+		Zones:    zones,
+		MinZones: min(minZones, total),
+	}
+
+	spanned := len(zones)
+	if zones[unknownZone] > 0 {
+		spanned--
+	}
+
+	spread.Balanced = spanned >= spread.MinZones
+
+	return spread
+}
@@ -0,0 +1,56 @@
+package k8sctl
+
+import (
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func zoneInstance(zone string) (instance ec2types.Instance) {
+	instance = ec2types.Instance{Placement: &ec2types.Placement{AvailabilityZone: awssdk.String(zone)}}
+	return instance
+}
+
+func TestControlPlaneSpread(t *testing.T) {
+	nodes := []manager.NodeInfo{
+		{Name: "cluster1-cp-1", ID: "i-1"},
+		{Name: "cluster1-cp-2", ID: "i-2"},
+		{Name: "cluster1-cp-3", ID: "i-3"},
+		{Name: "cluster1-worker-1", ID: "i-4"},
+	}
+
+	instances := map[string]ec2types.Instance{
+		"i-1": zoneInstance("us-east-1a"),
+		"i-2": zoneInstance("us-east-1a"),
+		"i-3": zoneInstance("us-east-1b"),
+		"i-4": zoneInstance("us-east-1c"),
+	}
+
+	spread := controlPlaneSpread(nodes, instances, 3)
+	require.NotNil(t, spread)
+	assert.Equal(t, map[string]int{"us-east-1a": 2, "us-east-1b": 1}, spread.Zones)
+	assert.False(t, spread.Balanced)
+	assert.Equal(t, "2 zone(s), 3 expected: us-east-1a=2, us-east-1b=1", spread.Summary())
+
+	assert.True(t, controlPlaneSpread(nodes, instances, 2).Balanced)
+
+	instances["i-2"] = zoneInstance("us-east-1c")
+	assert.True(t, controlPlaneSpread(nodes, instances, 3).Balanced)
+
+	// A node whose zone isn't known doesn't count as spanning a zone
+	delete(instances, "i-3")
+	spread = controlPlaneSpread(nodes, instances, 3)
+	assert.Equal(t, 1, spread.Zones[unknownZone])
+	assert.False(t, spread.Balanced)
+
+	// A single control plane node can only be in one zone
+	spread = controlPlaneSpread(nodes[:1], instances, 3)
+	assert.Equal(t, 1, spread.MinZones)
+	assert.True(t, spread.Balanced)
+
+	assert.Nil(t, controlPlaneSpread(nodes[3:], instances, 3))
+}
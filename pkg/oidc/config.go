@@ -5,31 +5,89 @@ import (
 	"strings"
 )
 
+// DefaultAllowedAlgorithm is the only token signing algorithm accepted when none are configured.
+const DefaultAllowedAlgorithm = "RS256"
+
+// DefaultGroupsClaim is the token claim the caller's groups are read from when none is configured.
+const DefaultGroupsClaim = "groups"
+
 // Config holds OIDC configuration.
 type Config struct {
-	IssuerURL     string
-	Audience      string
+	IssuerURL string
+	Audience  string
+	// AllowedGroups are the groups whose members may use the server.  An entry ending in * matches by prefix, e.g.
+	// engineering/platform/* matches engineering/platform/sre, as do entries in CredentialGroups.
 	AllowedGroups []string
+	// CredentialGroups are the groups whose members may call endpoints that return cluster credentials, e.g. a
+	// kubeconfig.  With none, nobody may.
+	CredentialGroups []string
+	// AdminGroups are the groups whose members may call the admin endpoints, e.g. to turn maintenance mode on.  With
+	// none, nobody may.
+	AdminGroups []string
+	// AllowedAlgorithms are the JWT signing algorithms accepted, e.g. RS256.  Tokens signed with any other are rejected.
+	AllowedAlgorithms []string
+	// AllowedEmailDomains, if any, are the domains a caller's email must be in, e.g. example.com, on top of being in an
+	// allowed group.
+	AllowedEmailDomains []string
+	// GroupsClaim is the token claim the caller's groups are read from, e.g. roles.  A claim of that name is used if
+	// there is one, e.g. https://myorg.example.com/groups; otherwise, a dotted name is a path to a nested claim, e.g.
+	// realm_access.roles.
+	GroupsClaim string
+	// Introspection is whether tokens are checked with the issuer's introspection endpoint: IntrospectionOff (the
+	// default), IntrospectionFallback for tokens that can't be verified locally, or IntrospectionAlways.
+	// IntrospectionURL defaults to Dex's, /token/introspect under the issuer.  The client credentials, if any, are sent
+	// with basic auth.
+	Introspection             string
+	IntrospectionURL          string
+	IntrospectionClientID     string
+	IntrospectionClientSecret string
+	// CACertFile is a PEM bundle of extra CAs to trust when fetching the issuer's JWKS, for an issuer with an internal CA.
+	CACertFile string
 }
 
 // LoadConfigFromEnv loads OIDC configuration from environment variables.
 func LoadConfigFromEnv() (config *Config) {
 	config = &Config{
-		IssuerURL: os.Getenv("OIDC_ISSUER_URL"),
-		Audience:  os.Getenv("OIDC_AUDIENCE"),
+		IssuerURL:  os.Getenv("OIDC_ISSUER_URL"),
+		Audience:   os.Getenv("OIDC_AUDIENCE"),
+		CACertFile: os.Getenv("K8SCTL_CA_CERT"),
 	}
 
 	// Parse allowed groups from comma-separated list
-	allowedGroupsStr := os.Getenv("OIDC_ALLOWED_GROUPS")
-	if allowedGroupsStr != "" {
-		groups := strings.Split(allowedGroupsStr, ",")
-		for _, group := range groups {
-			trimmed := strings.TrimSpace(group)
-			if trimmed != "" {
-				config.AllowedGroups = append(config.AllowedGroups, trimmed)
-			}
-		}
+	config.AllowedGroups = splitList(os.Getenv("OIDC_ALLOWED_GROUPS"))
+	config.CredentialGroups = splitList(os.Getenv("OIDC_CREDENTIAL_GROUPS"))
+	config.AdminGroups = splitList(os.Getenv("OIDC_ADMIN_GROUPS"))
+	config.AllowedEmailDomains = splitList(os.Getenv("OIDC_ALLOWED_EMAIL_DOMAINS"))
+
+	config.GroupsClaim = os.Getenv("OIDC_GROUPS_CLAIM")
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = DefaultGroupsClaim
+	}
+
+	config.Introspection = os.Getenv("OIDC_INTROSPECTION")
+	if config.Introspection == "" {
+		config.Introspection = IntrospectionOff
+	}
+	config.IntrospectionURL = os.Getenv("OIDC_INTROSPECTION_URL")
+	config.IntrospectionClientID = os.Getenv("OIDC_INTROSPECTION_CLIENT_ID")
+	config.IntrospectionClientSecret = os.Getenv("OIDC_INTROSPECTION_CLIENT_SECRET")
+
+	config.AllowedAlgorithms = splitList(os.Getenv("OIDC_ALLOWED_ALGORITHMS"))
+	if len(config.AllowedAlgorithms) == 0 {
+		config.AllowedAlgorithms = []string{DefaultAllowedAlgorithm}
 	}
 
 	return config
 }
+
+// splitList splits a comma-separated list, trimming whitespace and dropping empty items.
+func splitList(list string) (items []string) {
+	for _, item := range strings.Split(list, ",") {
+		trimmed := strings.TrimSpace(item)
+		if trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,118 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// How tokens are checked with the issuer's introspection endpoint (RFC 7662).
+const (
+	IntrospectionOff      = "off"      // tokens are only verified locally, against the issuer's JWKS
+	IntrospectionFallback = "fallback" // tokens that can't be verified locally are introspected, e.g. opaque tokens
+	IntrospectionAlways   = "always"   // every token is introspected, rather than verified locally
+)
+
+// maxIntrospectionResponseSize bounds the introspection response read.
+const maxIntrospectionResponseSize = 1 << 20
+
+// introspectionURL is the configured introspection endpoint, or Dex's, under the issuer.
+func (v *Validator) introspectionURL() (endpoint string) {
+	endpoint = v.config.IntrospectionURL
+	if endpoint == "" {
+		endpoint = strings.TrimSuffix(v.config.IssuerURL, "/") + "/token/introspect"
+	}
+
+	return endpoint
+}
+
+// introspectToken checks a token with the issuer's introspection endpoint, and returns its claims if the issuer says
+// it's active.  The claims are verified as a local token's are: audience, groups, and email domain, and issuer and
+// expiration where the response has them, as they're optional in an introspection response.
+func (v *Validator) introspectToken(tokenString string) (claims jwt.MapClaims, err error) {
+	form := url.Values{"token": {tokenString}}
+
+	var req *http.Request
+	req, err = http.NewRequestWithContext(context.Background(), http.MethodPost, v.introspectionURL(), strings.NewReader(form.Encode()))
+	if err != nil {
+		err = fmt.Errorf("failed to create introspection request: %w", err)
+		return claims, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if v.config.IntrospectionClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(v.config.IntrospectionClientID), url.QueryEscape(v.config.IntrospectionClientSecret))
+	}
+
+	var resp *http.Response
+	resp, err = v.httpClient.Do(req)
+	if err != nil {
+		err = fmt.Errorf("failed to introspect token: %w", err)
+		return claims, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIntrospectionResponseSize))
+	if err != nil {
+		err = fmt.Errorf("failed reading introspection response: %w", err)
+		return claims, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("token introspection failed with status %d: %s", resp.StatusCode, body)
+		return claims, err
+	}
+
+	var mapClaims jwt.MapClaims
+	err = json.Unmarshal(body, &mapClaims)
+	if err != nil {
+		err = fmt.Errorf("failed to decode introspection response: %w", err)
+		return claims, err
+	}
+
+	if active, _ := mapClaims["active"].(bool); !active {
+		err = errors.New("token is not active")
+		return claims, err
+	}
+
+	if _, ok := mapClaims["iss"]; ok {
+		err = v.verifyIssuer(mapClaims)
+		if err != nil {
+			return claims, err
+		}
+	}
+
+	err = v.verifyAudience(mapClaims)
+	if err != nil {
+		return claims, err
+	}
+
+	if _, ok := mapClaims["exp"]; ok {
+		err = v.verifyExpiration(mapClaims)
+		if err != nil {
+			return claims, err
+		}
+	}
+
+	err = v.verifyGroupMembership(mapClaims)
+	if err != nil {
+		return claims, err
+	}
+
+	err = v.verifyEmailDomain(mapClaims)
+	if err != nil {
+		return claims, err
+	}
+
+	claims = mapClaims
+	return claims, err
+}
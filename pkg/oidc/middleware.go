@@ -2,6 +2,7 @@ package oidc
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net/http"
@@ -11,18 +12,31 @@ import (
 	"github.com/MicahParks/jwkset"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/nikogura/k8sctl/pkg/tlsconfig"
 	"go.uber.org/zap"
 )
 
+// ErrEmailDomainNotAllowed is the error of a token whose email isn't in one of the allowed email domains.
+var ErrEmailDomainNotAllowed = errors.New("email domain not allowed")
+
 // Validator validates OIDC tokens.
 type Validator struct {
-	config *Config
-	logger *zap.Logger
-	jwks   jwkset.Storage
+	config     *Config
+	logger     *zap.Logger
+	jwks       jwkset.Storage
+	httpClient *http.Client // for token introspection
 }
 
-// NewValidator creates a new OIDC validator.
-func NewValidator(config *Config, logger *zap.Logger) (validator *Validator) {
+// NewValidator creates a new OIDC validator.  It fails if the configured CA bundle can't be loaded, or the
+// introspection mode isn't one of IntrospectionOff, IntrospectionFallback, or IntrospectionAlways.
+func NewValidator(config *Config, logger *zap.Logger) (validator *Validator, err error) {
+	switch config.Introspection {
+	case "", IntrospectionOff, IntrospectionFallback, IntrospectionAlways:
+	default:
+		err = fmt.Errorf("invalid introspection mode %q: use %s, %s, or %s", config.Introspection, IntrospectionOff, IntrospectionFallback, IntrospectionAlways)
+		return validator, err
+	}
+
 	// Create JWKS client to fetch public keys from OIDC provider
 	jwksURL := fmt.Sprintf("%s/.well-known/jwks.json", strings.TrimSuffix(config.IssuerURL, "/"))
 
@@ -31,6 +45,16 @@ func NewValidator(config *Config, logger *zap.Logger) (validator *Validator) {
 		Timeout: 10 * time.Second,
 	}
 
+	// Trust the extra CAs, if any
+	if config.CACertFile != "" {
+		var pool *x509.CertPool
+		pool, err = tlsconfig.LoadCertPool(config.CACertFile)
+		if err != nil {
+			return validator, err
+		}
+		httpClient.Transport = tlsconfig.NewTransport(pool, false)
+	}
+
 	// Create JWKS storage with auto-refresh
 	options := jwkset.HTTPClientStorageOptions{
 		Client:          httpClient,
@@ -40,27 +64,51 @@ func NewValidator(config *Config, logger *zap.Logger) (validator *Validator) {
 		},
 	}
 
-	storage, err := jwkset.NewStorageFromHTTP(jwksURL, options)
-	if err != nil {
-		logger.Error("failed to create JWKS storage", zap.Error(err), zap.String("url", jwksURL))
+	storage, storageErr := jwkset.NewStorageFromHTTP(jwksURL, options)
+	if storageErr != nil {
+		logger.Error("failed to create JWKS storage", zap.Error(storageErr), zap.String("url", jwksURL))
 		// Fall back to memory storage
 		storage = jwkset.NewMemoryStorage()
 	}
 
 	validator = &Validator{
-		config: config,
-		logger: logger,
-		jwks:   storage,
+		config:     config,
+		logger:     logger,
+		jwks:       storage,
+		httpClient: httpClient,
 	}
 
-	return validator
+	return validator, err
 }
 
 // ValidateToken validates an OIDC token.
 func (v *Validator) ValidateToken(tokenString string) (claims jwt.MapClaims, err error) {
-	// Parse token
+	// Parse token, accepting only the allowed signing algorithms
+	allowedAlgorithms := v.config.AllowedAlgorithms
+	if len(allowedAlgorithms) == 0 {
+		allowedAlgorithms = []string{DefaultAllowedAlgorithm}
+	}
+
+	if v.config.Introspection == IntrospectionAlways {
+		claims, err = v.introspectToken(tokenString)
+		return claims, err
+	}
+
 	var token *jwt.Token
-	token, err = jwt.Parse(tokenString, v.getKeyFunc)
+	token, err = jwt.Parse(tokenString, v.getKeyFunc, jwt.WithValidMethods(allowedAlgorithms))
+
+	// A token that can't be verified locally, as it's opaque, or the key it's signed with can't be fetched, may still
+	// be one the issuer accepts
+	if err != nil && v.config.Introspection == IntrospectionFallback {
+		parseErr := err
+		v.logger.Debug("token not verified locally, introspecting it", zap.Error(parseErr))
+
+		claims, err = v.introspectToken(tokenString)
+		if err != nil {
+			err = fmt.Errorf("failed to parse token: %v; and introspecting it: %w", parseErr, err)
+		}
+		return claims, err
+	}
 
 	if err != nil {
 		err = fmt.Errorf("failed to parse token: %w", err)
@@ -100,6 +148,12 @@ func (v *Validator) ValidateToken(tokenString string) (claims jwt.MapClaims, err
 		return claims, err
 	}
 
+	// Verify email domain
+	err = v.verifyEmailDomain(mapClaims)
+	if err != nil {
+		return claims, err
+	}
+
 	claims = mapClaims
 	return claims, err
 }
@@ -180,38 +234,91 @@ func (v *Validator) verifyGroupMembership(mapClaims jwt.MapClaims) (err error) {
 	}
 
 	var userGroups []string
-	userGroups, err = v.extractUserGroups(mapClaims)
+	userGroups, err = v.UserGroups(mapClaims)
 	if err != nil {
 		return err
 	}
 
-	hasValidGroup := false
+	if !inAnyGroup(userGroups, v.config.AllowedGroups) {
+		err = fmt.Errorf("user not in allowed groups. User groups: %v, allowed: %v",
+			userGroups, v.config.AllowedGroups)
+		return err
+	}
+
+	return err
+}
+
+// verifyEmailDomain verifies the user's email is in an allowed domain, if any are configured.  Its error wraps
+// ErrEmailDomainNotAllowed, for Middleware to refuse the request with a 403 rather than a 401.
+func (v *Validator) verifyEmailDomain(mapClaims jwt.MapClaims) (err error) {
+	if len(v.config.AllowedEmailDomains) == 0 {
+		return err
+	}
+
+	email, _ := mapClaims["email"].(string)
+
+	_, domain, found := strings.Cut(email, "@")
+	if !found || domain == "" {
+		err = fmt.Errorf("%w: token has no email domain", ErrEmailDomainNotAllowed)
+		return err
+	}
+
+	for _, allowedDomain := range v.config.AllowedEmailDomains {
+		if strings.EqualFold(domain, allowedDomain) {
+			return err
+		}
+	}
+
+	err = fmt.Errorf("%w: %s", ErrEmailDomainNotAllowed, domain)
+	return err
+}
+
+// inAnyGroup returns true if any of the user's groups matches one of the allowed groups.
+func inAnyGroup(userGroups []string, allowedGroups []string) (member bool) {
 	for _, userGroup := range userGroups {
-		for _, allowedGroup := range v.config.AllowedGroups {
-			if userGroup == allowedGroup {
-				hasValidGroup = true
-				break
+		for _, allowedGroup := range allowedGroups {
+			if groupMatches(userGroup, allowedGroup) {
+				member = true
+				return member
 			}
 		}
-		if hasValidGroup {
-			break
-		}
 	}
 
-	if !hasValidGroup {
-		err = fmt.Errorf("user not in allowed groups. User groups: %v, allowed: %v",
-			userGroups, v.config.AllowedGroups)
-		return err
+	return member
+}
+
+// groupMatches returns true if the user's group matches an allowed group.  An allowed group ending in * matches every
+// group starting with the rest of it, e.g. engineering/platform/* matches engineering/platform/sre; any other must
+// match exactly.
+func groupMatches(userGroup string, allowedGroup string) (match bool) {
+	prefix, wildcard := strings.CutSuffix(allowedGroup, "*")
+	if wildcard {
+		match = strings.HasPrefix(userGroup, prefix)
+		return match
 	}
 
-	return err
+	match = userGroup == allowedGroup
+	return match
+}
+
+// UserGroups returns the caller's groups, from the configured groups claim of their token.
+func (v *Validator) UserGroups(mapClaims jwt.MapClaims) (userGroups []string, err error) {
+	claimName := v.config.GroupsClaim
+	if claimName == "" {
+		claimName = DefaultGroupsClaim
+	}
+
+	userGroups, err = extractUserGroups(mapClaims, claimName)
+	return userGroups, err
 }
 
-// extractUserGroups extracts user groups from token claims.
-func (v *Validator) extractUserGroups(mapClaims jwt.MapClaims) (userGroups []string, err error) {
-	groupsInterface, groupsOK := mapClaims["groups"]
+// extractUserGroups extracts user groups from the named token claim.  A claim with the whole name is used if there is
+// one, as namespaced claim names like https://myorg.example.com/groups have dots in them.  Otherwise a dotted name is
+// followed through nested claims, e.g. realm_access.roles.
+func extractUserGroups(mapClaims jwt.MapClaims, claimName string) (userGroups []string, err error) {
+	groupsInterface, groupsOK := lookupClaim(mapClaims, claimName)
 	if !groupsOK {
-		err = errors.New("token missing groups claim")
+		err = fmt.Errorf("token missing %s claim", claimName)
 		return userGroups, err
 	}
 
@@ -226,13 +333,39 @@ func (v *Validator) extractUserGroups(mapClaims jwt.MapClaims) (userGroups []str
 	case []string:
 		userGroups = groups
 	default:
-		err = errors.New("invalid groups claim type")
+		err = fmt.Errorf("invalid %s claim type", claimName)
 		return userGroups, err
 	}
 
 	return userGroups, err
 }
 
+// lookupClaim returns the claim with the name, or failing that, the nested claim at the name's dotted path.
+func lookupClaim(mapClaims jwt.MapClaims, claimName string) (value interface{}, ok bool) {
+	value, ok = mapClaims[claimName]
+	if ok {
+		return value, ok
+	}
+
+	var current interface{} = map[string]interface{}(mapClaims)
+
+	for _, key := range strings.Split(claimName, ".") {
+		object, isObject := current.(map[string]interface{})
+		if !isObject {
+			ok = false
+			return value, ok
+		}
+
+		current, ok = object[key]
+		if !ok {
+			return value, ok
+		}
+	}
+
+	value = current
+	return value, ok
+}
+
 // getKeyFunc returns a JWT key function for token verification.
 func (v *Validator) getKeyFunc(token *jwt.Token) (key interface{}, err error) {
 	// Verify signing method
@@ -293,6 +426,16 @@ func Middleware(validator *Validator) (handler gin.HandlerFunc) {
 
 		// Validate token
 		claims, err := validator.ValidateToken(tokenString)
+		if errors.Is(err, ErrEmailDomainNotAllowed) {
+			validator.logger.Warn("token email domain not allowed",
+				zap.Error(err),
+				zap.String("remote_addr", ctx.ClientIP()),
+			)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
 		if err != nil {
 			validator.logger.Warn("token validation failed",
 				zap.Error(err),
@@ -314,6 +457,9 @@ func Middleware(validator *Validator) (handler gin.HandlerFunc) {
 		if sub, ok := claims["sub"].(string); ok {
 			ctx.Set("user_id", sub)
 		}
+		if userGroups, groupsErr := validator.UserGroups(claims); groupsErr == nil {
+			ctx.Set("user_groups", userGroups)
+		}
 
 		validator.logger.Debug("request authenticated",
 			zap.String("user_email", ctx.GetString("user_email")),
@@ -326,3 +472,28 @@ func Middleware(validator *Validator) (handler gin.HandlerFunc) {
 
 	return handler
 }
+
+// RequireGroups returns a Gin middleware that only lets through callers whose token, already validated by Middleware,
+// has one of the groups.  It guards endpoints that need more than the allowed groups, such as those returning
+// credentials.  With no groups, every request is refused.
+func RequireGroups(groups []string) (handler gin.HandlerFunc) {
+	handler = func(ctx *gin.Context) {
+		if !inAnyGroup(ctx.GetStringSlice("user_groups"), groups) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": fmt.Sprintf("this endpoint is restricted to groups %v", groups),
+			})
+			return
+		}
+
+		ctx.Next()
+	}
+
+	return handler
+}
+
+// InGroup returns true if the caller's token, already validated by Middleware, has the group.  It's for handlers whose
+// required group depends on the request, which RequireGroups can't express.
+func InGroup(ctx *gin.Context, group string) (member bool) {
+	member = inAnyGroup(ctx.GetStringSlice("user_groups"), []string{group})
+	return member
+}
@@ -0,0 +1,47 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// LoadCertPool returns the system's trusted CAs plus those in the given PEM bundle, for talking to services with
+// certificates from an internal CA.
+func LoadCertPool(caCertFile string) (pool *x509.CertPool, err error) {
+	pemBytes, err := os.ReadFile(caCertFile)
+	if err != nil {
+		err = fmt.Errorf("failed to read CA bundle %s: %w", caCertFile, err)
+		return pool, err
+	}
+
+	pool, err = x509.SystemCertPool()
+	if err != nil {
+		// No system pool on this platform: trust just the bundle
+		pool = x509.NewCertPool()
+		err = nil
+	}
+
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		pool = nil
+		err = fmt.Errorf("no certificates found in CA bundle %s", caCertFile)
+		return pool, err
+	}
+
+	return pool, err
+}
+
+// NewTransport returns an HTTP transport trusting the given CAs, or the system's if pool is nil.
+// With insecureSkipVerify, certificates aren't verified at all.
+func NewTransport(pool *x509.CertPool, insecureSkipVerify bool) (transport *http.Transport) {
+	transport = http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		RootCAs:            pool,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+
+	return transport
+}
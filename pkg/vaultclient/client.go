@@ -0,0 +1,134 @@
+package vaultclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"go.uber.org/zap"
+)
+
+// reloginRetryInterval is how long to wait before trying again when logging back in to Vault fails.
+const reloginRetryInterval = 30 * time.Second
+
+// NewClient creates a Vault client from the config, gets a token with its auth method, and checks the token works, so
+// a misconfigured Vault fails at startup rather than on the first request that needs it.  A token from logging in is
+// replaced by logging in again before it expires, until ctx is done.
+func NewClient(ctx context.Context, config *Config, logger *zap.Logger) (client *api.Client, err error) {
+	err = config.Validate()
+	if err != nil {
+		return client, err
+	}
+
+	apiConfig := api.DefaultConfig()
+	if apiConfig.Error != nil {
+		err = fmt.Errorf("failed reading Vault environment: %w", apiConfig.Error)
+		return client, err
+	}
+
+	apiConfig.Address = config.Address
+
+	client, err = api.NewClient(apiConfig)
+	if err != nil {
+		err = fmt.Errorf("failed creating Vault client: %w", err)
+		return client, err
+	}
+
+	if config.Namespace != "" {
+		client.SetNamespace(config.Namespace)
+	}
+
+	auth, err := login(ctx, client, config)
+	if err != nil {
+		return client, err
+	}
+
+	_, err = client.Auth().Token().LookupSelfWithContext(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed checking Vault token at %s: %w", config.Address, err)
+		return client, err
+	}
+
+	if auth != nil {
+		go keepLoggedIn(ctx, client, config, auth, logger)
+	}
+
+	return client, err
+}
+
+// login sets the client's token with the config's auth method.  For the token method, that's the configured token,
+// and there's no login response.
+func login(ctx context.Context, client *api.Client, config *Config) (auth *api.SecretAuth, err error) {
+	var data map[string]interface{}
+
+	switch config.AuthMethod {
+	case AuthMethodToken:
+		client.SetToken(config.Token)
+		return auth, err
+	case AuthMethodAppRole:
+		data = map[string]interface{}{
+			"role_id":   config.RoleID,
+			"secret_id": config.SecretID,
+		}
+	case AuthMethodKubernetes:
+		jwt, readErr := os.ReadFile(config.KubernetesTokenPath)
+		if readErr != nil {
+			err = fmt.Errorf("failed reading service account token %s: %w", config.KubernetesTokenPath, readErr)
+			return auth, err
+		}
+
+		data = map[string]interface{}{
+			"role": config.KubernetesRole,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		}
+	}
+
+	path := fmt.Sprintf("auth/%s/login", config.authMount())
+
+	secret, err := client.Logical().WriteWithContext(ctx, path, data)
+	if err != nil {
+		err = fmt.Errorf("failed logging in to Vault with %s auth at %s: %w", config.AuthMethod, path, err)
+		return auth, err
+	}
+
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		err = fmt.Errorf("logging in to Vault with %s auth at %s returned no token", config.AuthMethod, path)
+		return auth, err
+	}
+
+	client.SetToken(secret.Auth.ClientToken)
+
+	auth = secret.Auth
+	return auth, err
+}
+
+// keepLoggedIn logs in again when two thirds of the token's lease has passed, so the client always has a live token.
+// A token without a lease never expires, so it's kept.  Failed logins are retried, and logged: until one works,
+// requests to Vault fail once the old token expires.
+func keepLoggedIn(ctx context.Context, client *api.Client, config *Config, auth *api.SecretAuth, logger *zap.Logger) {
+	for auth.LeaseDuration > 0 {
+		wait := time.Duration(auth.LeaseDuration) * time.Second * 2 / 3
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+
+			newAuth, err := login(ctx, client, config)
+			if err == nil {
+				auth = newAuth
+				break
+			}
+
+			logger.Error("failed logging back in to Vault", zap.Error(err))
+			wait = reloginRetryInterval
+		}
+
+		logger.Debug("logged back in to Vault", zap.Int("lease_seconds", auth.LeaseDuration))
+	}
+}
@@ -0,0 +1,108 @@
+package vaultclient
+
+import (
+	"fmt"
+	"os"
+)
+
+// Vault auth methods.  Token uses a token given to the server.  AppRole and Kubernetes log in for one, with a role ID
+// and secret ID, or with the pod's service account token.
+const (
+	AuthMethodToken      = "token"
+	AuthMethodAppRole    = "approle"
+	AuthMethodKubernetes = "kubernetes"
+)
+
+// DefaultKVMount is the KV v2 mount cluster secrets are read from when none is configured.
+const DefaultKVMount = "secret"
+
+// DefaultKubernetesTokenPath is where a pod's service account token is mounted.
+const DefaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Config holds Vault configuration.
+type Config struct {
+	Address string
+	// Namespace is the Vault Enterprise namespace to work in, if any.
+	Namespace string
+	// AuthMethod is how the server gets its Vault token: token (the default), approle, or kubernetes.
+	AuthMethod string
+	// AuthMount is where the auth method is mounted, if not at its default path, e.g. approle.
+	AuthMount string
+	Token     string
+	RoleID    string
+	SecretID  string
+	// KubernetesRole is the Vault role to log in as with the kubernetes auth method, using the service account token at
+	// KubernetesTokenPath.
+	KubernetesRole      string
+	KubernetesTokenPath string
+	// KVMount is the KV v2 mount holding the cluster secrets.
+	KVMount string
+}
+
+// LoadConfigFromEnv loads Vault configuration from environment variables.  Without VAULT_ADDR, Vault isn't
+// configured, and the returned config's Address is empty.
+func LoadConfigFromEnv() (config *Config) {
+	config = &Config{
+		Address:             os.Getenv("VAULT_ADDR"),
+		Namespace:           os.Getenv("VAULT_NAMESPACE"),
+		AuthMethod:          os.Getenv("K8SCTL_VAULT_AUTH_METHOD"),
+		AuthMount:           os.Getenv("K8SCTL_VAULT_AUTH_MOUNT"),
+		Token:               os.Getenv("VAULT_TOKEN"),
+		RoleID:              os.Getenv("K8SCTL_VAULT_ROLE_ID"),
+		SecretID:            os.Getenv("K8SCTL_VAULT_SECRET_ID"),
+		KubernetesRole:      os.Getenv("K8SCTL_VAULT_K8S_ROLE"),
+		KubernetesTokenPath: os.Getenv("K8SCTL_VAULT_K8S_TOKEN_PATH"),
+		KVMount:             os.Getenv("K8SCTL_VAULT_MOUNT"),
+	}
+
+	if config.AuthMethod == "" {
+		config.AuthMethod = AuthMethodToken
+	}
+
+	if config.KubernetesTokenPath == "" {
+		config.KubernetesTokenPath = DefaultKubernetesTokenPath
+	}
+
+	if config.KVMount == "" {
+		config.KVMount = DefaultKVMount
+	}
+
+	return config
+}
+
+// Validate checks the config has what its auth method needs.
+func (c *Config) Validate() (err error) {
+	if c.Address == "" {
+		err = fmt.Errorf("no Vault address")
+		return err
+	}
+
+	switch c.AuthMethod {
+	case AuthMethodToken:
+		if c.Token == "" {
+			err = fmt.Errorf("the token auth method needs VAULT_TOKEN")
+		}
+	case AuthMethodAppRole:
+		if c.RoleID == "" || c.SecretID == "" {
+			err = fmt.Errorf("the approle auth method needs K8SCTL_VAULT_ROLE_ID and K8SCTL_VAULT_SECRET_ID")
+		}
+	case AuthMethodKubernetes:
+		if c.KubernetesRole == "" {
+			err = fmt.Errorf("the kubernetes auth method needs K8SCTL_VAULT_K8S_ROLE")
+		}
+	default:
+		err = fmt.Errorf("unknown Vault auth method %q: use %s, %s, or %s", c.AuthMethod, AuthMethodToken, AuthMethodAppRole, AuthMethodKubernetes)
+	}
+
+	return err
+}
+
+// authMount returns the path the auth method is mounted at.
+func (c *Config) authMount() (mount string) {
+	mount = c.AuthMount
+	if mount == "" {
+		mount = c.AuthMethod
+	}
+
+	return mount
+}
@@ -0,0 +1,66 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestAccessLog checks each request is logged with its status and user, and gets a request ID, the client's if it
+// sent one.
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(k8sctl.AccessLog(zap.New(core)))
+	router.POST("/v1/auth-check", func(ctx *gin.Context) {
+		ctx.Set("user_email", "test-user@example.com")
+		ctx.Status(http.StatusTeapot)
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	for _, requestID := range []string{"client-request-id", ""} {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL+"/v1/auth-check", nil)
+		require.NoError(t, err)
+
+		if requestID != "" {
+			req.Header.Set(k8sctl.RequestIDHeader, requestID)
+		}
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		returnedID := resp.Header.Get(k8sctl.RequestIDHeader)
+		require.NotEmpty(t, returnedID)
+		if requestID != "" {
+			assert.Equal(t, requestID, returnedID)
+		}
+
+		entries := logs.TakeAll()
+		require.Len(t, entries, 1)
+
+		fields := entries[0].ContextMap()
+		assert.Equal(t, http.MethodPost, fields["method"])
+		assert.Equal(t, "/v1/auth-check", fields["path"])
+		assert.Equal(t, int64(http.StatusTeapot), fields["status"])
+		assert.Equal(t, returnedID, fields["request_id"])
+		assert.Equal(t, "test-user@example.com", fields["user_email"])
+		assert.Contains(t, fields, "latency")
+		assert.Contains(t, fields, "client_ip")
+	}
+}
@@ -0,0 +1,65 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCapabilitiesHandler checks every route is listed, with the groups it needs and whether it's destructive.
+func TestCapabilitiesHandler(t *testing.T) {
+	commands, err := k8sctl.LoadK8sCtlCommandsFromBytes([]byte(`{"commands": [
+		{"name": "hello", "command": "echo", "description": "Say hello"},
+		{"name": "admins", "command": "touch", "role": "admins"}
+	]}`))
+	require.NoError(t, err)
+
+	k8sctl.SetAccessGroups([]string{"engineering"}, []string{"sre"})
+	t.Cleanup(func() { k8sctl.SetAccessGroups(nil, nil) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/v1/capabilities", commands.CapabilitiesHandler)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/capabilities", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var result k8sctl.CapabilitiesResult
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"aws"}, result.Providers)
+	assert.Equal(t, []string{"engineering"}, result.AllowedGroups)
+	assert.Len(t, result.Operations, len(commands.APIRoutes()))
+
+	ops := make(map[string]k8sctl.Capability)
+	for _, op := range result.Operations {
+		ops[op.Method+" "+op.Path] = op
+	}
+
+	require.Contains(t, ops, "POST /v1/cluster/{cluster}/node/delete/{name}")
+	assert.True(t, ops["POST /v1/cluster/{cluster}/node/delete/{name}"].Destructive)
+	assert.True(t, ops["POST /v1/cluster/{cluster}/upgrade"].Destructive)
+	assert.False(t, ops["POST /v1/cluster/describe/{cluster}"].Destructive)
+	assert.False(t, ops["POST /v1/cluster/describe/{cluster}"].Restricted)
+
+	kubeconfig := ops["POST /v1/cluster/{cluster}/kubeconfig"]
+	assert.True(t, kubeconfig.Restricted)
+	assert.Equal(t, []string{"sre"}, kubeconfig.Groups)
+
+	assert.True(t, ops["POST /v1/cluster/{cluster}/run/{command}"].RoleGated)
+	assert.True(t, ops["POST /v1/cluster/{cluster}/node/create"].Idempotent)
+	assert.Contains(t, ops, "GET /v1/capabilities")
+
+	assert.Equal(t, []k8sctl.CommandCapability{
+		{Name: "hello", Description: "Say hello"},
+		{Name: "admins", Group: "admins"},
+	}, result.Commands)
+}
@@ -0,0 +1,201 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nikogura/k8sctl/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const unversionedConfig = `# k8sctl config
+
+# Default environment
+default_environment: dev
+
+clusters:
+  cluster1:
+    environment: dev # the dev environment
+    some_future_setting: true
+`
+
+// TestLoadConfigVersion checks an unversioned config loads as version 0, a newer one still loads, and an invalid one
+// doesn't.
+func TestLoadConfigVersion(t *testing.T) {
+	dir := t.TempDir()
+
+	testCases := []struct {
+		name    string
+		content string
+		version int
+		valid   bool
+	}{
+		{name: "unversioned", content: unversionedConfig, version: 0, valid: true},
+		{name: "current", content: "version: 1\nclusters: {}\n", version: config.CurrentVersion, valid: true},
+		{name: "newer", content: "version: 99\nclusters: {}\n", version: 99, valid: true},
+		{name: "negative", content: "version: -1\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name+".yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0600))
+
+			cfg, err := config.Load(path)
+			if !tc.valid {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.version, cfg.Version)
+		})
+	}
+}
+
+// TestMigrateConfig checks a migration sets the version, keeps comments and unknown settings, backs up the original,
+// and leaves a current config alone.
+func TestMigrateConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "k8sctl.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(unversionedConfig), 0600))
+
+	from, migrated, err := config.Migrate(path)
+	require.NoError(t, err)
+	assert.Equal(t, 0, from)
+	assert.True(t, migrated)
+
+	backup, err := os.ReadFile(path + ".bak")
+	require.NoError(t, err)
+	assert.Equal(t, unversionedConfig, string(backup))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "# Default environment")
+	assert.Contains(t, string(data), "# the dev environment")
+	assert.Contains(t, string(data), "some_future_setting: true")
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, config.CurrentVersion, cfg.Version)
+	assert.Equal(t, "dev", cfg.GetClusterEnvironment("cluster1"))
+
+	from, migrated, err = config.Migrate(path)
+	require.NoError(t, err)
+	assert.Equal(t, config.CurrentVersion, from)
+	assert.False(t, migrated)
+
+	newer := filepath.Join(dir, "newer.yaml")
+	require.NoError(t, os.WriteFile(newer, []byte("version: 99\n"), 0600))
+
+	_, _, err = config.Migrate(newer)
+	require.Error(t, err)
+}
+
+// TestLoadConfigEnv checks ${VAR} and $VAR are expanded in config values, and $$ is a literal $.
+func TestLoadConfigEnv(t *testing.T) {
+	t.Setenv("K8SCTL_TEST_ENV", "staging")
+	t.Setenv("K8SCTL_TEST_DOMAIN", "example.com")
+	t.Setenv("K8SCTL_TEST_ACCOUNT", "123456789012")
+
+	content := `version: 1
+default_environment: $K8SCTL_TEST_ENV
+clusters:
+  cluster1:
+    environment: ${K8SCTL_TEST_ENV}
+    server_url: https://k8sctl-${K8SCTL_TEST_ENV}.${K8SCTL_TEST_DOMAIN}
+    region: us-east-1
+    aws_role_arn: arn:aws:iam::${K8SCTL_TEST_ACCOUNT}:role/k8sctl
+    aws_external_id: pa$$word
+    aws_session_name: ${K8SCTL_TEST_UNSET}
+`
+	path := filepath.Join(t.TempDir(), "k8sctl.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "staging", cfg.DefaultEnvironment)
+	assert.Equal(t, config.ClusterConfig{
+		Environment:    "staging",
+		ServerURL:      "https://k8sctl-staging.example.com",
+		Region:         "us-east-1",
+		AWSRoleARN:     "arn:aws:iam::123456789012:role/k8sctl",
+		AWSExternalID:  "pa$word",
+		AWSSessionName: "",
+	}, cfg.Clusters["cluster1"])
+}
+
+// TestConfigTemplates checks the server URL and audience templates default to the built-in URL, the audience follows
+// the URL unless set apart, and both are filled with the environment.
+func TestConfigTemplates(t *testing.T) {
+	cfg := &config.Config{}
+	assert.Equal(t, "https://k8sctl-dev.example.com", config.FillTemplate(cfg.GetURLTemplate(), "dev"))
+	assert.Equal(t, cfg.GetURLTemplate(), cfg.GetAudienceTemplate())
+
+	t.Setenv("K8SCTL_TEST_DOMAIN", "corp.example.com")
+
+	content := `version: 1
+url_template: https://k8sctl.{environment}.${K8SCTL_TEST_DOMAIN}
+`
+	path := filepath.Join(t.TempDir(), "k8sctl.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://k8sctl.prod.corp.example.com", config.FillTemplate(cfg.GetURLTemplate(), "prod"))
+	assert.Equal(t, "https://k8sctl.prod.corp.example.com", config.FillTemplate(cfg.GetAudienceTemplate(), "prod"))
+
+	cfg.AudienceTemplate = "k8sctl-{environment}"
+	assert.Equal(t, "k8sctl-prod", config.FillTemplate(cfg.GetAudienceTemplate(), "prod"))
+	assert.Equal(t, "https://k8sctl.prod.corp.example.com", config.FillTemplate(cfg.GetURLTemplate(), "prod"))
+}
+
+func TestConfigDefaults(t *testing.T) {
+	t.Setenv("K8SCTL_TEST_OUTPUT", "json")
+
+	content := `version: 1
+defaults:
+  verbose: true
+  output: ${K8SCTL_TEST_OUTPUT}
+`
+	path := filepath.Join(t.TempDir(), "k8sctl.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, config.Defaults{Verbose: true, Output: "json"}, cfg.Defaults)
+}
+
+func TestConfigRequiredTags(t *testing.T) {
+	t.Setenv("K8SCTL_TEST_COST_CENTER", "1234")
+
+	content := `version: 1
+required_tags:
+  team: ""
+  cost-center: ${K8SCTL_TEST_COST_CENTER}
+  environment: dev
+clusters:
+  prod-us:
+    required_tags:
+      environment: prod
+      owner: sre
+  cluster1:
+    environment: dev
+`
+	path := filepath.Join(t.TempDir(), "k8sctl.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"team": "", "cost-center": "1234", "environment": "dev"}, cfg.GetRequiredTags("cluster1"))
+	assert.Equal(t, map[string]string{"team": "", "cost-center": "1234", "environment": "prod", "owner": "sre"}, cfg.GetRequiredTags("prod-us"))
+	assert.Equal(t, cfg.GetRequiredTags("cluster1"), cfg.GetRequiredTags("unconfigured"))
+
+	assert.Nil(t, (&config.Config{}).GetRequiredTags("cluster1"))
+}
@@ -0,0 +1,389 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager/aws"
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeClusterName = "cluster1"
+
+// fakeAWSLatency is how long each fake AWS call takes, roughly the latency of a real one.
+const fakeAWSLatency = 2 * time.Millisecond
+
+// TestDescribeClusterMatchesClusterManager checks the concurrent describe reports the same info as the cluster manager's.
+func TestDescribeClusterMatchesClusterManager(t *testing.T) {
+	cm := newFakeClusterManager(0)
+
+	expected, err := cm.DescribeCluster(fakeClusterName)
+	require.NoError(t, err)
+
+	result, err := k8sctl.DescribeCluster(context.Background(), cm, fakeClusterName, k8sctl.DescribeClusterOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, result.ClusterInfo)
+
+	require.Len(t, result.NodeInstances, len(result.Nodes))
+	for _, instance := range result.NodeInstances {
+		assert.Equal(t, "on-demand", instance.Lifecycle, instance.Name)
+	}
+
+	require.NotEmpty(t, result.UnhealthyTargets)
+	for _, target := range result.UnhealthyTargets {
+		assert.Equal(t, string(elbtypes.TargetHealthReasonEnumFailedHealthChecks), target.Reason)
+	}
+}
+
+// TestDescribeClusterNodeLBs checks each node is reported in every target group it's registered with, with its health.
+func TestDescribeClusterNodeLBs(t *testing.T) {
+	cm := newFakeClusterManager(0)
+
+	result, err := k8sctl.DescribeCluster(context.Background(), cm, fakeClusterName, k8sctl.DescribeClusterOptions{NodeLBs: true})
+	require.NoError(t, err)
+
+	require.Len(t, result.NodeAttachments, 6)
+	for i, attachment := range result.NodeAttachments {
+		assert.Len(t, attachment.TargetGroups, 6, attachment.Node)
+		assert.Empty(t, attachment.MissingTargetGroups, attachment.Node)
+
+		expectedState := string(elbtypes.TargetHealthStateEnumHealthy)
+		if i == len(result.NodeAttachments)-1 {
+			expectedState = string(elbtypes.TargetHealthStateEnumUnhealthy)
+		}
+		for _, tg := range attachment.TargetGroups {
+			assert.Equal(t, expectedState, tg.State, attachment.Node)
+		}
+	}
+
+	result, err = k8sctl.DescribeCluster(context.Background(), cm, fakeClusterName, k8sctl.DescribeClusterOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, result.NodeAttachments)
+}
+
+// TestDescribeClusterNodeLBsIPTargets checks nodes are matched to ip type target groups by private IP, and that target
+// groups whose health can't be fetched are reported rather than silently left out.
+func TestDescribeClusterNodeLBsIPTargets(t *testing.T) {
+	cm := newFakeClusterManager(0)
+	elb := cm.ELBClient.(*fakeELBClient)
+	elb.ipTargetGroups = map[string]bool{"ingress-443": true}
+	// The describe itself lists the targets of each target group once, so fail the target health fetch after that
+	elb.failTargetHealthAfter = map[string]int{"ingress-tls-80": 1}
+	elb.targetHealthCalls = make(map[string]int)
+
+	result, err := k8sctl.DescribeCluster(context.Background(), cm, fakeClusterName, k8sctl.DescribeClusterOptions{NodeLBs: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"ingress-tls/ingress-tls-80"}, result.UncheckedTargetGroups)
+
+	require.Len(t, result.NodeAttachments, 6)
+	for _, attachment := range result.NodeAttachments {
+		tgNames := make([]string, 0, len(attachment.TargetGroups))
+		for _, tg := range attachment.TargetGroups {
+			tgNames = append(tgNames, tg.TargetGroup)
+		}
+
+		assert.Contains(t, tgNames, "ingress-443", attachment.Node)
+		assert.NotContains(t, tgNames, "ingress-tls-80", attachment.Node)
+		assert.Len(t, attachment.TargetGroups, 5, attachment.Node)
+		assert.Empty(t, attachment.MissingTargetGroups, attachment.Node)
+	}
+}
+
+// TestDescribeClusterDualStack checks nodes on dual-stack subnets are reported with their IPv4 and IPv6 addresses, and
+// are matched to ip type target groups registering them by any of their IPv6 addresses.
+func TestDescribeClusterDualStack(t *testing.T) {
+	cm := newFakeClusterManager(0)
+	ec2Client := cm.Ec2Client.(*fakeEC2Client)
+	for i := range ec2Client.instances {
+		ec2Client.instances[i].Ipv6Address = awssdk.String(fmt.Sprintf("2600:1f18:aaaa::%d", i+1))
+		ec2Client.instances[i].NetworkInterfaces = []ec2types.InstanceNetworkInterface{{
+			Ipv6Addresses: []ec2types.InstanceIpv6Address{
+				{Ipv6Address: awssdk.String(fmt.Sprintf("2600:1f18:aaaa::%d", i+1)), IsPrimaryIpv6: awssdk.Bool(true)},
+				{Ipv6Address: awssdk.String(fmt.Sprintf("2600:1f18:bbbb::%d", i+1))},
+			},
+		}}
+	}
+
+	elb := cm.ELBClient.(*fakeELBClient)
+	elb.ipTargetGroups = map[string]bool{"ingress-80": true}
+	elb.ipv6TargetGroups = map[string]bool{"ingress-443": true}
+
+	result, err := k8sctl.DescribeCluster(context.Background(), cm, fakeClusterName, k8sctl.DescribeClusterOptions{NodeLBs: true})
+	require.NoError(t, err)
+
+	require.Len(t, result.NodeInstances, 6)
+	for i, instance := range result.NodeInstances {
+		assert.Equal(t, fmt.Sprintf("10.0.0.%d", i+1), instance.PrivateIP, instance.Name)
+		assert.Equal(t, []string{fmt.Sprintf("2600:1f18:aaaa::%d", i+1), fmt.Sprintf("2600:1f18:bbbb::%d", i+1)}, instance.IPv6Addresses, instance.Name)
+	}
+
+	require.Len(t, result.NodeAttachments, 6)
+	for _, attachment := range result.NodeAttachments {
+		tgNames := make([]string, 0, len(attachment.TargetGroups))
+		for _, tg := range attachment.TargetGroups {
+			tgNames = append(tgNames, tg.TargetGroup)
+		}
+
+		assert.Contains(t, tgNames, "ingress-80", attachment.Node)
+		assert.Contains(t, tgNames, "ingress-443", attachment.Node)
+		assert.Len(t, attachment.TargetGroups, 6, attachment.Node)
+		assert.Empty(t, attachment.MissingTargetGroups, attachment.Node)
+	}
+}
+
+// TestDescribeClusterTimeout checks a describe gives up rather than hanging on a slow AWS call.
+func TestDescribeClusterTimeout(t *testing.T) {
+	cm := newFakeClusterManager(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := k8sctl.DescribeCluster(ctx, cm, fakeClusterName, k8sctl.DescribeClusterOptions{})
+	require.Error(t, err)
+}
+
+func BenchmarkDescribeCluster(b *testing.B) {
+	b.Run("cluster manager", func(b *testing.B) {
+		cm := newFakeClusterManager(fakeAWSLatency)
+		for b.Loop() {
+			_, err := cm.DescribeCluster(fakeClusterName)
+			require.NoError(b, err)
+		}
+	})
+
+	b.Run("concurrent", func(b *testing.B) {
+		cm := newFakeClusterManager(fakeAWSLatency)
+		for b.Loop() {
+			_, err := k8sctl.DescribeCluster(context.Background(), cm, fakeClusterName, k8sctl.DescribeClusterOptions{})
+			require.NoError(b, err)
+		}
+	})
+}
+
+// newFakeClusterManager returns a cluster manager for a cluster of 6 nodes behind 3 load balancers with 2 target groups each.
+// The last node fails its health checks.
+func newFakeClusterManager(latency time.Duration) (cm *aws.AWSClusterManager) {
+	instances := make([]ec2types.Instance, 0)
+	for i := 1; i <= 6; i++ {
+		instances = append(instances, ec2types.Instance{
+			InstanceId:       awssdk.String(fmt.Sprintf("i-%04d", i)),
+			InstanceType:     ec2types.InstanceTypeM5Large,
+			PrivateIpAddress: awssdk.String(fmt.Sprintf("10.0.0.%d", i)),
+			Tags: []ec2types.Tag{
+				{Key: awssdk.String(aws.EC2TagName), Value: awssdk.String(fmt.Sprintf("%s-worker-%d", fakeClusterName, i))},
+			},
+		})
+	}
+
+	cm = &aws.AWSClusterManager{
+		Name:               fakeClusterName,
+		Context:            context.Background(),
+		Ec2Client:          &fakeEC2Client{latency: latency, instances: instances},
+		ELBClient:          &fakeELBClient{latency: latency, lbs: []string{"apiserver", "ingress", "ingress-tls"}, instances: instances},
+		FetchedNodesById:   make(map[string]manager.NodeInfo),
+		FetchedNodesByName: make(map[string]manager.NodeInfo),
+	}
+
+	return cm
+}
+
+// fakeEC2Client returns canned instances.  Methods describe doesn't use are left to the nil embedded interface.
+type fakeEC2Client struct {
+	aws.Ec2Client
+	latency   time.Duration
+	instances []ec2types.Instance
+}
+
+func (f *fakeEC2Client) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, _ ...func(*ec2.Options)) (output *ec2.DescribeInstancesOutput, err error) {
+	err = fakeDelay(ctx, f.latency)
+	if err != nil {
+		return output, err
+	}
+
+	output = &ec2.DescribeInstancesOutput{}
+	for _, instance := range f.instances {
+		if len(params.InstanceIds) > 0 && !slices.Contains(params.InstanceIds, *instance.InstanceId) {
+			continue
+		}
+		output.Reservations = append(output.Reservations, ec2types.Reservation{Instances: []ec2types.Instance{instance}})
+	}
+
+	return output, err
+}
+
+// fakeELBClient serves load balancers with an http and https target group each, targeting every instance.
+// Target groups named in ipTargetGroups target the instances by private IP, and those in ipv6TargetGroups by the last of
+// their IPv6 addresses, written out in full.  Those in failTargetHealthAfter fail to
+// describe their target health after that many calls.
+type fakeELBClient struct {
+	aws.ELBClient
+	latency               time.Duration
+	lbs                   []string
+	instances             []ec2types.Instance
+	ipTargetGroups        map[string]bool
+	ipv6TargetGroups      map[string]bool
+	failTargetHealthAfter map[string]int
+	mu                    sync.Mutex
+	targetHealthCalls     map[string]int
+}
+
+func (f *fakeELBClient) DescribeLoadBalancers(ctx context.Context, _ *elasticloadbalancingv2.DescribeLoadBalancersInput, _ ...func(*elasticloadbalancingv2.Options)) (output *elasticloadbalancingv2.DescribeLoadBalancersOutput, err error) {
+	err = fakeDelay(ctx, f.latency)
+	if err != nil {
+		return output, err
+	}
+
+	output = &elasticloadbalancingv2.DescribeLoadBalancersOutput{}
+	for _, name := range f.lbs {
+		output.LoadBalancers = append(output.LoadBalancers, elbtypes.LoadBalancer{
+			LoadBalancerName: awssdk.String(name),
+			LoadBalancerArn:  awssdk.String("arn:lb/" + name),
+		})
+	}
+
+	return output, err
+}
+
+func (f *fakeELBClient) DescribeTags(ctx context.Context, params *elasticloadbalancingv2.DescribeTagsInput, _ ...func(*elasticloadbalancingv2.Options)) (output *elasticloadbalancingv2.DescribeTagsOutput, err error) {
+	err = fakeDelay(ctx, f.latency)
+	if err != nil {
+		return output, err
+	}
+
+	output = &elasticloadbalancingv2.DescribeTagsOutput{
+		TagDescriptions: []elbtypes.TagDescription{
+			{
+				ResourceArn: awssdk.String(params.ResourceArns[0]),
+				Tags:        []elbtypes.Tag{{Key: awssdk.String(aws.ELBClusterTag), Value: awssdk.String(fakeClusterName)}},
+			},
+		},
+	}
+
+	return output, err
+}
+
+func (f *fakeELBClient) DescribeTargetGroups(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetGroupsInput, _ ...func(*elasticloadbalancingv2.Options)) (output *elasticloadbalancingv2.DescribeTargetGroupsOutput, err error) {
+	err = fakeDelay(ctx, f.latency)
+	if err != nil {
+		return output, err
+	}
+
+	output = &elasticloadbalancingv2.DescribeTargetGroupsOutput{}
+	for _, lb := range f.lbs {
+		for _, port := range []int32{80, 443} {
+			name := fmt.Sprintf("%s-%d", lb, port)
+			if params.LoadBalancerArn != nil && *params.LoadBalancerArn != "arn:lb/"+lb {
+				continue
+			}
+			if len(params.Names) > 0 && params.Names[0] != name {
+				continue
+			}
+			output.TargetGroups = append(output.TargetGroups, elbtypes.TargetGroup{
+				TargetGroupName: awssdk.String(name),
+				TargetGroupArn:  awssdk.String("arn:tg/" + name),
+				Port:            awssdk.Int32(port),
+			})
+		}
+	}
+
+	return output, err
+}
+
+func (f *fakeELBClient) DescribeTargetHealth(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetHealthInput, _ ...func(*elasticloadbalancingv2.Options)) (output *elasticloadbalancingv2.DescribeTargetHealthOutput, err error) {
+	err = fakeDelay(ctx, f.latency)
+	if err != nil {
+		return output, err
+	}
+
+	tgName := strings.TrimPrefix(*params.TargetGroupArn, "arn:tg/")
+	if after, ok := f.failTargetHealthAfter[tgName]; ok {
+		f.mu.Lock()
+		calls := f.targetHealthCalls[tgName]
+		f.targetHealthCalls[tgName] = calls + 1
+		f.mu.Unlock()
+
+		if calls >= after {
+			err = errors.New("throttled")
+			return output, err
+		}
+	}
+
+	var port int32 = 80
+	if strings.HasSuffix(*params.TargetGroupArn, "-443") {
+		port = 443
+	}
+
+	output = &elasticloadbalancingv2.DescribeTargetHealthOutput{}
+	for i, instance := range f.instances {
+		health := &elbtypes.TargetHealth{State: elbtypes.TargetHealthStateEnumHealthy}
+		if i == len(f.instances)-1 {
+			health = &elbtypes.TargetHealth{
+				State:       elbtypes.TargetHealthStateEnumUnhealthy,
+				Reason:      elbtypes.TargetHealthReasonEnumFailedHealthChecks,
+				Description: awssdk.String("Health checks failed"),
+			}
+		}
+
+		targetID := instance.InstanceId
+		if f.ipTargetGroups[tgName] {
+			targetID = instance.PrivateIpAddress
+		}
+		if f.ipv6TargetGroups[tgName] {
+			last := instance.NetworkInterfaces[0].Ipv6Addresses[len(instance.NetworkInterfaces[0].Ipv6Addresses)-1]
+			targetID = awssdk.String(expandIPv6(*last.Ipv6Address))
+		}
+
+		output.TargetHealthDescriptions = append(output.TargetHealthDescriptions, elbtypes.TargetHealthDescription{
+			Target:       &elbtypes.TargetDescription{Id: targetID, Port: awssdk.Int32(port)},
+			TargetHealth: health,
+		})
+	}
+
+	return output, err
+}
+
+// expandIPv6 writes an IPv6 address out in full, as a differently written form of the same address.
+func expandIPv6(address string) (expanded string) {
+	addr := netip.MustParseAddr(address).As16()
+
+	groups := make([]string, 0, 8)
+	for i := 0; i < len(addr); i += 2 {
+		groups = append(groups, fmt.Sprintf("%02x%02x", addr[i], addr[i+1]))
+	}
+
+	expanded = strings.Join(groups, ":")
+	return expanded
+}
+
+// fakeDelay simulates the latency of an AWS call, returning early if the context is done.
+func fakeDelay(ctx context.Context, latency time.Duration) (err error) {
+	err = ctx.Err()
+	if err != nil || latency == 0 {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-time.After(latency):
+	}
+
+	return err
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewClusterOverview checks a cluster's describe is summarized with its nodes by role, and the nodes no load
+// balancer targets, matched without their domains.
+func TestNewClusterOverview(t *testing.T) {
+	cost := 42.5
+	info := k8sctl.DescribeClusterResult{
+		ClusterInfo: manager.ClusterInfo{
+			Nodes: []manager.NodeInfo{
+				{Name: "cluster1-cp-1.example.com"},
+				{Name: "cluster1-worker-1.example.com"},
+				{Name: "cluster1-worker-2"},
+			},
+			LoadBalancers: []manager.LBInfo{
+				{Name: "cluster1-apiserver", Targets: []manager.LBTargetInfo{{Name: "cluster1-cp-1"}}},
+				{Name: "cluster1-ingress", Targets: []manager.LBTargetInfo{{Name: "cluster1-worker-1.example.com"}}},
+			},
+			EstimatedDailyCost: &cost,
+		},
+		UnhealthyTargets:      []k8sctl.UnhealthyTarget{{LoadBalancer: "cluster1-ingress", Target: "cluster1-worker-1.example.com", State: "unhealthy"}},
+		UncheckedTargetGroups: []string{"cluster1-ingress/http"},
+	}
+
+	overview := k8sctl.NewClusterOverview("cluster1", info)
+
+	assert.Equal(t, k8sctl.ClusterOverview{
+		Cluster:               "cluster1",
+		Nodes:                 3,
+		ControlPlane:          1,
+		Workers:               2,
+		LoadBalancers:         2,
+		UnhealthyTargets:      1,
+		NotInLB:               []string{"cluster1-worker-2"},
+		UncheckedTargetGroups: 1,
+		EstimatedDailyCost:    &cost,
+	}, overview)
+	assert.Equal(t, 2, overview.Issues())
+
+	assert.Equal(t, 0, k8sctl.NewClusterOverview("cluster2", k8sctl.DescribeClusterResult{}).Issues())
+}
@@ -0,0 +1,129 @@
+package test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIdempotent checks a request retried with the same idempotency key is only carried out once, and that reusing a
+// key for a different request, or while the first is running, is refused.  Failures are retried, not replayed, unless
+// the failed request had changed something.
+func TestIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/create", k8sctl.Idempotent(), func(ctx *gin.Context) {
+		calls.Add(1)
+		body, _ := io.ReadAll(ctx.Request.Body)
+		if string(body) == "bad" {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		if string(body) == "slow" {
+			<-release
+		}
+		if string(body) == "throttled" {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if string(body) == "launched" {
+			k8sctl.KeepIdempotentResult(ctx)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"created": string(body)})
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func(key string, body string) (resp *http.Response, respBody string) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL+"/create", strings.NewReader(body))
+		require.NoError(t, err)
+		if key != "" {
+			req.Header.Set(k8sctl.IdempotencyKeyHeader, key)
+		}
+
+		client := &http.Client{Timeout: 5 * time.Second}
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		respBody = string(data)
+		return resp, respBody
+	}
+
+	first, firstBody := post("key-1", "node-1")
+	require.Equal(t, http.StatusOK, first.StatusCode)
+	assert.Empty(t, first.Header.Get(k8sctl.IdempotentReplayHeader))
+
+	retry, retryBody := post("key-1", "node-1")
+	require.Equal(t, http.StatusOK, retry.StatusCode)
+	assert.Equal(t, "true", retry.Header.Get(k8sctl.IdempotentReplayHeader))
+	assert.Equal(t, firstBody, retryBody)
+	assert.Equal(t, int32(1), calls.Load())
+
+	reused, _ := post("key-1", "node-2")
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Without a key, every request is carried out
+	post("", "node-1")
+	post("", "node-1")
+	assert.Equal(t, int32(3), calls.Load())
+
+	// A rejected request did nothing, so it can be retried with its key
+	bad, _ := post("key-2", "bad")
+	assert.Equal(t, http.StatusBadRequest, bad.StatusCode)
+	post("key-2", "bad")
+	assert.Equal(t, int32(5), calls.Load())
+
+	// A retry while the first request is running is turned away
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		post("key-3", "slow")
+	}()
+
+	require.Eventually(t, func() bool { return calls.Load() == 6 }, 5*time.Second, 10*time.Millisecond)
+	inProgress, _ := post("key-3", "slow")
+	assert.Equal(t, http.StatusConflict, inProgress.StatusCode)
+
+	close(release)
+	<-done
+
+	finished, _ := post("key-3", "slow")
+	assert.Equal(t, http.StatusOK, finished.StatusCode)
+	assert.Equal(t, int32(6), calls.Load())
+
+	// A server error before anything was changed isn't replayed, so the retry runs the handler again
+	throttled, _ := post("key-4", "throttled")
+	assert.Equal(t, http.StatusInternalServerError, throttled.StatusCode)
+	retried, _ := post("key-4", "throttled")
+	assert.Equal(t, http.StatusInternalServerError, retried.StatusCode)
+	assert.Empty(t, retried.Header.Get(k8sctl.IdempotentReplayHeader))
+	assert.Equal(t, int32(8), calls.Load())
+
+	// One after the handler changed something is, so it isn't carried out twice
+	post("key-5", "launched")
+	replayed, _ := post("key-5", "launched")
+	assert.Equal(t, http.StatusInternalServerError, replayed.StatusCode)
+	assert.Equal(t, "true", replayed.Header.Get(k8sctl.IdempotentReplayHeader))
+	assert.Equal(t, int32(9), calls.Load())
+}
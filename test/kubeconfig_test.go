@@ -0,0 +1,63 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// talosKubeconfig is shaped like the admin kubeconfig Talos hands out.
+const talosKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: cluster1
+  cluster:
+    server: https://10.0.0.10:6443
+contexts:
+- name: admin@cluster1
+  context:
+    cluster: cluster1
+    user: admin@cluster1
+current-context: admin@cluster1
+users:
+- name: admin@cluster1
+  user:
+    token: secret
+`
+
+// TestMergeKubeconfig checks a kubeconfig is merged under the given context name, alongside the existing contexts,
+// without changing the current context unless there wasn't one.
+func TestMergeKubeconfig(t *testing.T) {
+	existing := clientcmdapi.NewConfig()
+	existing.Clusters["other"] = &clientcmdapi.Cluster{Server: "https://other:6443"}
+	existing.AuthInfos["other"] = &clientcmdapi.AuthInfo{Token: "other"}
+	existing.Contexts["other"] = &clientcmdapi.Context{Cluster: "other", AuthInfo: "other"}
+	existing.CurrentContext = "other"
+
+	err := k8sctl.MergeKubeconfig(existing, []byte(talosKubeconfig), "cluster1-admin")
+	require.NoError(t, err)
+
+	assert.Equal(t, "other", existing.CurrentContext)
+	assert.Len(t, existing.Contexts, 2)
+	require.Contains(t, existing.Contexts, "cluster1-admin")
+	assert.Equal(t, "cluster1-admin", existing.Contexts["cluster1-admin"].Cluster)
+	assert.Equal(t, "cluster1-admin", existing.Contexts["cluster1-admin"].AuthInfo)
+	assert.Equal(t, "https://10.0.0.10:6443", existing.Clusters["cluster1-admin"].Server)
+	assert.Equal(t, "secret", existing.AuthInfos["cluster1-admin"].Token)
+
+	// Merging again replaces the earlier merge
+	err = k8sctl.MergeKubeconfig(existing, []byte(talosKubeconfig), "cluster1-admin")
+	require.NoError(t, err)
+	assert.Len(t, existing.Contexts, 2)
+
+	empty := clientcmdapi.NewConfig()
+	err = k8sctl.MergeKubeconfig(empty, []byte(talosKubeconfig), "cluster1")
+	require.NoError(t, err)
+	assert.Equal(t, "cluster1", empty.CurrentContext)
+
+	err = k8sctl.MergeKubeconfig(empty, []byte("current-context: missing\n"), "broken")
+	require.Error(t, err)
+}
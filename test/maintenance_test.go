@@ -0,0 +1,82 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/nikogura/k8sctl/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMaintenance checks maintenance mode is turned on by an admin, who's recorded, that it refuses the routes changing
+// clusters, and a reconcile fixing tags, while the others keep working, and that a non-admin can't turn it off.
+func TestMaintenance(t *testing.T) {
+	t.Cleanup(func() { k8sctl.SetMaintenance(false, "", "test cleanup") })
+
+	dex := newMockDex(t)
+	token := dex.Token(t, dex.Claims())
+
+	gin.SetMode(gin.TestMode)
+	router, err := (&k8sctl.K8sCtlCommands{}).NewRouter(k8sctl.RouterConfig{
+		Authenticate: oidc.Middleware(dex.Validator(t)),
+		AdminGroups:  []string{mockDexGroup},
+	})
+	require.NoError(t, err)
+
+	request := func(router *gin.Engine, method string, path string, body string) (recorder *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	recorder := request(router, http.MethodPost, "/v1/maintenance", `{"enabled": true, "reason": "AWS account migration"}`)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var status k8sctl.MaintenanceStatus
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+	assert.True(t, status.Enabled)
+	assert.Equal(t, "AWS account migration", status.Reason)
+	assert.Equal(t, "test-user@example.com", status.EnabledBy)
+	assert.NotNil(t, status.Since)
+
+	for _, path := range []string{
+		"/v1/cluster/cluster1/node/cordon/node1",
+		"/v1/cluster/cluster1/node/create",
+		"/v1/cluster/cluster1/upgrade",
+		"/v1/cluster/cluster1/secrets/sync",
+	} {
+		recorder = request(router, http.MethodPost, path, "{}")
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code, path)
+		assert.Contains(t, recorder.Body.String(), "AWS account migration", path)
+	}
+
+	recorder = request(router, http.MethodPost, "/v1/cluster/cluster1/reconcile", `{"fix_tags": true}`)
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	recorder = request(router, http.MethodGet, "/v1/capabilities", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = request(router, http.MethodGet, "/v1/maintenance", "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+	assert.True(t, status.Enabled)
+
+	// Without an admin group, nobody may turn it off
+	recorder = request(newTestRouter(t, dex), http.MethodPost, "/v1/maintenance", `{"enabled": false}`)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.True(t, k8sctl.Maintenance().Enabled)
+
+	recorder = request(router, http.MethodPost, "/v1/maintenance", `{"enabled": false}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.False(t, k8sctl.Maintenance().Enabled)
+}
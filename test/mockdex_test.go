@@ -0,0 +1,227 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MicahParks/jwkset"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nikogura/k8sctl/pkg/oidc"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const mockDexKID = "mock-dex-key"
+
+const mockDexAudience = "https://k8sctl.example.com"
+
+const mockDexGroup = "engineering"
+
+// mockDexClientID and mockDexClientSecret are the client credentials the mock Dex's introspection endpoint accepts.
+const mockDexClientID = "k8sctl"
+
+const mockDexClientSecret = "k8sctl-secret"
+
+// mockDex is a stand-in for Dex: an httptest server publishing a JWKS with a generated RSA key, which mints tokens
+// signed with that key.  It also introspects the opaque tokens it's issued.
+type mockDex struct {
+	server     *httptest.Server
+	key        *rsa.PrivateKey
+	caCertFile string // PEM file of the CA for a mock Dex served over TLS
+
+	mu     sync.Mutex
+	opaque map[string]jwt.MapClaims // the claims of each opaque token, by token
+}
+
+// newMockDex starts a mock Dex, stopped when the test ends.
+func newMockDex(t *testing.T) (dex *mockDex) {
+	t.Helper()
+
+	dex = startMockDex(t, httptest.NewServer)
+
+	return dex
+}
+
+// newMockDexTLS starts a mock Dex served over TLS, with a certificate from its own CA.  The validator it returns
+// trusts that CA through a CA bundle file, as a server would trust an internal CA.
+func newMockDexTLS(t *testing.T) (dex *mockDex) {
+	t.Helper()
+
+	dex = startMockDex(t, httptest.NewTLSServer)
+
+	dex.caCertFile = filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: dex.server.Certificate().Raw})
+	err := os.WriteFile(dex.caCertFile, caPEM, 0600)
+	require.NoError(t, err)
+
+	return dex
+}
+
+// startMockDex generates a key and serves its JWKS with the given httptest server constructor.
+func startMockDex(t *testing.T, newServer func(http.Handler) *httptest.Server) (dex *mockDex) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwk, err := jwkset.NewJWKFromKey(&key.PublicKey, jwkset.JWKOptions{
+		Metadata: jwkset.JWKMetadataOptions{
+			ALG: jwkset.AlgRS256,
+			KID: mockDexKID,
+			USE: jwkset.UseSig,
+		},
+	})
+	require.NoError(t, err)
+
+	storage := jwkset.NewMemoryStorage()
+	err = storage.KeyWrite(context.Background(), jwk)
+	require.NoError(t, err)
+
+	jwksJSON, err := storage.JSONPublic(context.Background())
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/jwks.json", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jwksJSON)
+	})
+
+	dex = &mockDex{
+		key:    key,
+		opaque: make(map[string]jwt.MapClaims),
+	}
+
+	mux.HandleFunc("/token/introspect", dex.introspect)
+
+	dex.server = newServer(mux)
+
+	t.Cleanup(dex.server.Close)
+
+	return dex
+}
+
+// IssuerURL is the mock Dex's issuer URL.
+func (d *mockDex) IssuerURL() (url string) {
+	url = d.server.URL
+	return url
+}
+
+// Validator returns a validator trusting the mock Dex, for mockDexAudience and mockDexGroup.
+func (d *mockDex) Validator(t *testing.T) (validator *oidc.Validator) {
+	t.Helper()
+
+	validator = d.ValidatorWith(t, nil)
+
+	return validator
+}
+
+// ValidatorWith returns a validator trusting the mock Dex, with its config tweaked by configure, if not nil.
+func (d *mockDex) ValidatorWith(t *testing.T, configure func(config *oidc.Config)) (validator *oidc.Validator) {
+	t.Helper()
+
+	config := &oidc.Config{
+		IssuerURL:         d.IssuerURL(),
+		Audience:          mockDexAudience,
+		AllowedGroups:     []string{mockDexGroup},
+		AllowedAlgorithms: []string{oidc.DefaultAllowedAlgorithm},
+		CACertFile:        d.caCertFile,
+	}
+
+	if configure != nil {
+		configure(config)
+	}
+
+	validator, err := oidc.NewValidator(config, zap.NewNop())
+	require.NoError(t, err)
+
+	return validator
+}
+
+// Claims returns the claims of a token the mock Dex's validator accepts, to be tweaked by the test.
+func (d *mockDex) Claims() (claims jwt.MapClaims) {
+	now := time.Now()
+
+	claims = jwt.MapClaims{
+		"iss":    d.IssuerURL(),
+		"aud":    mockDexAudience,
+		"sub":    "test-user",
+		"email":  "test-user@example.com",
+		"groups": []string{mockDexGroup},
+		"iat":    now.Unix(),
+		"exp":    now.Add(time.Hour).Unix(),
+	}
+
+	return claims
+}
+
+// Token mints an RS256 token for the claims, signed with the mock Dex's key.
+func (d *mockDex) Token(t *testing.T, claims jwt.MapClaims) (token string) {
+	t.Helper()
+
+	token = d.SignedToken(t, jwt.SigningMethodRS256, mockDexKID, d.key, claims)
+
+	return token
+}
+
+// OpaqueToken issues an opaque token for the claims, which only introspection can check.
+func (d *mockDex) OpaqueToken(claims jwt.MapClaims) (token string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	token = fmt.Sprintf("opaque-%d", len(d.opaque))
+	d.opaque[token] = claims
+
+	return token
+}
+
+// introspect answers an RFC 7662 introspection request from a client with the mock Dex's client credentials: the
+// token's claims, with active set, if it's one the mock Dex issued.
+func (d *mockDex) introspect(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != mockDexClientID || clientSecret != mockDexClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	d.mu.Lock()
+	claims, issued := d.opaque[r.PostFormValue("token")]
+	d.mu.Unlock()
+
+	response := jwt.MapClaims{"active": false}
+	if issued {
+		response = jwt.MapClaims{"active": true}
+		for name, value := range claims {
+			response[name] = value
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// SignedToken mints a token with the given signing method, kid header, and key, for tokens the validator should reject.
+// An empty kid leaves the kid header out.
+func (d *mockDex) SignedToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) (token string) {
+	t.Helper()
+
+	unsigned := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		unsigned.Header["kid"] = kid
+	}
+
+	token, err := unsigned.SignedString(key)
+	require.NoError(t, err)
+
+	return token
+}
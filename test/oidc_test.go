@@ -0,0 +1,414 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nikogura/k8sctl/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// TestValidateToken checks the validator accepts a good token from Dex, and rejects each way a token can be bad.
+func TestValidateToken(t *testing.T) {
+	dex := newMockDex(t)
+	validator := dex.Validator(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	withClaim := func(name string, value interface{}) (claims jwt.MapClaims) {
+		claims = dex.Claims()
+		claims[name] = value
+		return claims
+	}
+
+	testCases := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{
+			name:  "valid",
+			token: dex.Token(t, dex.Claims()),
+			valid: true,
+		},
+		{
+			name:  "valid with audience list",
+			token: dex.Token(t, withClaim("aud", []string{"other", mockDexAudience})),
+			valid: true,
+		},
+		{
+			name:  "expired",
+			token: dex.Token(t, withClaim("exp", time.Now().Add(-time.Minute).Unix())),
+		},
+		{
+			name:  "wrong audience",
+			token: dex.Token(t, withClaim("aud", "https://other.example.com")),
+		},
+		{
+			name:  "wrong issuer",
+			token: dex.Token(t, withClaim("iss", "https://dex.other.example.com")),
+		},
+		{
+			name:  "missing group",
+			token: dex.Token(t, withClaim("groups", []string{"sales"})),
+		},
+		{
+			name:  "no groups claim",
+			token: dex.Token(t, withClaim("groups", nil)),
+		},
+		{
+			name:  "unknown kid",
+			token: dex.SignedToken(t, jwt.SigningMethodRS256, "unknown-key", otherKey, dex.Claims()),
+		},
+		{
+			name:  "missing kid",
+			token: dex.SignedToken(t, jwt.SigningMethodRS256, "", dex.key, dex.Claims()),
+		},
+		{
+			name:  "signed by another key",
+			token: dex.SignedToken(t, jwt.SigningMethodRS256, mockDexKID, otherKey, dex.Claims()),
+		},
+		{
+			name:  "algorithm not allowed",
+			token: dex.SignedToken(t, jwt.SigningMethodRS512, mockDexKID, dex.key, dex.Claims()),
+		},
+		{
+			name:  "HMAC signed with the public key",
+			token: dex.SignedToken(t, jwt.SigningMethodHS256, mockDexKID, publicKeyBytes(t, dex), dex.Claims()),
+		},
+		{
+			name:  "garbage",
+			token: "not-a-token",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := validator.ValidateToken(tc.token)
+			if tc.valid {
+				require.NoError(t, err)
+				assert.Equal(t, "test-user", claims["sub"])
+				return
+			}
+
+			require.Error(t, err)
+			assert.Nil(t, claims)
+		})
+	}
+}
+
+// TestMiddleware checks the middleware lets requests with a good token through to the handler, with the token's
+// claims, and turns everything else away with a 401.
+func TestMiddleware(t *testing.T) {
+	dex := newMockDex(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(oidc.Middleware(dex.Validator(t)))
+	router.POST("/v1/auth-check", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{
+			"email":   ctx.GetString("user_email"),
+			"user_id": ctx.GetString("user_id"),
+		})
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	expired := dex.Claims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+
+	testCases := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{name: "valid token", authorization: "Bearer " + dex.Token(t, dex.Claims()), status: http.StatusOK},
+		{name: "lowercase scheme", authorization: "bearer " + dex.Token(t, dex.Claims()), status: http.StatusOK},
+		{name: "expired token", authorization: "Bearer " + dex.Token(t, expired), status: http.StatusUnauthorized},
+		{name: "no authorization header", status: http.StatusUnauthorized},
+		{name: "not a bearer token", authorization: "Basic dXNlcjpwYXNz", status: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL+"/v1/auth-check", nil)
+			require.NoError(t, err)
+
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			client := &http.Client{Timeout: 5 * time.Second}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, tc.status, resp.StatusCode)
+
+			if tc.status == http.StatusOK {
+				var result map[string]string
+				err = json.NewDecoder(resp.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Equal(t, "test-user@example.com", result["email"])
+				assert.Equal(t, "test-user", result["user_id"])
+			}
+		})
+	}
+}
+
+// TestRequireGroups checks a credential route only lets through callers in one of its groups, and that a route with no
+// groups lets nobody through.
+func TestRequireGroups(t *testing.T) {
+	dex := newMockDex(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(oidc.Middleware(dex.Validator(t)))
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+	router.POST("/admins", oidc.RequireGroups([]string{"admins", mockDexGroup}), ok)
+	router.POST("/others", oidc.RequireGroups([]string{"admins"}), ok)
+	router.POST("/nobody", oidc.RequireGroups(nil), ok)
+	router.POST("/prefix", oidc.RequireGroups([]string{"admins", "engineer*"}), ok)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	testCases := []struct {
+		path   string
+		status int
+	}{
+		{path: "/admins", status: http.StatusOK},
+		{path: "/others", status: http.StatusForbidden},
+		{path: "/nobody", status: http.StatusForbidden},
+		{path: "/prefix", status: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL+tc.path, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+dex.Token(t, dex.Claims()))
+
+			client := &http.Client{Timeout: 5 * time.Second}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.status, resp.StatusCode)
+		})
+	}
+}
+
+// TestValidateTokenGroupPatterns checks allowed groups match exactly, unless they end in *, when they match by prefix.
+func TestValidateTokenGroupPatterns(t *testing.T) {
+	dex := newMockDex(t)
+	validator := dex.ValidatorWith(t, func(config *oidc.Config) {
+		config.AllowedGroups = []string{"admins", "engineering/platform/*"}
+	})
+
+	testCases := []struct {
+		name   string
+		groups []string
+		valid  bool
+	}{
+		{name: "exact", groups: []string{"admins"}, valid: true},
+		{name: "prefix", groups: []string{"sales", "engineering/platform/sre"}, valid: true},
+		{name: "nested prefix", groups: []string{"engineering/platform/sre/oncall"}, valid: true},
+		{name: "exact isn't a prefix", groups: []string{"admins-readonly"}},
+		{name: "prefix parent", groups: []string{"engineering/platform"}},
+		{name: "prefix sibling", groups: []string{"engineering/data/etl"}},
+		{name: "literal *", groups: []string{"engineering/*"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := dex.Claims()
+			claims["groups"] = tc.groups
+
+			_, err := validator.ValidateToken(dex.Token(t, claims))
+			if tc.valid {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestMiddlewareEmailDomains checks a caller whose email isn't in an allowed domain is refused with a 403 naming it,
+// even if they're in an allowed group, and that one in a group and a domain is let through.
+func TestMiddlewareEmailDomains(t *testing.T) {
+	dex := newMockDex(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(oidc.Middleware(dex.ValidatorWith(t, func(config *oidc.Config) {
+		config.AllowedEmailDomains = []string{"other.example.com", "example.com"}
+	})))
+	router.POST("/v1/auth-check", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	withEmail := func(email interface{}) (claims jwt.MapClaims) {
+		claims = dex.Claims()
+		claims["email"] = email
+		return claims
+	}
+
+	notInGroup := dex.Claims()
+	notInGroup["groups"] = []string{"sales"}
+
+	testCases := []struct {
+		name   string
+		claims jwt.MapClaims
+		status int
+		error  string
+	}{
+		{name: "allowed domain", claims: dex.Claims(), status: http.StatusOK},
+		{name: "allowed domain other case", claims: withEmail("test-user@Example.COM"), status: http.StatusOK},
+		{name: "other domain", claims: withEmail("test-user@example.org"), status: http.StatusForbidden, error: "example.org"},
+		{name: "subdomain", claims: withEmail("test-user@evil.example.com"), status: http.StatusForbidden, error: "evil.example.com"},
+		{name: "no email", claims: withEmail(nil), status: http.StatusForbidden, error: "no email domain"},
+		{name: "allowed domain not in group", claims: notInGroup, status: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL+"/v1/auth-check", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+dex.Token(t, tc.claims))
+
+			client := &http.Client{Timeout: 5 * time.Second}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.status, resp.StatusCode)
+
+			if tc.error != "" {
+				var result map[string]string
+				err = json.NewDecoder(resp.Body).Decode(&result)
+				require.NoError(t, err)
+				assert.Contains(t, result["error"], tc.error)
+			}
+		})
+	}
+}
+
+// TestValidateTokenGroupsClaim checks the caller's groups are read from the configured claim: a namespaced claim by
+// its whole name, and a nested one by its dotted path.  The groups claim is ignored.
+func TestValidateTokenGroupsClaim(t *testing.T) {
+	dex := newMockDex(t)
+
+	testCases := []struct {
+		name   string
+		claim  string
+		claims jwt.MapClaims
+		valid  bool
+	}{
+		{name: "roles", claim: "roles", claims: jwt.MapClaims{"roles": []string{mockDexGroup}}, valid: true},
+		{name: "namespaced", claim: "https://myorg.example.com/groups", claims: jwt.MapClaims{"https://myorg.example.com/groups": []string{mockDexGroup}}, valid: true},
+		{name: "nested", claim: "realm_access.roles", claims: jwt.MapClaims{"realm_access": map[string]interface{}{"roles": []string{mockDexGroup}}}, valid: true},
+		{name: "not in group", claim: "roles", claims: jwt.MapClaims{"roles": []string{"sales"}}},
+		{name: "nested not an object", claim: "realm_access.roles", claims: jwt.MapClaims{"realm_access": "roles"}},
+		{name: "missing", claim: "roles"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validator := dex.ValidatorWith(t, func(config *oidc.Config) {
+				config.GroupsClaim = tc.claim
+			})
+
+			claims := dex.Claims()
+			for name, value := range tc.claims {
+				claims[name] = value
+			}
+
+			_, err := validator.ValidateToken(dex.Token(t, claims))
+			if tc.valid {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestValidateTokenIntrospection checks tokens are introspected as configured: opaque tokens only when falling back,
+// and every token, even a good JWT, always.  Introspected claims are checked as a JWT's are.
+func TestValidateTokenIntrospection(t *testing.T) {
+	dex := newMockDex(t)
+
+	withIntrospection := func(mode string, clientSecret string) (validator *oidc.Validator) {
+		validator = dex.ValidatorWith(t, func(config *oidc.Config) {
+			config.Introspection = mode
+			config.IntrospectionClientID = mockDexClientID
+			config.IntrospectionClientSecret = clientSecret
+		})
+		return validator
+	}
+
+	notInGroup := dex.Claims()
+	notInGroup["groups"] = []string{"sales"}
+
+	expired := dex.Claims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+
+	testCases := []struct {
+		name      string
+		validator *oidc.Validator
+		token     string
+		valid     bool
+	}{
+		{name: "off, opaque", validator: withIntrospection(oidc.IntrospectionOff, mockDexClientSecret), token: dex.OpaqueToken(dex.Claims())},
+		{name: "fallback, opaque", validator: withIntrospection(oidc.IntrospectionFallback, mockDexClientSecret), token: dex.OpaqueToken(dex.Claims()), valid: true},
+		{name: "fallback, JWT", validator: withIntrospection(oidc.IntrospectionFallback, mockDexClientSecret), token: dex.Token(t, dex.Claims()), valid: true},
+		{name: "fallback, unknown", validator: withIntrospection(oidc.IntrospectionFallback, mockDexClientSecret), token: "opaque-unknown"},
+		{name: "fallback, not in group", validator: withIntrospection(oidc.IntrospectionFallback, mockDexClientSecret), token: dex.OpaqueToken(notInGroup)},
+		{name: "fallback, expired", validator: withIntrospection(oidc.IntrospectionFallback, mockDexClientSecret), token: dex.OpaqueToken(expired)},
+		{name: "fallback, wrong client secret", validator: withIntrospection(oidc.IntrospectionFallback, "wrong"), token: dex.OpaqueToken(dex.Claims())},
+		{name: "always, opaque", validator: withIntrospection(oidc.IntrospectionAlways, mockDexClientSecret), token: dex.OpaqueToken(dex.Claims()), valid: true},
+		{name: "always, JWT", validator: withIntrospection(oidc.IntrospectionAlways, mockDexClientSecret), token: dex.Token(t, dex.Claims())},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := tc.validator.ValidateToken(tc.token)
+			if tc.valid {
+				require.NoError(t, err)
+				assert.Equal(t, "test-user", claims["sub"])
+				return
+			}
+
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := oidc.NewValidator(&oidc.Config{IssuerURL: dex.IssuerURL(), Introspection: "sometimes"}, zap.NewNop())
+	assert.Error(t, err, "unknown introspection mode")
+}
+
+// publicKeyBytes returns the mock Dex's public key modulus, as an attacker might use the public key as an HMAC secret.
+func publicKeyBytes(t *testing.T, dex *mockDex) (key []byte) {
+	t.Helper()
+
+	key = dex.key.PublicKey.N.Bytes()
+
+	return key
+}
@@ -0,0 +1,129 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/nikogura/k8sctl/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRouter returns the server's router, authenticating against the mock Dex, with /status as its only
+// unauthenticated route.
+func newTestRouter(t *testing.T, dex *mockDex) (router *gin.Engine) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	router, err := (&k8sctl.K8sCtlCommands{}).NewRouter(k8sctl.RouterConfig{
+		Authenticate: oidc.Middleware(dex.Validator(t)),
+		Unauthenticated: []k8sctl.UnauthenticatedRoute{
+			{Method: http.MethodGet, Path: "/status", Handler: func(ctx *gin.Context) { ctx.Status(http.StatusOK) }},
+		},
+	})
+	require.NoError(t, err)
+
+	return router
+}
+
+// TestRouterRequiresAuth checks every /v1 route turns away a request without a token, as do paths matching no route,
+// while the unauthenticated routes are served to anyone.
+func TestRouterRequiresAuth(t *testing.T) {
+	router := newTestRouter(t, newMockDex(t))
+
+	routes := (&k8sctl.K8sCtlCommands{}).APIRoutes()
+	require.NotEmpty(t, routes)
+
+	for _, route := range routes {
+		// Fill in the path params
+		segments := strings.Split(k8sctl.APIPathPrefix+route.Path, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, ":") {
+				segments[i] = strings.TrimPrefix(segment, ":")
+			}
+		}
+		path := strings.Join(segments, "/")
+
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(route.Method, path, strings.NewReader("{}")))
+
+			assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		})
+	}
+
+	for _, path := range []string{"/v1/no-such-route", "/metrics"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code, path)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/status", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "only the unauthenticated route's method is unauthenticated")
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+// TestRouterAuthenticated checks a request with a token is let through to its route.
+func TestRouterAuthenticated(t *testing.T) {
+	dex := newMockDex(t)
+	router := newTestRouter(t, dex)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/capabilities", nil)
+	req.Header.Set("Authorization", "Bearer "+dex.Token(t, dex.Claims()))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	// Without a credential group, the credential routes refuse everyone
+	req = httptest.NewRequest(http.MethodPost, "/v1/cluster/cluster1/kubeconfig", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer "+dex.Token(t, dex.Claims()))
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+// TestAuthCheckIdentity checks auth-check returns who the server authenticated the caller as.
+func TestAuthCheckIdentity(t *testing.T) {
+	dex := newMockDex(t)
+	router := newTestRouter(t, dex)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/auth-check", nil)
+	req.Header.Set("Authorization", "Bearer "+dex.Token(t, dex.Claims()))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var result k8sctl.AuthCheckResult
+	err := json.Unmarshal(recorder.Body.Bytes(), &result)
+	require.NoError(t, err)
+
+	assert.Equal(t, "authenticated", result.Status)
+	assert.Equal(t, "test-user@example.com", result.UserEmail)
+	assert.Equal(t, []string{mockDexGroup}, result.Groups)
+}
+
+// TestRouterRefusesUnauthenticatedAPIRoutes checks an unauthenticated route can't be added under /v1.
+func TestRouterRefusesUnauthenticatedAPIRoutes(t *testing.T) {
+	dex := newMockDex(t)
+
+	for _, path := range []string{"/v1", "/v1/cluster/:cluster/node/delete/:name"} {
+		_, err := (&k8sctl.K8sCtlCommands{}).NewRouter(k8sctl.RouterConfig{
+			Authenticate:    oidc.Middleware(dex.Validator(t)),
+			Unauthenticated: []k8sctl.UnauthenticatedRoute{{Method: http.MethodPost, Path: path, Handler: func(ctx *gin.Context) {}}},
+		})
+		assert.Error(t, err, path)
+	}
+}
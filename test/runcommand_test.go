@@ -0,0 +1,166 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/nikogura/k8sctl/pkg/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCommandsJSON = `{
+  "commands": [
+    {"name": "hello", "command": "echo", "args": ["hello"], "description": "Say hello"},
+    {"name": "cluster", "command": "sh", "args": ["-c", "echo $K8SCTL_CLUSTER"]},
+    {"name": "fail", "command": "sh", "args": ["-c", "echo failing; exit 3"]},
+    {"name": "missing", "command": "/nonexistent/k8sctl-test-command"}
+  ]
+}`
+
+func TestLoadK8sCtlCommands(t *testing.T) {
+	commands, err := k8sctl.LoadK8sCtlCommandsFromBytes([]byte(testCommandsJSON))
+	require.NoError(t, err)
+
+	assert.Len(t, commands.Commands, 4)
+	require.Contains(t, commands.CommandsMap, "hello")
+	assert.Equal(t, "echo", commands.CommandsMap["hello"].Command)
+
+	_, err = k8sctl.LoadK8sCtlCommandsFromBytes([]byte(`{"commands": [{"name": "a", "command": "echo"}, {"name": "a", "command": "ls"}]}`))
+	assert.Error(t, err, "duplicate names")
+
+	_, err = k8sctl.LoadK8sCtlCommandsFromBytes([]byte(`{"commands": [{"name": "a"}]}`))
+	assert.Error(t, err, "no executable")
+
+	_, err = k8sctl.LoadK8sCtlCommandsFromBytes([]byte(`{"commands": [{"command": "echo"}]}`))
+	assert.Error(t, err, "no name")
+}
+
+// TestRunCommandHandler runs registered commands through the handler: args are appended to the command's own, the
+// cluster is passed in the environment, and a failing command's exit code is returned rather than an error.
+func TestRunCommandHandler(t *testing.T) {
+	commands, err := k8sctl.LoadK8sCtlCommandsFromBytes([]byte(testCommandsJSON))
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/v1/cluster/:cluster/run/:command", commands.RunCommandHandler)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	run := func(command string, args ...string) (status int, result k8sctl.K8sCtlCommandResult) {
+		body, err := json.Marshal(k8sctl.RunCommandBody{Args: args})
+		require.NoError(t, err)
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL+"/v1/cluster/cluster1/run/"+command, strings.NewReader(string(body)))
+		require.NoError(t, err)
+
+		client := &http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		status = resp.StatusCode
+		if status == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(&result)
+			require.NoError(t, err)
+		}
+
+		return status, result
+	}
+
+	status, result := run("hello", "world")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "hello world\n", result.Output)
+	assert.Equal(t, []string{"hello", "world"}, result.Args)
+	assert.Equal(t, 0, result.ExitCode)
+
+	status, result = run("cluster")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "cluster1\n", result.Output)
+
+	status, result = run("fail")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "failing\n", result.Output)
+	assert.Equal(t, 3, result.ExitCode)
+
+	status, _ = run("missing")
+	assert.Equal(t, http.StatusInternalServerError, status)
+
+	status, _ = run("unregistered")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+// TestRunCommandRole checks a command with a role only runs for members of its group: others get a 403, and the
+// command isn't run at all.  A command without a role runs for anyone the server lets in.
+func TestRunCommandRole(t *testing.T) {
+	dex := newMockDex(t)
+	dir := t.TempDir()
+
+	commands := &k8sctl.K8sCtlCommands{
+		CommandsMap: map[string]*k8sctl.K8sCtlCommand{
+			"member": {Name: "member", Command: "touch", Args: []string{filepath.Join(dir, "member")}, Role: mockDexGroup},
+			"admins": {Name: "admins", Command: "touch", Args: []string{filepath.Join(dir, "admins")}, Role: "admins"},
+			"anyone": {Name: "anyone", Command: "touch", Args: []string{filepath.Join(dir, "anyone")}},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/v1/cluster/:cluster/run/:command", oidc.Middleware(dex.Validator(t)), commands.RunCommandHandler)
+	router.POST("/noauth/cluster/:cluster/run/:command", commands.RunCommandHandler)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	testCases := []struct {
+		name    string
+		path    string
+		status  int
+		touched string
+	}{
+		{name: "in the command's group", path: "/v1/cluster/cluster1/run/member", status: http.StatusOK, touched: "member"},
+		{name: "not in the command's group", path: "/v1/cluster/cluster1/run/admins", status: http.StatusForbidden},
+		{name: "command without a role", path: "/v1/cluster/cluster1/run/anyone", status: http.StatusOK, touched: "anyone"},
+		{name: "no token claims", path: "/noauth/cluster/cluster1/run/admins", status: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL+tc.path, strings.NewReader("{}"))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+dex.Token(t, dex.Claims()))
+
+			client := &http.Client{Timeout: 5 * time.Second}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.status, resp.StatusCode)
+
+			if tc.touched != "" {
+				assert.FileExists(t, filepath.Join(dir, tc.touched))
+			}
+		})
+	}
+
+	assert.NoFileExists(t, filepath.Join(dir, "admins"), "a command the caller may not run must not run")
+}
+
+// TestRunCommandRouteForbidden checks the OpenAPI spec documents the 403 a caller outside a command's group gets.
+func TestRunCommandRouteForbidden(t *testing.T) {
+	spec := k8sctl.GenerateOpenAPISpec((&k8sctl.K8sCtlCommands{}).APIRoutes())
+
+	op, ok := spec.Paths["/v1/cluster/{cluster}/run/{command}"]["post"]
+	require.True(t, ok)
+	assert.Contains(t, op.Responses, "403")
+}
@@ -0,0 +1,56 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nikogura/k8s-cluster-manager/pkg/manager"
+	"github.com/nikogura/k8sctl/pkg/k8sctl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecretManager is a secret manager that's never reached: the requests tested are refused before any secret is read.
+type fakeSecretManager struct {
+	manager.SecretManager
+}
+
+// TestSecretsStatusRefused checks a secrets status is refused without Vault, and, for the whole fleet, without any
+// configured clusters.
+func TestSecretsStatusRefused(t *testing.T) {
+	commands := &k8sctl.K8sCtlCommands{}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/v1/secrets/status", commands.SecretsStatusHandler)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func() (status int) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL+"/v1/secrets/status", strings.NewReader("{}"))
+		require.NoError(t, err)
+
+		client := &http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		status = resp.StatusCode
+		return status
+	}
+
+	k8sctl.SetSecretManager(nil)
+	assert.Equal(t, http.StatusInternalServerError, post(), "no Vault")
+
+	k8sctl.SetSecretManager(&fakeSecretManager{})
+	defer k8sctl.SetSecretManager(nil)
+
+	k8sctl.SetClusterConfig(nil)
+	assert.Equal(t, http.StatusBadRequest, post(), "no clusters to check")
+}
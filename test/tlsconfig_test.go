@@ -0,0 +1,91 @@
+package test
+
+import (
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nikogura/k8sctl/pkg/oidc"
+	"github.com/nikogura/k8sctl/pkg/tlsconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// TestLoadCertPool checks a CA bundle loads, and that a missing, empty, or garbage bundle is an error.
+func TestLoadCertPool(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+
+	dir := t.TempDir()
+	writeFile := func(name string, content []byte) (path string) {
+		path = filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, content, 0600))
+		return path
+	}
+
+	testCases := []struct {
+		name  string
+		path  string
+		valid bool
+	}{
+		{
+			name:  "valid bundle",
+			path:  writeFile("ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+			valid: true,
+		},
+		{name: "missing file", path: filepath.Join(dir, "missing.pem")},
+		{name: "empty file", path: writeFile("empty.pem", nil)},
+		{name: "garbage", path: writeFile("garbage.pem", []byte("not a certificate"))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool, err := tlsconfig.LoadCertPool(tc.path)
+			if tc.valid {
+				require.NoError(t, err)
+				assert.NotNil(t, pool)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Nil(t, pool)
+		})
+	}
+}
+
+// TestValidatorCustomCA checks the validator fetches the JWKS from an issuer with an internal CA's certificate when
+// given the CA bundle, and can't without it.
+func TestValidatorCustomCA(t *testing.T) {
+	dex := newMockDexTLS(t)
+
+	t.Run("with CA bundle", func(t *testing.T) {
+		validator := dex.Validator(t)
+
+		_, err := validator.ValidateToken(dex.Token(t, dex.Claims()))
+		require.NoError(t, err)
+	})
+
+	t.Run("without CA bundle", func(t *testing.T) {
+		validator, err := oidc.NewValidator(&oidc.Config{
+			IssuerURL:     dex.IssuerURL(),
+			Audience:      mockDexAudience,
+			AllowedGroups: []string{mockDexGroup},
+		}, zap.NewNop())
+		require.NoError(t, err)
+
+		_, err = validator.ValidateToken(dex.Token(t, dex.Claims()))
+		require.Error(t, err)
+	})
+
+	t.Run("bad CA bundle", func(t *testing.T) {
+		_, err := oidc.NewValidator(&oidc.Config{
+			IssuerURL:  dex.IssuerURL(),
+			Audience:   mockDexAudience,
+			CACertFile: filepath.Join(t.TempDir(), "missing.pem"),
+		}, zap.NewNop())
+		require.Error(t, err)
+	})
+}
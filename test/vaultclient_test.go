@@ -0,0 +1,160 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nikogura/k8sctl/pkg/vaultclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// mockVault answers the Vault login and token lookup calls, recording the logins and the namespace they're made in.
+type mockVault struct {
+	server *httptest.Server
+
+	mu         sync.Mutex
+	logins     map[string]map[string]interface{} // login path to request body
+	namespaces []string
+}
+
+func newMockVault(t *testing.T) (vault *mockVault) {
+	t.Helper()
+
+	vault = &mockVault{logins: make(map[string]map[string]interface{})}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/auth/", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		vault.mu.Lock()
+		vault.logins[r.URL.Path] = body
+		vault.namespaces = append(vault.namespaces, r.Header.Get("X-Vault-Namespace"))
+		vault.mu.Unlock()
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"auth": map[string]interface{}{"client_token": "logged-in-token", "lease_duration": 0},
+		})
+	})
+	mux.HandleFunc("/v1/auth/token/lookup-self", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") == "bad-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"id": r.Header.Get("X-Vault-Token")}})
+	})
+
+	vault.server = httptest.NewServer(mux)
+	t.Cleanup(vault.server.Close)
+
+	return vault
+}
+
+func TestVaultConfigValidate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config vaultclient.Config
+		valid  bool
+	}{
+		{name: "token", config: vaultclient.Config{Address: "https://vault", AuthMethod: vaultclient.AuthMethodToken, Token: "t"}, valid: true},
+		{name: "token without a token", config: vaultclient.Config{Address: "https://vault", AuthMethod: vaultclient.AuthMethodToken}},
+		{name: "approle", config: vaultclient.Config{Address: "https://vault", AuthMethod: vaultclient.AuthMethodAppRole, RoleID: "r", SecretID: "s"}, valid: true},
+		{name: "approle without a secret ID", config: vaultclient.Config{Address: "https://vault", AuthMethod: vaultclient.AuthMethodAppRole, RoleID: "r"}},
+		{name: "kubernetes", config: vaultclient.Config{Address: "https://vault", AuthMethod: vaultclient.AuthMethodKubernetes, KubernetesRole: "k8sctl"}, valid: true},
+		{name: "kubernetes without a role", config: vaultclient.Config{Address: "https://vault", AuthMethod: vaultclient.AuthMethodKubernetes}},
+		{name: "unknown method", config: vaultclient.Config{Address: "https://vault", AuthMethod: "ldap"}},
+		{name: "no address", config: vaultclient.Config{AuthMethod: vaultclient.AuthMethodToken, Token: "t"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVaultConfigFromEnv(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "https://vault.example.com")
+	t.Setenv("VAULT_NAMESPACE", "admin/platform")
+	t.Setenv("K8SCTL_VAULT_AUTH_METHOD", "")
+	t.Setenv("K8SCTL_VAULT_MOUNT", "")
+	t.Setenv("K8SCTL_VAULT_K8S_TOKEN_PATH", "")
+
+	config := vaultclient.LoadConfigFromEnv()
+	assert.Equal(t, "https://vault.example.com", config.Address)
+	assert.Equal(t, "admin/platform", config.Namespace)
+	assert.Equal(t, vaultclient.AuthMethodToken, config.AuthMethod)
+	assert.Equal(t, vaultclient.DefaultKVMount, config.KVMount)
+	assert.Equal(t, vaultclient.DefaultKubernetesTokenPath, config.KubernetesTokenPath)
+}
+
+func TestVaultNewClient(t *testing.T) {
+	vault := newMockVault(t)
+	logger := zap.NewNop()
+
+	t.Run("token", func(t *testing.T) {
+		client, err := vaultclient.NewClient(context.Background(), &vaultclient.Config{Address: vault.server.URL, AuthMethod: vaultclient.AuthMethodToken, Token: "static-token"}, logger)
+		require.NoError(t, err)
+		assert.Equal(t, "static-token", client.Token())
+	})
+
+	t.Run("token that doesn't work", func(t *testing.T) {
+		_, err := vaultclient.NewClient(context.Background(), &vaultclient.Config{Address: vault.server.URL, AuthMethod: vaultclient.AuthMethodToken, Token: "bad-token"}, logger)
+		assert.Error(t, err)
+	})
+
+	t.Run("approle in a namespace, at its own mount", func(t *testing.T) {
+		config := &vaultclient.Config{
+			Address:    vault.server.URL,
+			Namespace:  "admin/platform",
+			AuthMethod: vaultclient.AuthMethodAppRole,
+			AuthMount:  "approle-k8sctl",
+			RoleID:     "role",
+			SecretID:   "secret",
+		}
+
+		client, err := vaultclient.NewClient(context.Background(), config, logger)
+		require.NoError(t, err)
+		assert.Equal(t, "logged-in-token", client.Token())
+
+		vault.mu.Lock()
+		defer vault.mu.Unlock()
+		assert.Equal(t, map[string]interface{}{"role_id": "role", "secret_id": "secret"}, vault.logins["/v1/auth/approle-k8sctl/login"])
+		assert.Contains(t, vault.namespaces, "admin/platform")
+	})
+
+	t.Run("kubernetes", func(t *testing.T) {
+		tokenPath := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenPath, []byte("service-account-jwt\n"), 0600))
+
+		config := &vaultclient.Config{
+			Address:             vault.server.URL,
+			AuthMethod:          vaultclient.AuthMethodKubernetes,
+			KubernetesRole:      "k8sctl",
+			KubernetesTokenPath: tokenPath,
+		}
+
+		client, err := vaultclient.NewClient(context.Background(), config, logger)
+		require.NoError(t, err)
+		assert.Equal(t, "logged-in-token", client.Token())
+
+		vault.mu.Lock()
+		defer vault.mu.Unlock()
+		assert.Equal(t, map[string]interface{}{"role": "k8sctl", "jwt": "service-account-jwt"}, vault.logins["/v1/auth/kubernetes/login"])
+	})
+}